@@ -0,0 +1,131 @@
+package ospry
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// An ArchiveItem is an image to add to an archive (see
+// DownloadArchive).
+type ArchiveItem struct {
+	URL string
+	// Opts, if set, add a render of the image rather than the
+	// original. Private images need a TimeExpired.
+	Opts *RenderOpts
+	// Filename is the image's name in the archive. If empty, the
+	// url's base name is used. Names used twice get a "-2" suffix,
+	// and so on.
+	Filename string
+}
+
+// DownloadArchive calls DownloadArchive on the default client.
+func DownloadArchive(w io.Writer, items []ArchiveItem) ([]BatchResult[int64], error) {
+	return DefaultClient.DownloadArchive(w, items)
+}
+
+// DownloadArchive writes a zip archive of the items to w, e.g. for
+// "download all" buttons. Up to BatchConcurrency items are downloaded
+// at once, into memory, while the archive is written in order. Items
+// that fail to download are left out. The results' values are the
+// number of bytes added per item; if some items failed, the error is
+// a *BatchError. If writing to w fails, DownloadArchive stops and
+// returns that error.
+func (c *Client) DownloadArchive(w io.Writer, items []ArchiveItem) ([]BatchResult[int64], error) {
+	type fetched struct {
+		data []byte
+		err  error
+		dur  time.Duration
+	}
+	// An item keeps its slot until it has been written, so that at
+	// most BatchConcurrency items are held in memory.
+	done := make([]chan fetched, len(items))
+	written := make([]chan struct{}, len(items))
+	for i := range done {
+		done[i] = make(chan fetched, 1)
+		written[i] = make(chan struct{})
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	go fanOut(c.batchConcurrency(), len(items),
+		func(i int) string { return items[i].URL },
+		func(i int) (struct{}, error) {
+			select {
+			case <-stop:
+				return struct{}{}, nil
+			default:
+			}
+			start := time.Now()
+			var buf bytes.Buffer
+			rc, err := c.Download(items[i].URL, items[i].Opts)
+			if err == nil {
+				_, err = io.Copy(&buf, rc)
+				rc.Close()
+			}
+			done[i] <- fetched{buf.Bytes(), err, time.Since(start)}
+			select {
+			case <-written[i]:
+			case <-stop:
+			}
+			return struct{}{}, nil
+		})
+
+	zw := zip.NewWriter(w)
+	names := map[string]int{}
+	results := make([]BatchResult[int64], len(items))
+	for i, item := range items {
+		f := <-done[i]
+		results[i] = BatchResult[int64]{Item: item.URL, Err: f.err, Attempts: 1, Duration: f.dur}
+		if f.err == nil {
+			hdr := &zip.FileHeader{Name: archiveName(item, names), Method: zip.Store, Modified: c.now()}
+			fw, err := zw.CreateHeader(hdr)
+			if err == nil {
+				_, err = fw.Write(f.data)
+			}
+			if err != nil {
+				return results[:i+1], err
+			}
+			results[i].Value = int64(len(f.data))
+		}
+		close(written[i])
+	}
+	if err := zw.Close(); err != nil {
+		return results, err
+	}
+	return results, batchErr(results)
+}
+
+// archiveName returns the name of an item in an archive, unique among
+// the names used so far.
+func archiveName(item ArchiveItem, used map[string]int) string {
+	name := item.Filename
+	if name == "" {
+		if u, err := url.Parse(item.URL); err == nil {
+			name = path.Base(u.Path)
+		}
+	}
+	name = strings.TrimLeft(path.Clean("/"+strings.ReplaceAll(name, "\\", "/")), "/")
+	if name == "" || name == "." {
+		name = "image"
+	}
+	if used[name] == 0 {
+		used[name] = 1
+		return name
+	}
+	// Number duplicates, skipping numbered names already taken, e.g.
+	// by an item named "a-2.jpg".
+	ext := path.Ext(name)
+	for n := used[name] + 1; ; n++ {
+		candidate := strings.TrimSuffix(name, ext) + "-" + strconv.Itoa(n) + ext
+		if used[candidate] == 0 {
+			used[name] = n
+			used[candidate] = 1
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package ospry
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDownloadArchive(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing.jpg" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(r.URL.Path + "?" + r.URL.RawQuery))
+	})
+	c.BatchConcurrency = 2
+	base := strings.TrimSuffix(c.ServerURL, "/v1") + "/"
+	items := []ArchiveItem{
+		{URL: base + "a.jpg"},
+		{URL: base + "b.jpg", Opts: &RenderOpts{MaxWidth: 100}, Filename: "a.jpg"},
+		{URL: base + "missing.jpg"},
+		{URL: base + "c.jpg", Filename: "../../etc/c.jpg"},
+	}
+	var buf bytes.Buffer
+	results, err := c.DownloadArchive(&buf, items)
+	var berr *BatchError
+	if !errors.As(err, &berr) || len(berr.Errs) != 1 || results[2].Err == nil {
+		t.Fatalf("got %v, want missing.jpg failed", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		b, _ := ioutil.ReadAll(rc)
+		rc.Close()
+		got = append(got, f.Name+"="+string(b))
+	}
+	want := "a.jpg=/a.jpg?,a-2.jpg=/b.jpg?maxWidth=100,etc/c.jpg=/c.jpg?"
+	if strings.Join(got, ",") != want {
+		t.Fatalf("got %s, want %s", strings.Join(got, ","), want)
+	}
+	if results[1].Value != int64(len("/b.jpg?maxWidth=100")) {
+		t.Fatalf("got %d bytes, want %d", results[1].Value, len("/b.jpg?maxWidth=100"))
+	}
+}
+
+func TestArchiveName(t *testing.T) {
+	used := map[string]int{}
+	var got []string
+	for _, name := range []string{"a-2.jpg", "a.jpg", "a.jpg", "a.jpg", "..", "/", "../../etc/passwd", ""} {
+		got = append(got, archiveName(ArchiveItem{URL: "http://foo.ospry.io/", Filename: name}, used))
+	}
+	want := "a-2.jpg,a.jpg,a-3.jpg,a-4.jpg,image,image-2,etc/passwd,image-3"
+	if strings.Join(got, ",") != want {
+		t.Fatalf("got %s, want %s", strings.Join(got, ","), want)
+	}
+}
@@ -0,0 +1,117 @@
+package ospry
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Operations reported to a client's Audit hook.
+const (
+	OpUpload      = "upload"
+	OpClaim       = "claim"
+	OpMakePrivate = "make-private"
+	OpMakePublic  = "make-public"
+	OpDelete      = "delete"
+	OpCopy        = "copy"
+	OpReplace     = "replace"
+	OpRevert      = "revert"
+	OpSetCrops    = "set-crops"
+)
+
+// An AuditEvent describes a mutating operation run by a client.
+type AuditEvent struct {
+	Op string
+	// ImageID is the id of the image operated on. For uploads it's
+	// the new image's id, which is empty if the upload failed; for
+	// copies it's the original's.
+	ImageID string
+	// Actor is the client's Actor.
+	Actor string
+	Time  time.Time
+	// Err is the operation's error, nil if it succeeded.
+	Err error
+}
+
+// MarshalJSON encodes e with its error as a string.
+func (e *AuditEvent) MarshalJSON() ([]byte, error) {
+	var errstr string
+	if e.Err != nil {
+		errstr = e.Err.Error()
+	}
+	return json.Marshal(struct {
+		Op      string    `json:"op"`
+		ImageID string    `json:"imageId"`
+		Actor   string    `json:"actor,omitempty"`
+		Time    time.Time `json:"time"`
+		OK      bool      `json:"ok"`
+		Error   string    `json:"error,omitempty"`
+	}{e.Op, e.ImageID, e.Actor, e.Time, e.Err == nil, errstr})
+}
+
+// AuditLog returns an Audit hook writing each event to w as a line of
+// json. It's safe for concurrent use. Write errors are ignored.
+func AuditLog(w io.Writer) func(*AuditEvent) {
+	var mu sync.Mutex
+	return func(e *AuditEvent) {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		w.Write(append(b, '\n'))
+	}
+}
+
+// audit reports an operation to the client's Audit hook.
+func (c *Client) audit(op, id string, err error) {
+	if c.Audit == nil {
+		return
+	}
+	c.Audit(&AuditEvent{Op: op, ImageID: id, Actor: c.Actor, Time: c.now(), Err: err})
+}
+
+// auditBatch reports the mutating ops of a batch. err, if set, failed
+// the whole batch.
+func (c *Client) auditBatch(ops []BatchOp, results []BatchResult[*Metadata], err error) {
+	if c.Audit == nil {
+		return
+	}
+	for i, op := range ops {
+		name := batchOpName(op)
+		if name == "" {
+			continue
+		}
+		opErr := err
+		if i < len(results) {
+			opErr = results[i].Err
+		}
+		c.audit(name, strings.TrimPrefix(op.Path, "/images/"), opErr)
+	}
+}
+
+// batchOpName returns the audited operation an op runs, or "" if it
+// doesn't modify anything.
+func batchOpName(op BatchOp) string {
+	switch op.Method {
+	case "GET", "HEAD":
+		return ""
+	case "DELETE":
+		return OpDelete
+	}
+	if body, ok := op.Body.(map[string]interface{}); ok {
+		if _, ok := body["isClaimed"]; ok {
+			return OpClaim
+		}
+		if p, ok := body["isPrivate"].(bool); ok {
+			if p {
+				return OpMakePrivate
+			}
+			return OpMakePublic
+		}
+	}
+	return strings.ToLower(op.Method)
+}
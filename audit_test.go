@@ -0,0 +1,70 @@
+package ospry
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAudit(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/missing") {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":{"httpStatusCode":404,"message":"not found"}}`))
+			return
+		}
+		if r.URL.Path == "/batch" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"metadata":{"id":"foo"}}`))
+	}))
+	defer ts.Close()
+	var buf bytes.Buffer
+	c := New("sk-test-key")
+	c.ServerURL = ts.URL
+	c.Audit = AuditLog(&buf)
+	c.Actor = "alice"
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	c.Now = func() time.Time { return now }
+
+	c.UploadPublic("foo.jpg", strings.NewReader("foo"))
+	c.Claim("foo")
+	c.MakePrivate("foo")
+	c.GetMetadata("foo")
+	c.Delete("missing")
+	c.Batch([]BatchOp{BatchGetMetadata("foo"), BatchMakePublic("foo")})
+
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var e struct {
+			Op, ImageID, Actor, Error string
+			OK                        bool
+			Time                      time.Time
+		}
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatal(err)
+		}
+		if e.Actor != "alice" {
+			t.Fatalf("got actor %q, want alice", e.Actor)
+		}
+		if !e.Time.Equal(now) {
+			t.Fatalf("got time %v, want the client's clock", e.Time)
+		}
+		got = append(got, e.Op+" "+e.ImageID+" "+e.Error)
+	}
+	want := []string{
+		"upload foo ",
+		"claim foo ",
+		"make-private foo ",
+		"delete missing ospry: not found",
+		"make-public foo ",
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
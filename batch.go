@@ -0,0 +1,228 @@
+package ospry
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultBatchConcurrency is the default for Client.BatchConcurrency.
+const DefaultBatchConcurrency = 8
+
+// A BatchOp is an operation on a single image that can be sent as part
+// of a batch (see Batch).
+type BatchOp struct {
+	Method string      `json:"method"`
+	Path   string      `json:"path"`
+	Body   interface{} `json:"body,omitempty"`
+}
+
+// BatchGetMetadata returns an op that retrieves an image's metadata.
+func BatchGetMetadata(id string) BatchOp {
+	return BatchOp{Method: "GET", Path: "/images/" + id}
+}
+
+// BatchClaim returns an op that claims an image.
+func BatchClaim(id string) BatchOp {
+	return BatchOp{Method: "PUT", Path: "/images/" + id, Body: map[string]interface{}{"isClaimed": true}}
+}
+
+// BatchMakePrivate returns an op that makes an image private.
+func BatchMakePrivate(id string) BatchOp {
+	return BatchOp{Method: "PUT", Path: "/images/" + id, Body: map[string]interface{}{"isPrivate": true}}
+}
+
+// BatchMakePublic returns an op that makes an image public.
+func BatchMakePublic(id string) BatchOp {
+	return BatchOp{Method: "PUT", Path: "/images/" + id, Body: map[string]interface{}{"isPrivate": false}}
+}
+
+// BatchDelete returns an op that deletes an image.
+func BatchDelete(id string) BatchOp {
+	return BatchOp{Method: "DELETE", Path: "/images/" + id}
+}
+
+// Batch calls Batch on the default client.
+func Batch(ops []BatchOp) ([]BatchResult[*Metadata], error) {
+	return DefaultClient.Batch(ops)
+}
+
+// Batch runs ops in a single request to the api's batch endpoint and
+// returns their results in the same order. Ops fail individually: if
+// some of them did, the error is a *BatchError. Results hold the
+// metadata of the ops' images, which is nil for deletes. Other errors
+// mean the batch as a whole couldn't be run.
+//
+// If the server has no batch endpoint, the ops are sent as individual
+// requests instead, BatchConcurrency at a time. Like DeleteMany,
+// batches that delete images refuse to run against the Live
+// environment unless the client's ConfirmLive field is set.
+func (c *Client) Batch(ops []BatchOp) ([]BatchResult[*Metadata], error) {
+	if len(ops) == 0 {
+		return nil, nil
+	}
+	for _, op := range ops {
+		if op.Method != "DELETE" {
+			continue
+		}
+		if err := c.confirmDestructive(); err != nil {
+			c.auditBatch(ops, nil, err)
+			return nil, err
+		}
+		break
+	}
+	if c.ReadOnly {
+		// The batch endpoint is posted to, so read-only clients
+		// run reads individually.
+		for _, op := range ops {
+			if batchOpName(op) != "" {
+				c.auditBatch(ops, nil, ErrReadOnly)
+				return nil, ErrReadOnly
+			}
+		}
+	}
+	c.mu.Lock()
+	noBatch := c.noBatch || c.ReadOnly
+	c.mu.Unlock()
+	if !noBatch {
+		results, err := c.sendBatch(ops)
+		if err == nil {
+			c.cacheBatch(ops, results)
+			c.auditBatch(ops, results, nil)
+			return results, batchErr(results)
+		}
+		if err != errBatchUnsupported {
+			c.auditBatch(ops, nil, err)
+			return nil, err
+		}
+		c.mu.Lock()
+		c.noBatch = true
+		c.mu.Unlock()
+	}
+	results := c.runBatch(ops)
+	c.cacheBatch(ops, results)
+	c.auditBatch(ops, results, nil)
+	return results, batchErr(results)
+}
+
+// cacheBatch updates the client's MetadataCache with the results of
+// the ops that modified images. Images whose ops failed are dropped
+// from it, since they may have been modified anyway.
+func (c *Client) cacheBatch(ops []BatchOp, results []BatchResult[*Metadata]) {
+	if c.MetadataCache == nil {
+		return
+	}
+	for i, op := range ops {
+		if batchOpName(op) == "" {
+			continue
+		}
+		if r := results[i]; r.Err == nil && r.Value != nil && op.Method != "DELETE" {
+			c.cacheMetadata(r.Value)
+		} else {
+			c.uncacheMetadata(strings.TrimPrefix(op.Path, "/images/"))
+		}
+	}
+}
+
+var errBatchUnsupported = errors.New("ospry: batch endpoint not supported")
+
+func (c *Client) sendBatch(ops []BatchOp) ([]BatchResult[*Metadata], error) {
+	u, err := url.Parse(c.ServerURL)
+	if err != nil {
+		return nil, err
+	}
+	u.Path += "/batch"
+	b, err := json.Marshal(map[string]interface{}{"operations": ops})
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	res, err := c.curl("POST", u.String(), "application/json", bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return nil, errBatchUnsupported
+	}
+	var body struct {
+		Results []struct {
+			Metadata *Metadata `json:"metadata"`
+			Error    *Error    `json:"error"`
+		} `json:"results"`
+		Error *Error `json:"error"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	if body.Error != nil {
+		return nil, body.Error
+	}
+	if len(body.Results) != len(ops) {
+		return nil, errors.New("ospry: batch response doesn't match request")
+	}
+	d := time.Since(start)
+	results := make([]BatchResult[*Metadata], len(ops))
+	for i, r := range body.Results {
+		results[i] = BatchResult[*Metadata]{Item: ops[i].Path, Attempts: 1, Duration: d}
+		if r.Error != nil {
+			results[i].Err = r.Error
+			continue
+		}
+		if r.Metadata != nil {
+			c.checkInvariants(res, r.Metadata)
+			if err := c.checkFields(r.Metadata); err != nil {
+				results[i].Err = err
+				continue
+			}
+			if err := c.normalizeMetadata(r.Metadata); err != nil {
+				results[i].Err = err
+				continue
+			}
+		}
+		results[i].Value = r.Metadata
+	}
+	return results, nil
+}
+
+// runBatch runs ops as individual requests.
+func (c *Client) runBatch(ops []BatchOp) []BatchResult[*Metadata] {
+	return fanOut(c.batchConcurrency(), len(ops),
+		func(i int) string { return ops[i].Path },
+		func(i int) (*Metadata, error) { return c.runOp(ops[i]) })
+}
+
+// batchConcurrency returns the number of requests bulk operations
+// make at once.
+func (c *Client) batchConcurrency() int {
+	if c.BatchConcurrency <= 0 {
+		return DefaultBatchConcurrency
+	}
+	return c.BatchConcurrency
+}
+
+func (c *Client) runOp(op BatchOp) (*Metadata, error) {
+	if op.Body != nil {
+		return c.sendJSON(op.Method, op.Path, op.Body)
+	}
+	u, err := url.Parse(c.ServerURL)
+	if err != nil {
+		return nil, err
+	}
+	u.Path += op.Path
+	res, err := c.curl(op.Method, u.String(), "application/json", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	m, err := c.decodeMetadata(res)
+	if op.Method == "DELETE" {
+		return nil, err
+	}
+	return m, err
+}
@@ -0,0 +1,127 @@
+package ospry
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ospry/ospry-go/cache"
+)
+
+func TestBatch(t *testing.T) {
+	var requests int
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/v1/batch" {
+			t.Fatalf("got request to %s, want /v1/batch", r.URL.Path)
+		}
+		var body struct {
+			Operations []BatchOp `json:"operations"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		var results []map[string]interface{}
+		for _, op := range body.Operations {
+			id := strings.TrimPrefix(op.Path, "/images/")
+			if id == "missing" {
+				results = append(results, map[string]interface{}{"error": &Error{HTTPStatusCode: 404, Message: "not found"}})
+				continue
+			}
+			results = append(results, map[string]interface{}{"metadata": &Metadata{ID: id, IsClaimed: op.Method == "PUT"}})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"results": results})
+	})
+	results, err := c.Batch([]BatchOp{BatchGetMetadata("foo"), BatchClaim("bar"), BatchGetMetadata("missing")})
+	checkBatchErr(t, err)
+	if requests != 1 {
+		t.Fatalf("got %d requests, want 1", requests)
+	}
+	checkBatch(t, results)
+}
+
+func TestBatchFallback(t *testing.T) {
+	var mu sync.Mutex
+	var batchRequests, requests int
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.URL.Path == "/v1/batch" {
+			batchRequests++
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		requests++
+		id := strings.TrimPrefix(r.URL.Path, "/v1/images/")
+		if id == "missing" {
+			w.WriteHeader(http.StatusNotFound)
+			writeError(w, &Error{HTTPStatusCode: 404, Message: "not found"})
+			return
+		}
+		writeMetadata(w, &Metadata{ID: id, IsClaimed: r.Method == "PUT"})
+	})
+	ops := []BatchOp{BatchGetMetadata("foo"), BatchClaim("bar"), BatchGetMetadata("missing")}
+	for i := 0; i < 2; i++ {
+		results, err := c.Batch(ops)
+		checkBatchErr(t, err)
+		checkBatch(t, results)
+	}
+	if batchRequests != 1 || requests != 6 {
+		t.Fatalf("got %d batch and %d single requests, want 1 and 6", batchRequests, requests)
+	}
+}
+
+func checkBatchErr(t *testing.T, err error) {
+	t.Helper()
+	be, ok := err.(*BatchError)
+	if !ok {
+		t.Fatalf("got %v, want *BatchError", err)
+	}
+	if be.Total != 3 || len(be.Errs) != 1 {
+		t.Fatalf("got %d of %d failed, want 1 of 3", len(be.Errs), be.Total)
+	}
+}
+
+func checkBatch(t *testing.T, results []BatchResult[*Metadata]) {
+	t.Helper()
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+	if m := results[0].Value; results[0].Err != nil || m.ID != "foo" || m.IsClaimed || results[0].Item != "/images/foo" || results[0].Attempts != 1 {
+		t.Fatalf("got %+v, want unclaimed foo", results[0])
+	}
+	if m := results[1].Value; results[1].Err != nil || m.ID != "bar" || !m.IsClaimed {
+		t.Fatalf("got %+v, want claimed bar", results[1])
+	}
+	if results[2].Value != nil || results[2].Err == nil || results[2].Err.Error() != "ospry: not found" {
+		t.Fatalf("got %+v, want not found error", results[2])
+	}
+}
+
+func TestBatchDelete(t *testing.T) {
+	c := New("sk-live-abc")
+	if _, err := c.Batch([]BatchOp{BatchGetMetadata("foo"), BatchDelete("bar")}); err != ErrLiveNotConfirmed {
+		t.Fatalf("got %v, want %v", err, ErrLiveNotConfirmed)
+	}
+
+	c = newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"results": []map[string]interface{}{
+			{"metadata": &Metadata{ID: "foo", IsPrivate: true}},
+			{"metadata": &Metadata{ID: "bar"}},
+		}})
+	})
+	c.MetadataCache = cache.NewLRU(1 << 20)
+	c.cacheMetadata(&Metadata{ID: "foo"})
+	c.cacheMetadata(&Metadata{ID: "bar"})
+	if _, err := c.Batch([]BatchOp{BatchMakePrivate("foo"), BatchDelete("bar")}); err != nil {
+		t.Fatal(err)
+	}
+	if m, ok := c.cachedMetadata("foo"); !ok || !m.IsPrivate {
+		t.Fatalf("got cached %+v, want foo private", m)
+	}
+	if _, ok := c.cachedMetadata("bar"); ok {
+		t.Fatal("got bar cached, want it dropped after deleting it")
+	}
+}
@@ -0,0 +1,146 @@
+// Package cache provides caches for image data and metadata, e.g. for
+// serving renders without fetching them from ospry every time.
+package cache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// A Cache stores data by key. Caches are best-effort: Set may drop
+// data, and Get may miss at any time. Implementations must be safe for
+// concurrent use.
+type Cache interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, data []byte)
+}
+
+// A Deleter is a Cache that entries can be removed from, e.g. when the
+// data they hold changes.
+type Deleter interface {
+	Cache
+	Delete(key string)
+}
+
+// An LRU is an in-memory cache that evicts the least recently used
+// entries once its size exceeds a limit.
+type LRU struct {
+	maxBytes int64
+
+	mu    sync.Mutex
+	size  int64
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type entry struct {
+	key  string
+	data []byte
+}
+
+// NewLRU creates an LRU that holds up to maxBytes of data.
+func NewLRU(maxBytes int64) *LRU {
+	return &LRU{
+		maxBytes: maxBytes,
+		ll:       list.New(),
+		items:    map[string]*list.Element{},
+	}
+}
+
+// Get returns the data stored under key.
+func (c *LRU) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.ll.MoveToFront(e)
+	return e.Value.(*entry).data, true
+}
+
+// Set stores data under key, evicting old entries as needed. Data
+// larger than the cache itself isn't stored.
+func (c *LRU) Set(key string, data []byte) {
+	if int64(len(data)) > c.maxBytes {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		c.size -= int64(len(e.Value.(*entry).data))
+		c.ll.Remove(e)
+	}
+	c.items[key] = c.ll.PushFront(&entry{key, data})
+	c.size += int64(len(data))
+	for c.size > c.maxBytes {
+		e := c.ll.Back()
+		ent := e.Value.(*entry)
+		c.ll.Remove(e)
+		delete(c.items, ent.key)
+		c.size -= int64(len(ent.data))
+	}
+}
+
+// Delete removes the entry stored under key.
+func (c *LRU) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		c.size -= int64(len(e.Value.(*entry).data))
+		c.ll.Remove(e)
+		delete(c.items, key)
+	}
+}
+
+// Dir is a cache that stores each entry in a file in the named
+// directory. The directory is created as needed.
+type Dir string
+
+// Get returns the data stored under key.
+func (d Dir) Get(key string) ([]byte, bool) {
+	b, err := ioutil.ReadFile(d.path(key))
+	if err != nil {
+		return nil, false
+	}
+	return b, true
+}
+
+// Set stores data under key. Errors writing the file are ignored.
+func (d Dir) Set(key string, data []byte) {
+	if err := os.MkdirAll(string(d), 0755); err != nil {
+		return
+	}
+	// Write to a temporary file first so that readers never see a
+	// partially written entry.
+	f, err := ioutil.TempFile(string(d), ".tmp-")
+	if err != nil {
+		return
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return
+	}
+	if err := os.Rename(f.Name(), d.path(key)); err != nil {
+		os.Remove(f.Name())
+	}
+}
+
+// Delete removes the entry stored under key.
+func (d Dir) Delete(key string) {
+	os.Remove(d.path(key))
+}
+
+func (d Dir) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(string(d), hex.EncodeToString(sum[:]))
+}
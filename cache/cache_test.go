@@ -0,0 +1,47 @@
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestLRU(t *testing.T) {
+	c := NewLRU(10)
+	c.Set("a", []byte("aaaa"))
+	c.Set("b", []byte("bbbb"))
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("got false, want true")
+	}
+	// b is now the least recently used entry.
+	c.Set("c", []byte("cccc"))
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("got true, want false")
+	}
+	for _, k := range []string{"a", "c"} {
+		if _, ok := c.Get(k); !ok {
+			t.Fatalf("%s: got false, want true", k)
+		}
+	}
+	c.Set("d", []byte("too large to cache"))
+	if _, ok := c.Get("d"); ok {
+		t.Fatal("got true, want false")
+	}
+}
+
+func TestDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ospry-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	c := Dir(dir + "/sub")
+	if _, ok := c.Get("foo/bar"); ok {
+		t.Fatal("got true, want false")
+	}
+	c.Set("foo/bar", []byte("data"))
+	b, ok := c.Get("foo/bar")
+	if !ok || string(b) != "data" {
+		t.Fatalf("got %q, %t, want %q, true", b, ok, "data")
+	}
+}
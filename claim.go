@@ -0,0 +1,172 @@
+package ospry
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrNotYetVisible is returned by Claim when an image still wasn't
+// found at the end of the client's ClaimRetryWindow.
+var ErrNotYetVisible = errors.New("ospry: image not visible yet")
+
+// claimBackoff is the delay before retrying a claim. It doubles with
+// each retry, up to maxClaimBackoff.
+var (
+	claimBackoff    = 100 * time.Millisecond
+	maxClaimBackoff = 2 * time.Second
+)
+
+// claimWithRetry claims an image, retrying while it isn't found until
+// the client's ClaimRetryWindow has passed.
+func (c *Client) claimWithRetry(id string) (*Metadata, error) {
+	deadline := time.Now().Add(c.ClaimRetryWindow)
+	backoff := claimBackoff
+	for {
+		m, err := c.claim(id)
+		if e, ok := apiError(err); !ok || e.HTTPStatusCode != http.StatusNotFound {
+			return m, err
+		}
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			return nil, ErrNotYetVisible
+		}
+		if backoff < wait {
+			wait = backoff
+		}
+		time.Sleep(wait)
+		if backoff *= 2; backoff > maxClaimBackoff {
+			backoff = maxClaimBackoff
+		}
+	}
+}
+
+// ErrAlreadyClaimed matches the errors VerifyBeforeClaim returns for
+// images that have already been claimed when the constraints require
+// an unclaimed one (see ConstraintError.Is).
+var ErrAlreadyClaimed = errors.New("ospry: image already claimed")
+
+// Constraints are requirements an image must meet to be claimed (see
+// VerifyBeforeClaim and ValidateMetadata). Zero fields impose no constraint.
+type Constraints struct {
+	// MaxSize is the largest allowed image size in bytes.
+	MaxSize int64
+	// AllowedFormats lists the allowed image formats.
+	AllowedFormats []string
+	// MaxWidth and MaxHeight are the largest allowed dimensions.
+	MaxWidth  int
+	MaxHeight int
+	// MustBeUnclaimed rejects images that have already been claimed,
+	// e.g. by another user of the application.
+	MustBeUnclaimed bool
+}
+
+// VerifyBeforeClaim calls VerifyBeforeClaim on the default client.
+func VerifyBeforeClaim(id string, cons Constraints) (*Metadata, error) {
+	return DefaultClient.VerifyBeforeClaim(id, cons)
+}
+
+// VerifyBeforeClaim fetches the metadata of the image with the given
+// id and claims the image if it meets cons. Use it to claim ids
+// submitted by browsers, which may name someone else's image or an
+// image far larger than the application accepts.
+func (c *Client) VerifyBeforeClaim(id string, cons Constraints) (*Metadata, error) {
+	m, err := c.GetMetadata(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateMetadata(m, cons); err != nil {
+		return nil, err
+	}
+	if m.IsClaimed {
+		return m, nil
+	}
+	return c.Claim(id)
+}
+
+// A Violation is one way in which an image fails its constraints.
+type Violation struct {
+	// Field is the json name of the offending metadata field.
+	Field string
+	// Value is the field's value, and Limit what the constraints
+	// allow.
+	Value interface{}
+	Limit interface{}
+}
+
+func (v Violation) String() string {
+	switch v.Field {
+	case "isClaimed":
+		return "image already claimed"
+	case "format":
+		return fmt.Sprintf("format %v not in %v", v.Value, v.Limit)
+	}
+	return fmt.Sprintf("%s %v exceeds %v", v.Field, v.Value, v.Limit)
+}
+
+// A ConstraintError is returned for images that violate their
+// constraints. It lists every violation, not just the first.
+type ConstraintError struct {
+	ID         string
+	Violations []Violation
+}
+
+func (e *ConstraintError) Error() string {
+	s := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		s[i] = v.String()
+	}
+	return "ospry: image " + e.ID + " violates constraints: " + strings.Join(s, "; ")
+}
+
+// Is reports whether target is ErrAlreadyClaimed and the image was
+// rejected for being claimed.
+func (e *ConstraintError) Is(target error) bool {
+	if target != ErrAlreadyClaimed {
+		return false
+	}
+	for _, v := range e.Violations {
+		if v.Field == "isClaimed" {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateMetadata checks m against cons, returning a
+// *ConstraintError if it violates them. It's what VerifyBeforeClaim
+// uses, so the same constraints can be enforced wherever images enter
+// an application: claim handlers, webhook handlers (see
+// webhook.Constrain) and audits of existing images.
+func ValidateMetadata(m *Metadata, cons Constraints) error {
+	var vs []Violation
+	if cons.MustBeUnclaimed && m.IsClaimed {
+		vs = append(vs, Violation{"isClaimed", true, false})
+	}
+	if cons.MaxSize > 0 && m.Size > cons.MaxSize {
+		vs = append(vs, Violation{"size", m.Size, cons.MaxSize})
+	}
+	if len(cons.AllowedFormats) > 0 {
+		ok := false
+		for _, f := range cons.AllowedFormats {
+			if m.Format == f {
+				ok = true
+			}
+		}
+		if !ok {
+			vs = append(vs, Violation{"format", m.Format, cons.AllowedFormats})
+		}
+	}
+	if cons.MaxWidth > 0 && m.Width > cons.MaxWidth {
+		vs = append(vs, Violation{"width", m.Width, cons.MaxWidth})
+	}
+	if cons.MaxHeight > 0 && m.Height > cons.MaxHeight {
+		vs = append(vs, Violation{"height", m.Height, cons.MaxHeight})
+	}
+	if vs == nil {
+		return nil
+	}
+	return &ConstraintError{ID: m.ID, Violations: vs}
+}
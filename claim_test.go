@@ -0,0 +1,129 @@
+package ospry
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyBeforeClaim(t *testing.T) {
+	images := map[string]*Metadata{
+		"ok":      {ID: "ok", Format: "jpeg", Size: 100, Width: 10, Height: 10},
+		"big":     {ID: "big", Format: "jpeg", Size: 500 << 20, Width: 10, Height: 10},
+		"gif":     {ID: "gif", Format: "gif", Size: 100, Width: 10, Height: 10},
+		"wide":    {ID: "wide", Format: "png", Size: 100, Width: 10000, Height: 10},
+		"claimed": {ID: "claimed", Format: "jpeg", Size: 100, IsClaimed: true},
+	}
+	var claims []string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/v1/images/")
+		m := *images[id]
+		if r.Method == "PUT" {
+			claims = append(claims, id)
+			m.IsClaimed = true
+		}
+		writeMetadata(w, &m)
+	})
+	cons := Constraints{
+		MaxSize:         10 << 20,
+		AllowedFormats:  []string{"jpeg", "png"},
+		MaxWidth:        4000,
+		MaxHeight:       4000,
+		MustBeUnclaimed: true,
+	}
+	tests := []struct {
+		id, err string
+	}{
+		{"ok", ""},
+		{"big", "ospry: image big violates constraints: size 524288000 exceeds 10485760"},
+		{"gif", "ospry: image gif violates constraints: format gif not in [jpeg png]"},
+		{"wide", "ospry: image wide violates constraints: width 10000 exceeds 4000"},
+		{"claimed", "ospry: image claimed violates constraints: image already claimed"},
+	}
+	for _, test := range tests {
+		m, err := c.VerifyBeforeClaim(test.id, cons)
+		if test.err == "" {
+			if err != nil || !m.IsClaimed {
+				t.Fatalf("%s: got %v, %v, want claimed image", test.id, m, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != test.err {
+			t.Fatalf("%s: got %v, want %s", test.id, err, test.err)
+		}
+	}
+	if strings.Join(claims, ",") != "ok" {
+		t.Fatalf("got claims %v, want [ok]", claims)
+	}
+}
+
+func TestValidateMetadata(t *testing.T) {
+	cons := Constraints{MaxSize: 100, AllowedFormats: []string{"png"}, MaxWidth: 10, MaxHeight: 10, MustBeUnclaimed: true}
+	if err := ValidateMetadata(&Metadata{ID: "a", Format: "png", Size: 100, Width: 10, Height: 10}, cons); err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateMetadata(&Metadata{ID: "a", Format: "gif", Size: 1000}, Constraints{}); err != nil {
+		t.Fatalf("got %v for zero constraints, want nil", err)
+	}
+
+	err := ValidateMetadata(&Metadata{ID: "a", Format: "gif", Size: 101, Width: 11, Height: 12, IsClaimed: true}, cons)
+	ce, ok := err.(*ConstraintError)
+	if !ok {
+		t.Fatalf("got %v, want *ConstraintError", err)
+	}
+	var fields []string
+	for _, v := range ce.Violations {
+		fields = append(fields, v.Field)
+	}
+	if got, want := strings.Join(fields, ","), "isClaimed,size,format,width,height"; got != want {
+		t.Fatalf("got violations %s, want %s", got, want)
+	}
+	if !errors.Is(err, ErrAlreadyClaimed) {
+		t.Fatal("error doesn't match ErrAlreadyClaimed")
+	}
+	if errors.Is(ValidateMetadata(&Metadata{Size: 101}, cons), ErrAlreadyClaimed) {
+		t.Fatal("size violation matches ErrAlreadyClaimed")
+	}
+}
+
+func TestClaimRetryWindow(t *testing.T) {
+	claimBackoff = time.Millisecond
+	defer func() { claimBackoff = 100 * time.Millisecond }()
+
+	attempts := 0
+	visibleAfter := 3
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < visibleAfter {
+			w.WriteHeader(http.StatusNotFound)
+			writeError(w, &Error{HTTPStatusCode: 404, Message: "not found"})
+			return
+		}
+		writeMetadata(w, &Metadata{ID: "foo", IsClaimed: true})
+	})
+
+	// Without a window, 404s are returned as is.
+	if _, err := c.Claim("foo"); err == nil {
+		t.Fatal("got nil, want 404 error")
+	} else if e, ok := apiError(err); !ok || e.HTTPStatusCode != 404 {
+		t.Fatalf("got %v, want 404 error", err)
+	}
+
+	attempts = 0
+	c.ClaimRetryWindow = time.Second
+	m, err := c.Claim("foo")
+	if err != nil || !m.IsClaimed {
+		t.Fatalf("got %v, %v, want claimed image", m, err)
+	}
+	if attempts != 3 {
+		t.Fatalf("got %d attempts, want 3", attempts)
+	}
+
+	attempts, visibleAfter = 0, 1000
+	c.ClaimRetryWindow = 50 * time.Millisecond
+	if _, err := c.Claim("foo"); !errors.Is(err, ErrNotYetVisible) {
+		t.Fatalf("got %v, want %v", err, ErrNotYetVisible)
+	}
+}
@@ -0,0 +1,182 @@
+// Package cleanup deletes images matching criteria, e.g. uploads that
+// were never claimed:
+//
+//	cl := &cleanup.Cleaner{Client: c, ConfirmAbove: 100}
+//	report, err := cl.Run(ctx, &cleanup.Criteria{UnclaimedFor: 72 * time.Hour})
+//
+// Runs can be dry runs that only report what would be deleted, and
+// large deletions need to be confirmed.
+package cleanup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	ospry "github.com/ospry/ospry-go"
+)
+
+// ErrNotConfirmed is returned when a deletion needing confirmation
+// wasn't confirmed.
+var ErrNotConfirmed = errors.New("cleanup: deletion not confirmed")
+
+// ErrNoCriteria is returned when Run is given no criteria, which would
+// match every image.
+var ErrNoCriteria = errors.New("cleanup: no criteria")
+
+// Criteria select the images to delete. Images must match every
+// non-zero field.
+type Criteria struct {
+	// UnclaimedFor matches unclaimed images created at least this long
+	// ago.
+	UnclaimedFor time.Duration
+	// OlderThan matches images created at least this long ago.
+	OlderThan      time.Duration
+	FilenamePrefix string
+	// Tag matches images with a tag, as "key=value".
+	Tag    string
+	Format string
+}
+
+// filter returns the listing filter for c at time now.
+func (c *Criteria) filter(now time.Time) *ospry.ListFilter {
+	f := &ospry.ListFilter{
+		Format:         c.Format,
+		FilenamePrefix: c.FilenamePrefix,
+		Tag:            c.Tag,
+		Unclaimed:      c.UnclaimedFor > 0,
+	}
+	age := c.OlderThan
+	if c.UnclaimedFor > age {
+		age = c.UnclaimedFor
+	}
+	if age > 0 {
+		f.CreatedBefore = now.Add(-age)
+	}
+	return f
+}
+
+// match reports whether m matches the filter. Listings are filtered by
+// the server; this guards against deleting anything it got wrong.
+func match(f *ospry.ListFilter, m *ospry.Metadata) bool {
+	switch {
+	case f.Format != "" && m.Format != f.Format,
+		!strings.HasPrefix(m.Filename, f.FilenamePrefix),
+		f.Unclaimed && m.IsClaimed,
+		!f.CreatedBefore.IsZero() && !m.TimeCreated.Before(f.CreatedBefore):
+		return false
+	}
+	if f.Tag != "" {
+		kv := strings.SplitN(f.Tag, "=", 2)
+		if v, ok := m.Tags[kv[0]]; !ok || (len(kv) == 2 && v != kv[1]) {
+			return false
+		}
+	}
+	return true
+}
+
+// A Cleaner deletes images.
+type Cleaner struct {
+	// Client lists and deletes the images. If it's nil,
+	// ospry.DefaultClient is used. Deleting images in the live
+	// environment requires its ConfirmLive field to be set.
+	Client *ospry.Client
+	// DryRun makes Run report the matching images without deleting
+	// them.
+	DryRun bool
+	// ConfirmAbove, if positive, requires confirmation to delete more
+	// than that many images: Confirm is called with the number of
+	// matching images, and nothing is deleted unless it returns true.
+	ConfirmAbove int
+	Confirm      func(n int) bool
+}
+
+// A Report describes a cleanup run.
+type Report struct {
+	DryRun bool
+	// Matched are the images matching the criteria.
+	Matched []*ospry.Metadata
+	// Results are the results of deleting them, in the same order.
+	// They're empty for dry runs and unconfirmed runs.
+	Results []ospry.BatchResult[struct{}]
+}
+
+// Deleted returns the number of images deleted.
+func (r *Report) Deleted() int {
+	n := 0
+	for _, res := range r.Results {
+		if res.Err == nil {
+			n++
+		}
+	}
+	return n
+}
+
+// WriteText writes a summary of the report and a line per image to w.
+func (r *Report) WriteText(w io.Writer) error {
+	for i, m := range r.Matched {
+		status := "would delete"
+		if !r.DryRun {
+			status = "not deleted"
+			if i < len(r.Results) {
+				status = "deleted"
+				if err := r.Results[i].Err; err != nil {
+					status = "failed: " + err.Error()
+				}
+			}
+		}
+		created := m.TimeCreated.UTC().Format(time.RFC3339)
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", m.ID, created, m.Filename, status); err != nil {
+			return err
+		}
+	}
+	var err error
+	if r.DryRun {
+		_, err = fmt.Fprintf(w, "%d images match (dry run)\n", len(r.Matched))
+	} else {
+		_, err = fmt.Fprintf(w, "%d images match, %d deleted\n", len(r.Matched), r.Deleted())
+	}
+	return err
+}
+
+// Run deletes the images matching crit, which must have a non-zero
+// field. The report lists what matched and what was deleted, even when
+// Run fails part way.
+func (cl *Cleaner) Run(ctx context.Context, crit *Criteria) (*Report, error) {
+	c := cl.Client
+	if c == nil {
+		c = ospry.DefaultClient
+	}
+	report := &Report{DryRun: cl.DryRun}
+	if crit == nil || *crit == (Criteria{}) {
+		return report, ErrNoCriteria
+	}
+	f := crit.filter(time.Now())
+	images, errc := c.ListAll(ctx, f)
+	for m := range images {
+		if match(f, m) {
+			report.Matched = append(report.Matched, m)
+		}
+	}
+	if err := <-errc; err != nil {
+		return report, err
+	}
+	if cl.DryRun || len(report.Matched) == 0 {
+		return report, nil
+	}
+	if cl.ConfirmAbove > 0 && len(report.Matched) > cl.ConfirmAbove {
+		if cl.Confirm == nil || !cl.Confirm(len(report.Matched)) {
+			return report, ErrNotConfirmed
+		}
+	}
+	ids := make([]string, len(report.Matched))
+	for i, m := range report.Matched {
+		ids[i] = m.ID
+	}
+	var err error
+	report.Results, err = c.DeleteMany(ids)
+	return report, err
+}
@@ -0,0 +1,98 @@
+package cleanup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	ospry "github.com/ospry/ospry-go"
+)
+
+func newServer(t *testing.T, images []*ospry.Metadata, deleted *[]string, query *string) *ospry.Client {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "DELETE" {
+			*deleted = append(*deleted, strings.TrimPrefix(r.URL.Path, "/v1/images/"))
+			json.NewEncoder(w).Encode(map[string]interface{}{"metadata": &ospry.Metadata{}})
+			return
+		}
+		*query = r.URL.RawQuery
+		json.NewEncoder(w).Encode(map[string]interface{}{"images": images})
+	}))
+	t.Cleanup(srv.Close)
+	c := ospry.New("sk-test-key")
+	c.ServerURL = srv.URL + "/v1"
+	return c
+}
+
+func TestCleaner(t *testing.T) {
+	old := time.Now().Add(-100 * time.Hour)
+	images := []*ospry.Metadata{
+		{ID: "a", Filename: "tmp-a.jpg", TimeCreated: old},
+		{ID: "b", Filename: "tmp-b.jpg", TimeCreated: old},
+		// The server shouldn't return these, but if it does they're
+		// left alone.
+		{ID: "claimed", Filename: "tmp-c.jpg", TimeCreated: old, IsClaimed: true},
+		{ID: "new", Filename: "tmp-d.jpg", TimeCreated: time.Now()},
+	}
+	var deleted []string
+	var query string
+	c := newServer(t, images, &deleted, &query)
+	crit := &Criteria{UnclaimedFor: 72 * time.Hour, FilenamePrefix: "tmp-"}
+
+	cl := &Cleaner{Client: c, DryRun: true}
+	report, err := cl.Run(context.Background(), crit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Matched) != 2 || len(deleted) != 0 {
+		t.Fatalf("got %d matched, %d deleted, want 2, 0", len(report.Matched), len(deleted))
+	}
+	if !strings.Contains(query, "isClaimed=false") || !strings.Contains(query, "filenamePrefix=tmp-") || !strings.Contains(query, "createdBefore=") {
+		t.Fatalf("got query %s, want filters", query)
+	}
+	var buf bytes.Buffer
+	report.WriteText(&buf)
+	if !strings.HasSuffix(buf.String(), "2 images match (dry run)\n") {
+		t.Fatalf("got report %q", buf.String())
+	}
+
+	cl = &Cleaner{Client: c, ConfirmAbove: 1, Confirm: func(n int) bool { return false }}
+	if _, err := cl.Run(context.Background(), crit); err != ErrNotConfirmed {
+		t.Fatalf("got %v, want %v", err, ErrNotConfirmed)
+	}
+	if len(deleted) != 0 {
+		t.Fatalf("got %d deleted, want 0", len(deleted))
+	}
+
+	cl.Confirm = func(n int) bool { return n == 2 }
+	report, err = cl.Run(context.Background(), crit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(deleted, ",") != "a,b" || report.Deleted() != 2 {
+		t.Fatalf("got deleted %v, want [a b]", deleted)
+	}
+
+	for _, crit := range []*Criteria{nil, {}} {
+		if _, err := cl.Run(context.Background(), crit); err != ErrNoCriteria {
+			t.Fatalf("got %v, want %v", err, ErrNoCriteria)
+		}
+	}
+	if len(deleted) != 2 {
+		t.Fatalf("got %d deleted, want nothing more", len(deleted))
+	}
+}
+
+func TestMatchTag(t *testing.T) {
+	m := &ospry.Metadata{Tags: map[string]string{"batch": "7"}}
+	for tag, want := range map[string]bool{"batch=7": true, "batch": true, "batch=8": false, "other=7": false} {
+		if got := match(&ospry.ListFilter{Tag: tag}, m); got != want {
+			t.Fatalf("%s: got %v, want %v", tag, got, want)
+		}
+	}
+}
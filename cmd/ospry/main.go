@@ -0,0 +1,120 @@
+// Command ospry runs maintenance tasks against an ospry account.
+//
+// Usage:
+//
+//	ospry <command> [flags]
+//
+// The api key is read from the OSPRY_KEY environment variable. The
+// commands are:
+//
+//	cleanup    delete images matching criteria
+//	usage      report image counts and bytes by format, privacy and age
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	ospry "github.com/ospry/ospry-go"
+	"github.com/ospry/ospry-go/cleanup"
+	"github.com/ospry/ospry-go/usage"
+)
+
+const usageText = `usage: ospry <command> [flags]
+
+commands:
+  cleanup    delete images matching criteria
+  usage      report image counts and bytes by format, privacy and age
+`
+
+var commands = map[string]func(c *ospry.Client, args []string) error{
+	"cleanup": runCleanup,
+	"usage":   runUsage,
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("ospry: ")
+	if len(os.Args) < 2 || commands[os.Args[1]] == nil {
+		fmt.Fprint(os.Stderr, usageText)
+		os.Exit(2)
+	}
+	c := ospry.New(os.Getenv("OSPRY_KEY"))
+	if err := commands[os.Args[1]](c, os.Args[2:]); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func runCleanup(c *ospry.Client, args []string) error {
+	fs := flag.NewFlagSet("cleanup", flag.ExitOnError)
+	var crit cleanup.Criteria
+	fs.DurationVar(&crit.UnclaimedFor, "unclaimed-for", 0, "delete unclaimed images at least this old")
+	fs.DurationVar(&crit.OlderThan, "older-than", 0, "delete images at least this old")
+	fs.StringVar(&crit.FilenamePrefix, "prefix", "", "delete images whose filename has this prefix")
+	fs.StringVar(&crit.Tag, "tag", "", "delete images with this tag (key=value)")
+	fs.StringVar(&crit.Format, "format", "", "delete images in this format")
+	dryRun := fs.Bool("dry-run", false, "only report what would be deleted")
+	confirmAbove := fs.Int("confirm-above", 10, "ask before deleting more than this many images")
+	yes := fs.Bool("yes", false, "don't ask for confirmation")
+	live := fs.Bool("live", false, "allow deleting images in the live environment")
+	timeout := fs.Duration("timeout", time.Hour, "give up after this long")
+	fs.Parse(args)
+	if crit == (cleanup.Criteria{}) {
+		return fmt.Errorf("cleanup: no criteria given; refusing to delete everything")
+	}
+	c.ConfirmLive = *live
+
+	cl := &cleanup.Cleaner{
+		Client:       c,
+		DryRun:       *dryRun,
+		ConfirmAbove: *confirmAbove,
+		Confirm: func(n int) bool {
+			return *yes || confirm(os.Stdin, os.Stderr, n)
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	report, err := cl.Run(ctx, &crit)
+	if report != nil {
+		report.WriteText(os.Stdout)
+	}
+	return err
+}
+
+func runUsage(c *ospry.Client, args []string) error {
+	fs := flag.NewFlagSet("usage", flag.ExitOnError)
+	format := fs.String("format", "json", "output format, json or csv")
+	price := fs.Float64("price", 0, "storage price per GB-month, to estimate the monthly cost")
+	fs.Parse(args)
+	report, err := usage.Collect(context.Background(), c, nil)
+	if err != nil {
+		return err
+	}
+	switch *format {
+	case "json":
+		err = report.WriteJSON(os.Stdout)
+	case "csv":
+		err = report.WriteCSV(os.Stdout)
+	default:
+		return fmt.Errorf("usage: unknown format %q", *format)
+	}
+	if err == nil && *price > 0 {
+		fmt.Fprintf(os.Stderr, "estimated storage cost: %.2f/month\n", report.Cost(*price))
+	}
+	return err
+}
+
+// confirm asks whether to delete n images.
+func confirm(in io.Reader, out io.Writer, n int) bool {
+	fmt.Fprintf(out, "Delete %d images? [y/N] ", n)
+	line, _ := bufio.NewReader(in).ReadString('\n')
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,15 @@
+package main
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestConfirm(t *testing.T) {
+	for in, want := range map[string]bool{"y\n": true, "YES\n": true, "n\n": false, "\n": false, "": false} {
+		if got := confirm(strings.NewReader(in), ioutil.Discard, 3); got != want {
+			t.Fatalf("%q: got %v, want %v", in, got, want)
+		}
+	}
+}
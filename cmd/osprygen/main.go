@@ -0,0 +1,195 @@
+// Command osprygen generates typed render presets from a config file,
+// so that image render variants are checked at compile time instead
+// of being looked up by name.
+//
+// The config is a JSON file like:
+//
+//	{
+//	  "package": "images",
+//	  "presets": [
+//	    {"name": "thumb", "width": 200, "height": 200, "fit": "crop"},
+//	    {"name": "hero", "width": 1600, "format": "jpeg", "quality": 80}
+//	  ]
+//	}
+//
+// For each preset, the generated file has a Preset constant (e.g.
+// PresetThumb), a func returning its render options (ThumbOpts) and a
+// func formatting an image's url with them (URLThumb). Use it with go
+// generate:
+//
+//	//go:generate osprygen -config presets.json -o presets_gen.go
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"text/template"
+	"unicode"
+
+	ospry "github.com/ospry/ospry-go"
+)
+
+// A config describes the presets to generate.
+type config struct {
+	Package string         `json:"package"`
+	Presets []presetConfig `json:"presets"`
+}
+
+type presetConfig struct {
+	Name    string `json:"name"`
+	Width   int    `json:"width"`
+	Height  int    `json:"height"`
+	Fit     string `json:"fit"`
+	Format  string `json:"format"`
+	Quality int    `json:"quality"`
+}
+
+// Ident returns the Go identifier for the preset's name, e.g.
+// HeroBanner for "hero-banner".
+func (p presetConfig) Ident() string {
+	var b strings.Builder
+	upper := true
+	for _, r := range p.Name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("osprygen: ")
+	configPath := flag.String("config", "presets.json", "path to presets config")
+	out := flag.String("o", "", "output file (default stdout)")
+	pkg := flag.String("package", "", "package name (overrides the config's)")
+	flag.Parse()
+
+	b, err := ioutil.ReadFile(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var cfg config
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		log.Fatalf("%s: %v", *configPath, err)
+	}
+	if *pkg != "" {
+		cfg.Package = *pkg
+	}
+	src, err := generate(&cfg)
+	if err != nil {
+		log.Fatalf("%s: %v", *configPath, err)
+	}
+	if *out == "" {
+		os.Stdout.Write(src)
+		return
+	}
+	if err := ioutil.WriteFile(*out, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// generate returns the formatted Go source for cfg.
+func generate(cfg *config) ([]byte, error) {
+	if cfg.Package == "" {
+		return nil, errors.New("missing package name")
+	}
+	seen := map[string]bool{}
+	for _, p := range cfg.Presets {
+		id := p.Ident()
+		if id == "" || !unicode.IsLetter([]rune(id)[0]) {
+			return nil, fmt.Errorf("invalid preset name %q", p.Name)
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("duplicate preset %s", id)
+		}
+		seen[id] = true
+		// Validate the options the same way FormatURL will.
+		opts := &ospry.RenderOpts{
+			MaxWidth:  p.Width,
+			MaxHeight: p.Height,
+			Fit:       p.Fit,
+			Format:    p.Format,
+			Quality:   p.Quality,
+		}
+		if _, err := ospry.New("").FormatURL("http://example.ospry.io/x", opts); err != nil {
+			return nil, fmt.Errorf("preset %s: %v", p.Name, err)
+		}
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, cfg); err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
+
+var tmpl = template.Must(template.New("").Parse(`// Code generated by osprygen; DO NOT EDIT.
+
+package {{.Package}}
+
+import ospry "github.com/ospry/ospry-go"
+
+// A Preset names a render variant.
+type Preset string
+
+// Presets.
+const (
+{{- range .Presets}}
+	Preset{{.Ident}} Preset = {{printf "%q" .Name}}
+{{- end}}
+)
+
+// Presets lists all presets.
+var Presets = []Preset{ {{- range .Presets}}Preset{{.Ident}}, {{end -}} }
+
+// Opts returns the render options of p, or nil if p isn't a preset.
+func (p Preset) Opts() *ospry.RenderOpts {
+	switch p {
+{{- range .Presets}}
+	case Preset{{.Ident}}:
+		return {{.Ident}}Opts()
+{{- end}}
+	}
+	return nil
+}
+{{range .Presets}}
+// {{.Ident}}Opts returns the render options of the {{.Name}} preset.
+func {{.Ident}}Opts() *ospry.RenderOpts {
+	return &ospry.RenderOpts{
+{{- if .Width}}
+		MaxWidth: {{.Width}},
+{{- end}}
+{{- if .Height}}
+		MaxHeight: {{.Height}},
+{{- end}}
+{{- if .Fit}}
+		Fit: {{printf "%q" .Fit}},
+{{- end}}
+{{- if .Format}}
+		Format: {{printf "%q" .Format}},
+{{- end}}
+{{- if .Quality}}
+		Quality: {{.Quality}},
+{{- end}}
+	}
+}
+
+// URL{{.Ident}} returns the url of m rendered with the {{.Name}} preset.
+func URL{{.Ident}}(m *ospry.Metadata) (string, error) {
+	return m.RenderURL({{.Ident}}Opts())
+}
+{{end}}`))
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	cfg := &config{
+		Package: "images",
+		Presets: []presetConfig{
+			{Name: "thumb", Width: 200, Height: 200, Fit: "crop"},
+			{Name: "hero-banner", Width: 1600, Format: "jpeg", Quality: 80},
+		},
+	}
+	src, err := generate(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "presets_gen.go", src, 0); err != nil {
+		t.Fatalf("%v\n%s", err, src)
+	}
+	for _, want := range []string{
+		`PresetHeroBanner Preset = "hero-banner"`,
+		"func URLThumb(m *ospry.Metadata) (string, error)",
+		"func HeroBannerOpts() *ospry.RenderOpts",
+		`Format:   "jpeg"`,
+	} {
+		if !strings.Contains(string(src), want) {
+			t.Fatalf("missing %q in\n%s", want, src)
+		}
+	}
+}
+
+func TestGenerateInvalid(t *testing.T) {
+	invalid := []*config{
+		{Presets: []presetConfig{{Name: "thumb"}}},
+		{Package: "p", Presets: []presetConfig{{Name: "thumb"}, {Name: "Thumb"}}},
+		{Package: "p", Presets: []presetConfig{{Name: "1x"}}},
+		{Package: "p", Presets: []presetConfig{{Name: "thumb", Format: "bmp"}}},
+		{Package: "p", Presets: []presetConfig{{Name: "thumb", Width: 10, Fit: "crop"}}},
+	}
+	for _, cfg := range invalid {
+		if _, err := generate(cfg); err == nil {
+			t.Fatalf("%+v: got nil, want error", cfg)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package ospry
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/v1/images/foo/copies" {
+			t.Fatalf("got %s %s, want POST /v1/images/foo/copies", r.Method, r.URL.Path)
+		}
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		writeMetadata(w, &Metadata{ID: "bar", Format: body["format"]})
+	})
+	md, err := c.Convert("foo", "png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if md.ID != "bar" || md.Format != "png" {
+		t.Fatalf("got %s/%s, want bar/png", md.ID, md.Format)
+	}
+	for _, format := range []string{"bmp", ""} {
+		if _, err := c.Convert("foo", format); err == nil {
+			t.Fatalf("%q: got nil, want error", format)
+		}
+	}
+}
+
+func TestCopy(t *testing.T) {
+	var query string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		writeMetadata(w, &Metadata{ID: "bar"})
+	})
+	_, err := c.Copy("foo", &RenderOpts{MaxWidth: 1200, MaxHeight: 630, Fit: FitCrop})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "fit=crop&maxHeight=630&maxWidth=1200"; query != want {
+		t.Fatalf("got %s, want %s", query, want)
+	}
+	if _, err := c.Copy("foo", &RenderOpts{MaxWidth: 1200, Fit: FitCrop}); err == nil {
+		t.Fatal("got nil, want error")
+	}
+}
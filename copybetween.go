@@ -0,0 +1,78 @@
+package ospry
+
+import (
+	"io"
+	"time"
+)
+
+// CopyOpts are options for CopyBetween.
+type CopyOpts struct {
+	// Render, if set, copies the image rendered with these options
+	// (e.g. resized) instead of the original. Its TimeExpired is
+	// ignored; private images are signed for the copy.
+	Render *RenderOpts
+	// Filename, if set, replaces the original's filename.
+	Filename string
+	// Upload, if set, sets the copy's privacy and tags. If nil, the
+	// original's are kept.
+	Upload *UploadOpts
+	// Progress, if set, is told about the bytes copied, with the
+	// original's id as the item.
+	Progress ProgressReporter
+}
+
+// CopyBetween copies the image with the given id from src's account to
+// dst's and returns the copy's metadata. The image data is streamed
+// from the download into the upload, without being buffered in memory
+// or on disk. Unlike Copy, it works across accounts.
+func CopyBetween(src, dst *Client, id string, opts *CopyOpts) (*Metadata, error) {
+	m, err := src.GetMetadata(id)
+	if err != nil {
+		return nil, err
+	}
+	return CopyMetaBetween(src, dst, m, opts)
+}
+
+// CopyMetaBetween is like CopyBetween, but copies the image described
+// by m, saving a metadata request when it's already known (e.g. from a
+// listing).
+func CopyMetaBetween(src, dst *Client, m *Metadata, opts *CopyOpts) (copied *Metadata, err error) {
+	if opts == nil {
+		opts = &CopyOpts{}
+	}
+	render := RenderOpts{}
+	if opts.Render != nil {
+		render = *opts.Render
+	}
+	render.TimeExpired = time.Time{}
+	if m.IsPrivate {
+		render.TimeExpired = src.now().Add(time.Hour)
+	}
+	filename := m.Filename
+	if opts.Filename != "" {
+		filename = opts.Filename
+	}
+	upload := opts.Upload
+	if upload == nil {
+		upload = &UploadOpts{IsPrivate: m.IsPrivate, Tags: m.Tags}
+	}
+
+	if p := opts.Progress; p != nil {
+		size := m.Size
+		if opts.Render != nil {
+			size = -1
+		}
+		p.Start(m.ID, size)
+		defer func() { p.Done(m.ID, err) }()
+	}
+	rc, err := src.DownloadMeta(m, &render)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	var data io.Reader = rc
+	if p := opts.Progress; p != nil {
+		data = &progressReader{rc, m.ID, p}
+	}
+	return dst.Upload(filename, data, upload)
+}
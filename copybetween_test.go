@@ -0,0 +1,41 @@
+package ospry
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+func TestCopyBetween(t *testing.T) {
+	var imgURL string
+	src := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/images/img-1" {
+			writeMetadata(w, &Metadata{ID: "img-1", URL: imgURL, Filename: "cat.jpg", Size: 5, Tags: map[string]string{"k": "v"}})
+			return
+		}
+		w.Write([]byte("meow!"))
+	})
+	imgURL = src.ServerURL + "/cat.jpg"
+	var got string
+	dst := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		q := r.URL.Query()
+		got = q.Get("filename") + " " + q.Get("isPrivate") + " " + string(b)
+		writeMetadata(w, &Metadata{ID: "img-2"})
+	})
+
+	p := &recordingProgress{}
+	m, err := CopyBetween(src, dst, "img-1", &CopyOpts{Progress: p})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.ID != "img-2" {
+		t.Fatalf("got %s, want img-2", m.ID)
+	}
+	if want := "cat.jpg false meow!"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if got, want := p.summary(), "start img-1 5; progress img-1; done img-1 <nil>"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
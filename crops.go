@@ -0,0 +1,73 @@
+package ospry
+
+import (
+	"errors"
+	"fmt"
+)
+
+// A Crop is a rectangle of an image, in pixels of the original.
+type Crop struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// ErrUnknownCrop is returned when rendering an image with a named crop
+// its metadata doesn't have.
+var ErrUnknownCrop = errors.New("ospry: unknown crop")
+
+// SetCrops calls SetCrops on the default client.
+func SetCrops(id string, crops map[string]Crop) (*Metadata, error) {
+	return DefaultClient.SetCrops(id, crops)
+}
+
+// SetCrops replaces the image's named crops, e.g. a "square" and a
+// "banner" picked in an editor. Renders asking for a crop by name (see
+// RenderOpts.Crop) are cut from that rectangle first.
+func (c *Client) SetCrops(id string, crops map[string]Crop) (m *Metadata, err error) {
+	defer func() {
+		c.audit(OpSetCrops, id, err)
+		err = opError("ospry.SetCrops", id, c.apiURL("/images/"+id), err)
+	}()
+	for name, crop := range crops {
+		if !isCropName(name) {
+			return nil, errors.New("ospry: invalid crop name " + name)
+		}
+		if crop.X < 0 || crop.Y < 0 || crop.Width <= 0 || crop.Height <= 0 {
+			return nil, fmt.Errorf("ospry: invalid crop %s %+v", name, crop)
+		}
+	}
+	if crops == nil {
+		crops = map[string]Crop{}
+	}
+	return c.patch(id, map[string]interface{}{
+		"crops": crops,
+	})
+}
+
+// isCropName reports whether name can name a crop: letters, digits,
+// "-" and "_".
+func isCropName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// checkCrop fails if opts ask for a crop m doesn't have. Metadata
+// without any crops may be sparse, so it isn't checked.
+func checkCrop(m *Metadata, opts *RenderOpts) error {
+	if opts == nil || opts.Crop == "" || m.Crops == nil {
+		return nil
+	}
+	if _, ok := m.Crops[opts.Crop]; !ok {
+		return fmt.Errorf("%w %s for image %s", ErrUnknownCrop, opts.Crop, m.ID)
+	}
+	return nil
+}
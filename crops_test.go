@@ -0,0 +1,45 @@
+package ospry
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCrops(t *testing.T) {
+	var body map[string]map[string]Crop
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" || r.URL.Path != "/v1/images/foo" {
+			t.Errorf("got %s %s, want PUT /v1/images/foo", r.Method, r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		writeMetadata(w, &Metadata{ID: "foo", URL: "http://foo.ospry.io/bar.jpg", Crops: body["crops"]})
+	})
+	square := Crop{X: 100, Y: 0, Width: 600, Height: 600}
+	m, err := c.SetCrops("foo", map[string]Crop{"square": square})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body["crops"]["square"] != square || m.Crops["square"] != square {
+		t.Fatalf("got sent %v, returned %v, want the square crop", body, m.Crops)
+	}
+
+	got, err := c.FormatMetaURL(m, &RenderOpts{Crop: "square", MaxWidth: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "crop=square") {
+		t.Fatalf("got %s, want crop=square", got)
+	}
+	if _, err := c.FormatMetaURL(m, &RenderOpts{Crop: "banner"}); !errors.Is(err, ErrUnknownCrop) {
+		t.Fatalf("got %v, want ErrUnknownCrop", err)
+	}
+	if _, err := c.SetCrops("foo", map[string]Crop{"bad name": square}); err == nil {
+		t.Fatal("got no error, want invalid crop name")
+	}
+	if _, err := c.SetCrops("foo", map[string]Crop{"empty": {}}); err == nil {
+		t.Fatal("got no error, want empty crop rejected")
+	}
+}
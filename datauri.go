@@ -0,0 +1,52 @@
+package ospry
+
+import (
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// DefaultInlineBytes is the size limit of InlineDataURI when maxBytes
+// is zero.
+const DefaultInlineBytes = 8 << 10
+
+// InlineDataURI calls InlineDataURI on the default client.
+func InlineDataURI(urlstr string, opts *RenderOpts, maxBytes int64) (string, error) {
+	return DefaultClient.InlineDataURI(urlstr, opts, maxBytes)
+}
+
+// InlineDataURI downloads an image (see Download), typically a small
+// render, and returns it as a base64 data: uri, e.g. to embed tiny
+// previews in emails or in critical-path html. Images larger than
+// maxBytes (DefaultInlineBytes if zero) fail with a
+// *DownloadTooLargeError.
+func (c *Client) InlineDataURI(urlstr string, opts *RenderOpts, maxBytes int64) (string, error) {
+	if maxBytes <= 0 {
+		maxBytes = DefaultInlineBytes
+	}
+	rc, err := c.Download(urlstr, opts)
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	b, err := ioutil.ReadAll(io.LimitReader(rc, maxBytes+1))
+	if err != nil {
+		return "", err
+	}
+	if int64(len(b)) > maxBytes {
+		return "", &DownloadTooLargeError{URL: redactURL(urlstr), Limit: maxBytes}
+	}
+	return DataURI(b), nil
+}
+
+// DataURI returns image data as a base64 data: uri, with the media
+// type detected from the data.
+func DataURI(b []byte) string {
+	typ := http.DetectContentType(b)
+	if i := strings.IndexByte(typ, ';'); i >= 0 {
+		typ = typ[:i]
+	}
+	return "data:" + typ + ";base64," + base64.StdEncoding.EncodeToString(b)
+}
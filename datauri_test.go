@@ -0,0 +1,25 @@
+package ospry
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestInlineDataURI(t *testing.T) {
+	gif := []byte("GIF89a\x01\x00\x01\x00")
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write(gif)
+	})
+	imgURL := c.ServerURL + "/foo.gif"
+	got, err := c.InlineDataURI(imgURL, nil, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "data:image/gif;base64,R0lGODlhAQABAA=="; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if _, err := c.InlineDataURI(imgURL, nil, 5); !errors.As(err, new(*DownloadTooLargeError)) {
+		t.Fatalf("got %v, want *DownloadTooLargeError", err)
+	}
+}
@@ -0,0 +1,170 @@
+package ospry
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"io/ioutil"
+)
+
+// Default decode limits (see Client.MaxImagePixels and
+// Client.MaxDecodeMemory).
+const (
+	DefaultMaxImagePixels  = 50 * 1000 * 1000
+	DefaultMaxDecodeMemory = 256 << 20
+)
+
+// maxHeaderBytes is how much of an image is read looking for its
+// dimensions. Headers are small, but jpegs can carry long metadata
+// segments before the frame header.
+const maxHeaderBytes = 1 << 20
+
+// ErrHeaderTooLarge is returned when an image's dimensions can't be
+// found in its first megabyte.
+var ErrHeaderTooLarge = errors.New("ospry: image header too large")
+
+// An ImageTooLargeError is returned when an image exceeds the client's
+// decode limits. Memory is the estimated size of the decoded image.
+type ImageTooLargeError struct {
+	Width, Height int
+	Memory        int64
+	MaxPixels     int64
+	MaxMemory     int64
+}
+
+func (e *ImageTooLargeError) Error() string {
+	if e.MaxPixels > 0 && int64(e.Width)*int64(e.Height) > e.MaxPixels {
+		return fmt.Sprintf("ospry: %dx%d image exceeds %d pixels", e.Width, e.Height, e.MaxPixels)
+	}
+	return fmt.Sprintf("ospry: %dx%d image needs %d bytes to decode, limit is %d", e.Width, e.Height, e.Memory, e.MaxMemory)
+}
+
+// DecodeImage calls DecodeImage on the default client.
+func DecodeImage(r io.Reader) (image.Image, string, error) {
+	return DefaultClient.DecodeImage(r)
+}
+
+// DownloadImage calls DownloadImage on the default client.
+func DownloadImage(url string, opts *RenderOpts) (image.Image, string, error) {
+	return DefaultClient.DownloadImage(url, opts)
+}
+
+// DecodeImage decodes an image, like image.Decode, after checking its
+// dimensions against the client's MaxImagePixels and MaxDecodeMemory,
+// so that small files claiming huge dimensions are rejected with an
+// *ImageTooLargeError before any pixels are allocated.
+func (c *Client) DecodeImage(r io.Reader) (image.Image, string, error) {
+	var head bytes.Buffer
+	if _, _, err := c.checkImage(io.TeeReader(r, &head)); err != nil {
+		return nil, "", err
+	}
+	return image.Decode(io.MultiReader(&head, r))
+}
+
+// DownloadImage downloads and decodes an image (see Download and
+// DecodeImage).
+func (c *Client) DownloadImage(urlstr string, opts *RenderOpts) (image.Image, string, error) {
+	rc, err := c.Download(urlstr, opts)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rc.Close()
+	return c.decodeDownload(rc)
+}
+
+// decodeDownload decodes a downloaded image and reads the rest of the
+// download, which decoders may leave unread, so that its progress is
+// reported as done rather than abandoned.
+func (c *Client) decodeDownload(rc io.Reader) (image.Image, string, error) {
+	img, format, err := c.DecodeImage(rc)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := io.Copy(ioutil.Discard, rc); err != nil {
+		return nil, "", err
+	}
+	return img, format, nil
+}
+
+// checkImage reads the header of the image in r and checks it against
+// the client's decode limits.
+func (c *Client) checkImage(r io.Reader) (image.Config, string, error) {
+	lr := &io.LimitedReader{R: r, N: maxHeaderBytes}
+	cfg, format, err := image.DecodeConfig(lr)
+	if err != nil {
+		if lr.N == 0 {
+			return cfg, format, ErrHeaderTooLarge
+		}
+		return cfg, format, err
+	}
+	maxPixels, maxMemory := c.MaxImagePixels, c.MaxDecodeMemory
+	if maxPixels == 0 {
+		maxPixels = DefaultMaxImagePixels
+	}
+	if maxMemory == 0 {
+		maxMemory = DefaultMaxDecodeMemory
+	}
+	pixels := int64(cfg.Width) * int64(cfg.Height)
+	mem := pixels * bytesPerPixel(cfg.ColorModel)
+	if (maxPixels > 0 && pixels > maxPixels) || (maxMemory > 0 && mem > maxMemory) {
+		return cfg, format, &ImageTooLargeError{
+			Width:     cfg.Width,
+			Height:    cfg.Height,
+			Memory:    mem,
+			MaxPixels: maxPixels,
+			MaxMemory: maxMemory,
+		}
+	}
+	return cfg, format, nil
+}
+
+// validateUpload checks the image about to be uploaded against the
+// client's decode limits, returning a reader for the whole image.
+// Formats without a registered decoder aren't checked.
+func (c *Client) validateUpload(data io.Reader) (io.Reader, error) {
+	start := int64(-1)
+	if s, ok := data.(io.Seeker); ok {
+		if off, err := s.Seek(0, io.SeekCurrent); err == nil {
+			start = off
+		}
+	}
+	var head bytes.Buffer
+	_, _, err := c.checkImage(io.TeeReader(data, &head))
+	if err != nil && err != image.ErrFormat {
+		return nil, err
+	}
+	if start >= 0 {
+		if _, err := data.(io.Seeker).Seek(start, io.SeekStart); err != nil {
+			return nil, err
+		}
+		return data, nil
+	}
+	return io.MultiReader(&head, data), nil
+}
+
+// bytesPerPixel estimates the memory a decoded pixel takes in the
+// given color model.
+func bytesPerPixel(m color.Model) int64 {
+	switch m {
+	case color.GrayModel, color.AlphaModel:
+		return 1
+	case color.Gray16Model, color.Alpha16Model:
+		return 2
+	case color.YCbCrModel:
+		return 3
+	case color.NYCbCrAModel:
+		return 4
+	case color.RGBA64Model, color.NRGBA64Model:
+		return 8
+	}
+	if _, ok := m.(color.Palette); ok {
+		return 1
+	}
+	return 4
+}
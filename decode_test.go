@@ -0,0 +1,111 @@
+package ospry
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// pngBomb returns a png header claiming the given dimensions, without
+// any pixel data.
+func pngBomb(t *testing.T, w, h uint32) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	binary.BigEndian.PutUint32(b[16:], w)
+	binary.BigEndian.PutUint32(b[20:], h)
+	binary.BigEndian.PutUint32(b[29:], crc32.ChecksumIEEE(b[12:29]))
+	return b
+}
+
+func TestDecodeImage(t *testing.T) {
+	c := New("")
+	img, format, err := c.DecodeImage(bytes.NewReader(pngBomb(t, 3, 2)))
+	if err == nil {
+		t.Fatalf("got %s image %v, want error for missing pixel data", format, img.Bounds())
+	}
+	if _, ok := err.(*ImageTooLargeError); ok {
+		t.Fatalf("got %v, want a decode error", err)
+	}
+
+	_, _, err = c.DecodeImage(bytes.NewReader(pngBomb(t, 100000, 100000)))
+	tooLarge, ok := err.(*ImageTooLargeError)
+	if !ok {
+		t.Fatalf("got %v, want *ImageTooLargeError", err)
+	}
+	if tooLarge.Width != 100000 || tooLarge.MaxPixels != DefaultMaxImagePixels {
+		t.Fatalf("got %+v, want 100000 wide image over default limit", tooLarge)
+	}
+
+	// 4000x4000 gray is within the pixel limit, but not the memory
+	// limit.
+	c.MaxDecodeMemory = 1 << 20
+	_, _, err = c.DecodeImage(bytes.NewReader(pngBomb(t, 4000, 4000)))
+	if tooLarge, ok := err.(*ImageTooLargeError); !ok || tooLarge.Memory != 16000000 {
+		t.Fatalf("got %v, want *ImageTooLargeError for 16000000 bytes", err)
+	}
+	c.MaxDecodeMemory = -1
+	c.MaxImagePixels = -1
+	if _, _, err := c.checkImage(bytes.NewReader(pngBomb(t, 100000, 100000))); err != nil {
+		t.Fatalf("got %v, want no limit", err)
+	}
+}
+
+func TestDecodeImageValid(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 30, 20))); err != nil {
+		t.Fatal(err)
+	}
+	img, format, err := New("").DecodeImage(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "png" || img.Bounds().Dx() != 30 || img.Bounds().Dy() != 20 {
+		t.Fatalf("got %s %v, want png 30x20", format, img.Bounds())
+	}
+}
+
+func TestUploadValidate(t *testing.T) {
+	uploads := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		uploads++
+		b, _ := ioutil.ReadAll(r.Body)
+		if !strings.HasPrefix(string(b), "\x89PNG") && string(b) != "not an image" {
+			t.Errorf("got body %q, want the whole image", b)
+		}
+		w.Write([]byte(`{"id":"a"}`))
+	}))
+	defer ts.Close()
+	c := New("sk-test-key")
+	c.ServerURL = ts.URL
+	c.ValidateUploads = true
+	_, err := c.UploadPublic("bomb.png", bytes.NewReader(pngBomb(t, 100000, 100000)))
+	if !errors.As(err, new(*ImageTooLargeError)) {
+		t.Fatalf("got %v, want *ImageTooLargeError", err)
+	}
+	if _, err := c.Replace("a", bytes.NewReader(pngBomb(t, 100000, 100000))); !errors.As(err, new(*ImageTooLargeError)) {
+		t.Fatalf("got %v, want Replace to return *ImageTooLargeError", err)
+	}
+	if uploads != 0 {
+		t.Fatalf("got %d uploads, want 0", uploads)
+	}
+	if _, err := c.UploadPublic("small.png", ioutil.NopCloser(bytes.NewReader(pngBomb(t, 3, 2)))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.UploadPublic("other", strings.NewReader("not an image")); err != nil {
+		t.Fatal(err)
+	}
+	if uploads != 2 {
+		t.Fatalf("got %d uploads, want 2", uploads)
+	}
+}
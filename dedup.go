@@ -0,0 +1,163 @@
+package ospry
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+// ContentHashTag is the tag UploadIfAbsent stores the hex-encoded
+// SHA-256 hash of an image's data in.
+const ContentHashTag = "contentHash"
+
+// A HashIndex maps the content hashes of uploaded images to their ids.
+// Implementations must be safe for concurrent use.
+type HashIndex interface {
+	// Lookup returns the id of the image with the given hash.
+	Lookup(hash string) (id string, ok bool, err error)
+	// Store records the id of the image with the given hash.
+	Store(hash, id string) error
+	// Delete forgets the image with the given hash.
+	Delete(hash string) error
+}
+
+// A MemoryHashIndex is a HashIndex that keeps its entries in memory.
+type MemoryHashIndex struct {
+	mu  sync.Mutex
+	ids map[string]string
+}
+
+// NewMemoryHashIndex creates an empty MemoryHashIndex.
+func NewMemoryHashIndex() *MemoryHashIndex {
+	return &MemoryHashIndex{ids: map[string]string{}}
+}
+
+func (x *MemoryHashIndex) Lookup(hash string) (string, bool, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	id, ok := x.ids[hash]
+	return id, ok, nil
+}
+
+func (x *MemoryHashIndex) Store(hash, id string) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.ids[hash] = id
+	return nil
+}
+
+func (x *MemoryHashIndex) Delete(hash string) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	delete(x.ids, hash)
+	return nil
+}
+
+// UploadIfAbsent calls UploadIfAbsent on the default client.
+func UploadIfAbsent(filename string, data io.Reader, opts *UploadOpts) (*Metadata, error) {
+	return DefaultClient.UploadIfAbsent(filename, data, opts)
+}
+
+// UploadIfAbsent is like Upload, but if an image with the same content
+// was uploaded before, its metadata is returned instead of uploading
+// it again. That makes ingestion pipelines safe to restart. Uploads
+// are tagged with their content hash (see ContentHashTag), and
+// previous ones are looked up in the client's HashIndex, if set, and
+// then by that tag, so that images uploaded by other processes are
+// found too.
+//
+// The data is hashed before uploading. If it isn't an io.Seeker, it's
+// buffered in memory.
+func (c *Client) UploadIfAbsent(filename string, data io.Reader, opts *UploadOpts) (*Metadata, error) {
+	hash, data, err := hashData(data)
+	if err != nil {
+		return nil, err
+	}
+	if c.HashIndex != nil {
+		m, err := c.lookupHash(hash)
+		if m != nil || err != nil {
+			return m, err
+		}
+	}
+	page, err := c.List(&ListFilter{Tag: ContentHashTag + "=" + hash, PageSize: 1}, "")
+	if err != nil {
+		return nil, err
+	}
+	if len(page.Images) > 0 {
+		m := page.Images[0]
+		return m, c.storeHash(hash, m.ID)
+	}
+	o := UploadOpts{}
+	if opts != nil {
+		o = *opts
+	}
+	o.Tags = map[string]string{ContentHashTag: hash}
+	if opts != nil {
+		for k, v := range opts.Tags {
+			o.Tags[k] = v
+		}
+	}
+	m, err := c.Upload(filename, data, &o)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.storeHash(hash, m.ID); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// lookupHash returns the metadata of the image the client's HashIndex
+// has for hash, or nil if there's none.
+func (c *Client) lookupHash(hash string) (*Metadata, error) {
+	id, ok, err := c.HashIndex.Lookup(hash)
+	if err != nil || !ok {
+		return nil, err
+	}
+	m, err := c.GetMetadata(id)
+	if err == nil {
+		return m, nil
+	}
+	if e, isAPIErr := apiError(err); !isAPIErr || e.HTTPStatusCode != http.StatusNotFound {
+		return nil, err
+	}
+	// The image was deleted since.
+	return nil, c.HashIndex.Delete(hash)
+}
+
+// storeHash records id as the image with the given hash in the client's
+// HashIndex, if set.
+func (c *Client) storeHash(hash, id string) error {
+	if c.HashIndex == nil {
+		return nil
+	}
+	return c.HashIndex.Store(hash, id)
+}
+
+// hashData returns the hex-encoded SHA-256 hash of data, along with a
+// reader for the same data.
+func hashData(data io.Reader) (string, io.Reader, error) {
+	h := sha256.New()
+	if s, ok := data.(io.ReadSeeker); ok {
+		start, err := s.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return "", nil, err
+		}
+		if _, err := io.Copy(h, s); err != nil {
+			return "", nil, err
+		}
+		if _, err := s.Seek(start, io.SeekStart); err != nil {
+			return "", nil, err
+		}
+		return hex.EncodeToString(h.Sum(nil)), s, nil
+	}
+	b, err := ioutil.ReadAll(io.TeeReader(data, h))
+	if err != nil {
+		return "", nil, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), bytes.NewReader(b), nil
+}
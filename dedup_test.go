@@ -0,0 +1,77 @@
+package ospry
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestUploadIfAbsent(t *testing.T) {
+	var mu sync.Mutex
+	var uploaded []*Metadata
+	deleted := false
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == "POST":
+			m := &Metadata{ID: "foo", Tags: map[string]string{}}
+			for _, tag := range r.URL.Query()["tag"] {
+				kv := strings.SplitN(tag, "=", 2)
+				m.Tags[kv[0]] = kv[1]
+			}
+			uploaded = append(uploaded, m)
+			writeMetadata(w, m)
+		case r.URL.Path == "/v1/images":
+			images := []*Metadata{}
+			kv := strings.SplitN(r.URL.Query().Get("tag"), "=", 2)
+			for _, m := range uploaded {
+				if !deleted && len(kv) == 2 && m.Tags[kv[0]] == kv[1] {
+					images = append(images, m)
+				}
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"images": images})
+		case deleted:
+			w.WriteHeader(http.StatusNotFound)
+			writeError(w, &Error{HTTPStatusCode: http.StatusNotFound, Message: "not found"})
+		default:
+			writeMetadata(w, &Metadata{ID: strings.TrimPrefix(r.URL.Path, "/v1/images/")})
+		}
+	})
+	// Without a HashIndex, previous uploads are found by their tag.
+	for i := 0; i < 2; i++ {
+		md, err := c.UploadIfAbsent("foo.jpg", strings.NewReader("data"), &UploadOpts{Tags: map[string]string{"batch": "1"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if md.ID != "foo" {
+			t.Fatalf("got %s, want foo", md.ID)
+		}
+	}
+	if len(uploaded) != 1 || uploaded[0].Tags[ContentHashTag] == "" || uploaded[0].Tags["batch"] != "1" {
+		t.Fatalf("got %+v, want 1 upload tagged with its hash", uploaded)
+	}
+
+	c.HashIndex = NewMemoryHashIndex()
+	if _, err := c.UploadIfAbsent("foo.jpg", strings.NewReader("data"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if id, ok, _ := c.HashIndex.Lookup(uploaded[0].Tags[ContentHashTag]); !ok || id != "foo" {
+		t.Fatalf("got %q, want the tagged image indexed", id)
+	}
+	if _, err := c.UploadIfAbsent("foo.jpg", strings.NewReader("data"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(uploaded) != 1 {
+		t.Fatalf("got %d uploads, want 1", len(uploaded))
+	}
+	deleted = true
+	if _, err := c.UploadIfAbsent("foo.jpg", strings.NewReader("data"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(uploaded) != 2 {
+		t.Fatalf("got %d uploads, want 2", len(uploaded))
+	}
+}
@@ -0,0 +1,36 @@
+package ospry
+
+import (
+	"go/build"
+	"strings"
+	"testing"
+)
+
+// coreDeps are the subpackages the ospry package may import. Like the
+// ospry package itself, they may only import the standard library.
+var coreDeps = map[string]string{
+	"github.com/ospry/ospry-go/cache": "cache",
+}
+
+// TestLeanCore keeps the ospry package free of third-party
+// dependencies, which belong in optional subpackages.
+func TestLeanCore(t *testing.T) {
+	dirs := []string{"."}
+	for _, dir := range coreDeps {
+		dirs = append(dirs, dir)
+	}
+	for _, dir := range dirs {
+		pkg, err := build.ImportDir(dir, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, imp := range pkg.Imports {
+			if _, ok := coreDeps[imp]; ok && dir == "." {
+				continue
+			}
+			if first := strings.SplitN(imp, "/", 2)[0]; strings.Contains(first, ".") {
+				t.Errorf("%s imports %s, want only the standard library", pkg.Name, imp)
+			}
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package ospry
+
+import (
+	"net/url"
+)
+
+// customDomain returns the custom domain the image at urlstr is
+// served from, if any, along with the image's canonical ospry url.
+func (c *Client) customDomain(urlstr string) (string, string, error) {
+	if len(c.CustomDomains) == 0 {
+		return "", urlstr, nil
+	}
+	u, err := url.Parse(urlstr)
+	if err != nil {
+		return "", "", err
+	}
+	for custom, ospryHost := range c.CustomDomains {
+		if u.Host == custom || u.Host == ospryHost {
+			u.Host = ospryHost
+			return custom, u.String(), nil
+		}
+	}
+	return "", urlstr, nil
+}
+
+// brandURL rewrites an ospry image url to use its custom domain, if
+// it has one.
+func (c *Client) brandURL(urlstr string) (string, error) {
+	custom, _, err := c.customDomain(urlstr)
+	if err != nil || custom == "" {
+		return urlstr, err
+	}
+	u, err := url.Parse(urlstr)
+	if err != nil {
+		return "", err
+	}
+	u.Host = custom
+	return u.String(), nil
+}
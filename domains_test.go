@@ -0,0 +1,60 @@
+package ospry
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCustomDomainFormatURL(t *testing.T) {
+	c := New("sk-test-key")
+	c.CustomDomains = map[string]string{"images.mybrand.com": "mybrand.ospry.io"}
+	branded := "http://images.mybrand.com/bar/baz.png"
+	canonical := "http://mybrand.ospry.io/bar/baz.png"
+	for _, in := range []string{branded, canonical} {
+		got, err := c.FormatURL(in, &RenderOpts{MaxWidth: 100})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := branded + "?maxWidth=100"; got != want {
+			t.Fatalf("got %s, want %s", got, want)
+		}
+	}
+	exp := time.Now().Add(time.Minute)
+	signed, err := c.FormatURL(branded, &RenderOpts{TimeExpired: exp})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(signed)
+	if u.Host != "images.mybrand.com" {
+		t.Fatalf("got %s, want images.mybrand.com", u.Host)
+	}
+	if u.Query().Get("url") != canonical {
+		t.Fatalf("got %s, want %s", u.Query().Get("url"), canonical)
+	}
+	plain := New("sk-test-key")
+	want, _ := plain.FormatURL(canonical, &RenderOpts{TimeExpired: exp})
+	wu, _ := url.Parse(want)
+	if u.Query().Get("signature") != wu.Query().Get("signature") {
+		t.Fatal("signature doesn't match the canonical url's signature")
+	}
+}
+
+func TestCustomDomainMetadata(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeMetadata(w, &Metadata{ID: "foo", URL: "http://mybrand.ospry.io/foo.jpg", HTTPSURL: "https://ssl.ospry.io/foo.jpg?sub=mybrand"})
+	})
+	c.CustomDomains = map[string]string{"images.mybrand.com": "mybrand.ospry.io"}
+	md, err := c.GetMetadata("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(md.URL, "http://images.mybrand.com/") {
+		t.Fatalf("got %s, want images.mybrand.com url", md.URL)
+	}
+	if md.HTTPSURL != "https://images.mybrand.com/foo.jpg" {
+		t.Fatalf("got %s, want https://images.mybrand.com/foo.jpg", md.HTTPSURL)
+	}
+}
@@ -0,0 +1,111 @@
+package ospry
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+
+	"github.com/ospry/ospry-go/cache"
+)
+
+// cacheKey identifies a formatted url's render independently of its
+// signature, so that renders stay cached when urls are re-signed.
+func cacheKey(urlstr string) (string, error) {
+	imgURL, opts, err := ParseRenderURL(urlstr)
+	if err != nil {
+		return "", err
+	}
+	opts.TimeExpired = time.Time{}
+	q := url.Values{}
+	if err := opts.encode(q); err != nil {
+		return "", err
+	}
+	if len(q) == 0 {
+		return imgURL, nil
+	}
+	return imgURL + "?" + q.Encode(), nil
+}
+
+// maxCachedDownload bounds the downloads added to a client's cache if
+// its RetryBufferSize is zero.
+const maxCachedDownload = 32 << 20
+
+// A cachingReader adds the data read from body to a cache once body
+// has been read completely, unless it's bigger than max.
+type cachingReader struct {
+	body  io.ReadCloser
+	cache cache.Cache
+	key   string
+	max   int64
+	buf   bytes.Buffer
+	err   error
+}
+
+func (r *cachingReader) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	if r.err == nil {
+		if int64(r.buf.Len()+n) > r.max {
+			// Too big to cache; stop buffering.
+			r.buf = bytes.Buffer{}
+			r.err = errTooBigToCache
+			return n, err
+		}
+		r.buf.Write(p[:n])
+		if err == io.EOF {
+			r.cache.Set(r.key, r.buf.Bytes())
+		}
+		r.err = err
+	}
+	return n, err
+}
+
+var errTooBigToCache = errors.New("ospry: download too big to cache")
+
+func (r *cachingReader) Close() error {
+	return r.body.Close()
+}
+
+type teeReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// A DownloadTooLargeError is returned when an image is larger than the
+// client's MaxDownloadBytes.
+type DownloadTooLargeError struct {
+	URL   string
+	Limit int64
+}
+
+func (e *DownloadTooLargeError) Error() string {
+	return fmt.Sprintf("ospry: download of %s exceeds %d bytes", e.URL, e.Limit)
+}
+
+// A limitedBody fails with a *DownloadTooLargeError once more than n
+// bytes have been read from body.
+type limitedBody struct {
+	body io.ReadCloser
+	n    int64
+	url  string
+}
+
+func (r *limitedBody) Read(p []byte) (int, error) {
+	// Read one byte past the limit to tell an image of exactly n
+	// bytes from a larger one.
+	if int64(len(p)) > r.n+1 {
+		p = p[:r.n+1]
+	}
+	n, err := r.body.Read(p)
+	if int64(n) > r.n {
+		return int(r.n), &DownloadTooLargeError{URL: r.url, Limit: r.n}
+	}
+	r.n -= int64(n)
+	return n, err
+}
+
+func (r *limitedBody) Close() error {
+	return r.body.Close()
+}
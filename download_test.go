@@ -0,0 +1,120 @@
+package ospry
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ospry/ospry-go/cache"
+)
+
+func TestDownloadCache(t *testing.T) {
+	downloads := 0
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		downloads++
+		w.Write([]byte("image " + r.URL.Query().Get("maxWidth")))
+	})
+	c.Cache = cache.NewLRU(1 << 20)
+	imgURL := c.ServerURL + "/foo.jpg"
+	for i := 0; i < 2; i++ {
+		rc, err := c.Download(imgURL, &RenderOpts{MaxWidth: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != "image 10" {
+			t.Fatalf("got %q, want %q", b, "image 10")
+		}
+	}
+	if downloads != 1 {
+		t.Fatalf("got %d downloads, want 1", downloads)
+	}
+	c.RetryBufferSize = 5
+	for i := 0; i < 2; i++ {
+		rc, err := c.Download(imgURL, &RenderOpts{MaxWidth: 20})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(rc)
+		rc.Close()
+	}
+	if downloads != 3 {
+		t.Fatalf("got %d downloads, want renders over RetryBufferSize left uncached", downloads)
+	}
+	key, _ := cacheKey(imgURL + "?maxWidth=10")
+	signedKey, _ := cacheKey("https://api.ospry.io/?maxWidth=10&signature=x&timeExpired=" +
+		time.Now().Format(time.RFC3339Nano) + "&url=" + imgURL)
+	if key != signedKey {
+		t.Fatalf("got %s, want %s", signedKey, key)
+	}
+}
+
+func TestDownloadTee(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("image"))
+	})
+	var buf bytes.Buffer
+	rc, err := c.DownloadTee(c.ServerURL+"/foo.jpg", nil, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	b, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "image" || buf.String() != "image" {
+		t.Fatalf("got %q and %q, want %q", b, buf.String(), "image")
+	}
+}
+
+func TestMaxDownloadBytes(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		data := bytes.Repeat([]byte("x"), 100)
+		if r.URL.Query().Get("chunked") != "" {
+			// Flushing before writing the body keeps the server from
+			// setting Content-Length.
+			w.(http.Flusher).Flush()
+		}
+		w.Write(data)
+	})
+	c.MaxDownloadBytes = 100
+	imgURL := c.ServerURL + "/foo.jpg"
+
+	rc, err := c.Download(imgURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(rc)
+	rc.Close()
+	if err != nil || len(b) != 100 {
+		t.Fatalf("got %d bytes, %v, want 100 bytes", len(b), err)
+	}
+
+	c.MaxDownloadBytes = 99
+	if _, err := c.Download(imgURL, nil); err == nil {
+		t.Fatal("got nil error, want *DownloadTooLargeError")
+	} else if !errors.As(err, new(*DownloadTooLargeError)) {
+		t.Fatalf("got %v, want *DownloadTooLargeError", err)
+	}
+
+	rc, err = c.Download(imgURL+"?chunked=1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	b, err = ioutil.ReadAll(rc)
+	if _, ok := err.(*DownloadTooLargeError); !ok {
+		t.Fatalf("got %v, want *DownloadTooLargeError", err)
+	}
+	if len(b) != 99 {
+		t.Fatalf("got %d bytes, want 99", len(b))
+	}
+}
@@ -0,0 +1,229 @@
+package ospry
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// DefaultAging is the default for Downloader.Aging.
+const DefaultAging = 10 * time.Second
+
+// A DownloadItem is an image to be fetched by a Downloader.
+type DownloadItem struct {
+	URL  string
+	Opts *RenderOpts
+	// Dest receives the image data.
+	Dest io.Writer
+	// Priority orders the queue: items with higher priorities are
+	// downloaded first.
+	Priority int
+}
+
+// A Downloader downloads images concurrently from a priority queue.
+// Items can be added while earlier ones are being downloaded, so that
+// e.g. thumbnails needed for a page overtake a running archival job.
+//
+// A Downloader must not be copied after first use.
+type Downloader struct {
+	// Client downloads the images. If it's nil, DefaultClient is
+	// used.
+	Client *Client
+	// Concurrency is the number of downloads run at once. Zero means
+	// DefaultBatchConcurrency.
+	Concurrency int
+	// Aging raises the priority of queued items by one for each Aging
+	// they've waited, so that low priority items aren't starved by a
+	// steady stream of higher priority ones. Zero means DefaultAging.
+	Aging time.Duration
+
+	mu      sync.Mutex
+	cond    sync.Cond
+	queue   downloadQueue
+	started time.Time
+	seq     int
+	workers sync.WaitGroup
+	closed  bool
+	stopped bool
+	running map[int]string
+	done    chan struct{}
+	results []BatchResult[int64]
+}
+
+// ErrDownloaderClosed is returned by Add after Wait or Shutdown has been
+// called.
+var ErrDownloaderClosed = errors.New("ospry: downloader closed")
+
+// Add queues item for downloading.
+func (d *Downloader) Add(item *DownloadItem) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return ErrDownloaderClosed
+	}
+	if d.started.IsZero() {
+		d.start()
+	}
+	aging := d.Aging
+	if aging <= 0 {
+		aging = DefaultAging
+	}
+	// An item's effective priority is Priority + waited/aging. Items
+	// all age at the same rate, so ordering them by Priority -
+	// added/aging gives the same order at any time.
+	added := time.Since(d.started)
+	heap.Push(&d.queue, &queuedDownload{
+		item: item,
+		key:  float64(item.Priority) - float64(added)/float64(aging),
+		seq:  d.seq,
+	})
+	d.seq++
+	d.results = append(d.results, BatchResult[int64]{Item: item.URL})
+	d.cond.Signal()
+	if p := d.client().Progress; p != nil {
+		p.Expect(1, -1)
+	}
+	return nil
+}
+
+// Wait waits for all queued items to be downloaded and returns their
+// results, in the order they were added. The results' values are the
+// number of bytes downloaded. If some downloads failed, the error is a
+// *BatchError. No items can be added after Wait is called.
+func (d *Downloader) Wait() ([]BatchResult[int64], error) {
+	d.mu.Lock()
+	d.closed = true
+	d.cond.Broadcast()
+	d.mu.Unlock()
+	d.workers.Wait()
+	return d.results, batchErr(d.results)
+}
+
+// Shutdown stops accepting items and waits for the queued ones to be
+// downloaded. If ctx is done first, the queued items are dropped, with
+// ctx's error as their results' Err, and their urls returned along
+// with those of the downloads still running, which finish in the
+// background. Shutdown implements Shutdowner.
+func (d *Downloader) Shutdown(ctx context.Context) ([]string, error) {
+	d.mu.Lock()
+	d.closed = true
+	if d.done == nil {
+		d.done = make(chan struct{})
+		go func() {
+			d.workers.Wait()
+			close(d.done)
+		}()
+	}
+	d.cond.Broadcast()
+	d.mu.Unlock()
+	select {
+	case <-d.done:
+		return nil, nil
+	case <-ctx.Done():
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.stopped = true
+	var incomplete []string
+	for _, url := range d.running {
+		incomplete = append(incomplete, url)
+	}
+	for _, q := range d.queue {
+		incomplete = append(incomplete, q.item.URL)
+		d.results[q.seq].Err = ctx.Err()
+	}
+	d.queue = nil
+	return incomplete, ctx.Err()
+}
+
+// start starts the workers. d.mu must be held.
+func (d *Downloader) start() {
+	d.started = time.Now()
+	d.cond.L = &d.mu
+	n := d.Concurrency
+	if n <= 0 {
+		n = DefaultBatchConcurrency
+	}
+	d.workers.Add(n)
+	for i := 0; i < n; i++ {
+		go d.work()
+	}
+}
+
+func (d *Downloader) work() {
+	defer d.workers.Done()
+	c := d.client()
+	for {
+		d.mu.Lock()
+		for d.queue.Len() == 0 && !d.closed {
+			d.cond.Wait()
+		}
+		if d.queue.Len() == 0 || d.stopped {
+			d.mu.Unlock()
+			return
+		}
+		q := heap.Pop(&d.queue).(*queuedDownload)
+		if d.running == nil {
+			d.running = map[int]string{}
+		}
+		d.running[q.seq] = q.item.URL
+		d.mu.Unlock()
+
+		start := time.Now()
+		n, err := c.downloadTo(q.item)
+		d.mu.Lock()
+		delete(d.running, q.seq)
+		r := &d.results[q.seq]
+		r.Value, r.Err, r.Attempts, r.Duration = n, err, 1, time.Since(start)
+		d.mu.Unlock()
+	}
+}
+
+func (c *Client) downloadTo(item *DownloadItem) (int64, error) {
+	rc, err := c.Download(item.URL, item.Opts)
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+	return io.Copy(item.Dest, rc)
+}
+
+type queuedDownload struct {
+	item *DownloadItem
+	key  float64
+	seq  int
+}
+
+// downloadQueue is a max-heap of queued downloads, first in first out
+// among equal keys.
+type downloadQueue []*queuedDownload
+
+func (q downloadQueue) Len() int { return len(q) }
+
+func (q downloadQueue) Less(i, j int) bool {
+	if q[i].key != q[j].key {
+		return q[i].key > q[j].key
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q downloadQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *downloadQueue) Push(x interface{}) { *q = append(*q, x.(*queuedDownload)) }
+
+func (q *downloadQueue) Pop() interface{} {
+	old := *q
+	x := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return x
+}
+
+func (d *Downloader) client() *Client {
+	if d.Client == nil {
+		return DefaultClient
+	}
+	return d.Client
+}
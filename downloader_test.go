@@ -0,0 +1,111 @@
+package ospry
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDownloaderPriority(t *testing.T) {
+	var mu sync.Mutex
+	var order []string
+	block := make(chan struct{})
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/")
+		if name == "blocker.jpg" {
+			<-block
+		}
+		mu.Lock()
+		order = append(order, name)
+		mu.Unlock()
+		w.Write([]byte(name))
+	})
+	base := strings.TrimSuffix(c.ServerURL, "/v1") + "/"
+	d := &Downloader{Client: c, Concurrency: 1, Aging: time.Hour}
+	item := func(name string, priority int) *DownloadItem {
+		return &DownloadItem{URL: base + name, Dest: new(bytes.Buffer), Priority: priority}
+	}
+
+	// The single worker is busy with the blocker while the rest queue
+	// up.
+	blocker := item("blocker.jpg", 0)
+	d.Add(blocker)
+	time.Sleep(50 * time.Millisecond)
+	items := []*DownloadItem{item("archive1.jpg", 0), item("thumb1.jpg", 10), item("archive2.jpg", 0), item("thumb2.jpg", 10), item("medium.jpg", 5)}
+	for _, it := range items {
+		if err := d.Add(it); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(block)
+	results, err := d.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 6 || results[2].Item != base+"thumb1.jpg" || results[2].Value != int64(len("thumb1.jpg")) {
+		t.Fatalf("got %+v, want results in the order items were added", results)
+	}
+	got := strings.Join(order, ",")
+	want := "blocker.jpg,thumb1.jpg,thumb2.jpg,medium.jpg,archive1.jpg,archive2.jpg"
+	if got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if s := items[1].Dest.(*bytes.Buffer).String(); s != "thumb1.jpg" {
+		t.Fatalf("got %q, want %q", s, "thumb1.jpg")
+	}
+	if err := d.Add(item("late.jpg", 0)); err != ErrDownloaderClosed {
+		t.Fatalf("got %v, want ErrDownloaderClosed", err)
+	}
+}
+
+func TestDownloaderShutdown(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		<-block
+	})
+	base := strings.TrimSuffix(c.ServerURL, "/v1") + "/"
+	d := &Downloader{Client: c, Concurrency: 1}
+	d.Add(&DownloadItem{URL: base + "running.jpg", Dest: new(bytes.Buffer)})
+	time.Sleep(50 * time.Millisecond)
+	d.Add(&DownloadItem{URL: base + "queued.jpg", Dest: new(bytes.Buffer)})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	incomplete, err := d.Shutdown(ctx)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("got %v, want context.DeadlineExceeded", err)
+	}
+	got := strings.Join(incomplete, ",")
+	want := base + "running.jpg," + base + "queued.jpg"
+	if got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	d.mu.Lock()
+	qerr := d.results[1].Err
+	d.mu.Unlock()
+	if qerr != context.DeadlineExceeded {
+		t.Fatalf("got %v for the dropped item, want context.DeadlineExceeded", qerr)
+	}
+	if err := d.Add(&DownloadItem{URL: base + "late.jpg"}); err != ErrDownloaderClosed {
+		t.Fatalf("got %v, want ErrDownloaderClosed", err)
+	}
+}
+
+func TestDownloaderAging(t *testing.T) {
+	// Setting started keeps the downloader from starting workers.
+	d := &Downloader{Aging: time.Millisecond}
+	d.started = time.Now().Add(-time.Second)
+	// An item queued 5 aging periods earlier beats one with a priority
+	// 4 higher.
+	d.Add(&DownloadItem{URL: "old", Priority: 0})
+	d.started = d.started.Add(-5 * time.Millisecond)
+	d.Add(&DownloadItem{URL: "new", Priority: 4})
+	if first := d.queue[0].item.URL; first != "old" {
+		t.Fatalf("got %s first, want old", first)
+	}
+}
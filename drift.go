@@ -0,0 +1,99 @@
+package ospry
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// A Warning reports an api response that breaks the invariants the
+// package relies on, e.g. metadata without an id, which suggests the
+// api changed in a way the package doesn't know about yet (see
+// Client.Warn).
+type Warning struct {
+	// URL is the url requested, without credentials or signature.
+	URL    string
+	Status int
+	// ImageID is the id of the image the response describes, if
+	// known.
+	ImageID string
+	Problem string
+}
+
+func (w *Warning) String() string {
+	s := fmt.Sprintf("ospry: unexpected api response (%d %s)", w.Status, w.URL)
+	if w.ImageID != "" {
+		s += " for image " + w.ImageID
+	}
+	return s + ": " + w.Problem
+}
+
+// warn reports a problem with a response to the client's Warn hook.
+func (c *Client) warn(res *http.Response, id, problem string, args ...interface{}) {
+	w := &Warning{Status: res.StatusCode, ImageID: id, Problem: fmt.Sprintf(problem, args...)}
+	if res.Request != nil {
+		w.URL = redactURL(res.Request.URL.String())
+	}
+	c.Warn(w)
+}
+
+// checkShape checks that a metadata response has the shape its status
+// calls for. m and err are the response's parsed metadata and error.
+func (c *Client) checkShape(res *http.Response, m *Metadata, err error) {
+	if c.Warn == nil {
+		return
+	}
+	ok := res.StatusCode >= 200 && res.StatusCode < 300
+	var e *Error
+	isAPIErr := errors.As(err, &e)
+	switch {
+	case ok && isAPIErr:
+		c.warn(res, "", "error in a successful response: %s", e.Message)
+	case !ok && err == nil:
+		c.warn(res, "", "error status without an error")
+	case ok && err == nil && m == nil:
+		c.warn(res, "", "neither metadata nor an error")
+	case isAPIErr && e.HTTPStatusCode != 0 && e.HTTPStatusCode != res.StatusCode:
+		c.warn(res, "", "error status %d doesn't match the response's", e.HTTPStatusCode)
+	}
+	if m != nil {
+		c.checkInvariants(res, m)
+	}
+}
+
+// checkInvariants checks the fields every image's metadata has. Only
+// the fields asked for are checked in responses restricted to some
+// fields (see GetMetadataFields).
+func (c *Client) checkInvariants(res *http.Response, m *Metadata) {
+	if c.Warn == nil {
+		return
+	}
+	fields := requestedFields(res)
+	if fields["id"] && m.ID == "" {
+		c.warn(res, "", "metadata without an id")
+	}
+	if fields["url"] && m.URL == "" {
+		c.warn(res, m.ID, "metadata without a url")
+	}
+	if fields["timeCreated"] && m.TimeCreated.IsZero() {
+		c.warn(res, m.ID, "metadata without a creation time")
+	}
+}
+
+// requestedFields returns the set of metadata fields the request of
+// res asked for: all of them unless it was restricted with fields=.
+func requestedFields(res *http.Response) map[string]bool {
+	if res.Request == nil {
+		return metadataFields
+	}
+	list := res.Request.URL.Query().Get("fields")
+	if list == "" {
+		return metadataFields
+	}
+	fields := map[string]bool{}
+	for _, f := range strings.Split(list, ",") {
+		fields[f] = true
+	}
+	return fields
+}
@@ -0,0 +1,57 @@
+package ospry
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestWarn(t *testing.T) {
+	var status int
+	var body string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	})
+	var warnings []string
+	c.Warn = func(w *Warning) { warnings = append(warnings, w.Problem) }
+	tests := []struct {
+		status int
+		body   string
+		want   string
+	}{
+		{200, `{"metadata":{"id":"a","url":"http://foo.ospry.io/a.jpg","timeCreated":"2015-01-01T00:00:00Z"}}`, ""},
+		{200, `{"metadata":{"id":"a"}}`, "metadata without a url; metadata without a creation time"},
+		{200, `{"error":{"httpStatusCode":404,"message":"not found"}}`, "error in a successful response: not found"},
+		{502, `{}`, "error status without an error"},
+		{404, `{"error":{"httpStatusCode":500,"message":"oops"}}`, "error status 500 doesn't match the response's"},
+		{200, `{}`, "neither metadata nor an error"},
+	}
+	for _, test := range tests {
+		status, body, warnings = test.status, test.body, nil
+		c.GetMetadata("a")
+		if got := strings.Join(warnings, "; "); got != test.want {
+			t.Fatalf("%d %s: got %s, want %s", test.status, test.body, got, test.want)
+		}
+	}
+
+	// Responses restricted to some fields only need those.
+	status, body, warnings = 200, `{"metadata":{"id":"a","isPrivate":true}}`, nil
+	if _, err := c.GetMetadataFields("a", "id", "isPrivate"); err != nil {
+		t.Fatal(err)
+	}
+	status, body = 200, `{"images":[{"id":"a"}]}`
+	if _, err := c.List(&ListFilter{Fields: []string{"id"}}, ""); err != nil {
+		t.Fatal(err)
+	}
+	status, body = 200, `{"metadata":{"isPrivate":true}}`
+	c.GetMetadataFields("a", "id", "isPrivate")
+	if got, want := strings.Join(warnings, "; "), "metadata without an id"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+
+	w := &Warning{URL: "https://api.ospry.io/v1/images/a", Status: 200, ImageID: "a", Problem: "metadata without a url"}
+	if got, want := w.String(), "ospry: unexpected api response (200 https://api.ospry.io/v1/images/a) for image a: metadata without a url"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
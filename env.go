@@ -0,0 +1,113 @@
+package ospry
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// An Environment identifies which ospry environment a client talks
+// to.
+type Environment string
+
+const (
+	// Live is the production environment. Live keys start with
+	// "sk-live-" or "pk-live-".
+	Live Environment = "live"
+	// Sandbox is the test environment. Sandbox keys start with
+	// "sk-test-" or "pk-test-".
+	Sandbox Environment = "sandbox"
+	// Custom is any server other than the default api server (see
+	// Client.ServerURL) used with a key of neither environment.
+	Custom Environment = "custom"
+)
+
+var (
+	// ErrLiveNotConfirmed is returned by destructive batch operations
+	// against the Live environment when the client's ConfirmLive
+	// field isn't set.
+	ErrLiveNotConfirmed = errors.New("ospry: destructive batch operation against live environment not confirmed")
+	// ErrEnvironmentMismatch is returned when a client's Environment
+	// was set explicitly and its key belongs to a different one.
+	ErrEnvironmentMismatch = errors.New("ospry: key doesn't belong to the client's environment")
+)
+
+// DeleteMany calls DeleteMany on the default client.
+func DeleteMany(ids []string) ([]BatchResult[struct{}], error) {
+	return DefaultClient.DeleteMany(ids)
+}
+
+// Env returns the environment the client talks to. If the client's
+// Environment field is empty, it's inferred from the key prefix, so
+// that live keys stay Live behind a proxy, and failing that from
+// ServerURL. Keys with an unrecognized prefix are treated as Live on
+// the default api server.
+func (c *Client) Env() Environment {
+	if c.Environment != "" {
+		return c.Environment
+	}
+	key, _ := c.currentKey()
+	if env := keyEnv(key); env != "" {
+		return env
+	}
+	if c.ServerURL != defaultServerURL {
+		return Custom
+	}
+	return Live
+}
+
+// DeleteMany deletes the images with the given ids, returning a
+// result for each; if some deletes failed, the error is a
+// *BatchError. It refuses to run against the Live environment unless
+// the client's ConfirmLive field is set, so that cleanup scripts run
+// with the wrong key don't wipe out production images.
+func (c *Client) DeleteMany(ids []string) ([]BatchResult[struct{}], error) {
+	if err := c.confirmDestructive(); err != nil {
+		return nil, err
+	}
+	results := make([]BatchResult[struct{}], len(ids))
+	for i, id := range ids {
+		start := time.Now()
+		err := c.Delete(id)
+		results[i] = BatchResult[struct{}]{Item: id, Err: err, Attempts: 1, Duration: time.Since(start)}
+	}
+	return results, batchErr(results)
+}
+
+// confirmDestructive guards operations that remove or modify many
+// images at once.
+func (c *Client) confirmDestructive() error {
+	if err := c.checkEnv(); err != nil {
+		return err
+	}
+	if c.Env() == Live && !c.ConfirmLive {
+		return ErrLiveNotConfirmed
+	}
+	return nil
+}
+
+// checkEnv verifies that the client's key matches an explicitly set
+// Environment.
+func (c *Client) checkEnv() error {
+	if c.Environment != Live && c.Environment != Sandbox {
+		return nil
+	}
+	key, err := c.currentKey()
+	if err != nil {
+		return err
+	}
+	if env := keyEnv(key); env != "" && env != c.Environment {
+		return ErrEnvironmentMismatch
+	}
+	return nil
+}
+
+func keyEnv(key string) Environment {
+	switch {
+	case strings.HasPrefix(key, "sk-live-"), strings.HasPrefix(key, "pk-live-"):
+		return Live
+	case strings.HasPrefix(key, "sk-test-"), strings.HasPrefix(key, "pk-test-"):
+		return Sandbox
+	}
+	return ""
+}
@@ -0,0 +1,91 @@
+package ospry
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestEnv(t *testing.T) {
+	tests := []struct {
+		key       string
+		serverURL string
+		env       Environment
+		want      Environment
+	}{
+		{"sk-test-abc", defaultServerURL, "", Sandbox},
+		{"pk-test-abc", defaultServerURL, "", Sandbox},
+		{"sk-live-abc", defaultServerURL, "", Live},
+		{"abc", defaultServerURL, "", Live},
+		{"sk-live-abc", "http://localhost:8080/v1", "", Live},
+		{"sk-test-abc", "http://localhost:8080/v1", "", Sandbox},
+		{"abc", "http://localhost:8080/v1", "", Custom},
+		{"sk-live-abc", defaultServerURL, Sandbox, Sandbox},
+	}
+	for _, test := range tests {
+		c := New(test.key)
+		c.ServerURL = test.serverURL
+		c.Environment = test.env
+		if got := c.Env(); got != test.want {
+			t.Fatalf("%s: got %s, want %s", test.key, got, test.want)
+		}
+	}
+}
+
+func TestDeleteManyLiveGuard(t *testing.T) {
+	c := New("sk-live-abc")
+	if _, err := c.DeleteMany([]string{"foo"}); err != ErrLiveNotConfirmed {
+		t.Fatalf("got %v, want %v", err, ErrLiveNotConfirmed)
+	}
+	c = New("sk-live-abc")
+	c.Environment = Sandbox
+	if _, err := c.DeleteMany([]string{"foo"}); err != ErrEnvironmentMismatch {
+		t.Fatalf("got %v, want %v", err, ErrEnvironmentMismatch)
+	}
+}
+
+func TestDeleteMany(t *testing.T) {
+	var deleted, methods []string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		id := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		deleted = append(deleted, id)
+		writeMetadata(w, &Metadata{ID: id})
+	})
+	results, err := c.DeleteMany([]string{"a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(methods, ",") != "DELETE,DELETE" {
+		t.Fatalf("got methods %v, want DELETEs", methods)
+	}
+	if strings.Join(deleted, ",") != "a,b" {
+		t.Fatalf("got %v, want [a b]", deleted)
+	}
+	if len(results) != 2 || results[1].Item != "b" || results[1].Attempts != 1 {
+		t.Fatalf("got %+v, want a result per id", results)
+	}
+}
+
+func TestDeleteManyPartialFailure(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/missing") {
+			w.WriteHeader(http.StatusNotFound)
+			writeError(w, &Error{HTTPStatusCode: 404, Message: "not found"})
+			return
+		}
+		writeMetadata(w, &Metadata{})
+	})
+	results, err := c.DeleteMany([]string{"a", "missing", "b"})
+	be, ok := err.(*BatchError)
+	if !ok {
+		t.Fatalf("got %v, want *BatchError", err)
+	}
+	if be.Total != 3 || len(be.Errs) != 1 || !errors.Is(err, be.Errs[0]) {
+		t.Fatalf("got %v, want 1 of 3 failed", err)
+	}
+	if len(results) != 3 || results[0].Err != nil || results[1].Err == nil || results[2].Err != nil {
+		t.Fatalf("got %+v, want only the second delete to fail", results)
+	}
+}
@@ -0,0 +1,115 @@
+package ospry
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// metadataFields is the set of json names of Metadata's fields.
+var metadataFields = jsonFields(reflect.TypeOf(Metadata{}))
+
+func jsonFields(t reflect.Type) map[string]bool {
+	fields := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if f.PkgPath != "" || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = true
+	}
+	return fields
+}
+
+// metadata has Metadata's fields but not its methods, so that it can
+// be (un)marshaled without recursing.
+type metadata Metadata
+
+// UnmarshalJSON implements json.Unmarshaler, keeping unknown fields in
+// m.Extra.
+func (m *Metadata) UnmarshalJSON(b []byte) error {
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(b, &all); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, (*metadata)(m)); err != nil {
+		return err
+	}
+	m.Extra = nil
+	for k, v := range all {
+		if metadataFields[k] || isFoldedField(k) {
+			continue
+		}
+		if m.Extra == nil {
+			m.Extra = make(map[string]json.RawMessage)
+		}
+		m.Extra[k] = v
+	}
+	return nil
+}
+
+// isFoldedField reports whether k matches a Metadata field
+// case-insensitively, the way encoding/json does.
+func isFoldedField(k string) bool {
+	for f := range metadataFields {
+		if strings.EqualFold(f, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// MarshalJSON implements json.Marshaler, including m.Extra's fields.
+func (m Metadata) MarshalJSON() ([]byte, error) {
+	b, err := json.Marshal(metadata(m))
+	if err != nil || len(m.Extra) == 0 {
+		return b, err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(b, &all); err != nil {
+		return nil, err
+	}
+	for k, v := range m.Extra {
+		if _, ok := all[k]; !ok {
+			all[k] = v
+		}
+	}
+	return json.Marshal(all)
+}
+
+// An UnknownFieldsError is returned in strict decoding mode (see
+// Client.StrictDecoding) when the api sends metadata fields the
+// package doesn't know.
+type UnknownFieldsError struct {
+	ID     string
+	Fields []string
+}
+
+func (e *UnknownFieldsError) Error() string {
+	return "ospry: unknown metadata fields in image " + e.ID + ": " + strings.Join(e.Fields, ", ")
+}
+
+// checkFields reports the unknown fields of m, decoded from an api
+// response, and fails in strict decoding mode.
+func (c *Client) checkFields(m *Metadata) error {
+	if len(m.Extra) == 0 || (c.UnknownFields == nil && !c.StrictDecoding) {
+		return nil
+	}
+	fields := make([]string, 0, len(m.Extra))
+	for k := range m.Extra {
+		fields = append(fields, k)
+	}
+	sort.Strings(fields)
+	if c.UnknownFields != nil {
+		c.UnknownFields(fields)
+	}
+	if c.StrictDecoding {
+		return &UnknownFieldsError{ID: m.ID, Fields: fields}
+	}
+	return nil
+}
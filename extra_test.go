@@ -0,0 +1,88 @@
+package ospry
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMetadataExtra(t *testing.T) {
+	const body = `{"id":"foo","format":"jpeg","height":10,"blurHash":"LKO2?U%2Tw=w","exif":{"iso":100}}`
+	var m Metadata
+	if err := json.Unmarshal([]byte(body), &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.ID != "foo" || m.Format != "jpeg" || m.Height != 10 {
+		t.Fatalf("got %+v, want known fields decoded", m)
+	}
+	if len(m.Extra) != 2 || string(m.Extra["blurHash"]) != `"LKO2?U%2Tw=w"` || string(m.Extra["exif"]) != `{"iso":100}` {
+		t.Fatalf("got extra %s, want blurHash and exif", m.Extra)
+	}
+
+	b, err := json.Marshal(&m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got, want map[string]interface{}
+	json.Unmarshal(b, &got)
+	json.Unmarshal([]byte(body), &want)
+	for k, v := range want {
+		if gb, _ := json.Marshal(got[k]); string(gb) != mustMarshal(v) {
+			t.Fatalf("%s: got %s, want %s", k, gb, mustMarshal(v))
+		}
+	}
+
+	// Known fields aren't overridden by stale extras.
+	m.Extra["id"] = json.RawMessage(`"bar"`)
+	b, _ = json.Marshal(m)
+	var m2 Metadata
+	json.Unmarshal(b, &m2)
+	if m2.ID != "foo" {
+		t.Fatalf("got %s, want foo", m2.ID)
+	}
+}
+
+func mustMarshal(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return string(b)
+}
+
+func TestGetMetadataExtra(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"metadata":{"id":"foo","dominantColor":"#ff0000"}}`))
+	})
+	m, err := c.GetMetadata("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(m.Extra["dominantColor"]), `"#ff0000"`; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestStrictDecoding(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"metadata":{"id":"foo","dominantColor":"#ff0000","blurHash":"L"}}`))
+	})
+	var reported []string
+	c.UnknownFields = func(fields []string) { reported = fields }
+	if _, err := c.GetMetadata("foo"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(reported, ","), "blurHash,dominantColor"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+
+	c.StrictDecoding = true
+	_, err := c.GetMetadata("foo")
+	var e *UnknownFieldsError
+	if !errors.As(err, &e) || e.ID != "foo" || len(e.Fields) != 2 {
+		t.Fatalf("got %v, want *UnknownFieldsError", err)
+	}
+}
@@ -0,0 +1,69 @@
+package ospry
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// GetMetadataFields calls GetMetadataFields on the default client.
+func GetMetadataFields(id string, fields ...string) (*Metadata, error) {
+	return DefaultClient.GetMetadataFields(id, fields...)
+}
+
+// GetExtendedMetadata calls GetExtendedMetadata on the default client.
+func GetExtendedMetadata(id string) (*Metadata, error) {
+	return DefaultClient.GetExtendedMetadata(id)
+}
+
+// GetExtendedMetadata is like GetMetadata, but also asks the api for
+// the extended metadata fields (MIMEType, ColorSpace and so on), which
+// take the api longer to look up. It bypasses the MetadataCache.
+func (c *Client) GetExtendedMetadata(id string) (*Metadata, error) {
+	u, err := url.Parse(c.ServerURL)
+	if err != nil {
+		return nil, opError("ospry.GetExtendedMetadata", id, "", err)
+	}
+	u.Path += "/images/" + id
+	u.RawQuery = url.Values{"extended": {"true"}}.Encode()
+	m, err := c.getMetadata(u.String())
+	if err != nil {
+		return nil, opError("ospry.GetExtendedMetadata", id, u.String(), err)
+	}
+	return m, nil
+}
+
+// GetMetadataFields is like GetMetadata, but asks the api for only the
+// given fields, named as in Metadata's json encoding (e.g. "id",
+// "url", "isPrivate"). The other fields of the returned metadata are
+// left zero. No fields means all of them.
+func (c *Client) GetMetadataFields(id string, fields ...string) (*Metadata, error) {
+	if len(fields) == 0 {
+		return c.GetMetadata(id)
+	}
+	if err := checkFieldNames(fields); err != nil {
+		return nil, opError("ospry.GetMetadataFields", id, "", err)
+	}
+	u, err := url.Parse(c.ServerURL)
+	if err != nil {
+		return nil, opError("ospry.GetMetadataFields", id, "", err)
+	}
+	u.Path += "/images/" + id
+	u.RawQuery = url.Values{"fields": {strings.Join(fields, ",")}}.Encode()
+	m, err := c.getMetadata(u.String())
+	if err != nil {
+		return nil, opError("ospry.GetMetadataFields", id, u.String(), err)
+	}
+	return m, nil
+}
+
+// checkFieldNames checks that fields are json names of Metadata's
+// fields.
+func checkFieldNames(fields []string) error {
+	for _, f := range fields {
+		if !metadataFields[f] {
+			return errors.New("ospry: unknown metadata field " + f)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package ospry
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestGetMetadataFields(t *testing.T) {
+	var fields string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		fields = r.URL.Query().Get("fields")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"metadata":{"id":"foo","isPrivate":true}}`))
+	})
+	m, err := c.GetMetadataFields("foo", "id", "isPrivate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fields != "id,isPrivate" {
+		t.Fatalf("got %s, want %s", fields, "id,isPrivate")
+	}
+	if m.ID != "foo" || !m.IsPrivate || m.URL != "" {
+		t.Fatalf("got %+v, want sparse metadata", m)
+	}
+
+	if _, err := c.GetMetadata("foo"); err != nil {
+		t.Fatal(err)
+	}
+	if fields != "" {
+		t.Fatalf("got fields %s, want none", fields)
+	}
+
+	_, err = c.GetMetadataFields("foo", "id", "bogus")
+	var oe *OpError
+	if !errors.As(err, &oe) || oe.Op != "ospry.GetMetadataFields" || oe.ID != "foo" {
+		t.Fatalf("got %v, want an *OpError for the unknown field", err)
+	}
+}
+
+func TestGetExtendedMetadata(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("extended") != "true" {
+			t.Errorf("got %s, want extended metadata asked for", r.URL)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"metadata":{"id":"foo","url":"http://foo.ospry.io/a.gif","mimeType":"image/gif","colorSpace":"srgb","bitDepth":8,"orientation":1,"isAnimated":true,"hasExif":false,"hasIccProfile":true}}`))
+	})
+	m, err := c.GetExtendedMetadata("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.MIMEType != "image/gif" || m.ColorSpace != "srgb" || m.BitDepth != 8 || m.Orientation != 1 || !m.IsAnimated || m.HasEXIF || !m.HasICCProfile {
+		t.Fatalf("got %+v, want the extended fields", m)
+	}
+	if len(m.Extra) != 0 {
+		t.Fatalf("got extra fields %v, want none", m.Extra)
+	}
+}
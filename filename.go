@@ -0,0 +1,51 @@
+package ospry
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// contentDisposition returns a Content-Disposition header value for a
+// file with the given (UTF-8) name, with an ASCII fallback filename
+// and the full name encoded as described in RFC 5987.
+func contentDisposition(filename string) string {
+	var fallback strings.Builder
+	for _, r := range filename {
+		if r >= utf8.RuneSelf || r < ' ' || r == '"' || r == '\\' || r == 0x7f {
+			fallback.WriteByte('_')
+		} else {
+			fallback.WriteRune(r)
+		}
+	}
+	v := `attachment; filename="` + fallback.String() + `"`
+	if fallback.String() != filename {
+		v += "; filename*=UTF-8''" + rfc5987Escape(filename)
+	}
+	return v
+}
+
+// rfc5987Escape percent-encodes every byte of s that isn't an
+// attr-char (RFC 5987 section 3.2.1).
+func rfc5987Escape(s string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if isAttrChar(c) {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&15])
+	}
+	return b.String()
+}
+
+func isAttrChar(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", c) >= 0
+}
@@ -0,0 +1,36 @@
+package ospry
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestUploadInternationalFilenames(t *testing.T) {
+	var disposition string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		disposition = r.Header.Get("Content-Disposition")
+		writeMetadata(w, &Metadata{ID: "foo", Filename: r.URL.Query().Get("filename")})
+	})
+	tests := []struct {
+		filename, want, disposition string
+	}{
+		{"foo.jpg", "foo.jpg", `attachment; filename="foo.jpg"`},
+		{"föö.jpg", "föö.jpg", `attachment; filename="f__.jpg"; filename*=UTF-8''f%C3%B6%C3%B6.jpg`},
+		{"写真.png", "写真.png", `attachment; filename="__.png"; filename*=UTF-8''%E5%86%99%E7%9C%9F.png`},
+		{"🐈 cat.gif", "🐈 cat.gif", `attachment; filename="_ cat.gif"; filename*=UTF-8''%F0%9F%90%88%20cat.gif`},
+		{"bad\xffname.jpg", "bad�name.jpg", `attachment; filename="bad_name.jpg"; filename*=UTF-8''bad%EF%BF%BDname.jpg`},
+	}
+	for _, test := range tests {
+		md, err := c.UploadPublic(test.filename, strings.NewReader("data"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if md.Filename != test.want {
+			t.Fatalf("got %q, want %q", md.Filename, test.want)
+		}
+		if disposition != test.disposition {
+			t.Fatalf("got %s, want %s", disposition, test.disposition)
+		}
+	}
+}
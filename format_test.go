@@ -0,0 +1,234 @@
+package ospry
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestFormatURLRenderHost(t *testing.T) {
+	c := New("sk-test-key")
+	imgURL := "http://foo.ospry.io/bar/baz.png"
+	exp := time.Now().Add(time.Minute)
+	signed, err := c.FormatURL(imgURL, &RenderOpts{TimeExpired: exp})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.RenderHost = "cdn.example.com"
+	cdnSigned, err := c.FormatURL(imgURL, &RenderOpts{TimeExpired: exp})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(signed)
+	cu, _ := url.Parse(cdnSigned)
+	if cu.Host != "cdn.example.com" {
+		t.Fatalf("got %s, want cdn.example.com", cu.Host)
+	}
+	if cu.RawQuery != u.RawQuery {
+		t.Fatalf("got %s, want %s", cu.RawQuery, u.RawQuery)
+	}
+	override, err := c.FormatURL(imgURL, &RenderOpts{RenderHost: "img.example.com", MaxWidth: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "https://img.example.com/?maxWidth=10&url=" + url.QueryEscape(imgURL)
+	if override != want {
+		t.Fatalf("got %s, want %s", override, want)
+	}
+}
+
+func TestFormatURLDownload(t *testing.T) {
+	c := New("sk-test-key")
+	imgURL := "http://foo.ospry.io/bar/baz.png"
+	got, err := c.FormatURL(imgURL, &RenderOpts{Download: "cat photo.png"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := imgURL + "?download=cat+photo.png"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	_, opts, err := ParseRenderURL(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Download != "cat photo.png" {
+		t.Fatalf("got %s, want cat photo.png", opts.Download)
+	}
+	if _, err := c.FormatURL(imgURL, &RenderOpts{Download: "../etc/passwd"}); err == nil {
+		t.Fatal("got nil, want error for a path")
+	}
+}
+
+func TestFormatURLCacheTTL(t *testing.T) {
+	c := New("sk-test-key")
+	imgURL := "http://foo.ospry.io/bar/baz.png"
+	for _, test := range []struct {
+		ttl  time.Duration
+		want string
+	}{
+		{time.Hour, "3600"},
+		{time.Millisecond, "1"},
+		{-1, "0"},
+	} {
+		got, err := c.FormatURL(imgURL, &RenderOpts{CacheTTL: test.ttl})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := imgURL + "?cacheTTL=" + test.want; got != want {
+			t.Fatalf("got %s, want %s", got, want)
+		}
+		if _, opts, _ := ParseRenderURL(got); (opts.CacheTTL > 0) != (test.ttl > 0) {
+			t.Fatalf("got ttl %v from %s, want one like %v", opts.CacheTTL, got, test.ttl)
+		}
+	}
+}
+
+func TestFormatURLICCProfile(t *testing.T) {
+	c := New("sk-test-key")
+	imgURL := "http://foo.ospry.io/bar/baz.png"
+	got, err := c.FormatURL(imgURL, &RenderOpts{Format: "jpeg", ICCProfile: ICCPreserve})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := imgURL + "?format=jpeg&icc=preserve"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if _, opts, _ := ParseRenderURL(got); opts.ICCProfile != ICCPreserve {
+		t.Fatalf("got %q from %s, want %q", opts.ICCProfile, got, ICCPreserve)
+	}
+	if _, err := c.FormatURL(imgURL, &RenderOpts{ICCProfile: "convert"}); err == nil {
+		t.Fatal("got no error, want invalid ICC profile handling")
+	}
+}
+
+func TestFormatURLProgressive(t *testing.T) {
+	c := New("sk-test-key")
+	imgURL := "http://foo.ospry.io/bar/baz.png"
+	got, err := c.FormatURL(imgURL, &RenderOpts{Format: "jpeg", Progressive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := imgURL + "?format=jpeg&progressive=true"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if _, opts, _ := ParseRenderURL(got); !opts.Progressive || opts.Interlaced {
+		t.Fatalf("got %+v from %s, want progressive", opts, got)
+	}
+	if _, err := c.FormatURL(imgURL, &RenderOpts{Format: "png", Progressive: true}); err == nil {
+		t.Fatal("got no error, want progressive png rejected")
+	}
+	if _, err := c.FormatURL(imgURL, &RenderOpts{Format: "webp", Interlaced: true}); err == nil {
+		t.Fatal("got no error, want interlaced webp rejected")
+	}
+	if _, err := c.FormatURL(imgURL, &RenderOpts{Interlaced: true}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFormatURLCompression(t *testing.T) {
+	c := New("sk-test-key")
+	imgURL := "http://foo.ospry.io/bar/baz.png"
+	got, err := c.FormatURL(imgURL, &RenderOpts{Format: "webp", Lossless: true, CompressionLevel: 6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := imgURL + "?compression=6&format=webp&lossless=true"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if _, opts, _ := ParseRenderURL(got); !opts.Lossless || opts.CompressionLevel != 6 {
+		t.Fatalf("got %+v from %s, want lossless at level 6", opts, got)
+	}
+	for _, opts := range []*RenderOpts{
+		{Format: "jpeg", Lossless: true},
+		{Format: "webp", Lossless: true, Quality: 80},
+		{Format: "webp", CompressionLevel: 7},
+		{Format: "png", CompressionLevel: 10},
+		{CompressionLevel: 3},
+	} {
+		if _, err := c.FormatURL(imgURL, opts); err == nil {
+			t.Fatalf("got no error for %+v, want it rejected", opts)
+		}
+	}
+}
+
+func TestFormatURLBackground(t *testing.T) {
+	c := New("sk-test-key")
+	imgURL := "http://foo.ospry.io/bar/logo.png"
+	for _, bg := range []string{"#FFAA00", "fa0", "ffaa00"} {
+		got, err := c.FormatURL(imgURL, &RenderOpts{Format: "jpeg", Background: bg})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := imgURL + "?bg=ffaa00&format=jpeg"; got != want {
+			t.Fatalf("got %s, want %s", got, want)
+		}
+	}
+	for _, bg := range []string{"white", "#ffaa0", "#ggaa00"} {
+		if _, err := c.FormatURL(imgURL, &RenderOpts{Background: bg}); err == nil {
+			t.Fatalf("got no error for %s, want it rejected", bg)
+		}
+	}
+}
+
+func TestFormatURLTrim(t *testing.T) {
+	c := New("sk-test-key")
+	imgURL := "http://foo.ospry.io/bar/shot.png"
+	got, err := c.FormatURL(imgURL, &RenderOpts{Trim: true, TrimThreshold: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := imgURL + "?trim=true&trimThreshold=5"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if _, opts, _ := ParseRenderURL(got); !opts.Trim || opts.TrimThreshold != 5 {
+		t.Fatalf("got %+v from %s, want trim at 5", opts, got)
+	}
+	if _, err := c.FormatURL(imgURL, &RenderOpts{TrimThreshold: 5}); err == nil {
+		t.Fatal("got no error, want TrimThreshold without Trim rejected")
+	}
+	if _, err := c.FormatURL(imgURL, &RenderOpts{Trim: true, TrimThreshold: 101}); err == nil {
+		t.Fatal("got no error, want TrimThreshold 101 rejected")
+	}
+}
+
+func TestFormatURLPad(t *testing.T) {
+	c := New("sk-test-key")
+	imgURL := "http://foo.ospry.io/bar/product.png"
+	got, err := c.FormatURL(imgURL, &RenderOpts{MaxWidth: 300, MaxHeight: 300, Fit: FitPad, Background: "fff"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := imgURL + "?bg=ffffff&fit=pad&maxHeight=300&maxWidth=300"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if _, err := c.FormatURL(imgURL, &RenderOpts{MaxWidth: 300, Fit: FitPad}); err == nil {
+		t.Fatal("got no error, want padding without MaxHeight rejected")
+	}
+}
+
+func TestFormatURLAspectRatio(t *testing.T) {
+	c := New("sk-test-key")
+	imgURL := "http://foo.ospry.io/bar/hero.jpg"
+	got, err := c.FormatURL(imgURL, &RenderOpts{MaxWidth: 1600, Fit: FitCrop, AspectRatio: "16:9", Gravity: GravityNorth})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := imgURL + "?ar=16%3A9&fit=crop&gravity=north&maxWidth=1600"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if _, opts, _ := ParseRenderURL(got); opts.AspectRatio != "16:9" || opts.Gravity != GravityNorth {
+		t.Fatalf("got %+v from %s, want 16:9 north", opts, got)
+	}
+	for _, opts := range []*RenderOpts{
+		{AspectRatio: "16:9"},
+		{Fit: FitCrop, AspectRatio: "16x9"},
+		{Fit: FitCrop, AspectRatio: "0:9"},
+		{Fit: FitPad, AspectRatio: "1:1", MaxWidth: 10, MaxHeight: 10},
+		{Fit: FitPad, AspectRatio: "1:1", Gravity: GravityNorth},
+		{Fit: FitCrop, AspectRatio: "1:1", Gravity: "up"},
+	} {
+		if _, err := c.FormatURL(imgURL, opts); err == nil {
+			t.Fatalf("got no error for %+v, want it rejected", opts)
+		}
+	}
+}
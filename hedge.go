@@ -0,0 +1,124 @@
+package ospry
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+const (
+	// hedgeSamples is the number of recent latencies the hedging
+	// delay is computed from, and hedgeMinSamples the number needed
+	// before they replace HedgeAfter.
+	hedgeSamples    = 100
+	hedgeMinSamples = 20
+	// hedgeFraction is the share of reads that may be hedged.
+	hedgeFraction = 0.1
+)
+
+// hedgeStats tracks metadata read latencies and how many reads were
+// hedged. It's guarded by the client's mu.
+type hedgeStats struct {
+	latencies []time.Duration
+	next      int
+	reads     int
+	hedges    int
+}
+
+// getMetadata retrieves the metadata at urlstr, hedging the request if
+// the client has a HedgeAfter.
+func (c *Client) getMetadata(urlstr string) (*Metadata, error) {
+	if c.HedgeAfter <= 0 {
+		return c.getMetadataOnce(context.Background(), urlstr)
+	}
+	c.mu.Lock()
+	c.hedging.reads++
+	c.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	type result struct {
+		m   *Metadata
+		err error
+	}
+	results := make(chan result, 2)
+	attempt := func() {
+		start := time.Now()
+		m, err := c.getMetadataOnce(ctx, urlstr)
+		if err == nil {
+			c.recordLatency(time.Since(start))
+		}
+		results <- result{m, err}
+	}
+	go attempt()
+	timer := time.NewTimer(c.hedgeDelay())
+	defer timer.Stop()
+	select {
+	case r := <-results:
+		return r.m, r.err
+	case <-timer.C:
+	}
+	if !c.allowHedge() {
+		r := <-results
+		return r.m, r.err
+	}
+	go attempt()
+	r := <-results
+	if r.err != nil {
+		// The other attempt may still succeed.
+		if r2 := <-results; r2.err == nil {
+			return r2.m, nil
+		}
+	}
+	return r.m, r.err
+}
+
+func (c *Client) getMetadataOnce(ctx context.Context, urlstr string) (*Metadata, error) {
+	req, err := c.newRequest("GET", urlstr, "application/json", nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	return c.decodeMetadata(res)
+}
+
+func (c *Client) recordLatency(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	h := &c.hedging
+	if len(h.latencies) < hedgeSamples {
+		h.latencies = append(h.latencies, d)
+		return
+	}
+	h.latencies[h.next] = d
+	h.next = (h.next + 1) % hedgeSamples
+}
+
+// hedgeDelay returns how long to wait for a response before hedging.
+func (c *Client) hedgeDelay() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.hedging.latencies) < hedgeMinSamples {
+		return c.HedgeAfter
+	}
+	l := append([]time.Duration(nil), c.hedging.latencies...)
+	sort.Slice(l, func(i, j int) bool { return l[i] < l[j] })
+	return l[len(l)*95/100]
+}
+
+// allowHedge reports whether another read may be hedged, and counts
+// it if so.
+func (c *Client) allowHedge() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	h := &c.hedging
+	if float64(h.hedges) >= hedgeFraction*float64(h.reads)+1 {
+		return false
+	}
+	h.hedges++
+	return true
+}
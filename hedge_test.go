@@ -0,0 +1,70 @@
+package ospry
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHedgeGetMetadata(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		n := requests
+		mu.Unlock()
+		if n != 3 {
+			// All but the hedge are slow, unless the client gives up
+			// on them.
+			select {
+			case <-time.After(500 * time.Millisecond):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		writeMetadata(w, &Metadata{ID: "foo"})
+	})
+
+	start := time.Now()
+	if _, err := c.GetMetadata("foo"); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 500*time.Millisecond {
+		t.Fatalf("unhedged read took %v, want at least 500ms", d)
+	}
+
+	c.HedgeAfter = 20 * time.Millisecond
+	start = time.Now()
+	m, err := c.GetMetadata("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d > 300*time.Millisecond {
+		t.Fatalf("hedged read took %v, want less than 300ms", d)
+	}
+	if m.ID != "foo" {
+		t.Fatalf("got %s, want foo", m.ID)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if requests != 3 {
+		t.Fatalf("got %d requests, want 3", requests)
+	}
+}
+
+func TestHedgeBudget(t *testing.T) {
+	c := New("sk-test-key")
+	c.HedgeAfter = time.Millisecond
+	allowed := 0
+	for i := 0; i < 100; i++ {
+		c.hedging.reads++
+		if c.allowHedge() {
+			allowed++
+		}
+	}
+	if allowed != 11 {
+		t.Fatalf("got %d hedges for 100 reads, want 11", allowed)
+	}
+}
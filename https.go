@@ -0,0 +1,35 @@
+package ospry
+
+import (
+	"net/url"
+	"strings"
+)
+
+// upgradeHTTPS switches an http image url to https. Images on ospry
+// subdomains are served over https from ssl.ospry.io, with the
+// subdomain moved into the sub query parameter.
+func upgradeHTTPS(u *url.URL, q url.Values) {
+	if u.Scheme != "http" {
+		return
+	}
+	u.Scheme = "https"
+	sub := strings.TrimSuffix(u.Host, ".ospry.io")
+	if sub != u.Host && sub != "api" && sub != "ssl" && !strings.Contains(sub, ".") {
+		q.Set("sub", sub)
+		u.Host = "ssl.ospry.io"
+	}
+}
+
+// downgradeHTTPS undoes upgradeHTTPS, returning the http url on the
+// image's ospry subdomain for urls on ssl.ospry.io. Other urls are
+// returned unchanged.
+func downgradeHTTPS(u *url.URL, q url.Values) *url.URL {
+	sub := q.Get("sub")
+	if u.Host != "ssl.ospry.io" || sub == "" {
+		return u
+	}
+	iu := *u
+	iu.Scheme = "http"
+	iu.Host = sub + ".ospry.io"
+	return &iu
+}
@@ -0,0 +1,86 @@
+package ospry
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestPreferHTTPSFormatURL(t *testing.T) {
+	c := New("sk-test-key")
+	c.PreferHTTPS = true
+	tests := []struct {
+		in, want string
+	}{
+		{"http://foo.ospry.io/bar/baz.png", "https://ssl.ospry.io/bar/baz.png?maxWidth=10&sub=foo"},
+		{"https://ssl.ospry.io/bar/baz.png?sub=foo", "https://ssl.ospry.io/bar/baz.png?maxWidth=10&sub=foo"},
+		{"http://example.com/baz.png", "https://example.com/baz.png?maxWidth=10"},
+	}
+	for _, test := range tests {
+		got, err := c.FormatURL(test.in, &RenderOpts{MaxWidth: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Fatalf("got %s, want %s", got, test.want)
+		}
+	}
+}
+
+func TestPreferHTTPSMetadata(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeMetadata(w, &Metadata{
+			ID:       "foo",
+			URL:      "http://foo.ospry.io/bar/baz.png",
+			HTTPSURL: "https://ssl.ospry.io/bar/baz.png?sub=foo",
+		})
+	})
+	c.PreferHTTPS = true
+	md, err := c.GetMetadata("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if md.URL != md.HTTPSURL {
+		t.Fatalf("got %s, want %s", md.URL, md.HTTPSURL)
+	}
+	c.CustomDomains = map[string]string{"images.mybrand.com": "foo.ospry.io"}
+	md, err = c.GetMetadata("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "https://images.mybrand.com/bar/baz.png"; md.URL != want {
+		t.Fatalf("got %s, want %s", md.URL, want)
+	}
+}
+
+func TestPreferHTTPSSignedURL(t *testing.T) {
+	c := New("sk-test-key")
+	c.PreferHTTPS = true
+	exp := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	const want = "http://foo.ospry.io/bar/baz.png"
+	wantSig, err := sign("sk-test-key", "", want, exp.Format(time.RFC3339Nano))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, in := range []string{want, "https://ssl.ospry.io/bar/baz.png?sub=foo"} {
+		got, err := c.FormatURL(in, &RenderOpts{TimeExpired: exp})
+		if err != nil {
+			t.Fatal(err)
+		}
+		u, err := url.Parse(got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		q := u.Query()
+		if q.Get("url") != want {
+			t.Fatalf("%s: got url=%s, want %s", in, q.Get("url"), want)
+		}
+		if q.Get("signature") != wantSig {
+			t.Fatalf("%s: got signature %s, want %s", in, q.Get("signature"), wantSig)
+		}
+		if u.Scheme != "https" {
+			t.Fatalf("%s: got %s, want an https url", in, got)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+// Package worker holds what the durable queues (store.Outbox,
+// spool.Queue and jobs.Queue) have in common: running drains
+// periodically, shutting them down, backing off failed items and
+// writing files atomically.
+package worker
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// A Loop runs a queue's drains, one at a time, and shuts them down.
+// The zero Loop is ready to use.
+type Loop struct {
+	mu       sync.Mutex
+	draining sync.Mutex
+	quit     chan struct{}
+	runs     sync.WaitGroup
+}
+
+func (l *Loop) quitChan() chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.quit == nil {
+		l.quit = make(chan struct{})
+	}
+	return l.quit
+}
+
+// Stopped reports whether Shutdown has been called. Drains check it
+// between items.
+func (l *Loop) Stopped() bool {
+	select {
+	case <-l.quitChan():
+		return true
+	default:
+		return false
+	}
+}
+
+// Drain calls drain once no other drain is running.
+func (l *Loop) Drain(drain func() (int, error)) (int, error) {
+	l.draining.Lock()
+	defer l.draining.Unlock()
+	return drain()
+}
+
+// Run calls drain every interval until ctx is done or Shutdown is
+// called, returning ctx's error or errShutdown. Drain errors other
+// than errShutdown don't stop it.
+func (l *Loop) Run(ctx context.Context, interval time.Duration, drain func(context.Context) (int, error), errShutdown error) error {
+	quit := l.quitChan()
+	l.runs.Add(1)
+	defer l.runs.Done()
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		if _, err := drain(ctx); err == errShutdown {
+			return err
+		} else if err != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-quit:
+			return errShutdown
+		case <-t.C:
+		}
+	}
+}
+
+// Shutdown stops Run, waits for the drain in flight and returns the
+// items pending lists. If ctx is done first, it stops waiting and
+// returns the pending items with ctx's error.
+func (l *Loop) Shutdown(ctx context.Context, pending func() ([]string, error)) ([]string, error) {
+	quit := l.quitChan()
+	l.mu.Lock()
+	select {
+	case <-quit:
+	default:
+		close(quit)
+	}
+	l.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		l.runs.Wait()
+		l.draining.Lock()
+		l.draining.Unlock()
+		close(done)
+	}()
+	var waitErr error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		waitErr = ctx.Err()
+	}
+	items, err := pending()
+	if waitErr != nil {
+		return items, waitErr
+	}
+	return items, err
+}
+
+// Backoff returns the delay before retrying after the given attempt:
+// base, doubled after each attempt but the first, up to max.
+func Backoff(base, max time.Duration, attempt int) time.Duration {
+	d := base
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
+
+// WriteFile writes a file with write and syncs it, through a temporary
+// file in the same directory so that a crash never leaves a partial
+// file at path.
+func WriteFile(path string, write func(*os.File) error) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	err = write(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
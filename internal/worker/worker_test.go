@@ -0,0 +1,80 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 4: 8 * time.Second, 20: time.Minute} {
+		if got := Backoff(time.Second, time.Minute, attempt); got != want {
+			t.Fatalf("attempt %d: got %v, want %v", attempt, got, want)
+		}
+	}
+}
+
+func TestLoopShutdown(t *testing.T) {
+	errShutdown := errors.New("shut down")
+	var l Loop
+	drained := make(chan struct{}, 1)
+	release := make(chan struct{})
+	errc := make(chan error)
+	go func() {
+		errc <- l.Run(context.Background(), time.Hour, func(context.Context) (int, error) {
+			return l.Drain(func() (int, error) {
+				drained <- struct{}{}
+				<-release
+				return 0, nil
+			})
+		}, errShutdown)
+	}()
+	<-drained
+
+	// The drain in flight outlives a cancelled shutdown, which still
+	// lists the pending items.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	pending, err := l.Shutdown(ctx, func() ([]string, error) { return []string{"a"}, nil })
+	if err != context.Canceled || len(pending) != 1 {
+		t.Fatalf("got %v, %v, want [a] and context.Canceled", pending, err)
+	}
+	if !l.Stopped() {
+		t.Fatal("got loop running, want it stopped")
+	}
+	close(release)
+	if err := <-errc; err != errShutdown {
+		t.Fatalf("got %v, want %v", err, errShutdown)
+	}
+	if _, err := l.Shutdown(context.Background(), func() ([]string, error) { return nil, nil }); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.json")
+	if err := WriteFile(path, func(f *os.File) error {
+		_, err := f.Write([]byte("a"))
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	failed := errors.New("failed")
+	if err := WriteFile(path, func(f *os.File) error {
+		f.Write([]byte("partial"))
+		return failed
+	}); err != failed {
+		t.Fatalf("got %v, want %v", err, failed)
+	}
+	if b, _ := ioutil.ReadFile(path); string(b) != "a" {
+		t.Fatalf("got %q, want the file unchanged", b)
+	}
+	if names, _ := filepath.Glob(filepath.Join(dir, ".tmp-*")); len(names) != 0 {
+		t.Fatalf("got %v, want temporary files removed", names)
+	}
+}
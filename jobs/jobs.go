@@ -0,0 +1,256 @@
+// Package jobs runs claims, deletes and privacy changes from a durable
+// queue, so that a crash between deciding on a change and making it
+// doesn't lose the change:
+//
+//	q := &jobs.Queue{Store: &jobs.DirStore{Dir: "/var/lib/app/jobs"}, Client: c}
+//	q.Enqueue(jobs.Delete, id)
+//	go q.Run(ctx, 10*time.Second)
+//
+// Jobs are run at least once, retried with exponential backoff, and
+// given up on (dead-lettered) after MaxAttempts tries or an error that
+// retrying can't fix. Dead jobs stay in the store until they're retried
+// or discarded.
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+
+	ospry "github.com/ospry/ospry-go"
+	"github.com/ospry/ospry-go/internal/worker"
+)
+
+// Job operations.
+const (
+	Claim       = ospry.OpClaim
+	Delete      = ospry.OpDelete
+	MakePrivate = ospry.OpMakePrivate
+	MakePublic  = ospry.OpMakePublic
+)
+
+// Defaults for a Queue's zero fields.
+const (
+	DefaultMaxAttempts = 10
+	DefaultBackoff     = time.Second
+	maxBackoff         = time.Hour
+)
+
+var (
+	// ErrUnknownOp is returned by Enqueue for operations it can't run.
+	ErrUnknownOp = errors.New("jobs: unknown operation")
+	// ErrShutdown is returned by Enqueue, Drain and Run once Shutdown
+	// has been called.
+	ErrShutdown = errors.New("jobs: queue shut down")
+)
+
+// A Job is an operation on an image.
+type Job struct {
+	ID          string    `json:"id"`
+	Op          string    `json:"op"`
+	ImageID     string    `json:"imageId"`
+	Created     time.Time `json:"created"`
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `json:"nextAttempt"`
+	LastError   string    `json:"lastError,omitempty"`
+	// Dead is set once the job has been given up on.
+	Dead bool `json:"dead,omitempty"`
+}
+
+// A Queue runs the jobs kept in its store. Several goroutines may use a
+// queue; several processes may share a store if it supports that, since
+// running a job twice does no harm.
+type Queue struct {
+	Store Store
+	// Client runs the jobs. If nil, the default client is used.
+	Client *ospry.Client
+	// MaxAttempts is the number of times a job is tried before it's
+	// given up on. If zero, DefaultMaxAttempts is used.
+	MaxAttempts int
+	// Backoff is the delay before a job is retried, doubled after
+	// each failure up to an hour. If zero, DefaultBackoff is used.
+	Backoff time.Duration
+	// DeadLetter, if set, is called with each job given up on, e.g.
+	// to alert someone.
+	DeadLetter func(*Job)
+
+	loop worker.Loop
+}
+
+// Enqueue saves a job running op on the image with the given id. The
+// job is durable once Enqueue returns.
+func (q *Queue) Enqueue(op, imageID string) (*Job, error) {
+	switch op {
+	case Claim, Delete, MakePrivate, MakePublic:
+	default:
+		return nil, ErrUnknownOp
+	}
+	if q.loop.Stopped() {
+		return nil, ErrShutdown
+	}
+	now := time.Now()
+	b := make([]byte, 4)
+	rand.Read(b)
+	// Ids sort in the order jobs were enqueued.
+	id := strconv.FormatInt(now.UnixNano(), 10) + "-" + hex.EncodeToString(b)
+	j := &Job{ID: id, Op: op, ImageID: imageID, Created: now, NextAttempt: now}
+	if err := q.Store.Save(j); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// Drain runs the jobs that are due, oldest first, and returns the
+// number that succeeded.
+func (q *Queue) Drain(ctx context.Context) (int, error) {
+	return q.loop.Drain(func() (int, error) { return q.drain(ctx) })
+}
+
+func (q *Queue) drain(ctx context.Context) (int, error) {
+	jobs, err := q.Store.List()
+	if err != nil {
+		return 0, err
+	}
+	done := 0
+	for _, j := range jobs {
+		if err := ctx.Err(); err != nil {
+			return done, err
+		}
+		if q.loop.Stopped() {
+			return done, ErrShutdown
+		}
+		if j.Dead || time.Now().Before(j.NextAttempt) {
+			continue
+		}
+		if err := q.run(j); err != nil {
+			j.Attempts++
+			j.LastError = err.Error()
+			j.NextAttempt = time.Now().Add(q.backoff(j.Attempts))
+			j.Dead = j.Attempts >= q.maxAttempts() || permanent(err)
+			if err := q.Store.Save(j); err != nil {
+				return done, err
+			}
+			if j.Dead && q.DeadLetter != nil {
+				q.DeadLetter(j)
+			}
+			continue
+		}
+		if err := q.Store.Delete(j.ID); err != nil {
+			return done, err
+		}
+		done++
+	}
+	return done, nil
+}
+
+// Run drains the queue every interval until ctx is done or Shutdown is
+// called.
+func (q *Queue) Run(ctx context.Context, interval time.Duration) error {
+	return q.loop.Run(ctx, interval, q.Drain, ErrShutdown)
+}
+
+// Shutdown stops accepting jobs and stops Run and Drain after the job
+// in flight. It returns the jobs still pending, as "<op> <image id>";
+// they stay in the store for the next process to run. If ctx is done
+// before the job in flight finishes, the pending jobs are returned
+// with ctx's error. Shutdown implements ospry.Shutdowner.
+func (q *Queue) Shutdown(ctx context.Context) ([]string, error) {
+	return q.loop.Shutdown(ctx, func() ([]string, error) {
+		jobs, err := q.Store.List()
+		var pending []string
+		for _, j := range jobs {
+			if !j.Dead {
+				pending = append(pending, j.Op+" "+j.ImageID)
+			}
+		}
+		return pending, err
+	})
+}
+
+// Dead returns the jobs that were given up on, oldest first.
+func (q *Queue) Dead() ([]*Job, error) {
+	jobs, err := q.Store.List()
+	if err != nil {
+		return nil, err
+	}
+	dead := []*Job{}
+	for _, j := range jobs {
+		if j.Dead {
+			dead = append(dead, j)
+		}
+	}
+	return dead, nil
+}
+
+// Retry makes a dead job due again, with a fresh set of attempts.
+func (q *Queue) Retry(j *Job) error {
+	j.Dead, j.Attempts, j.NextAttempt = false, 0, time.Now()
+	return q.Store.Save(j)
+}
+
+// Discard removes a job from the queue.
+func (q *Queue) Discard(j *Job) error {
+	return q.Store.Delete(j.ID)
+}
+
+func (q *Queue) run(j *Job) error {
+	c := q.Client
+	if c == nil {
+		c = ospry.DefaultClient
+	}
+	var err error
+	switch j.Op {
+	case Claim:
+		_, err = c.Claim(j.ImageID)
+	case Delete:
+		err = c.Delete(j.ImageID)
+		var e *ospry.Error
+		if errors.As(err, &e) && e.HTTPStatusCode == 404 {
+			return nil
+		}
+	case MakePrivate:
+		_, err = c.MakePrivate(j.ImageID)
+	case MakePublic:
+		_, err = c.MakePublic(j.ImageID)
+	default:
+		err = ErrUnknownOp
+	}
+	return err
+}
+
+// permanent reports whether err won't go away by retrying.
+func permanent(err error) bool {
+	if err == ErrUnknownOp {
+		return true
+	}
+	var uerr *url.Error
+	if errors.As(err, &uerr) {
+		return false
+	}
+	var e *ospry.Error
+	if !errors.As(err, &e) {
+		return false
+	}
+	code := e.HTTPStatusCode
+	return code >= 400 && code < 500 && code != 408 && code != 429
+}
+
+func (q *Queue) maxAttempts() int {
+	if q.MaxAttempts == 0 {
+		return DefaultMaxAttempts
+	}
+	return q.MaxAttempts
+}
+
+// backoff returns the delay before retrying after the given attempt.
+func (q *Queue) backoff(attempt int) time.Duration {
+	d := q.Backoff
+	if d == 0 {
+		d = DefaultBackoff
+	}
+	return worker.Backoff(d, maxBackoff, attempt)
+}
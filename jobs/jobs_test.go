@@ -0,0 +1,113 @@
+package jobs
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	ospry "github.com/ospry/ospry-go"
+)
+
+func TestQueue(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/images/")
+		switch id {
+		case "gone":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":{"httpStatusCode":404,"message":"not found"}}`))
+		case "forbidden":
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error":{"httpStatusCode":403,"message":"forbidden"}}`))
+		case "flaky":
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error":{"httpStatusCode":503,"message":"unavailable"}}`))
+		default:
+			w.Write([]byte(`{"metadata":{"id":"` + id + `","url":"http://foo.ospry.io/x.jpg"}}`))
+		}
+	}))
+	defer ts.Close()
+	c := ospry.New("sk-test-key")
+	c.ServerURL = ts.URL
+
+	dir, err := ioutil.TempDir("", "jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var dead []string
+	q := &Queue{Store: &DirStore{Dir: dir}, Client: c, MaxAttempts: 2, Backoff: time.Nanosecond,
+		DeadLetter: func(j *Job) { dead = append(dead, j.ImageID) }}
+	for _, j := range [][2]string{{Claim, "foo"}, {Delete, "gone"}, {MakePrivate, "forbidden"}, {MakePublic, "flaky"}} {
+		if _, err := q.Enqueue(j[0], j[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := q.Enqueue("rename", "foo"); err != ErrUnknownOp {
+		t.Fatalf("got %v, want ErrUnknownOp", err)
+	}
+
+	n, err := q.Drain(context.Background())
+	if err != nil || n != 2 {
+		t.Fatalf("got %d, %v, want 2 done", n, err)
+	}
+	if len(dead) != 1 || dead[0] != "forbidden" {
+		t.Fatalf("got dead %v, want forbidden given up on at once", dead)
+	}
+	time.Sleep(time.Millisecond)
+	q.Drain(context.Background())
+	if len(dead) != 2 || dead[1] != "flaky" {
+		t.Fatalf("got dead %v, want flaky given up on after 2 attempts", dead)
+	}
+
+	jobs, _ := q.Dead()
+	if len(jobs) != 2 || jobs[1].Attempts != 2 || !strings.Contains(jobs[1].LastError, "unavailable") {
+		t.Fatalf("got %+v, want 2 dead jobs", jobs)
+	}
+	if err := q.Retry(jobs[1]); err != nil {
+		t.Fatal(err)
+	}
+	q.Discard(jobs[0])
+	pending, err := q.Shutdown(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0] != "make-public flaky" {
+		t.Fatalf("got %v, want [make-public flaky]", pending)
+	}
+	if _, err := q.Enqueue(Claim, "foo"); err != ErrShutdown {
+		t.Fatalf("got %v, want ErrShutdown", err)
+	}
+}
+
+func TestQueueShutdownTimeout(t *testing.T) {
+	started := make(chan struct{})
+	block := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-block
+	}))
+	defer ts.Close()
+	defer close(block)
+	c := ospry.New("sk-test-key")
+	c.ServerURL = ts.URL
+	q := &Queue{Store: &MemoryStore{}, Client: c}
+	if _, err := q.Enqueue(Claim, "slow"); err != nil {
+		t.Fatal(err)
+	}
+	go q.Drain(context.Background())
+	<-started
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	pending, err := q.Shutdown(ctx)
+	if err != context.Canceled {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+	if len(pending) != 1 || pending[0] != "claim slow" {
+		t.Fatalf("got %v, want [claim slow]", pending)
+	}
+}
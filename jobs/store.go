@@ -0,0 +1,110 @@
+package jobs
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/ospry/ospry-go/internal/worker"
+)
+
+// A Store keeps a queue's jobs. Implementations must be safe for
+// concurrent use, and durable once Save returns.
+type Store interface {
+	// Save inserts or replaces the job with j's id.
+	Save(j *Job) error
+	// Delete removes the job with the given id, if there is one.
+	Delete(id string) error
+	// List returns all jobs, ordered by id.
+	List() ([]*Job, error)
+}
+
+// A MemoryStore is a Store that lives in memory, e.g. for tests. It
+// isn't durable.
+type MemoryStore struct {
+	mu   sync.Mutex
+	jobs map[string]Job
+}
+
+func (s *MemoryStore) Save(j *Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.jobs == nil {
+		s.jobs = map[string]Job{}
+	}
+	s.jobs[j.ID] = *j
+	return nil
+}
+
+func (s *MemoryStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.jobs, id)
+	return nil
+}
+
+func (s *MemoryStore) List() ([]*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := make([]*Job, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		j := j
+		jobs = append(jobs, &j)
+	}
+	sort.Slice(jobs, func(i, k int) bool { return jobs[i].ID < jobs[k].ID })
+	return jobs, nil
+}
+
+// A DirStore is a Store keeping each job in a json file in Dir. Only
+// one process may use the directory at a time.
+type DirStore struct {
+	Dir string
+}
+
+func (s *DirStore) Save(j *Job) error {
+	b, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.Dir, 0700); err != nil {
+		return err
+	}
+	return worker.WriteFile(filepath.Join(s.Dir, j.ID+".json"), func(f *os.File) error {
+		_, err := f.Write(b)
+		return err
+	})
+}
+
+func (s *DirStore) Delete(id string) error {
+	err := os.Remove(filepath.Join(s.Dir, id+".json"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *DirStore) List() ([]*Job, error) {
+	names, err := filepath.Glob(filepath.Join(s.Dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	jobs := []*Job{}
+	for _, name := range names {
+		b, err := ioutil.ReadFile(name)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		j := &Job{}
+		if err := json.Unmarshal(b, j); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, nil
+}
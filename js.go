@@ -0,0 +1,105 @@
+package ospry
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ParseJSMetadata calls ParseJSMetadata on the default client.
+func ParseJSMetadata(b []byte) (*Metadata, error) {
+	return DefaultClient.ParseJSMetadata(b)
+}
+
+// JSMetadataFromRequest calls JSMetadataFromRequest on the default
+// client.
+func JSMetadataFromRequest(r *http.Request, field string) (*Metadata, error) {
+	return DefaultClient.JSMetadataFromRequest(r, field)
+}
+
+// ParseJSMetadata decodes the metadata ospry.js hands the browser
+// after an upload, as forwarded to the server, either bare or wrapped
+// in a {"metadata": ...} object. It checks that the metadata is well
+// formed: it has an id, and its urls point to ospry (or one of the
+// client's custom domains).
+//
+// The metadata comes from the browser, so it's only as trustworthy as
+// the browser. Fetch the image's metadata with GetMetadata before
+// relying on anything but its id.
+func (c *Client) ParseJSMetadata(b []byte) (*Metadata, error) {
+	b = bytes.TrimSpace(b)
+	var wrapped struct {
+		Metadata *Metadata `json:"metadata"`
+	}
+	if err := json.Unmarshal(b, &wrapped); err != nil {
+		return nil, errors.New("ospry: invalid metadata json: " + err.Error())
+	}
+	m := wrapped.Metadata
+	if m == nil {
+		m = new(Metadata)
+		if err := json.Unmarshal(b, m); err != nil {
+			return nil, errors.New("ospry: invalid metadata json: " + err.Error())
+		}
+	}
+	if err := c.checkJSMetadata(m); err != nil {
+		return nil, err
+	}
+	if err := c.normalizeMetadata(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// maxJSMetadataSize is the largest json body JSMetadataFromRequest
+// reads.
+const maxJSMetadataSize = 1 << 20
+
+// JSMetadataFromRequest parses the metadata (see ParseJSMetadata) in a
+// request: the given form field, or the request body, up to 1MB, if
+// the request is a json post.
+func (c *Client) JSMetadataFromRequest(r *http.Request, field string) (*Metadata, error) {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		b, err := ioutil.ReadAll(http.MaxBytesReader(nil, r.Body, maxJSMetadataSize))
+		if err != nil {
+			return nil, err
+		}
+		return c.ParseJSMetadata(b)
+	}
+	v := r.FormValue(field)
+	if v == "" {
+		return nil, errors.New("ospry: no metadata in form field " + field)
+	}
+	return c.ParseJSMetadata([]byte(v))
+}
+
+func (c *Client) checkJSMetadata(m *Metadata) error {
+	if m.ID == "" {
+		return errors.New("ospry: metadata has no id")
+	}
+	if strings.ContainsAny(m.ID, "/?#%") {
+		return errors.New("ospry: invalid image id " + m.ID)
+	}
+	for _, urlstr := range []string{m.URL, m.HTTPSURL} {
+		if urlstr == "" {
+			continue
+		}
+		u, err := url.Parse(urlstr)
+		if err != nil {
+			return &URLError{URL: urlstr, Err: err}
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || !c.isImageHost(u.Hostname()) {
+			return &URLError{URL: urlstr, Err: errors.New("not an ospry image url")}
+		}
+	}
+	if m.Format != "" && !isFormat(m.Format) {
+		return errors.New("ospry: invalid format " + m.Format)
+	}
+	if m.Size < 0 || m.Width < 0 || m.Height < 0 {
+		return errors.New("ospry: invalid image dimensions")
+	}
+	return nil
+}
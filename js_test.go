@@ -0,0 +1,66 @@
+package ospry
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParseJSMetadata(t *testing.T) {
+	c := New("sk-test-key")
+	const js = `{"id":"abc","url":"http://foo.ospry.io/abc/cat.jpg","httpsURL":"https://ssl.ospry.io/foo/abc/cat.jpg","isPrivate":false,"filename":"cat.jpg","format":"jpeg","size":1234,"width":10,"height":20}`
+	for _, b := range []string{js, `{"metadata":` + js + `}`, "  " + js + "\n"} {
+		m, err := c.ParseJSMetadata([]byte(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.ID != "abc" || m.Format != "jpeg" || m.Size != 1234 || m.Width != 10 || m.Height != 20 {
+			t.Fatalf("got %+v, want decoded metadata", m)
+		}
+		if m.boundClient() != c {
+			t.Fatal("metadata not bound to client")
+		}
+	}
+
+	bad := []string{
+		`not json`,
+		`{"url":"http://foo.ospry.io/abc/cat.jpg"}`,
+		`{"id":"../abc"}`,
+		`{"id":"abc","url":"http://evil.example.com/abc/cat.jpg"}`,
+		`{"id":"abc","url":"javascript:alert(1)"}`,
+		`{"id":"abc","format":"exe"}`,
+		`{"id":"abc","size":-1}`,
+	}
+	for _, b := range bad {
+		if _, err := c.ParseJSMetadata([]byte(b)); err == nil {
+			t.Fatalf("%s: got nil error", b)
+		}
+	}
+}
+
+func TestJSMetadataFromRequest(t *testing.T) {
+	c := New("sk-test-key")
+	const js = `{"id":"abc","url":"http://foo.ospry.io/abc/cat.jpg"}`
+
+	r := httptest.NewRequest("POST", "/", strings.NewReader(js))
+	r.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if m, err := c.JSMetadataFromRequest(r, "image"); err != nil || m.ID != "abc" {
+		t.Fatalf("got %v, %v, want abc", m, err)
+	}
+
+	r = httptest.NewRequest("POST", "/", strings.NewReader(js+strings.Repeat(" ", maxJSMetadataSize)))
+	r.Header.Set("Content-Type", "application/json")
+	if _, err := c.JSMetadataFromRequest(r, "image"); err == nil || !strings.Contains(err.Error(), "too large") {
+		t.Fatalf("got %v, want the body refused for its size", err)
+	}
+
+	r = httptest.NewRequest("POST", "/", strings.NewReader(url.Values{"image": {js}}.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if m, err := c.JSMetadataFromRequest(r, "image"); err != nil || m.ID != "abc" {
+		t.Fatalf("got %v, %v, want abc", m, err)
+	}
+	if _, err := c.JSMetadataFromRequest(r, "other"); err == nil {
+		t.Fatal("got nil error for missing field")
+	}
+}
@@ -0,0 +1,254 @@
+package ospry
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A KeyProvider supplies a client's key (see Client.KeyProvider), so
+// that the key can be rotated while the client is in use. Key is
+// called for every request and signed url, so it should be cheap.
+// Implementations must be safe for concurrent use.
+type KeyProvider interface {
+	Key() (string, error)
+}
+
+// A KeyWatcher is a KeyProvider that can tell when its key changes,
+// e.g. to refresh urls signed with the old key.
+type KeyWatcher interface {
+	KeyProvider
+	// Watch calls fn with the new key whenever the key changes,
+	// until the returned func is called.
+	Watch(fn func(key string)) (stop func())
+}
+
+// currentKey returns the key the client authenticates with.
+func (c *Client) currentKey() (string, error) {
+	if c.KeyProvider != nil {
+		return c.KeyProvider.Key()
+	}
+	return c.Key, nil
+}
+
+// watchers keeps the callbacks of a KeyWatcher.
+type watchers struct {
+	mu   sync.Mutex
+	next int
+	fns  map[int]func(string)
+}
+
+func (w *watchers) watch(fn func(string)) func() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.fns == nil {
+		w.fns = map[int]func(string){}
+	}
+	id := w.next
+	w.next++
+	w.fns[id] = fn
+	return func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		delete(w.fns, id)
+	}
+}
+
+func (w *watchers) notify(key string) {
+	w.mu.Lock()
+	fns := make([]func(string), 0, len(w.fns))
+	for _, fn := range w.fns {
+		fns = append(fns, fn)
+	}
+	w.mu.Unlock()
+	for _, fn := range fns {
+		fn(key)
+	}
+}
+
+// A RotatingKey is a KeyWatcher whose key is set with SetKey, e.g.
+// when a secret manager reports a new version of the key.
+type RotatingKey struct {
+	mu  sync.RWMutex
+	key string
+	w   watchers
+}
+
+// NewRotatingKey creates a RotatingKey with the given initial key.
+func NewRotatingKey(key string) *RotatingKey {
+	return &RotatingKey{key: key}
+}
+
+func (k *RotatingKey) Key() (string, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.key, nil
+}
+
+// SetKey replaces the key.
+func (k *RotatingKey) SetKey(key string) {
+	k.mu.Lock()
+	changed := key != k.key
+	k.key = key
+	k.mu.Unlock()
+	if changed {
+		k.w.notify(key)
+	}
+}
+
+func (k *RotatingKey) Watch(fn func(key string)) func() {
+	return k.w.watch(fn)
+}
+
+// DefaultKeyFileInterval is the Interval of a KeyFile whose Interval
+// is zero.
+const DefaultKeyFileInterval = 10 * time.Second
+
+// A KeyFile is a KeyWatcher reading the key from a file, e.g. one
+// mounted from a secret store. The file is checked for changes at most
+// once per Interval, when the key is needed; surrounding whitespace is
+// ignored.
+type KeyFile struct {
+	Path     string
+	Interval time.Duration
+
+	mu      sync.Mutex
+	key     string
+	modTime time.Time
+	checked time.Time
+	w       watchers
+}
+
+func (k *KeyFile) Key() (string, error) {
+	k.mu.Lock()
+	interval := k.Interval
+	if interval == 0 {
+		interval = DefaultKeyFileInterval
+	}
+	if k.key != "" && time.Since(k.checked) < interval {
+		defer k.mu.Unlock()
+		return k.key, nil
+	}
+	old := k.key
+	err := k.reload()
+	key := k.key
+	k.mu.Unlock()
+	if err != nil && key == "" {
+		return "", err
+	}
+	if old != "" && key != old {
+		k.w.notify(key)
+	}
+	// A key that can't be reloaded (e.g. while the file is being
+	// replaced) keeps being used.
+	return key, nil
+}
+
+// reload reads the file if it changed since it was last read.
+func (k *KeyFile) reload() error {
+	k.checked = time.Now()
+	fi, err := os.Stat(k.Path)
+	if err != nil {
+		return err
+	}
+	if k.key != "" && fi.ModTime().Equal(k.modTime) {
+		return nil
+	}
+	b, err := ioutil.ReadFile(k.Path)
+	if err != nil {
+		return err
+	}
+	if key := strings.TrimSpace(string(b)); key != "" {
+		k.key = key
+		k.modTime = fi.ModTime()
+	}
+	return nil
+}
+
+func (k *KeyFile) Watch(fn func(key string)) func() {
+	return k.w.watch(fn)
+}
+
+// DefaultRefreshInterval is the Interval of a RefreshingKey whose
+// Interval is zero.
+const DefaultRefreshInterval = 5 * time.Minute
+
+// A RefreshingKey is a KeyWatcher that fetches the key, e.g. from a
+// secret manager, and caches it for Interval. Once the cached key is
+// stale, it keeps being used while it's refreshed in the background,
+// and if refreshing it fails.
+type RefreshingKey struct {
+	Fetch    func() (string, error)
+	Interval time.Duration
+
+	mu       sync.Mutex
+	key      string
+	err      error
+	fetched  time.Time
+	fetching chan struct{}
+	w        watchers
+}
+
+func (k *RefreshingKey) Key() (string, error) {
+	k.mu.Lock()
+	interval := k.Interval
+	if interval == 0 {
+		interval = DefaultRefreshInterval
+	}
+	key := k.key
+	if key != "" && time.Since(k.fetched) < interval {
+		k.mu.Unlock()
+		return key, nil
+	}
+	// Only one fetch runs at a time, and only callers without a key
+	// to use wait for it.
+	done := k.fetching
+	if done == nil {
+		done = make(chan struct{})
+		k.fetching = done
+		go k.refresh(done)
+	}
+	k.mu.Unlock()
+	if key != "" {
+		return key, nil
+	}
+	<-done
+	k.mu.Lock()
+	key, err := k.key, k.err
+	k.mu.Unlock()
+	if key == "" {
+		if err == nil {
+			err = errors.New("ospry: empty key")
+		}
+		return "", err
+	}
+	return key, nil
+}
+
+// refresh fetches the key and closes done.
+func (k *RefreshingKey) refresh(done chan struct{}) {
+	defer close(done)
+	key, err := k.Fetch()
+	k.mu.Lock()
+	old := k.key
+	// Failed fetches are retried after an interval too, so a secret
+	// manager outage doesn't cost every request a round trip.
+	k.fetched = time.Now()
+	k.err = err
+	if err == nil && key != "" {
+		k.key = key
+	}
+	key = k.key
+	k.fetching = nil
+	k.mu.Unlock()
+	if old != "" && key != old {
+		k.w.notify(key)
+	}
+}
+
+func (k *RefreshingKey) Watch(fn func(key string)) func() {
+	return k.w.watch(fn)
+}
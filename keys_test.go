@@ -0,0 +1,154 @@
+package ospry
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRotatingKey(t *testing.T) {
+	var got string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _, _ = r.BasicAuth()
+		w.Write([]byte(`{"metadata":{"id":"foo"}}`))
+	}))
+	defer ts.Close()
+	k := NewRotatingKey("sk-test-old")
+	c := New("")
+	c.ServerURL = ts.URL
+	c.KeyProvider = k
+	var rotated []string
+	stop := k.Watch(func(key string) { rotated = append(rotated, key) })
+
+	c.GetMetadata("foo")
+	if got != "sk-test-old" {
+		t.Fatalf("got key %s, want sk-test-old", got)
+	}
+	k.SetKey("sk-test-new")
+	c.GetMetadata("foo")
+	if got != "sk-test-new" {
+		t.Fatalf("got key %s, want sk-test-new", got)
+	}
+	signed, err := c.FormatURL("http://foo.ospry.io/bar.png", &RenderOpts{TimeExpired: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifySignature(signed, "sk-test-new"); err != nil {
+		t.Fatal(err)
+	}
+	stop()
+	k.SetKey("sk-test-newer")
+	if len(rotated) != 1 || rotated[0] != "sk-test-new" {
+		t.Fatalf("got rotations %v, want sk-test-new", rotated)
+	}
+}
+
+func TestKeyFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ospry-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "key")
+	if _, err := (&KeyFile{Path: path}).Key(); err == nil {
+		t.Fatal("got nil, want error for missing file")
+	}
+	ioutil.WriteFile(path, []byte("sk-test-one\n"), 0600)
+	k := &KeyFile{Path: path, Interval: time.Nanosecond}
+	var rotated []string
+	k.Watch(func(key string) { rotated = append(rotated, key) })
+	if key, err := k.Key(); err != nil || key != "sk-test-one" {
+		t.Fatalf("got %s, %v, want sk-test-one", key, err)
+	}
+	ioutil.WriteFile(path, []byte("sk-test-two"), 0600)
+	os.Chtimes(path, time.Now(), time.Now().Add(time.Second))
+	if key, _ := k.Key(); key != "sk-test-two" {
+		t.Fatalf("got %s, want sk-test-two", key)
+	}
+	// The last key is kept while the file is missing.
+	os.Remove(path)
+	if key, err := k.Key(); err != nil || key != "sk-test-two" {
+		t.Fatalf("got %s, %v, want sk-test-two", key, err)
+	}
+	if len(rotated) != 1 || rotated[0] != "sk-test-two" {
+		t.Fatalf("got rotations %v, want sk-test-two", rotated)
+	}
+}
+
+func TestRefreshingKey(t *testing.T) {
+	keys := []string{"sk-test-one", "", "sk-test-two"}
+	errs := []error{nil, errors.New("unavailable"), nil}
+	fetches := 0
+	k := &RefreshingKey{Interval: time.Hour, Fetch: func() (string, error) {
+		i := fetches
+		fetches++
+		return keys[i], errs[i]
+	}}
+	var rotated []string
+	k.Watch(func(key string) { rotated = append(rotated, key) })
+	for i := 0; i < 2; i++ {
+		if key, err := k.Key(); err != nil || key != "sk-test-one" {
+			t.Fatalf("got %s, %v, want sk-test-one", key, err)
+		}
+	}
+	if fetches != 1 {
+		t.Fatalf("got %d fetches, want 1", fetches)
+	}
+	// Stale keys are served while they're refreshed, and a failed
+	// refresh keeps the cached key.
+	for _, want := range []string{"sk-test-one", "sk-test-two"} {
+		stale := refresh(k)
+		if key, err := k.Key(); err != nil || key != stale {
+			t.Fatalf("got %s, %v, want %s while refreshing", key, err, stale)
+		}
+		waitRefresh(k)
+		if key, err := k.Key(); err != nil || key != want {
+			t.Fatalf("got %s, %v, want %s", key, err, want)
+		}
+	}
+	if len(rotated) != 1 || rotated[0] != "sk-test-two" {
+		t.Fatalf("got rotations %v, want sk-test-two", rotated)
+	}
+}
+
+// refresh makes k's key stale and returns it.
+func refresh(k *RefreshingKey) string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.fetched = time.Time{}
+	return k.key
+}
+
+// waitRefresh waits for k's background fetch, if any.
+func waitRefresh(k *RefreshingKey) {
+	k.mu.Lock()
+	done := k.fetching
+	k.mu.Unlock()
+	if done != nil {
+		<-done
+	}
+}
+
+func TestRefreshingKeySlowFetch(t *testing.T) {
+	block := make(chan struct{})
+	k := &RefreshingKey{Interval: time.Hour, Fetch: func() (string, error) {
+		<-block
+		return "sk-test-two", nil
+	}}
+	k.key = "sk-test-one"
+	for i := 0; i < 3; i++ {
+		if key, err := k.Key(); err != nil || key != "sk-test-one" {
+			t.Fatalf("got %s, %v, want the stale key while fetching", key, err)
+		}
+	}
+	close(block)
+	waitRefresh(k)
+	if key, _ := k.Key(); key != "sk-test-two" {
+		t.Fatalf("got %s, want sk-test-two", key)
+	}
+}
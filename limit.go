@@ -0,0 +1,17 @@
+package ospry
+
+// acquireUpload waits for an upload slot (see
+// Client.MaxConcurrentUploads) and returns a func that releases it.
+func (c *Client) acquireUpload() func() {
+	c.mu.Lock()
+	if c.uploadSem == nil && c.MaxConcurrentUploads > 0 {
+		c.uploadSem = make(chan struct{}, c.MaxConcurrentUploads)
+	}
+	sem := c.uploadSem
+	c.mu.Unlock()
+	if sem == nil {
+		return func() {}
+	}
+	sem <- struct{}{}
+	return func() { <-sem }
+}
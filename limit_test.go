@@ -0,0 +1,42 @@
+package ospry
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMaxConcurrentUploads(t *testing.T) {
+	var mu sync.Mutex
+	active, maxActive := 0, 0
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		active++
+		if active > maxActive {
+			maxActive = active
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		active--
+		mu.Unlock()
+		writeMetadata(w, &Metadata{ID: "foo"})
+	})
+	c.MaxConcurrentUploads = 2
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.UploadPrivate("foo.jpg", strings.NewReader("data")); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if maxActive != 2 {
+		t.Fatalf("got %d concurrent uploads, want 2", maxActive)
+	}
+}
@@ -0,0 +1,169 @@
+package ospry
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A ListFilter selects the images to list. Zero fields match every
+// image.
+type ListFilter struct {
+	Format         string
+	FilenamePrefix string
+	// Tag matches images with the given tag, as "key=value".
+	Tag string
+	// Unclaimed matches only unclaimed images.
+	Unclaimed bool
+	// CreatedBefore and CreatedAfter bound the images' TimeCreated.
+	CreatedBefore time.Time
+	CreatedAfter  time.Time
+	// PageSize is the number of images per page. Zero lets the server
+	// choose.
+	PageSize int
+	// Fields, if set, asks for only the given metadata fields of each
+	// image, as in GetMetadataFields, which makes large listings
+	// cheaper. The other fields of the listed metadata are left zero.
+	Fields []string
+}
+
+// A ListPage is one page of a listing.
+type ListPage struct {
+	Images []*Metadata `json:"images"`
+	// NextCursor fetches the next page. It's empty on the last page.
+	NextCursor string `json:"nextCursor"`
+}
+
+// List calls List on the default client.
+func List(filter *ListFilter, cursor string) (*ListPage, error) {
+	return DefaultClient.List(filter, cursor)
+}
+
+// ListAll calls ListAll on the default client.
+func ListAll(ctx context.Context, filter *ListFilter) (<-chan *Metadata, <-chan error) {
+	return DefaultClient.ListAll(ctx, filter)
+}
+
+// List returns a page of the images matching filter, starting at
+// cursor; the empty cursor starts at the first page. A nil filter
+// lists all images.
+func (c *Client) List(filter *ListFilter, cursor string) (*ListPage, error) {
+	page, err := c.list(context.Background(), filter, cursor)
+	return page, opError("ospry.List", "", c.apiURL("/images"), err)
+}
+
+// ListAll lists all images matching filter in the background. Images
+// are sent on the returned channel as they're read, and the next page
+// is only fetched once the current one has been received, so slow
+// consumers hold the listing back rather than buffering it. Both
+// channels are closed when the listing ends; the error channel
+// receives an error first if it failed or ctx was canceled.
+func (c *Client) ListAll(ctx context.Context, filter *ListFilter) (<-chan *Metadata, <-chan error) {
+	images := make(chan *Metadata)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(errc)
+		defer close(images)
+		cursor := ""
+		for {
+			page, err := c.list(ctx, filter, cursor)
+			if err != nil {
+				if ctx.Err() != nil {
+					err = ctx.Err()
+				} else {
+					err = opError("ospry.List", "", c.apiURL("/images"), err)
+				}
+				errc <- err
+				return
+			}
+			for _, m := range page.Images {
+				select {
+				case images <- m:
+				case <-ctx.Done():
+					errc <- ctx.Err()
+					return
+				}
+			}
+			if page.NextCursor == "" {
+				return
+			}
+			cursor = page.NextCursor
+		}
+	}()
+	return images, errc
+}
+
+func (c *Client) list(ctx context.Context, filter *ListFilter, cursor string) (*ListPage, error) {
+	if filter == nil {
+		filter = &ListFilter{}
+	}
+	if err := checkFieldNames(filter.Fields); err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(c.ServerURL)
+	if err != nil {
+		return nil, err
+	}
+	u.Path += "/images"
+	q := url.Values{}
+	if filter.Format != "" {
+		q.Set("format", filter.Format)
+	}
+	if filter.FilenamePrefix != "" {
+		q.Set("filenamePrefix", filter.FilenamePrefix)
+	}
+	if filter.Tag != "" {
+		q.Set("tag", filter.Tag)
+	}
+	if filter.Unclaimed {
+		q.Set("isClaimed", "false")
+	}
+	if !filter.CreatedBefore.IsZero() {
+		q.Set("createdBefore", filter.CreatedBefore.Format(time.RFC3339Nano))
+	}
+	if !filter.CreatedAfter.IsZero() {
+		q.Set("createdAfter", filter.CreatedAfter.Format(time.RFC3339Nano))
+	}
+	if filter.PageSize > 0 {
+		q.Set("limit", strconv.Itoa(filter.PageSize))
+	}
+	if len(filter.Fields) > 0 {
+		q.Set("fields", strings.Join(filter.Fields, ","))
+	}
+	if cursor != "" {
+		q.Set("cursor", cursor)
+	}
+	u.RawQuery = q.Encode()
+	req, err := c.newRequest("GET", u.String(), "application/json", nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	var body struct {
+		ListPage
+		Error *Error `json:"error"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	if body.Error != nil {
+		return nil, body.Error
+	}
+	for _, m := range body.Images {
+		c.checkInvariants(res, m)
+		if err := c.checkFields(m); err != nil {
+			return nil, err
+		}
+		if err := c.normalizeMetadata(m); err != nil {
+			return nil, err
+		}
+	}
+	return &body.ListPage, nil
+}
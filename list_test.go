@@ -0,0 +1,119 @@
+package ospry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// listServer serves n images in pages of the requested size.
+func listServer(t *testing.T, n int, queries *[]string) *Client {
+	return newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if queries != nil {
+			*queries = append(*queries, r.URL.RawQuery)
+		}
+		start, _ := strconv.Atoi(q.Get("cursor"))
+		limit, _ := strconv.Atoi(q.Get("limit"))
+		if limit == 0 {
+			limit = 2
+		}
+		page := map[string]interface{}{}
+		var images []*Metadata
+		for i := start; i < n && i < start+limit; i++ {
+			images = append(images, &Metadata{ID: fmt.Sprint("img", i)})
+		}
+		page["images"] = images
+		if start+limit < n {
+			page["nextCursor"] = strconv.Itoa(start + limit)
+		}
+		json.NewEncoder(w).Encode(page)
+	})
+}
+
+func TestList(t *testing.T) {
+	var queries []string
+	c := listServer(t, 3, &queries)
+	before := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	page, err := c.List(&ListFilter{Format: "png", FilenamePrefix: "tmp-", Tag: "a=b", Unclaimed: true, CreatedBefore: before, PageSize: 2}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Images) != 2 || page.NextCursor != "2" || page.Images[0].boundClient() != c {
+		t.Fatalf("got %+v, want first page", page)
+	}
+	want := "createdBefore=2020-01-02T03%3A04%3A05Z&filenamePrefix=tmp-&format=png&isClaimed=false&limit=2&tag=a%3Db"
+	if queries[0] != want {
+		t.Fatalf("got %s, want %s", queries[0], want)
+	}
+}
+
+func TestListFields(t *testing.T) {
+	var queries []string
+	c := listServer(t, 3, &queries)
+	filter := &ListFilter{Fields: []string{"id", "isPrivate"}}
+	if _, err := c.List(filter, ""); err != nil {
+		t.Fatal(err)
+	}
+	images, errc := c.ListAll(context.Background(), filter)
+	for range images {
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	for _, q := range queries {
+		if !strings.Contains(q, "fields=id%2CisPrivate") {
+			t.Fatalf("got %s, want the fields asked for", q)
+		}
+	}
+	if len(queries) != 3 {
+		t.Fatalf("got %d requests, want 3", len(queries))
+	}
+
+	filter.Fields = []string{"id", "bogus"}
+	_, err := c.List(filter, "")
+	var oe *OpError
+	if !errors.As(err, &oe) || oe.Op != "ospry.List" {
+		t.Fatalf("got %v, want an *OpError for the unknown field", err)
+	}
+	images, errc = c.ListAll(context.Background(), filter)
+	for range images {
+	}
+	if err := <-errc; !errors.As(err, &oe) {
+		t.Fatalf("got %v, want an *OpError for the unknown field", err)
+	}
+	if len(queries) != 3 {
+		t.Fatalf("got %d requests, want none for unknown fields", len(queries)-3)
+	}
+}
+
+func TestListAll(t *testing.T) {
+	c := listServer(t, 5, nil)
+	images, errc := c.ListAll(context.Background(), nil)
+	var ids []string
+	for m := range images {
+		ids = append(ids, m.ID)
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(ids) != "[img0 img1 img2 img3 img4]" {
+		t.Fatalf("got %v, want 5 images", ids)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	images, errc = c.ListAll(ctx, nil)
+	<-images
+	cancel()
+	for range images {
+	}
+	if err := <-errc; err != context.Canceled {
+		t.Fatalf("got %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,247 @@
+// Package manifest records which local files were uploaded to ospry
+// as which images, in a form other systems (static site generators,
+// CDNs, later runs of the same job) can consume.
+//
+// A manifest can be produced by UploadFiles, or built up with Add
+// after uploading files some other way:
+//
+//	m, err := manifest.UploadFiles(client, paths, nil)
+//	err = m.WriteJSON(f)
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	ospry "github.com/ospry/ospry-go"
+)
+
+// An Entry describes one uploaded file.
+type Entry struct {
+	Path         string    `json:"path"`
+	ID           string    `json:"id"`
+	URL          string    `json:"url"`
+	Hash         string    `json:"hash"`
+	Size         int64     `json:"size"`
+	TimeUploaded time.Time `json:"timeUploaded"`
+}
+
+// A Manifest maps local paths to uploaded images. There's at most one
+// entry per path.
+type Manifest struct {
+	Entries []Entry `json:"entries"`
+}
+
+// Add records that the file at path, with the given content hash (see
+// Hash), was uploaded as the image described by md. An existing entry
+// for path is replaced.
+func (m *Manifest) Add(path, hash string, md *ospry.Metadata) {
+	e := Entry{
+		Path:         path,
+		ID:           md.ID,
+		URL:          md.URL,
+		Hash:         hash,
+		Size:         md.Size,
+		TimeUploaded: md.TimeCreated,
+	}
+	for i := range m.Entries {
+		if m.Entries[i].Path == path {
+			m.Entries[i] = e
+			return
+		}
+	}
+	m.Entries = append(m.Entries, e)
+}
+
+// Get returns the entry for path.
+func (m *Manifest) Get(path string) (Entry, bool) {
+	for _, e := range m.Entries {
+		if e.Path == path {
+			return e, true
+		}
+	}
+	return Entry{}, false
+}
+
+// UploadFiles uploads the files at the given paths and returns a
+// manifest of the uploads. It stops at the first error, returning the
+// manifest of the files uploaded so far.
+func UploadFiles(c *ospry.Client, paths []string, opts *ospry.UploadOpts) (*Manifest, error) {
+	m := &Manifest{}
+	if c.Progress != nil {
+		c.Progress.Expect(len(paths), totalSize(paths))
+	}
+	for _, path := range paths {
+		if err := uploadFile(c, m, path, opts); err != nil {
+			return m, err
+		}
+	}
+	return m, nil
+}
+
+// totalSize returns the combined size of the files at paths, or -1 if
+// any of them can't be stat'ed.
+func totalSize(paths []string) int64 {
+	var n int64
+	for _, path := range paths {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return -1
+		}
+		n += fi.Size()
+	}
+	return n
+}
+
+func uploadFile(c *ospry.Client, m *Manifest, path string, opts *ospry.UploadOpts) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	hash, err := Hash(f)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	md, err := c.Upload(filepath.Base(path), f, opts)
+	if err != nil {
+		return err
+	}
+	m.Add(path, hash, md)
+	return nil
+}
+
+// Hash returns the hex-encoded SHA-256 hash of r's contents, which is
+// also what ospry.HashIndex uses.
+func Hash(r io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (m *Manifest) sorted() []Entry {
+	entries := append([]Entry(nil), m.Entries...)
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return entries
+}
+
+// WriteJSON writes the manifest as JSON, with entries sorted by path.
+func (m *Manifest) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(&Manifest{Entries: m.sorted()})
+}
+
+// ReadJSON reads a manifest written by WriteJSON.
+func ReadJSON(r io.Reader) (*Manifest, error) {
+	m := &Manifest{}
+	if err := json.NewDecoder(r).Decode(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+var csvHeader = []string{"path", "id", "url", "hash", "size", "timeUploaded"}
+
+// WriteCSV writes the manifest as CSV with a header row, with entries
+// sorted by path.
+func (m *Manifest) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, e := range m.sorted() {
+		err := cw.Write([]string{
+			e.Path,
+			e.ID,
+			e.URL,
+			e.Hash,
+			strconv.FormatInt(e.Size, 10),
+			e.TimeUploaded.Format(time.RFC3339Nano),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ReadCSV reads a manifest written by WriteCSV.
+func ReadCSV(r io.Reader) (*Manifest, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = len(csvHeader)
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("manifest: missing csv header")
+	}
+	m := &Manifest{}
+	for _, rec := range records[1:] {
+		size, err := strconv.ParseInt(rec[4], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		t, err := time.Parse(time.RFC3339Nano, rec[5])
+		if err != nil {
+			return nil, err
+		}
+		m.Entries = append(m.Entries, Entry{
+			Path:         rec[0],
+			ID:           rec[1],
+			URL:          rec[2],
+			Hash:         rec[3],
+			Size:         size,
+			TimeUploaded: t,
+		})
+	}
+	return m, nil
+}
+
+// A Diff describes the changes between two manifests.
+type Diff struct {
+	// Added are the entries whose paths are only in the new manifest.
+	Added []Entry
+	// Removed are the entries whose paths are only in the old
+	// manifest.
+	Removed []Entry
+	// Changed are the new entries for paths whose content or image
+	// changed.
+	Changed []Entry
+}
+
+// Compare returns the changes from old to new.
+func Compare(old, new *Manifest) *Diff {
+	d := &Diff{}
+	for _, e := range new.sorted() {
+		prev, ok := old.Get(e.Path)
+		switch {
+		case !ok:
+			d.Added = append(d.Added, e)
+		case prev.Hash != e.Hash || prev.ID != e.ID:
+			d.Changed = append(d.Changed, e)
+		}
+	}
+	for _, e := range old.sorted() {
+		if _, ok := new.Get(e.Path); !ok {
+			d.Removed = append(d.Removed, e)
+		}
+	}
+	return d
+}
@@ -0,0 +1,65 @@
+package manifest
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	ospry "github.com/ospry/ospry-go"
+)
+
+func testManifest() *Manifest {
+	t := time.Date(2014, 10, 1, 12, 0, 0, 0, time.UTC)
+	m := &Manifest{}
+	m.Add("b.jpg", "hb", &ospry.Metadata{ID: "B", URL: "http://foo.ospry.io/b.jpg", Size: 2, TimeCreated: t})
+	m.Add("a.jpg", "ha", &ospry.Metadata{ID: "A", URL: "http://foo.ospry.io/a.jpg", Size: 1, TimeCreated: t})
+	return m
+}
+
+func TestRoundTrip(t *testing.T) {
+	m := testManifest()
+	want := &Manifest{Entries: m.sorted()}
+
+	var buf bytes.Buffer
+	if err := m.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadJSON(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v\n         want %v", got, want)
+	}
+
+	buf.Reset()
+	if err := m.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got, err = ReadCSV(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v\n         want %v", got, want)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	old := testManifest()
+	new := testManifest()
+	new.Add("a.jpg", "ha2", &ospry.Metadata{ID: "A2"})
+	new.Add("c.jpg", "hc", &ospry.Metadata{ID: "C"})
+	new.Entries = new.Entries[1:] // drop b.jpg
+	d := Compare(old, new)
+	if len(d.Added) != 1 || d.Added[0].Path != "c.jpg" {
+		t.Fatalf("got added %v, want c.jpg", d.Added)
+	}
+	if len(d.Removed) != 1 || d.Removed[0].Path != "b.jpg" {
+		t.Fatalf("got removed %v, want b.jpg", d.Removed)
+	}
+	if len(d.Changed) != 1 || d.Changed[0].ID != "A2" {
+		t.Fatalf("got changed %v, want A2", d.Changed)
+	}
+}
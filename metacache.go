@@ -0,0 +1,117 @@
+package ospry
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/ospry/ospry-go/cache"
+)
+
+// metadataKey is the key an image's metadata is cached under.
+func metadataKey(id string) string {
+	return "metadata/" + id
+}
+
+// cachedMetadata returns the metadata of the image with the given id
+// from the client's MetadataCache, bound to the client.
+func (c *Client) cachedMetadata(id string) (*Metadata, bool) {
+	if c.MetadataCache == nil {
+		return nil, false
+	}
+	b, ok := c.MetadataCache.Get(metadataKey(id))
+	if !ok || len(b) == 0 {
+		return nil, false
+	}
+	m := &Metadata{}
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, false
+	}
+	return c.Bind(m), true
+}
+
+// cacheMetadata adds m to the client's MetadataCache.
+func (c *Client) cacheMetadata(m *Metadata) {
+	if c.MetadataCache == nil || m == nil || m.ID == "" {
+		return
+	}
+	if b, err := json.Marshal(m); err == nil {
+		c.MetadataCache.Set(metadataKey(m.ID), b)
+	}
+}
+
+// uncacheMetadata removes the metadata of a deleted image from the
+// client's MetadataCache. Caches that can't delete entries are given
+// an empty entry, which reads as a miss.
+func (c *Client) uncacheMetadata(id string) {
+	switch mc := c.MetadataCache.(type) {
+	case nil:
+	case cache.Deleter:
+		mc.Delete(metadataKey(id))
+	default:
+		mc.Set(metadataKey(id), nil)
+	}
+}
+
+// A flightGroup coalesces concurrent metadata fetches for the same id.
+type flightGroup struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+type flight struct {
+	done chan struct{}
+	m    *Metadata
+	err  error
+}
+
+// do calls fetch, unless a call for id is already in flight, in which
+// case it waits for that call's result. Each caller gets its own copy
+// of the metadata.
+func (g *flightGroup) do(id string, fetch func() (*Metadata, error)) (*Metadata, error) {
+	g.mu.Lock()
+	if g.flights == nil {
+		g.flights = map[string]*flight{}
+	}
+	f, ok := g.flights[id]
+	if !ok {
+		f = &flight{done: make(chan struct{})}
+		g.flights[id] = f
+		g.mu.Unlock()
+		f.m, f.err = fetch()
+		g.mu.Lock()
+		delete(g.flights, id)
+		g.mu.Unlock()
+		close(f.done)
+	} else {
+		g.mu.Unlock()
+		<-f.done
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.m.clone(), nil
+}
+
+// clone returns a copy of m that shares none of its maps.
+func (m *Metadata) clone() *Metadata {
+	c := *m
+	if m.Crops != nil {
+		c.Crops = make(map[string]Crop, len(m.Crops))
+		for k, v := range m.Crops {
+			c.Crops[k] = v
+		}
+	}
+	if m.Tags != nil {
+		c.Tags = make(map[string]string, len(m.Tags))
+		for k, v := range m.Tags {
+			c.Tags[k] = v
+		}
+	}
+	if m.Extra != nil {
+		c.Extra = make(map[string]json.RawMessage, len(m.Extra))
+		for k, v := range m.Extra {
+			c.Extra[k] = v
+		}
+	}
+	return &c
+}
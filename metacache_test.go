@@ -0,0 +1,98 @@
+package ospry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ospry/ospry-go/cache"
+)
+
+func TestMetadataCache(t *testing.T) {
+	reads := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			reads++
+			w.Write([]byte(`{"metadata":{"id":"foo","isPrivate":false}}`))
+		case "PUT":
+			w.Write([]byte(`{"metadata":{"id":"foo","isPrivate":true}}`))
+		case "DELETE":
+			w.Write([]byte(`{"metadata":{"id":"foo"}}`))
+		}
+	}))
+	defer ts.Close()
+	for _, mc := range []cache.Cache{cache.NewLRU(1 << 20), setOnly{cache.NewLRU(1 << 20)}} {
+		reads = 0
+		c := New("sk-test-key")
+		c.ServerURL = ts.URL
+		c.MetadataCache = mc
+		for i := 0; i < 3; i++ {
+			if _, err := c.GetMetadata("foo"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if reads != 1 {
+			t.Fatalf("got %d reads, want 1", reads)
+		}
+		if _, err := c.MakePrivate("foo"); err != nil {
+			t.Fatal(err)
+		}
+		m, err := c.GetMetadata("foo")
+		if err != nil || !m.IsPrivate || reads != 1 {
+			t.Fatalf("got %+v, %v after %d reads, want cached private image", m, err, reads)
+		}
+		if m.client != c {
+			t.Fatal("got cached metadata unbound, want it bound to the client")
+		}
+		if err := c.Delete("foo"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.GetMetadata("foo"); err != nil || reads != 2 {
+			t.Fatalf("got %v after %d reads, want 2 reads", err, reads)
+		}
+	}
+}
+
+// setOnly hides a cache's Delete method.
+type setOnly struct{ c cache.Cache }
+
+func (s setOnly) Get(key string) ([]byte, bool) { return s.c.Get(key) }
+func (s setOnly) Set(key string, data []byte)   { s.c.Set(key, data) }
+
+func TestCoalesceMetadata(t *testing.T) {
+	var reads int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&reads, 1)
+		time.Sleep(50 * time.Millisecond)
+		writeMetadata(w, &Metadata{ID: "foo", URL: "http://foo.ospry.io/bar.jpg", Tags: map[string]string{"a": "b"}})
+	})
+	c.CoalesceMetadata = true
+	var wg sync.WaitGroup
+	results := make([]*Metadata, 20)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			m, err := c.GetMetadata("foo")
+			if err != nil {
+				t.Error(err)
+			}
+			results[i] = m
+		}(i)
+	}
+	wg.Wait()
+	if n := atomic.LoadInt32(&reads); n != 1 {
+		t.Fatalf("got %d reads, want 1", n)
+	}
+	if results[0] == results[1] || results[1].ID != "foo" {
+		t.Fatalf("got %v and %v, want separate copies of foo", results[0], results[1])
+	}
+	results[0].Tags["a"] = "changed"
+	if results[1].Tags["a"] != "b" {
+		t.Fatalf("got tags %v, want copies not sharing tags", results[1].Tags)
+	}
+}
@@ -0,0 +1,92 @@
+package ospry
+
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrUnsignedPrivate is returned when formatting an unsigned url to a
+// private image, which nobody would be able to download.
+var ErrUnsignedPrivate = errors.New("ospry: urls to private images need a TimeExpired")
+
+// FormatMetaURL calls FormatMetaURL on the default client.
+func FormatMetaURL(m *Metadata, opts *RenderOpts) (string, error) {
+	return DefaultClient.FormatMetaURL(m, opts)
+}
+
+// DownloadMeta calls DownloadMeta on the default client.
+func DownloadMeta(m *Metadata, opts *RenderOpts) (io.ReadCloser, error) {
+	return DefaultClient.DownloadMeta(m, opts)
+}
+
+// FormatMetaURL is like FormatURL, but formats the url of the image
+// described by m. The https url is used if the client prefers https.
+// Urls to private images must be signed: ErrUnsignedPrivate is
+// returned if opts has no TimeExpired.
+func (c *Client) FormatMetaURL(m *Metadata, opts *RenderOpts) (string, error) {
+	urlstr, err := c.metaURL(m, opts)
+	if err != nil {
+		return "", err
+	}
+	if err := checkCrop(m, opts); err != nil {
+		return "", err
+	}
+	return c.FormatURL(urlstr, c.versioned(m, opts))
+}
+
+// DownloadMeta is like Download, but downloads the image described by
+// m. Private images are subject to the same rules as in
+// FormatMetaURL.
+func (c *Client) DownloadMeta(m *Metadata, opts *RenderOpts) (io.ReadCloser, error) {
+	urlstr, err := c.metaURL(m, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCrop(m, opts); err != nil {
+		return nil, err
+	}
+	return c.Download(urlstr, c.versioned(m, opts))
+}
+
+func (c *Client) metaURL(m *Metadata, opts *RenderOpts) (string, error) {
+	if m.IsPrivate && (opts == nil || opts.TimeExpired.IsZero()) {
+		return "", ErrUnsignedPrivate
+	}
+	if c.PreferHTTPS && m.HTTPSURL != "" {
+		if custom, _, err := c.customDomain(m.URL); err != nil || custom == "" {
+			return m.HTTPSURL, nil
+		}
+	}
+	if m.URL == "" {
+		return "", errors.New("ospry: metadata has no url")
+	}
+	return m.URL, nil
+}
+
+// Bind attaches the client to m, so that m's url methods (e.g.
+// SignedURL) use it. Metadata returned by a client is already bound to
+// it. Unbound metadata (e.g. decoded from JSON) uses the default
+// client.
+func (c *Client) Bind(m *Metadata) *Metadata {
+	m.client = c
+	return m
+}
+
+// RenderURL calls FormatMetaURL on m's client.
+func (m *Metadata) RenderURL(opts *RenderOpts) (string, error) {
+	return m.boundClient().FormatMetaURL(m, opts)
+}
+
+// SignedURL returns a url to the image that's valid for ttl. It can be
+// used to access private images.
+func (m *Metadata) SignedURL(ttl time.Duration) (string, error) {
+	return m.boundClient().SignedURL(m, ttl, nil)
+}
+
+func (m *Metadata) boundClient() *Client {
+	if m.client == nil {
+		return DefaultClient
+	}
+	return m.client
+}
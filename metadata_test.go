@@ -0,0 +1,103 @@
+package ospry
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestFormatMetaURL(t *testing.T) {
+	c := New("sk-test-key")
+	m := &Metadata{
+		URL:      "http://foo.ospry.io/bar/baz.png",
+		HTTPSURL: "https://ssl.ospry.io/bar/baz.png?sub=foo",
+	}
+	got, err := c.FormatMetaURL(m, &RenderOpts{MaxWidth: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := m.URL + "?maxWidth=10"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	c.PreferHTTPS = true
+	got, err = c.FormatMetaURL(m, &RenderOpts{MaxWidth: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "https://ssl.ospry.io/bar/baz.png?maxWidth=10&sub=foo"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	m.IsPrivate = true
+	if _, err := c.FormatMetaURL(m, nil); err != ErrUnsignedPrivate {
+		t.Fatalf("got %v, want %v", err, ErrUnsignedPrivate)
+	}
+	if _, err := c.FormatMetaURL(m, &RenderOpts{TimeExpired: time.Now().Add(time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDownloadMeta(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("image"))
+	})
+	m := &Metadata{URL: c.ServerURL + "/foo.jpg"}
+	rc, err := c.DownloadMeta(m, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	b, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "image" {
+		t.Fatalf("got %q, want %q", b, "image")
+	}
+	m.IsPrivate = true
+	if _, err := c.DownloadMeta(m, nil); err != ErrUnsignedPrivate {
+		t.Fatalf("got %v, want %v", err, ErrUnsignedPrivate)
+	}
+}
+
+func TestBoundMetadata(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeMetadata(w, &Metadata{ID: "foo", URL: "http://foo.ospry.io/bar/baz.png", IsPrivate: true})
+	})
+	c.Key = "sk-test-bound"
+	md, err := c.GetMetadata("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := time.Now().Add(time.Minute)
+	got, err := md.RenderURL(&RenderOpts{TimeExpired: exp})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := c.FormatURL(md.URL, &RenderOpts{TimeExpired: exp})
+	if got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if _, err := md.RenderURL(nil); err != ErrUnsignedPrivate {
+		t.Fatalf("got %v, want %v", err, ErrUnsignedPrivate)
+	}
+	if _, err := md.SignedURL(time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := json.Marshal(md)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Metadata
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.boundClient() != DefaultClient {
+		t.Fatal("decoded metadata should use the default client")
+	}
+	if decoded.ID != md.ID || decoded.URL != md.URL {
+		t.Fatalf("got %v, want %v", decoded, md)
+	}
+}
@@ -0,0 +1,229 @@
+// Package migrate copies the images of one ospry account to another,
+// e.g. when splitting a staging account from production:
+//
+//	m := &migrate.Migrator{Src: prod, Dst: staging, Checkpoint: "ids.jsonl"}
+//	report, err := m.Run(ctx)
+//
+// Images are streamed from one account to the other, keeping their
+// filenames, privacy and tags, and the new id of every copied image is
+// recorded. With a Checkpoint, an interrupted run can be started again
+// and carries on where it stopped.
+package migrate
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+
+	ospry "github.com/ospry/ospry-go"
+)
+
+// DefaultConcurrency is the Concurrency of a Migrator whose
+// Concurrency is zero.
+const DefaultConcurrency = 4
+
+// A Migrator copies images from one account to another.
+type Migrator struct {
+	// Src is the client of the account copied from. If it's nil,
+	// ospry.DefaultClient is used.
+	Src *ospry.Client
+	// Dst is the client of the account copied to.
+	Dst *ospry.Client
+	// Filter selects the images to copy. If nil, all are copied.
+	Filter *ospry.ListFilter
+	// Checkpoint, if set, is the path of a file recording the ids of
+	// the images copied so far. Images it lists are skipped, so a run
+	// can be resumed by starting it again with the same file.
+	Checkpoint string
+	// Concurrency is the number of images copied at once. If zero,
+	// DefaultConcurrency is used.
+	Concurrency int
+}
+
+// A Report describes a migration run.
+type Report struct {
+	// IDs maps the ids of the images in the source account to the
+	// ids of their copies, including those copied by earlier runs
+	// recorded in the checkpoint.
+	IDs map[string]string
+	// Copied is the number of images copied by this run, and Skipped
+	// the number already copied by earlier runs.
+	Copied  int
+	Skipped int
+	// Failed maps the ids of the images that couldn't be copied to
+	// the reason. Running the migration again retries them.
+	Failed map[string]error
+}
+
+// WriteText writes a line per failed image and a summary to w.
+func (r *Report) WriteText(w io.Writer) error {
+	ids := make([]string, 0, len(r.Failed))
+	for id := range r.Failed {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if _, err := fmt.Fprintf(w, "%s\tfailed: %v\n", id, r.Failed[id]); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "%d copied, %d skipped, %d failed\n", r.Copied, r.Skipped, len(r.Failed))
+	return err
+}
+
+// Run copies the images that haven't been copied yet. Images that fail
+// to copy are reported in the report's Failed; Run itself only fails
+// if listing the images or writing the checkpoint does, returning
+// what was done until then.
+func (mg *Migrator) Run(ctx context.Context) (*Report, error) {
+	src := mg.Src
+	if src == nil {
+		src = ospry.DefaultClient
+	}
+	if mg.Dst == nil {
+		return nil, errors.New("migrate: no destination client")
+	}
+	report := &Report{IDs: map[string]string{}, Failed: map[string]error{}}
+	var cp *checkpoint
+	if mg.Checkpoint != "" {
+		ids, err := ReadCheckpoint(mg.Checkpoint)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		for id, copyID := range ids {
+			report.IDs[id] = copyID
+		}
+		if cp, err = openCheckpoint(mg.Checkpoint); err != nil {
+			return nil, err
+		}
+		defer cp.Close()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	images, errc := src.ListAll(ctx, mg.Filter)
+	n := mg.Concurrency
+	if n <= 0 {
+		n = DefaultConcurrency
+	}
+	var (
+		mu    sync.Mutex
+		wg    sync.WaitGroup
+		cpErr error
+	)
+	work := make(chan *ospry.Metadata)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for m := range work {
+				nm, err := ospry.CopyMetaBetween(src, mg.Dst, m, nil)
+				mu.Lock()
+				if err != nil {
+					report.Failed[m.ID] = err
+				} else {
+					report.IDs[m.ID] = nm.ID
+					report.Copied++
+					if cp != nil && cpErr == nil {
+						if cpErr = cp.Add(m.ID, nm.ID); cpErr != nil {
+							cancel()
+						}
+					}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for m := range images {
+		mu.Lock()
+		_, done := report.IDs[m.ID]
+		if done {
+			report.Skipped++
+		}
+		mu.Unlock()
+		if done {
+			continue
+		}
+		select {
+		case work <- m:
+		case <-ctx.Done():
+		}
+	}
+	close(work)
+	wg.Wait()
+	if cpErr != nil {
+		return report, cpErr
+	}
+	return report, <-errc
+}
+
+// ReadCheckpoint returns the ids recorded in a checkpoint file, mapping
+// the source ids to the ids of the copies. A partly written last line,
+// left by a run that was killed, is ignored.
+func ReadCheckpoint(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	ids := map[string]string{}
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		var e checkpointEntry
+		if json.Unmarshal(s.Bytes(), &e) != nil || e.Src == "" {
+			continue
+		}
+		ids[e.Src] = e.Dst
+	}
+	return ids, s.Err()
+}
+
+type checkpointEntry struct {
+	Src string `json:"src"`
+	Dst string `json:"dst"`
+}
+
+// A checkpoint appends copied ids to a file as json lines.
+type checkpoint struct {
+	f *os.File
+}
+
+func openCheckpoint(path string) (*checkpoint, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	// End a partly written last line, so the next entry starts on a
+	// line of its own.
+	last := make([]byte, 1)
+	if fi, err := f.Stat(); err == nil && fi.Size() > 0 {
+		if _, err := f.ReadAt(last, fi.Size()-1); err == nil && last[0] != '\n' {
+			if _, err := f.Write([]byte{'\n'}); err != nil {
+				f.Close()
+				return nil, err
+			}
+		}
+	}
+	return &checkpoint{f}, nil
+}
+
+func (cp *checkpoint) Add(src, dst string) error {
+	b, err := json.Marshal(checkpointEntry{src, dst})
+	if err != nil {
+		return err
+	}
+	// Each line is written at once, so a crash leaves at most the
+	// last one incomplete.
+	_, err = cp.f.Write(append(b, '\n'))
+	return err
+}
+
+func (cp *checkpoint) Close() error {
+	return cp.f.Close()
+}
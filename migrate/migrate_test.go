@@ -0,0 +1,96 @@
+package migrate
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	ospry "github.com/ospry/ospry-go"
+)
+
+func TestMigrator(t *testing.T) {
+	var src *httptest.Server
+	src = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Image urls are served through the render host.
+		if u := r.URL.Query().Get("url"); u != "" {
+			r.URL.Path = strings.TrimPrefix(u, src.URL)
+		}
+		switch {
+		case r.URL.Path == "/images":
+			images := []*ospry.Metadata{
+				{ID: "a", URL: src.URL + "/a.jpg", Filename: "a.jpg"},
+				{ID: "b", URL: src.URL + "/b.png", Filename: "b.png", IsPrivate: true, Tags: map[string]string{"k": "v"}},
+				{ID: "c", URL: src.URL + "/c.jpg", Filename: "c.jpg"},
+				{ID: "broken", URL: src.URL + "/broken.jpg", Filename: "broken.jpg"},
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"images": images})
+		case r.URL.Path == "/broken.jpg":
+			w.WriteHeader(http.StatusNotFound)
+		case r.URL.Path == "/b.png" && r.URL.Query().Get("signature") == "":
+			w.WriteHeader(http.StatusForbidden)
+			return
+		default:
+			w.Write([]byte("data of " + r.URL.Path))
+		}
+	}))
+	defer src.Close()
+
+	var mu sync.Mutex
+	uploads := map[string]string{}
+	dst := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		q := r.URL.Query()
+		mu.Lock()
+		uploads[q.Get("filename")] = string(b) + " private=" + q.Get("isPrivate") + " tags=" + q.Get("tags")
+		mu.Unlock()
+		m := &ospry.Metadata{ID: "new-" + q.Get("filename")}
+		json.NewEncoder(w).Encode(map[string]interface{}{"metadata": m})
+	}))
+	defer dst.Close()
+
+	dir, err := ioutil.TempDir("", "migrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "ids.jsonl")
+	// "a" was copied by an earlier run, which was killed mid-write.
+	ioutil.WriteFile(path, []byte(`{"src":"a","dst":"new-a.jpg"}`+"\n"+`{"src":"c","d`), 0644)
+
+	srcClient := ospry.New("sk-test-src")
+	srcClient.ServerURL = src.URL
+	srcClient.HTTPClient = src.Client()
+	srcClient.RenderHost = strings.TrimPrefix(src.URL, "https://")
+	dstClient := ospry.New("sk-test-dst")
+	dstClient.ServerURL = dst.URL
+	m := &Migrator{Src: srcClient, Dst: dstClient, Checkpoint: path}
+	report, err := m.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Copied != 2 || report.Skipped != 1 || len(report.Failed) != 1 || report.Failed["broken"] == nil {
+		t.Fatalf("got %d copied, %d skipped, failed %v, want 2, 1, broken", report.Copied, report.Skipped, report.Failed)
+	}
+	if _, ok := uploads["a.jpg"]; ok {
+		t.Fatal("got a.jpg copied again, want it skipped")
+	}
+	if got, want := uploads["b.png"], "data of /b.png private=true"; !strings.HasPrefix(got, want) {
+		t.Fatalf("got %s, want %s...", got, want)
+	}
+	ids, err := ReadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"a", "b", "c"} {
+		if want := report.IDs[id]; want == "" || ids[id] != want {
+			t.Fatalf("got %s -> %s in checkpoint, want %s", id, ids[id], want)
+		}
+	}
+}
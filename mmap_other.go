@@ -0,0 +1,14 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package ospry
+
+import (
+	"errors"
+	"os"
+)
+
+// mmap isn't supported on this platform; uploads read files normally.
+func mmap(f *os.File, size int64) ([]byte, func(), error) {
+	return nil, nil, errors.New("ospry: mmap not supported")
+}
@@ -0,0 +1,74 @@
+package ospry
+
+import (
+	"errors"
+	"net/url"
+)
+
+// An OpError is returned by a client's operations when they fail. It
+// records which call on which image failed, so that errors in logs can
+// be traced back. Use errors.As to get at the api's *Error, or
+// errors.Is to compare with the package's errors.
+type OpError struct {
+	// Op is the operation, e.g. "ospry.Upload".
+	Op string
+	// ID is the id of the image operated on, if known.
+	ID string
+	// URL is the url requested, without credentials or signature.
+	URL string
+	Err error
+}
+
+func (e *OpError) Error() string {
+	s := e.Op
+	if e.ID != "" {
+		s += " " + e.ID
+	}
+	if e.URL != "" {
+		s += " " + `"` + e.URL + `"`
+	}
+	return s + ": " + e.Err.Error()
+}
+
+func (e *OpError) Unwrap() error {
+	return e.Err
+}
+
+// opError wraps err in an *OpError, unless it's nil or already wrapped
+// by an operation that called another.
+func opError(op, id, urlstr string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var oe *OpError
+	if errors.As(err, &oe) {
+		return err
+	}
+	return &OpError{Op: op, ID: id, URL: redactURL(urlstr), Err: err}
+}
+
+// redactURL strips the credentials and the signature from a url.
+func redactURL(urlstr string) string {
+	u, err := url.Parse(urlstr)
+	if err != nil {
+		return ""
+	}
+	u.User = nil
+	if q := u.Query(); q.Get("signature") != "" {
+		q.Del("signature")
+		u.RawQuery = q.Encode()
+	}
+	return u.String()
+}
+
+// apiURL returns the url of an api path, for OpErrors.
+func (c *Client) apiURL(path string) string {
+	return c.ServerURL + path
+}
+
+// apiError returns the api's *Error in err's chain, if any.
+func apiError(err error) (*Error, bool) {
+	var e *Error
+	ok := errors.As(err, &e)
+	return e, ok
+}
@@ -0,0 +1,135 @@
+package ospry
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"io"
+	"io/ioutil"
+)
+
+// DefaultOrientQuality is the jpeg quality of renders oriented by the
+// client when no Quality was requested (see Client.OrientFallback).
+const DefaultOrientQuality = 90
+
+// orient reads a render and, if it's a jpeg with an EXIF orientation
+// other than the default, returns it oriented. The render is decoded
+// within the client's decode limits.
+func (c *Client) orient(rc io.ReadCloser, quality int) (io.ReadCloser, error) {
+	b, err := ioutil.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		return nil, err
+	}
+	o := exifOrientation(b)
+	if o < 2 || o > 8 {
+		return ioutil.NopCloser(bytes.NewReader(b)), nil
+	}
+	img, _, err := c.DecodeImage(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	if quality <= 0 {
+		quality = DefaultOrientQuality
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, orientImage(img, o), &jpeg.Options{Quality: quality}); err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(&buf), nil
+}
+
+// exifOrientation returns the orientation tag of a jpeg's EXIF data,
+// or 0 if it has none.
+func exifOrientation(b []byte) int {
+	if len(b) < 4 || b[0] != 0xff || b[1] != 0xd8 {
+		return 0
+	}
+	for i := 2; i+4 <= len(b) && b[i] == 0xff; {
+		marker := b[i+1]
+		n := int(binary.BigEndian.Uint16(b[i+2:]))
+		if marker == 0xda {
+			// Start of scan: the metadata segments are over.
+			return 0
+		}
+		// Segment lengths count themselves, so they're at least 2.
+		if n < 2 || i+2+n > len(b) {
+			return 0
+		}
+		seg := b[i+4 : i+2+n]
+		if marker == 0xe1 && bytes.HasPrefix(seg, []byte("Exif\x00\x00")) {
+			return tiffOrientation(seg[6:])
+		}
+		i += 2 + n
+	}
+	return 0
+}
+
+// tiffOrientation returns the orientation tag of the first IFD of
+// EXIF's TIFF structure.
+func tiffOrientation(t []byte) int {
+	if len(t) < 8 {
+		return 0
+	}
+	var order binary.ByteOrder
+	switch string(t[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+	off := order.Uint32(t[4:])
+	if uint64(off)+2 > uint64(len(t)) {
+		return 0
+	}
+	ifd := int(off)
+	entries := int(order.Uint16(t[ifd:]))
+	for e := 0; e < entries; e++ {
+		p := ifd + 2 + 12*e
+		if p+12 > len(t) {
+			return 0
+		}
+		if order.Uint16(t[p:]) == 0x0112 {
+			return int(order.Uint16(t[p+8:]))
+		}
+	}
+	return 0
+}
+
+// orientImage returns img transformed as EXIF orientation o says, so
+// that it displays upright without its orientation tag.
+func orientImage(img image.Image, o int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if o >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch o {
+			case 2:
+				sx, sy = w-1-x, y
+			case 3:
+				sx, sy = w-1-x, h-1-y
+			case 4:
+				sx, sy = x, h-1-y
+			case 5:
+				sx, sy = y, x
+			case 6:
+				sx, sy = y, h-1-x
+			case 7:
+				sx, sy = w-1-y, h-1-x
+			case 8:
+				sx, sy = w-1-y, x
+			}
+			dst.Set(x, y, img.At(b.Min.X+sx, b.Min.Y+sy))
+		}
+	}
+	return dst
+}
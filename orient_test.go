@@ -0,0 +1,97 @@
+package ospry
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// exifJPEG encodes a 16x8 jpeg, red on the left and blue on the right,
+// tagged with the given EXIF orientation.
+func exifJPEG(t *testing.T, orientation uint16) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 16; x++ {
+			c := color.RGBA{255, 0, 0, 255}
+			if x >= 8 {
+				c = color.RGBA{0, 0, 255, 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatal(err)
+	}
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x01\x01\x12\x00\x03\x00\x00\x00\x01\x00\x00\x00\x00\x00\x00\x00\x00")
+	binary.BigEndian.PutUint16(tiff[18:], orientation)
+	app1 := append([]byte("Exif\x00\x00"), tiff...)
+	seg := []byte{0xff, 0xe1, 0, 0}
+	binary.BigEndian.PutUint16(seg[2:], uint16(len(app1)+2))
+	b := buf.Bytes()
+	return append(append(append([]byte{}, b[:2]...), append(seg, app1...)...), b[2:]...)
+}
+
+func TestOrientFallback(t *testing.T) {
+	data := exifJPEG(t, 6)
+	if o := exifOrientation(data); o != 6 {
+		t.Fatalf("got orientation %d, want 6", o)
+	}
+	var query string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		w.Write(data)
+	})
+	c.OrientFallback = true
+	urlstr := strings.TrimSuffix(c.ServerURL, "/v1") + "/photo.jpg"
+	rc, err := c.Download(urlstr, &RenderOpts{AutoOrient: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	if query != "autoOrient=true" {
+		t.Fatalf("got %s, want autoOrient=true", query)
+	}
+	img, err := jpeg.Decode(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 8 || b.Dy() != 16 {
+		t.Fatalf("got %v, want 8x16", b)
+	}
+	if r, _, bl, _ := img.At(4, 3).RGBA(); r < bl {
+		t.Fatalf("got top %v, want red rotated to the top", img.At(4, 3))
+	}
+	if r, _, bl, _ := img.At(4, 12).RGBA(); r > bl {
+		t.Fatalf("got bottom %v, want blue rotated to the bottom", img.At(4, 12))
+	}
+
+	// Renders without an orientation are passed through.
+	data = []byte("already oriented")
+	rc, err = c.Download(urlstr, &RenderOpts{AutoOrient: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadAll(rc); string(b) != "already oriented" {
+		t.Fatalf("got %q, want the render unchanged", b)
+	}
+}
+
+func TestExifOrientationMalformed(t *testing.T) {
+	for _, b := range [][]byte{
+		{0xff, 0xd8, 0xff, 0xe1, 0x00, 0x00, 0x00, 0x00},
+		{0xff, 0xd8, 0xff, 0xe1, 0x00, 0x01, 0x00, 0x00},
+		{0xff, 0xd8, 0xff, 0xe1, 0xff, 0xff, 0x00, 0x00},
+		append([]byte("\xff\xd8\xff\xe1\x00\x16Exif\x00\x00"), "MM\x00\x2a\xff\xff\xff\xf0\x00\x00"...),
+	} {
+		if o := exifOrientation(b); o != 0 {
+			t.Fatalf("got orientation %d for % x, want 0", o, b)
+		}
+	}
+}
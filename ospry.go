@@ -40,27 +40,56 @@
 // Remember to close any ReadClosers you get from Download once you're
 // done reading.
 //
+// This package only depends on the standard library. Optional
+// features live in subpackages, so programs only build what they
+// import: cache (render caches), redis (caches and dedup state shared
+// between processes), store (tracking images in a database), ospryhttp
+// and ospryui (serving images and a gallery over http), webhook
+// (verifying and handling webhooks), manifest and rewrite (tracking
+// and rewriting uploaded files), cleanup, reconcile, usage and stats
+// (account maintenance and reporting), migrate (copying images between
+// accounts), presets, tenant (clients per customer account), pool
+// (spreading requests over several keys), secrets (reading the key
+// from secret managers), spool (queueing uploads on disk while
+// offline), jobs (running claims and deletes from a durable queue),
+// phash (finding near-duplicate images), osprytest (testing helpers)
+// and the ospry command in cmd/ospry.
+// Integrations that need third-party modules, such as metrics and
+// tracing, belong in subpackages too, never in this one.
+//
 package ospry
 
 import (
 	"bytes"
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/base64"
+	"context"
 	"encoding/json"
 	"errors"
 	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/ospry/ospry-go/cache"
 )
 
 var (
-	Formats       = []string{"jpeg", "png", "gif"}
+	// Formats are the formats images can be rendered in (see
+	// RenderOpts.Format). Webp renders are smaller than jpeg and png
+	// ones, but older browsers can't display them.
+	Formats       = []string{"jpeg", "png", "gif", "webp"}
 	DefaultClient = New("")
 )
 
+const (
+	defaultServerURL  = "https://api.ospry.io/v1"
+	defaultRenderHost = "api.ospry.io"
+)
+
 type Metadata struct {
 	ID          string    `json:"id"`
 	URL         string    `json:"url"`
@@ -73,6 +102,38 @@ type Metadata struct {
 	Size        int64     `json:"size"`
 	Height      int       `json:"height"`
 	Width       int       `json:"width"`
+	// VersionID identifies the image's current data, which changes
+	// when it's replaced (see Replace and Versions).
+	VersionID string `json:"versionId,omitempty"`
+
+	// TimeModified is when the image's data was last replaced, zero
+	// if it never was.
+	TimeModified time.Time `json:"timeModified,omitempty"`
+
+	// Extended metadata, only sent by the api when asked for (see
+	// GetExtendedMetadata and GetMetadataFields).
+	MIMEType   string `json:"mimeType,omitempty"`
+	ColorSpace string `json:"colorSpace,omitempty"`
+	BitDepth   int    `json:"bitDepth,omitempty"`
+	// Orientation is the EXIF orientation (1-8), zero if the image has
+	// none.
+	Orientation   int  `json:"orientation,omitempty"`
+	IsAnimated    bool `json:"isAnimated,omitempty"`
+	HasEXIF       bool `json:"hasExif,omitempty"`
+	HasICCProfile bool `json:"hasIccProfile,omitempty"`
+
+	// Crops are the image's named crops (see SetCrops).
+	Crops map[string]Crop `json:"crops,omitempty"`
+
+	// Tags are key/value pairs attached to the image at upload time
+	// (see UploadOpts.Tags).
+	Tags map[string]string `json:"tags,omitempty"`
+
+	// Extra holds fields sent by the api that Metadata has no field
+	// for, keyed by name. They're kept when Metadata is marshaled.
+	Extra map[string]json.RawMessage `json:"-"`
+
+	client *Client
 }
 
 type Error struct {
@@ -85,11 +146,138 @@ func (e *Error) Error() string {
 	return "ospry: " + e.Message
 }
 
+// Fit modes for RenderOpts.
+const (
+	// FitContain scales the image down to fit within MaxWidth and
+	// MaxHeight, preserving its aspect ratio. It's the default.
+	FitContain = "contain"
+	// FitCrop scales the image to cover MaxWidth x MaxHeight and crops
+	// the overflow, so the result has exactly those dimensions.
+	FitCrop = "crop"
+	// FitPad scales the image down to fit within MaxWidth x MaxHeight
+	// and pads it with the Background color, so the result has exactly
+	// those dimensions without cropping, e.g. for uniform grids.
+	FitPad = "pad"
+)
+
+// Gravities for RenderOpts: the part of the image FitCrop keeps.
+// GravityCenter is the default.
+const (
+	GravityCenter = "center"
+	GravityNorth  = "north"
+	GravitySouth  = "south"
+	GravityEast   = "east"
+	GravityWest   = "west"
+)
+
+// ICC profile handling for RenderOpts.
+const (
+	// ICCPreserve keeps the image's ICC color profile in the render,
+	// so that converted renders show the same colors.
+	ICCPreserve = "preserve"
+	// ICCStrip removes the profile, e.g. for the smallest thumbnails.
+	ICCStrip = "strip"
+)
+
 type RenderOpts struct {
 	Format      string
 	MaxHeight   int
 	MaxWidth    int
 	TimeExpired time.Time
+
+	// Quality is the encoding quality (1-100) for lossy formats. Zero
+	// leaves it up to the server.
+	Quality int
+
+	// Fit controls how the image is fitted to MaxWidth and MaxHeight
+	// (see FitContain, FitCrop and FitPad).
+	Fit string
+
+	// RenderHost overrides the client's RenderHost for a single
+	// call.
+	RenderHost string
+
+	// Download, if set, makes browsers save the image as a file with
+	// this name instead of displaying it (it's served as an
+	// attachment), e.g. for "download original" buttons.
+	Download string
+
+	// CacheTTL, if positive, is how long the render may be cached,
+	// e.g. long for immutable thumbnails and short for renders that
+	// change often. It's sent in whole seconds. A negative CacheTTL
+	// asks for the render not to be cached; zero leaves caching up to
+	// the server.
+	CacheTTL time.Duration
+
+	// Version, if set, is added to the url as a token that changes
+	// whenever the image's data does, so that the url can be cached
+	// forever (see Client.VersionedURLs and VersionToken).
+	Version string
+
+	// AutoOrient rotates and flips the render as its EXIF orientation
+	// says, so that photos taken in portrait aren't shown sideways
+	// (see also Client.OrientFallback).
+	AutoOrient bool
+
+	// ICCProfile is ICCPreserve or ICCStrip. Empty leaves it up to the
+	// server.
+	ICCProfile string
+
+	// Progressive encodes jpeg renders progressively, and Interlaced
+	// interlaces png and gif renders, so that large images paint
+	// gradually on slow connections. Each fails with other Formats.
+	Progressive bool
+	Interlaced  bool
+
+	// Lossless encodes webp renders losslessly (png renders always
+	// are), e.g. for design assets. It fails with other Formats and
+	// with Quality.
+	Lossless bool
+
+	// CompressionLevel trades encoding time for size: 1-9 for png
+	// (zlib levels) and 1-6 for webp (encoder methods). It requires
+	// Format to be one of them. Zero leaves it up to the server.
+	CompressionLevel int
+
+	// Background is the color transparent images are flattened onto
+	// when rendered to a format without transparency (e.g. png logos
+	// rendered as jpeg), and that letterboxes are filled with. It's a
+	// hex RGB color such as "#ffffff" or "fff".
+	Background string
+
+	// Trim crops uniform borders off the image before rendering it,
+	// e.g. the whitespace around screenshots and product photos.
+	// TrimThreshold is how much (1-100, in percent) border pixels may
+	// differ from the corner color and still be trimmed; zero leaves
+	// it up to the server.
+	Trim          bool
+	TrimThreshold int
+
+	// AspectRatio, as "width:height" (e.g. "16:9"), makes FitCrop and
+	// FitPad render at that ratio, so that only one of MaxWidth and
+	// MaxHeight (or neither, to keep the image's size) needs to be
+	// set.
+	AspectRatio string
+
+	// Gravity is the part of the image FitCrop keeps (see
+	// GravityCenter and others).
+	Gravity string
+
+	// Crop names a crop stored with the image (see SetCrops) to cut
+	// the render from.
+	Crop string
+}
+
+// UploadOpts are options for uploading images.
+type UploadOpts struct {
+	// IsPrivate makes the uploaded image private.
+	IsPrivate bool
+	// Tags are attached to the uploaded image.
+	Tags map[string]string
+	// Size is the number of bytes the upload's data will return, if
+	// known. It's sent as the request's Content-Length. Zero means the
+	// size comes from the data itself (see SizedReader), or is unknown.
+	Size int64
 }
 
 // SetKey changes the api key used by the default client.
@@ -107,11 +295,21 @@ func UploadPrivate(filename string, data io.Reader) (*Metadata, error) {
 	return DefaultClient.UploadPrivate(filename, data)
 }
 
+// Upload calls Upload on the default client.
+func Upload(filename string, data io.Reader, opts *UploadOpts) (*Metadata, error) {
+	return DefaultClient.Upload(filename, data, opts)
+}
+
 // Download calls Download on the default client.
 func Download(url string, opts *RenderOpts) (io.ReadCloser, error) {
 	return DefaultClient.Download(url, opts)
 }
 
+// DownloadTee calls DownloadTee on the default client.
+func DownloadTee(url string, opts *RenderOpts, w io.Writer) (io.ReadCloser, error) {
+	return DefaultClient.DownloadTee(url, opts, w)
+}
+
 // Claim calls Claim on the default client.
 func Claim(id string) (*Metadata, error) {
 	return DefaultClient.Claim(id)
@@ -137,6 +335,21 @@ func Delete(id string) error {
 	return DefaultClient.Delete(id)
 }
 
+// DeleteWithMetadata calls DeleteWithMetadata on the default client.
+func DeleteWithMetadata(id string) (*Metadata, error) {
+	return DefaultClient.DeleteWithMetadata(id)
+}
+
+// Convert calls Convert on the default client.
+func Convert(id string, format string) (*Metadata, error) {
+	return DefaultClient.Convert(id, format)
+}
+
+// Copy calls Copy on the default client.
+func Copy(id string, opts *RenderOpts) (*Metadata, error) {
+	return DefaultClient.Copy(id, opts)
+}
+
 // FormatURL calls FormatURL on the default client.
 func FormatURL(urlstr string, opts *RenderOpts) (string, error) {
 	return DefaultClient.FormatURL(urlstr, opts)
@@ -144,18 +357,240 @@ func FormatURL(urlstr string, opts *RenderOpts) (string, error) {
 
 // A Client performs authenticated API calls.
 type Client struct {
-	Key        string
-	ServerURL  string
+	Key       string
+	ServerURL string
+	// HTTPClient, if set, sends the client's requests. If nil, the
+	// client creates its own when it first needs it, with a transport
+	// from NewTransport, so its connection pool, proxy and TLS
+	// settings are independent from the rest of the process. The
+	// package never uses or modifies http.DefaultClient or
+	// http.DefaultTransport.
 	HTTPClient *http.Client
+
+	// KeyProvider, if set, supplies the client's key in place of
+	// Key, so that it can be rotated without recreating the client
+	// (see RotatingKey and KeyFile).
+	KeyProvider KeyProvider
+
+	// DialContext, if set, opens the client's connections in place of
+	// the transport's dialer, e.g. to go through a SOCKS tunnel or a
+	// specific interface. Resolver, if set, resolves the hostnames the
+	// client connects to. Both require HTTPClient's transport to be
+	// an *http.Transport, which is copied with the new dialer so its
+	// other settings are kept; with other transports, requests fail
+	// with ErrDialerUnsupported. They must be set before the client's
+	// first request.
+	DialContext func(ctx context.Context, network, addr string) (net.Conn, error)
+	Resolver    *net.Resolver
+
+	// Environment selects the environment the client is meant to
+	// talk to. If it's empty, the environment is inferred (see
+	// Env). If it's Live or Sandbox, requests made with a key from
+	// the other environment fail with ErrEnvironmentMismatch.
+	Environment Environment
+
+	// ConfirmLive must be set to run destructive batch operations
+	// (e.g. DeleteMany) against the Live environment.
+	ConfirmLive bool
+
+	// RenderHost is the host that serves signed and rendered urls
+	// (see FormatURL), e.g. a CDN or custom domain fronting ospry. It
+	// defaults to api.ospry.io for signed urls. If it's set, unsigned
+	// urls are routed through it too.
+	RenderHost string
+
+	// CustomDomains maps custom domains (e.g. images.mybrand.com) to
+	// the ospry hosts they point to (e.g. mybrand.ospry.io). Image
+	// urls returned by the client use the custom domain, and
+	// FormatURL preserves it.
+	CustomDomains map[string]string
+
+	// PreferHTTPS makes every url the client returns use https:
+	// Metadata.URL is replaced by Metadata.HTTPSURL, and urls
+	// formatted by FormatURL (and downloaded by Download) are
+	// upgraded from http.
+	PreferHTTPS bool
+
+	// VersionedURLs makes FormatMetaURL (and the Metadata url methods)
+	// add the image's version token to its urls (see VersionToken),
+	// so that they change when the image is replaced and can be
+	// cached forever, e.g. by a CDN.
+	VersionedURLs bool
+
+	// Strict makes FormatURL reject urls with unknown query
+	// parameters (e.g. a misspelled "maxwidth"), renders exceeding
+	// MaxRenderWidth or MaxRenderHeight, expiration times further
+	// than MaxSignTTL in the future, and signing urls of hosts other
+	// than ospry's and CustomDomains.
+	Strict bool
+
+	// Now, if set, replaces time.Now as the clock signed urls expire
+	// by (see SignedURL), e.g. to correct a skewed clock or in tests.
+	Now func() time.Time
+
+	// StrictDecoding makes api responses whose metadata has fields
+	// the package doesn't know fail with an *UnknownFieldsError, to
+	// catch api changes early (e.g. in staging). Otherwise unknown
+	// fields are kept in Metadata.Extra.
+	StrictDecoding bool
+	// UnknownFields, if set, is called with the names of the unknown
+	// fields of each metadata decoded from an api response that has
+	// some, e.g. to count them.
+	UnknownFields func(fields []string)
+	// Warn, if set, is called when an api response breaks the
+	// invariants the package relies on (see Warning), so that version
+	// skew is noticed before it corrupts application data.
+	Warn func(*Warning)
+
+	// Limits enforced in strict mode. Zero means no limit. When a
+	// render limit is set, the corresponding RenderOpts dimension
+	// must be given.
+	MaxRenderWidth  int
+	MaxRenderHeight int
+	MaxSignTTL      time.Duration
+
+	// MaxConcurrentUploads limits the number of uploads the client
+	// runs at once. Further uploads wait for a slot. Zero means no
+	// limit. It must be set before the client's first upload.
+	MaxConcurrentUploads int
+
+	// Cache, if set, keeps downloaded images (see Download).
+	Cache cache.Cache
+
+	// MetadataCache, if set, keeps the metadata returned by
+	// GetMetadata. Changes made through the client (claiming, privacy
+	// changes, deletes) update it. A cache shared between processes
+	// (e.g. a redis.Cache) must only be shared by clients with the
+	// same key, CustomDomains and PreferHTTPS settings.
+	MetadataCache cache.Cache
+
+	// CoalesceMetadata makes concurrent GetMetadata calls for the
+	// same id share a single api request, e.g. when a busy page needs
+	// the same image many times before it's cached.
+	CoalesceMetadata bool
+
+	// PrewarmVariants, if set, are rendered in the background right
+	// after each upload and claim (see Prewarm), so that new images'
+	// first viewers don't wait for the renders. Failures are ignored.
+	PrewarmVariants []*RenderOpts
+	// PrewarmConcurrency limits the variants of an image Prewarm
+	// requests at once. Zero means DefaultPrewarmConcurrency.
+	PrewarmConcurrency int
+
+	// ReadOnly makes every operation that would modify images
+	// (uploads, claims, privacy changes, copies, deletes) fail with
+	// ErrReadOnly without contacting the api, e.g. for dashboards and
+	// reports run with a live key.
+	ReadOnly bool
+
+	// Audit, if set, is called after every mutating operation the
+	// client runs (uploads, claims, privacy changes, copies and
+	// deletes, including those in batches), e.g. with AuditLog to
+	// keep a record of who changed which image. Actor labels the
+	// client's operations, e.g. with the user or job it acts for.
+	Audit func(*AuditEvent)
+	Actor string
+
+	// HashIndex, if set, records the content hashes of images
+	// uploaded with UploadIfAbsent, saving a listing per lookup.
+	HashIndex HashIndex
+
+	// SanitizeFilenames makes uploads clean up the filenames they're
+	// given (see SanitizeFilename). The original name is kept in the
+	// image's OriginalFilenameTag tag when it changes.
+	SanitizeFilenames bool
+
+	// MaxDownloadBytes, if positive, limits the size of images
+	// returned by Download. Larger images fail with a
+	// *DownloadTooLargeError.
+	MaxDownloadBytes int64
+
+	// OrientFallback makes Download orient jpeg renders requested with
+	// AutoOrient itself when they still carry an EXIF orientation,
+	// i.e. when the server didn't orient them. The render is decoded
+	// and encoded again, at the requested Quality or
+	// DefaultOrientQuality.
+	OrientFallback bool
+
+	// MmapUploads makes uploads of regular files memory-map them
+	// rather than reading them through the file descriptor, where the
+	// platform supports it.
+	MmapUploads bool
+
+	// MaxBytesPerSecond, if positive, limits the combined rate at
+	// which the client's uploads and downloads transfer image data.
+	MaxBytesPerSecond int64
+	// MaxOpBytesPerSecond, if positive, limits the rate of each
+	// individual upload and download.
+	MaxOpBytesPerSecond int64
+
+	// BatchConcurrency is the number of requests Batch runs at once
+	// when the server has no batch endpoint. Zero means
+	// DefaultBatchConcurrency.
+	BatchConcurrency int
+
+	// HedgeAfter, if positive, makes metadata reads that haven't been
+	// answered after HedgeAfter send a second request and use
+	// whichever response arrives first. Once enough reads have been
+	// timed, the 95th percentile of their latency is used instead.
+	// At most about a tenth of reads are hedged.
+	HedgeAfter time.Duration
+
+	// UploadRetries is the number of times a failed upload is retried.
+	// Only network errors and 5xx and 429 responses are retried, and
+	// only if the upload's data can be rewound: if it's an io.Seeker,
+	// or no bigger than RetryBufferSize, in which case it's buffered
+	// in memory. RetryBufferSize also bounds the downloads added to
+	// the client's Cache (32MB if it's zero).
+	UploadRetries   int
+	RetryBufferSize int64
+
+	// ClaimRetryWindow, if positive, is how long Claim keeps retrying
+	// images that aren't found, e.g. because they were uploaded from
+	// the browser a moment ago.
+	ClaimRetryWindow time.Duration
+
+	// Progress, if set, is told about the progress of the client's
+	// uploads and downloads.
+	Progress ProgressReporter
+
+	// SignatureAlg is the algorithm signed urls are signed with, e.g.
+	// HMACSHA512. Empty means DefaultSignatureAlg. Urls signed with
+	// any registered algorithm can be verified (see VerifySignature).
+	SignatureAlg string
+
+	// MaxImagePixels and MaxDecodeMemory limit the images the client
+	// decodes (see DecodeImage), by pixel count and by the estimated
+	// memory of the decoded image. Zero means DefaultMaxImagePixels
+	// and DefaultMaxDecodeMemory, negative means no limit.
+	MaxImagePixels  int64
+	MaxDecodeMemory int64
+
+	// ValidateUploads makes uploads check the image's dimensions
+	// against MaxImagePixels and MaxDecodeMemory first, failing with
+	// an *ImageTooLargeError instead of uploading decode bombs.
+	ValidateUploads bool
+
+	mu        sync.Mutex
+	uploadSem chan struct{}
+	bandwidth *limiter
+	noBatch   bool
+	own       *http.Client
+	dialed    *http.Client
+	dialedFor *http.Client
+	hedging   hedgeStats
+	flights   flightGroup
+
+	// noMultiUpload is set once the server rejected a multipart
+	// upload (see UploadMany).
+	noMultiUpload bool
 }
 
-// New creates a client that authenticates with the given key. By
-// default, the client's HTTPClient is http.DefaultClient.
+// New creates a client that authenticates with the given key.
 func New(key string) *Client {
 	return &Client{
-		Key:        key,
-		ServerURL:  "https://api.ospry.io/v1",
-		HTTPClient: http.DefaultClient,
+		Key:       key,
+		ServerURL: defaultServerURL,
 	}
 }
 
@@ -163,75 +598,265 @@ func New(key string) *Client {
 // image will be automatically claimed if the client was initialized
 // with your secret key.
 func (c *Client) UploadPublic(filename string, data io.Reader) (*Metadata, error) {
-	return c.uploadImage(filename, false, data)
+	return c.Upload(filename, data, nil)
 }
 
 // UploadPrivate uploads a private image with the given filename. The
 // image will be automatically claimed if the client was initialized
 // with your secret key.
 func (c *Client) UploadPrivate(filename string, data io.Reader) (*Metadata, error) {
-	return c.uploadImage(filename, true, data)
+	return c.Upload(filename, data, &UploadOpts{IsPrivate: true})
 }
 
-func (c *Client) uploadImage(filename string, isPrivate bool, data io.Reader) (*Metadata, error) {
+// Upload uploads an image with the given filename and options. A nil
+// opts uploads a public image. The image will be automatically claimed
+// if the client was initialized with your secret key.
+func (c *Client) Upload(filename string, data io.Reader, opts *UploadOpts) (m *Metadata, err error) {
+	if opts == nil {
+		opts = &UploadOpts{}
+	}
+	defer func() { err = opError("ospry.Upload", "", c.apiURL("/images"), err) }()
+	defer func() {
+		var id string
+		if m != nil {
+			id = m.ID
+		}
+		c.audit(OpUpload, id, err)
+		if err == nil {
+			c.prewarmNew(m)
+		}
+	}()
+	return c.sendImage("POST", "/images", filename, filename, data, opts, func(q url.Values, filename string, tags map[string]string) {
+		q.Add("filename", filename)
+		q.Add("isPrivate", strconv.FormatBool(opts.IsPrivate))
+		addTags(q, tags)
+	})
+}
+
+// sendImage sends image data to the api path, as uploads do: the data
+// is sanitized and validated as the client's options say, progress is
+// reported on item, and failed requests are retried. query, if not
+// nil, adds to the request's query once filename has been sanitized.
+// An empty filename isn't sent.
+func (c *Client) sendImage(method, path, item, filename string, data io.Reader, opts *UploadOpts, query func(q url.Values, filename string, tags map[string]string)) (m *Metadata, err error) {
+	if err := c.checkWrite(); err != nil {
+		return nil, err
+	}
+	defer c.acquireUpload()()
 	u, err := url.Parse(c.ServerURL)
 	if err != nil {
 		return nil, err
 	}
-	u.Path += "/images"
-	q := url.Values{}
-	q.Add("filename", filename)
-	q.Add("isPrivate", strconv.FormatBool(isPrivate))
-	u.RawQuery = q.Encode()
-	// Content-type doesn't need to match the image but it needs to be
-	// something that indicates image data (rather than
-	// multipart/form-data).
-	res, err := c.curl("POST", u.String(), "image/jpeg", data)
+	u.Path += path
+	data, size, hold, err := c.uploadBody(data)
 	if err != nil {
 		return nil, err
 	}
-	defer res.Body.Close()
-	return parseMetadata(res.Body)
+	defer hold.release()
+	if size < 0 {
+		size = bodySize(data, opts)
+	}
+	if p := c.Progress; p != nil {
+		p.Start(item, size)
+		defer func() { p.Done(item, err) }()
+	}
+	var tags map[string]string
+	if c.SanitizeFilenames && filename != "" {
+		filename, data, tags, err = sanitizeUpload(filename, data, opts.Tags)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		tags = opts.Tags
+	}
+	if c.ValidateUploads {
+		if data, err = c.validateUpload(data); err != nil {
+			return nil, err
+		}
+	}
+	// Filenames are sent as UTF-8, both in the query and (for proxies
+	// and servers that look there) in an RFC 5987 encoded
+	// Content-Disposition header.
+	filename = strings.ToValidUTF8(filename, "\uFFFD")
+	if query != nil {
+		q := url.Values{}
+		query(q, filename, tags)
+		u.RawQuery = q.Encode()
+	}
+	data, size, rewind, err := c.retryBody(data, size)
+	if err != nil {
+		return nil, err
+	}
+	body := data
+	var progress *retryProgress
+	if p := c.Progress; p != nil {
+		progress = &retryProgress{r: data, item: item, reporter: p}
+		body = progress
+	}
+	for attempt := 0; ; attempt++ {
+		// Content-type doesn't need to match the image but it needs
+		// to be something that indicates image data (rather than
+		// multipart/form-data).
+		req, err := c.newRequest(method, u.String(), "image/jpeg", c.throttle(body))
+		if err != nil {
+			return nil, err
+		}
+		if filename != "" {
+			req.Header.Set("Content-Disposition", contentDisposition(filename))
+		}
+		if size >= 0 {
+			c.setBodySize(req, data, size)
+		}
+		hold.hold(req)
+		m, retry, err := c.sendUpload(req)
+		if !retry || attempt >= c.UploadRetries {
+			return m, err
+		}
+		if rewind == nil {
+			return nil, &UploadRetryError{Err: err}
+		}
+		if err := rewind(); err != nil {
+			return nil, err
+		}
+		if progress != nil {
+			progress.rewind()
+		}
+		time.Sleep(retryDelay(attempt))
+	}
 }
 
 // GetMetadata retrieves the metadata for the image with the given id.
 func (c *Client) GetMetadata(id string) (*Metadata, error) {
 	u, err := url.Parse(c.ServerURL)
 	if err != nil {
-		return nil, err
+		return nil, opError("ospry.GetMetadata", id, "", err)
 	}
 	u.Path += "/images/" + id
-	res, err := c.curl("GET", u.String(), "application/json", nil)
+	if m, ok := c.cachedMetadata(id); ok {
+		return m, nil
+	}
+	fetch := func() (*Metadata, error) {
+		m, err := c.getMetadata(u.String())
+		if err == nil {
+			c.cacheMetadata(m)
+		}
+		return m, err
+	}
+	var m *Metadata
+	if c.CoalesceMetadata {
+		m, err = c.flights.do(id, fetch)
+	} else {
+		m, err = fetch()
+	}
 	if err != nil {
-		return nil, err
+		return nil, opError("ospry.GetMetadata", id, u.String(), err)
 	}
-	defer res.Body.Close()
-	return parseMetadata(res.Body)
+	return m, nil
 }
 
 // Download retrieves the image data at the given url. You can render
 // a modified image by providing a non-nil RenderOpts.
+//
+// If the client has a Cache, images are served from it when
+// possible. Otherwise the downloaded data is added to the cache as
+// it's read, once the whole image has been read, unless it's bigger
+// than RetryBufferSize.
 func (c *Client) Download(urlstr string, opts *RenderOpts) (io.ReadCloser, error) {
+	rc, err := c.download(urlstr, opts)
+	if err == nil && c.OrientFallback && opts != nil && opts.AutoOrient {
+		rc, err = c.orient(rc, opts.Quality)
+	}
+	return rc, opError("ospry.Download", "", urlstr, err)
+}
+
+func (c *Client) download(urlstr string, opts *RenderOpts) (io.ReadCloser, error) {
 	var err error
-	urlstr, err = FormatURL(urlstr, opts)
+	urlstr, err = c.FormatURL(urlstr, opts)
 	if err != nil {
 		return nil, err
 	}
-	res, err := c.HTTPClient.Get(urlstr)
+	if c.Cache == nil {
+		return c.fetch(urlstr)
+	}
+	key, err := cacheKey(urlstr)
+	if err != nil {
+		return nil, err
+	}
+	if b, ok := c.Cache.Get(key); ok {
+		if max := c.MaxDownloadBytes; max > 0 && int64(len(b)) > max {
+			return nil, &DownloadTooLargeError{URL: urlstr, Limit: max}
+		}
+		return ioutil.NopCloser(bytes.NewReader(b)), nil
+	}
+	body, err := c.fetch(urlstr)
+	if err != nil {
+		return nil, err
+	}
+	max := c.RetryBufferSize
+	if max <= 0 {
+		max = maxCachedDownload
+	}
+	return &cachingReader{body: body, cache: c.Cache, key: key, max: max}, nil
+}
+
+// DownloadTee is like Download, but also writes the image data to w as
+// it's read.
+func (c *Client) DownloadTee(urlstr string, opts *RenderOpts, w io.Writer) (io.ReadCloser, error) {
+	rc, err := c.Download(urlstr, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &teeReadCloser{io.TeeReader(rc, w), rc}, nil
+}
+
+func (c *Client) fetch(urlstr string) (io.ReadCloser, error) {
+	res, err := c.httpClient().Get(urlstr)
 	if err != nil {
 		return nil, err
 	}
 	if res.StatusCode != 200 {
+		res.Body.Close()
 		return nil, errors.New("ospry: download resulted in non-200 status")
 	}
-	return res.Body, nil
+	body := res.Body
+	if max := c.MaxDownloadBytes; max > 0 {
+		if res.ContentLength > max {
+			res.Body.Close()
+			return nil, &DownloadTooLargeError{URL: urlstr, Limit: max}
+		}
+		body = &limitedBody{body: body, n: max, url: urlstr}
+	}
+	if p := c.Progress; p != nil {
+		p.Start(urlstr, res.ContentLength)
+		body = &progressBody{progressReader: progressReader{body, urlstr, p}, body: body}
+	}
+	return c.throttleBody(body), nil
 }
 
 // Claim claims ownership of an image that was uploaded
 // client-side. You need to claim images to prevent them from
 // disappearing (if you've turned claiming on in your account
 // settings).
-func (c *Client) Claim(id string) (*Metadata, error) {
+//
+// Images uploaded from the browser can take a moment to become
+// visible to the api. If the client has a ClaimRetryWindow, Claim
+// retries while the image isn't found, and returns ErrNotYetVisible if
+// it still isn't at the end of the window.
+func (c *Client) Claim(id string) (m *Metadata, err error) {
+	defer func() {
+		c.audit(OpClaim, id, err)
+		if err == nil {
+			c.prewarmNew(m)
+		}
+		err = opError("ospry.Claim", id, c.apiURL("/images/"+id), err)
+	}()
+	if c.ClaimRetryWindow > 0 {
+		return c.claimWithRetry(id)
+	}
+	return c.claim(id)
+}
+
+func (c *Client) claim(id string) (*Metadata, error) {
 	return c.patch(id, map[string]interface{}{
 		"isClaimed": true,
 	})
@@ -241,34 +866,120 @@ func (c *Client) Claim(id string) (*Metadata, error) {
 // images can be downloaded by anyone who has an unexpired, signed url
 // to that image (see FormatURL).
 func (c *Client) MakePrivate(id string) (*Metadata, error) {
-	return c.patch(id, map[string]interface{}{
+	m, err := c.patch(id, map[string]interface{}{
 		"isPrivate": true,
 	})
+	c.audit(OpMakePrivate, id, err)
+	return m, opError("ospry.MakePrivate", id, c.apiURL("/images/"+id), err)
 }
 
 // MakePublic makes an image public if it isn't already. Public images
 // can be downloaded by anyone who has the url to that image.
 func (c *Client) MakePublic(id string) (*Metadata, error) {
-	return c.patch(id, map[string]interface{}{
+	m, err := c.patch(id, map[string]interface{}{
 		"isPrivate": false,
 	})
+	c.audit(OpMakePublic, id, err)
+	return m, opError("ospry.MakePublic", id, c.apiURL("/images/"+id), err)
 }
 
 // Delete deletes an image. Attempts to retrieve images that have been
 // deleted will result in 404s.
 func (c *Client) Delete(id string) error {
+	_, err := c.DeleteWithMetadata(id)
+	return err
+}
+
+// DeleteWithMetadata is like Delete, but returns the deleted image's
+// last metadata, e.g. to name it in audit logs or "file removed"
+// messages without fetching it first.
+func (c *Client) DeleteWithMetadata(id string) (*Metadata, error) {
+	m, err := c.delete(id)
+	c.audit(OpDelete, id, err)
+	return m, opError("ospry.Delete", id, c.apiURL("/images/"+id), err)
+}
+
+func (c *Client) delete(id string) (*Metadata, error) {
+	if err := c.checkWrite(); err != nil {
+		return nil, err
+	}
 	u, err := url.Parse(c.ServerURL)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	u.Path += "/images/" + id
 	res, err := c.curl("DELETE", u.String(), "application/json", nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer res.Body.Close()
-	_, err = parseMetadata(res.Body)
-	return err
+	m, err := c.decodeMetadata(res)
+	if err != nil {
+		return nil, err
+	}
+	c.uncacheMetadata(id)
+	return m, nil
+}
+
+// Convert creates a copy of an image converted to the given format
+// and stores it under a new id. Serving the copy avoids converting the
+// image every time it's rendered. The original image is unchanged.
+func (c *Client) Convert(id string, format string) (*Metadata, error) {
+	m, err := c.convert(id, format)
+	c.audit(OpCopy, id, err)
+	return m, opError("ospry.Convert", id, c.apiURL("/images/"+id+"/copies"), err)
+}
+
+func (c *Client) convert(id string, format string) (*Metadata, error) {
+	if err := c.checkWrite(); err != nil {
+		return nil, err
+	}
+	if !isFormat(format) {
+		return nil, errors.New("ospry: invalid format " + format)
+	}
+	return c.sendJSON("POST", "/images/"+id+"/copies", map[string]interface{}{
+		"format": format,
+	})
+}
+
+// Copy renders an image with the given options and stores the result
+// under a new id. The copy has the same privacy as the original.
+// Copies can't be signed, so opts.TimeExpired must be zero.
+func (c *Client) Copy(id string, opts *RenderOpts) (*Metadata, error) {
+	m, err := c.copy(id, opts)
+	c.audit(OpCopy, id, err)
+	return m, opError("ospry.Copy", id, c.apiURL("/images/"+id+"/copies"), err)
+}
+
+func (c *Client) copy(id string, opts *RenderOpts) (*Metadata, error) {
+	if err := c.checkWrite(); err != nil {
+		return nil, err
+	}
+	if opts == nil {
+		opts = &RenderOpts{}
+	}
+	if !opts.TimeExpired.IsZero() {
+		return nil, errors.New("ospry: copies can't be signed")
+	}
+	if opts.Download != "" {
+		return nil, errors.New("ospry: copies can't be downloads")
+	}
+	u, err := url.Parse(c.ServerURL)
+	if err != nil {
+		return nil, err
+	}
+	u.Path += "/images/" + id + "/copies"
+	q := url.Values{}
+	if err := opts.encode(q); err != nil {
+		return nil, err
+	}
+	u.RawQuery = q.Encode()
+	res, err := c.curl("POST", u.String(), "application/json", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	return c.decodeMetadata(res)
 }
 
 // FormatURL modifies an image url to produce a url that can be used
@@ -276,127 +987,553 @@ func (c *Client) Delete(id string) error {
 // given, the url is signed with the client's key and can be used to
 // download access a private image until TimeExpired has past. An
 // error is returned if the given url is invalid.
+//
+// Signed urls are served from the client's RenderHost, which can be
+// overridden per call with RenderOpts.RenderHost.
 func (c *Client) FormatURL(urlstr string, opts *RenderOpts) (string, error) {
 	if opts == nil {
 		opts = &RenderOpts{}
 	} else {
-		opts = &RenderOpts{
-			Format:      opts.Format,
-			MaxHeight:   opts.MaxHeight,
-			MaxWidth:    opts.MaxWidth,
-			TimeExpired: opts.TimeExpired,
-		}
+		o := *opts
+		opts = &o
 	}
 	u, err := url.Parse(urlstr)
 	if err != nil {
+		return "", &URLError{URL: urlstr, Err: err}
+	}
+	q, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return "", &URLError{URL: urlstr, Err: err}
+	}
+	if err := opts.fill(q); err != nil {
+		return "", withURL(err, urlstr)
+	}
+	if opts.TimeExpired.IsZero() && q.Get("timeExpired") != "" {
+		opts.TimeExpired, err = time.Parse(time.RFC3339Nano, q.Get("timeExpired"))
+		if err != nil {
+			return "", &URLError{URL: urlstr, Param: "timeExpired", Err: err}
+		}
+	}
+	if q.Get("url") != "" {
+		u, err = canonicalURL(q.Get("url"))
+		if err != nil {
+			return "", withURL(err, urlstr)
+		}
+	} else {
+		u.RawQuery = ""
+		if u, err = canonicalURL(u.String()); err != nil {
+			return "", withURL(err, urlstr)
+		}
+	}
+	imgURL := u.String()
+	if c.PreferHTTPS {
+		// Urls upgraded by PreferHTTPS are signed in the http form
+		// they were upgraded from.
+		imgURL = downgradeHTTPS(u, q).String()
+	}
+
+	// Images on custom domains are signed with their ospry url, which
+	// is what the server verifies, but keep being served from the
+	// custom domain.
+	customHost, imgURL, err := c.customDomain(imgURL)
+	if err != nil {
+		return "", err
+	}
+	if customHost != "" {
+		u.Host = customHost
+	}
+	if c.PreferHTTPS {
+		// Only the served url is upgraded; the image url keeps the
+		// form it's signed in.
+		if customHost != "" {
+			u.Scheme = "https"
+			q.Del("sub")
+		} else {
+			upgradeHTTPS(u, q)
+		}
+	}
+
+	if c.Strict {
+		if err := c.checkStrict(q, opts, imgURL); err != nil {
+			return "", err
+		}
+	}
+
+	// Signed? The signature only covers the image url and expiration
+	// time, so it stays valid whichever host serves the render.
+	renderHost := opts.RenderHost
+	if renderHost == "" {
+		renderHost = c.RenderHost
+	}
+	if renderHost == "" && !opts.TimeExpired.IsZero() {
+		renderHost = customHost
+	}
+	if !opts.TimeExpired.IsZero() {
+		timeExpired := opts.TimeExpired.Format(time.RFC3339Nano)
+		key, err := c.currentKey()
+		if err != nil {
+			return "", err
+		}
+		sig, err := sign(key, c.SignatureAlg, imgURL, timeExpired)
+		if err != nil {
+			return "", err
+		}
+		q.Set("signature", sig)
+		q.Del("sigAlg")
+		if c.SignatureAlg != "" && c.SignatureAlg != DefaultSignatureAlg {
+			q.Set("sigAlg", c.SignatureAlg)
+		}
+		q.Set("url", imgURL)
+		q.Set("timeExpired", timeExpired)
+		if renderHost == "" {
+			renderHost = defaultRenderHost
+		}
+	}
+	if renderHost != "" {
+		q.Set("url", imgURL)
+		if c.PreferHTTPS {
+			q.Del("sub")
+		}
+		u.Host = renderHost
+		u.Path = "/"
+		u.Scheme = "https"
+	}
+
+	if err := opts.encode(q); err != nil {
 		return "", err
 	}
-	q := u.Query()
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// fill sets the zero-valued render options in opts from the query
+// parameters of a previously formatted url.
+func (opts *RenderOpts) fill(q url.Values) error {
 	if opts.Format == "" && q.Get("format") != "" {
 		opts.Format = q.Get("format")
 	}
 	if opts.MaxWidth == 0 && q.Get("maxWidth") != "" {
 		mw64, err := strconv.ParseInt(q.Get("maxWidth"), 10, 0)
 		if err != nil {
-			return "", err
+			return &URLError{Param: "maxWidth", Err: err}
 		}
 		opts.MaxWidth = int(mw64)
 	}
 	if opts.MaxHeight == 0 && q.Get("maxHeight") != "" {
 		mh64, err := strconv.ParseInt(q.Get("maxHeight"), 10, 0)
 		if err != nil {
-			return "", err
+			return &URLError{Param: "maxHeight", Err: err}
 		}
 		opts.MaxHeight = int(mh64)
 	}
-	if opts.TimeExpired.IsZero() && q.Get("timeExpired") != "" {
-		opts.TimeExpired, err = time.Parse(time.RFC3339Nano, q.Get("timeExpired"))
+	if opts.Quality == 0 && q.Get("quality") != "" {
+		q64, err := strconv.ParseInt(q.Get("quality"), 10, 0)
 		if err != nil {
-			return "", err
+			return &URLError{Param: "quality", Err: err}
 		}
+		opts.Quality = int(q64)
 	}
-	var imgURL string
-	if q.Get("url") != "" {
-		imgURL = q.Get("url")
-		u, err = url.Parse(imgURL)
+	if opts.Fit == "" && q.Get("fit") != "" {
+		opts.Fit = q.Get("fit")
+	}
+	if opts.Version == "" && q.Get("v") != "" {
+		opts.Version = q.Get("v")
+	}
+	if err := fillBool(q, "autoOrient", &opts.AutoOrient); err != nil {
+		return err
+	}
+	if err := fillBool(q, "progressive", &opts.Progressive); err != nil {
+		return err
+	}
+	if err := fillBool(q, "interlaced", &opts.Interlaced); err != nil {
+		return err
+	}
+	if err := fillBool(q, "lossless", &opts.Lossless); err != nil {
+		return err
+	}
+	if err := fillBool(q, "trim", &opts.Trim); err != nil {
+		return err
+	}
+	if opts.TrimThreshold == 0 && q.Get("trimThreshold") != "" {
+		t, err := strconv.ParseInt(q.Get("trimThreshold"), 10, 0)
 		if err != nil {
-			return "", err
+			return &URLError{Param: "trimThreshold", Err: err}
+		}
+		opts.TrimThreshold = int(t)
+	}
+	if opts.AspectRatio == "" && q.Get("ar") != "" {
+		opts.AspectRatio = q.Get("ar")
+	}
+	if opts.Crop == "" && q.Get("crop") != "" {
+		opts.Crop = q.Get("crop")
+	}
+	if opts.Gravity == "" && q.Get("gravity") != "" {
+		opts.Gravity = q.Get("gravity")
+	}
+	if opts.Background == "" && q.Get("bg") != "" {
+		opts.Background = q.Get("bg")
+	}
+	if opts.CompressionLevel == 0 && q.Get("compression") != "" {
+		l, err := strconv.ParseInt(q.Get("compression"), 10, 0)
+		if err != nil {
+			return &URLError{Param: "compression", Err: err}
+		}
+		opts.CompressionLevel = int(l)
+	}
+	if opts.ICCProfile == "" && q.Get("icc") != "" {
+		opts.ICCProfile = q.Get("icc")
+	}
+	if opts.Download == "" && q.Get("download") != "" {
+		opts.Download = q.Get("download")
+	}
+	if opts.CacheTTL == 0 && q.Get("cacheTTL") != "" {
+		s, err := strconv.ParseInt(q.Get("cacheTTL"), 10, 64)
+		if err != nil || s < 0 {
+			return &URLError{Param: "cacheTTL", Err: errors.New("invalid ttl")}
+		}
+		opts.CacheTTL = time.Duration(s) * time.Second
+		if s == 0 {
+			opts.CacheTTL = -1
 		}
-	} else {
-		u.RawQuery = ""
-		imgURL = u.String()
 	}
+	return nil
+}
 
-	// Signed?
-	if !opts.TimeExpired.IsZero() {
-		timeExpired := opts.TimeExpired.Format(time.RFC3339Nano)
-		payload := imgURL + "?timeExpired=" + url.QueryEscape(timeExpired)
-		h := hmac.New(sha256.New, []byte(c.Key))
-		h.Write([]byte(payload))
-		q.Set("signature", base64.StdEncoding.EncodeToString(h.Sum(nil)))
-		q.Set("url", imgURL)
-		q.Set("timeExpired", timeExpired)
-		u.Host = "api.ospry.io"
-		u.Path = "/"
-		u.Scheme = "https"
+// fillBool sets an unset boolean option from the query parameter
+// param.
+func fillBool(q url.Values, param string, v *bool) error {
+	if *v || q.Get(param) == "" {
+		return nil
+	}
+	b, err := strconv.ParseBool(q.Get(param))
+	if err != nil {
+		return &URLError{Param: param, Err: err}
 	}
+	*v = b
+	return nil
+}
 
+// encode validates the render options in opts and sets the
+// corresponding query parameters in q.
+func (opts *RenderOpts) encode(q url.Values) error {
 	if opts.Format != "" {
-		found := false
-		for _, f := range Formats {
-			if opts.Format == f {
-				found = true
-				break
-			}
-		}
-		if !found {
-			return "", errors.New("ospry: invalid format " + opts.Format)
+		if !isFormat(opts.Format) {
+			return errors.New("ospry: invalid format " + opts.Format)
 		}
 		q.Set("format", opts.Format)
 	}
 	if opts.MaxHeight < 0 {
-		return "", errors.New("ospry: MaxHeight can't be negative")
+		return errors.New("ospry: MaxHeight can't be negative")
 	}
 	if opts.MaxHeight > 0 {
 		q.Set("maxHeight", strconv.FormatInt(int64(opts.MaxHeight), 10))
 	}
 	if opts.MaxWidth < 0 {
-		return "", errors.New("ospry: MaxWidth can't be negative")
+		return errors.New("ospry: MaxWidth can't be negative")
 	}
 	if opts.MaxWidth > 0 {
 		q.Set("maxWidth", strconv.FormatInt(int64(opts.MaxWidth), 10))
 	}
-	u.RawQuery = q.Encode()
-	return u.String(), nil
+	if opts.Quality < 0 || opts.Quality > 100 {
+		return errors.New("ospry: Quality must be between 1 and 100")
+	}
+	if opts.Quality > 0 {
+		q.Set("quality", strconv.FormatInt(int64(opts.Quality), 10))
+	}
+	switch opts.Fit {
+	case "", FitContain:
+	case FitCrop:
+		if (opts.MaxWidth == 0 || opts.MaxHeight == 0) && opts.AspectRatio == "" {
+			return errors.New("ospry: cropping requires MaxWidth and MaxHeight, or AspectRatio")
+		}
+	case FitPad:
+		if (opts.MaxWidth == 0 || opts.MaxHeight == 0) && opts.AspectRatio == "" {
+			return errors.New("ospry: padding requires MaxWidth and MaxHeight, or AspectRatio")
+		}
+	default:
+		return errors.New("ospry: invalid fit " + opts.Fit)
+	}
+	if opts.Fit != "" {
+		q.Set("fit", opts.Fit)
+	}
+	if opts.AspectRatio != "" {
+		if opts.Fit != FitCrop && opts.Fit != FitPad {
+			return errors.New("ospry: AspectRatio requires FitCrop or FitPad")
+		}
+		if opts.MaxWidth > 0 && opts.MaxHeight > 0 {
+			return errors.New("ospry: AspectRatio can't be combined with both MaxWidth and MaxHeight")
+		}
+		if !isAspectRatio(opts.AspectRatio) {
+			return errors.New("ospry: invalid AspectRatio " + opts.AspectRatio)
+		}
+		q.Set("ar", opts.AspectRatio)
+	}
+	switch opts.Gravity {
+	case "":
+	case GravityCenter, GravityNorth, GravitySouth, GravityEast, GravityWest:
+		if opts.Fit != FitCrop {
+			return errors.New("ospry: Gravity requires FitCrop")
+		}
+		q.Set("gravity", opts.Gravity)
+	default:
+		return errors.New("ospry: invalid gravity " + opts.Gravity)
+	}
+	if opts.Download != "" {
+		if strings.ContainsAny(opts.Download, "/\\") {
+			return errors.New("ospry: Download must be a filename, not a path")
+		}
+		q.Set("download", strings.ToValidUTF8(opts.Download, "\uFFFD"))
+	}
+	if opts.CacheTTL != 0 {
+		q.Set("cacheTTL", strconv.FormatInt(cacheSeconds(opts.CacheTTL), 10))
+	}
+	if opts.Version != "" {
+		q.Set("v", opts.Version)
+	}
+	if opts.AutoOrient {
+		q.Set("autoOrient", "true")
+	}
+	if opts.Progressive {
+		if opts.Format != "" && opts.Format != "jpeg" {
+			return errors.New("ospry: Progressive requires jpeg, not " + opts.Format)
+		}
+		q.Set("progressive", "true")
+	}
+	if opts.Interlaced {
+		if opts.Format != "" && opts.Format != "png" && opts.Format != "gif" {
+			return errors.New("ospry: Interlaced requires png or gif, not " + opts.Format)
+		}
+		q.Set("interlaced", "true")
+	}
+	if opts.Lossless {
+		if opts.Format != "" && opts.Format != "png" && opts.Format != "webp" {
+			return errors.New("ospry: Lossless requires png or webp, not " + opts.Format)
+		}
+		if opts.Quality > 0 {
+			return errors.New("ospry: Lossless can't be combined with Quality")
+		}
+		q.Set("lossless", "true")
+	}
+	if opts.CompressionLevel != 0 {
+		max := map[string]int{"png": 9, "webp": 6}[opts.Format]
+		if max == 0 {
+			return errors.New("ospry: CompressionLevel requires Format png or webp")
+		}
+		if opts.CompressionLevel < 1 || opts.CompressionLevel > max {
+			return errors.New("ospry: CompressionLevel must be between 1 and " + strconv.Itoa(max) + " for " + opts.Format)
+		}
+		q.Set("compression", strconv.Itoa(opts.CompressionLevel))
+	}
+	if opts.TrimThreshold != 0 {
+		if !opts.Trim {
+			return errors.New("ospry: TrimThreshold requires Trim")
+		}
+		if opts.TrimThreshold < 1 || opts.TrimThreshold > 100 {
+			return errors.New("ospry: TrimThreshold must be between 1 and 100")
+		}
+		q.Set("trimThreshold", strconv.Itoa(opts.TrimThreshold))
+	}
+	if opts.Trim {
+		q.Set("trim", "true")
+	}
+	if opts.Crop != "" {
+		if !isCropName(opts.Crop) {
+			return errors.New("ospry: invalid crop name " + opts.Crop)
+		}
+		q.Set("crop", opts.Crop)
+	}
+	if opts.Background != "" {
+		bg, ok := hexColor(opts.Background)
+		if !ok {
+			return errors.New("ospry: invalid Background " + opts.Background)
+		}
+		q.Set("bg", bg)
+	}
+	switch opts.ICCProfile {
+	case "":
+	case ICCPreserve, ICCStrip:
+		q.Set("icc", opts.ICCProfile)
+	default:
+		return errors.New("ospry: invalid ICC profile handling " + opts.ICCProfile)
+	}
+	return nil
+}
+
+// isAspectRatio reports whether s is a ratio of positive integers,
+// as "width:height".
+func isAspectRatio(s string) bool {
+	w, h, ok := strings.Cut(s, ":")
+	if !ok {
+		return false
+	}
+	wi, err1 := strconv.Atoi(w)
+	hi, err2 := strconv.Atoi(h)
+	return err1 == nil && err2 == nil && wi > 0 && hi > 0
+}
+
+// hexColor returns a hex RGB color, with or without "#" and in short
+// or long form, as 6 lower case digits.
+func hexColor(s string) (string, bool) {
+	s = strings.ToLower(strings.TrimPrefix(s, "#"))
+	if len(s) == 3 {
+		s = string([]byte{s[0], s[0], s[1], s[1], s[2], s[2]})
+	}
+	if len(s) != 6 {
+		return "", false
+	}
+	for _, r := range s {
+		if !strings.ContainsRune("0123456789abcdef", r) {
+			return "", false
+		}
+	}
+	return s, true
+}
+
+// cacheSeconds returns ttl in whole seconds, rounded up so that short
+// positive ttls don't turn into "don't cache".
+func cacheSeconds(ttl time.Duration) int64 {
+	if ttl < 0 {
+		return 0
+	}
+	return int64((ttl + time.Second - 1) / time.Second)
+}
+
+func isFormat(format string) bool {
+	for _, f := range Formats {
+		if format == f {
+			return true
+		}
+	}
+	return false
 }
 
 func (c *Client) curl(method, urlstr string, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := c.newRequest(method, urlstr, contentType, body)
+	if err != nil {
+		return nil, err
+	}
+	return c.do(req)
+}
+
+func (c *Client) newRequest(method, urlstr string, contentType string, body io.Reader) (*http.Request, error) {
 	req, err := http.NewRequest(method, urlstr, body)
 	if err != nil {
 		return nil, err
 	}
-	req.SetBasicAuth(c.Key, "")
+	key, err := c.currentKey()
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(key, "")
 	if contentType != "" {
 		req.Header.Set("Content-Type", contentType)
 	}
-	return c.HTTPClient.Do(req)
+	return req, nil
+}
+
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	err := c.checkEnv()
+	if err == nil {
+		err = c.checkMethod(req.Method)
+	}
+	if err != nil {
+		// Close the body as the transport would have.
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	return c.httpClient().Do(req)
 }
 
 func (c *Client) patch(id string, p interface{}) (*Metadata, error) {
+	if err := c.checkWrite(); err != nil {
+		return nil, err
+	}
+	m, err := c.sendJSON("PUT", "/images/"+id, p)
+	if err != nil {
+		return nil, err
+	}
+	c.cacheMetadata(m)
+	return m, nil
+}
+
+func (c *Client) sendJSON(method, path string, p interface{}) (*Metadata, error) {
 	u, err := url.Parse(c.ServerURL)
 	if err != nil {
 		return nil, err
 	}
-	u.Path += "/images/" + id
+	u.Path += path
 	b, err := json.Marshal(p)
 	if err != nil {
 		return nil, err
 	}
-	res, err := c.curl("PUT", u.String(), "application/json", bytes.NewReader(b))
+	res, err := c.curl(method, u.String(), "application/json", bytes.NewReader(b))
 	if err != nil {
 		return nil, err
 	}
-	return parseMetadata(res.Body)
+	defer res.Body.Close()
+	return c.decodeMetadata(res)
+}
+
+// decodeMetadata parses an api response and adjusts the resulting
+// metadata to the client's settings.
+func (c *Client) decodeMetadata(res *http.Response) (*Metadata, error) {
+	m, err := parseMetadata(res.Body)
+	c.checkShape(res, m, err)
+	if err != nil || m == nil {
+		return m, err
+	}
+	if err := c.checkFields(m); err != nil {
+		return nil, err
+	}
+	if err := c.normalizeMetadata(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// normalizeMetadata rewrites the urls in m to the form the client
+// hands out.
+func (c *Client) normalizeMetadata(m *Metadata) error {
+	c.Bind(m)
+	if m.URL == "" {
+		return nil
+	}
+	custom, _, err := c.customDomain(m.URL)
+	if err != nil {
+		return err
+	}
+	if custom != "" {
+		if m.URL, err = c.brandURL(m.URL); err != nil {
+			return err
+		}
+		if m.HTTPSURL != "" {
+			// The api's https url is on ssl.ospry.io; custom domains
+			// serve https themselves.
+			u, err := url.Parse(m.URL)
+			if err != nil {
+				return err
+			}
+			u.Scheme = "https"
+			m.HTTPSURL = u.String()
+		}
+	}
+	if c.PreferHTTPS {
+		if custom == "" && m.HTTPSURL != "" {
+			m.URL = m.HTTPSURL
+			return nil
+		}
+		u, err := url.Parse(m.URL)
+		if err != nil {
+			return err
+		}
+		q := u.Query()
+		upgradeHTTPS(u, q)
+		u.RawQuery = q.Encode()
+		m.URL = u.String()
+	}
+	return nil
 }
 
 func parseMetadata(body io.Reader) (*Metadata, error) {
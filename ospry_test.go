@@ -6,6 +6,7 @@ import (
 	"crypto/sha256"
 	"crypto/tls"
 	"encoding/base64"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"image"
@@ -14,6 +15,7 @@ import (
 	_ "image/png"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"path/filepath"
 	"reflect"
@@ -177,11 +179,19 @@ func TestGetMetadata(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if !reflect.DeepEqual(md, testMeta) {
+	if !equalMetadata(md, testMeta) {
 		t.Fatalf("got %v\n         want %v", md, testMeta)
 	}
 }
 
+// equalMetadata reports whether a and b are equal, ignoring the
+// clients they're bound to.
+func equalMetadata(a, b *Metadata) bool {
+	x, y := *a, *b
+	x.client, y.client = nil, nil
+	return reflect.DeepEqual(&x, &y)
+}
+
 func TestPrivacy(t *testing.T) {
 	if testMeta.IsPrivate {
 		t.Fatal("testMeta should start out public")
@@ -299,3 +309,43 @@ func testFormatURL(t *testing.T, c *Client, in, want []string, opts *RenderOpts)
 		}
 	}
 }
+
+func TestDeleteWithMetadata(t *testing.T) {
+	var req string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		req = r.Method + " " + r.URL.Path
+		writeMetadata(w, &Metadata{ID: "foo", Filename: "cat.jpg", Size: 1234})
+	})
+	m, err := c.DeleteWithMetadata("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req != "DELETE /v1/images/foo" {
+		t.Fatalf("got %s, want DELETE /v1/images/foo", req)
+	}
+	if m.Filename != "cat.jpg" || m.Size != 1234 {
+		t.Fatalf("got %s (%d bytes), want cat.jpg (1234 bytes)", m.Filename, m.Size)
+	}
+}
+
+// newTestClient returns a client talking to a local server that
+// handles requests with h. The server is closed when the test ends.
+func newTestClient(t *testing.T, h http.HandlerFunc) *Client {
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	c := New("sk-test-key")
+	c.ServerURL = srv.URL + "/v1"
+	c.HTTPClient = srv.Client()
+	return c
+}
+
+// writeMetadata writes md the way the api server does.
+func writeMetadata(w http.ResponseWriter, md *Metadata) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"metadata": md})
+}
+
+// writeError writes e the way the api server does.
+func writeError(w http.ResponseWriter, e *Error) {
+	json.NewEncoder(w).Encode(map[string]interface{}{"error": e})
+}
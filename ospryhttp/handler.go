@@ -0,0 +1,157 @@
+// Package ospryhttp provides http handlers for serving ospry images
+// from your own server.
+package ospryhttp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	ospry "github.com/ospry/ospry-go"
+	"github.com/ospry/ospry-go/cache"
+	"github.com/ospry/ospry-go/presets"
+)
+
+// DefaultMaxAge is how long browsers may cache images served by a
+// Handler if its MaxAge is zero.
+const DefaultMaxAge = 365 * 24 * time.Hour
+
+// Original is the preset name a Handler uses for unmodified images.
+const Original = "original"
+
+// A Handler serves renders of public images at /{id}/{preset}, where
+// preset is the name of one of the handler's presets (or Original).
+// Mount it with http.StripPrefix, e.g.:
+//
+//	http.Handle("/i/", http.StripPrefix("/i", &ospryhttp.Handler{
+//		Cache: cache.NewLRU(64 << 20),
+//	}))
+//
+// Renders are fetched from ospry once and kept in the cache, and
+// browsers may cache responses for MaxAge. ETags are hashes of the
+// renders, so conditional requests for cached renders are answered
+// without contacting ospry. Private images aren't served, but renders
+// of images made private or replaced (see ospry.Replace) after they
+// were cached are served until they're evicted.
+type Handler struct {
+	// Client fetches images. If nil, the default client is used.
+	Client *ospry.Client
+	// Cache stores renders. If nil, every request that isn't
+	// conditional fetches the image from ospry.
+	Cache cache.Cache
+	// Presets are the renders the handler serves. If nil,
+	// presets.All is used.
+	Presets []presets.Preset
+	// MaxAge is the max-age sent in Cache-Control headers. If zero,
+	// DefaultMaxAge is used.
+	MaxAge time.Duration
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 2 || parts[0] == "" {
+		http.NotFound(w, r)
+		return
+	}
+	id, name := parts[0], parts[1]
+	var opts *ospry.RenderOpts
+	if name != Original {
+		p, ok := h.preset(name)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		opts = p.RenderOpts()
+	}
+
+	maxAge := h.MaxAge
+	if maxAge == 0 {
+		maxAge = DefaultMaxAge
+	}
+	key := id + "/" + name
+	b, ok := h.get(key)
+	if !ok {
+		var err error
+		b, err = h.fetch(id, opts)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if h.Cache != nil {
+			h.Cache.Set(key, b)
+		}
+	}
+	sum := sha256.Sum256(b)
+	etag := `"` + hex.EncodeToString(sum[:12]) + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.FormatInt(int64(maxAge/time.Second), 10))
+	if match := r.Header.Get("If-None-Match"); match == "*" || strings.Contains(match, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", http.DetectContentType(b))
+	w.Header().Set("Content-Length", strconv.Itoa(len(b)))
+	if r.Method == "GET" {
+		w.Write(b)
+	}
+}
+
+func (h *Handler) preset(name string) (presets.Preset, bool) {
+	if h.Presets == nil {
+		return presets.Lookup(name)
+	}
+	for _, p := range h.Presets {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return presets.Preset{}, false
+}
+
+func (h *Handler) get(key string) ([]byte, bool) {
+	if h.Cache == nil {
+		return nil, false
+	}
+	return h.Cache.Get(key)
+}
+
+func (h *Handler) fetch(id string, opts *ospry.RenderOpts) ([]byte, error) {
+	c := h.Client
+	if c == nil {
+		c = ospry.DefaultClient
+	}
+	m, err := c.GetMetadata(id)
+	if err != nil {
+		return nil, err
+	}
+	if m.IsPrivate {
+		return nil, &ospry.Error{HTTPStatusCode: http.StatusNotFound, Message: "not found"}
+	}
+	rc, err := c.DownloadMeta(m, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return ioutil.ReadAll(rc)
+}
+
+// writeError responds with the status of api errors, and with 502 Bad
+// Gateway otherwise.
+func writeError(w http.ResponseWriter, err error) {
+	code := http.StatusBadGateway
+	var e *ospry.Error
+	if errors.As(err, &e) && e.HTTPStatusCode >= 400 && e.HTTPStatusCode < 500 {
+		code = e.HTTPStatusCode
+	}
+	http.Error(w, http.StatusText(code), code)
+}
@@ -0,0 +1,102 @@
+package ospryhttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	ospry "github.com/ospry/ospry-go"
+	"github.com/ospry/ospry-go/cache"
+)
+
+// newAPI starts a fake api server with a public image "foo" and a
+// private image "bar", and returns a client for it along with a
+// counter of image downloads.
+func newAPI(t *testing.T) (*ospry.Client, *int) {
+	downloads := 0
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/v1/images/"):
+			id := strings.TrimPrefix(r.URL.Path, "/v1/images/")
+			if id != "foo" && id != "bar" {
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"error": &ospry.Error{HTTPStatusCode: 404, Message: "not found"},
+				})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"metadata": &ospry.Metadata{ID: id, URL: srv.URL + "/img/" + id, IsPrivate: id == "bar"},
+			})
+		case strings.HasPrefix(r.URL.Path, "/img/"):
+			downloads++
+			w.Write([]byte("GIF89a " + r.URL.RawQuery))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	c := ospry.New("sk-test-key")
+	c.ServerURL = srv.URL + "/v1"
+	return c, &downloads
+}
+
+func TestHandler(t *testing.T) {
+	c, downloads := newAPI(t)
+	h := &Handler{Client: c, Cache: cache.NewLRU(1 << 20)}
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/foo/avatar", nil))
+		if w.Code != 200 {
+			t.Fatalf("got %d, want 200", w.Code)
+		}
+		if want := "GIF89a fit=crop&maxHeight=400&maxWidth=400"; w.Body.String() != want {
+			t.Fatalf("got %q, want %q", w.Body.String(), want)
+		}
+		if w.Header().Get("Content-Type") != "image/gif" {
+			t.Fatalf("got %s, want image/gif", w.Header().Get("Content-Type"))
+		}
+		if !strings.HasPrefix(w.Header().Get("Cache-Control"), "public, max-age=") {
+			t.Fatalf("got %s, want public caching", w.Header().Get("Cache-Control"))
+		}
+	}
+	if *downloads != 1 {
+		t.Fatalf("got %d downloads, want 1", *downloads)
+	}
+}
+
+func TestHandlerConditional(t *testing.T) {
+	c, downloads := newAPI(t)
+	h := &Handler{Client: c, Cache: cache.NewLRU(1 << 20)}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/foo/original", nil))
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("no ETag")
+	}
+	r := httptest.NewRequest("GET", "/foo/original", nil)
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("got %d, want 304", w.Code)
+	}
+	if *downloads != 1 {
+		t.Fatalf("got %d downloads, want 1", *downloads)
+	}
+}
+
+func TestHandlerNotFound(t *testing.T) {
+	c, _ := newAPI(t)
+	h := &Handler{Client: c}
+	for _, path := range []string{"/foo/nope", "/baz/original", "/bar/original", "/foo"} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("%s: got %d, want 404", path, w.Code)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package ospryhttp
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	ospry "github.com/ospry/ospry-go"
+)
+
+// DefaultTTL is how long the urls signed by a Proxy are valid if its
+// TTL is zero.
+const DefaultTTL = time.Minute
+
+// renderParams are the query parameters a Proxy passes on to ospry.
+var renderParams = []string{"format", "maxWidth", "maxHeight", "quality", "fit", "download", "cacheTTL", "autoOrient", "icc", "progressive", "interlaced", "lossless", "compression", "bg", "trim", "trimThreshold", "ar", "gravity", "crop"}
+
+// A Proxy serves images at /{id} (relative to the path it's mounted
+// at, see Handler), signing urls on the fly so that private images
+// can be shown to browsers without handing them expiring urls. Render
+// options can be given as query parameters, e.g.
+// /{id}?maxWidth=200&format=png.
+type Proxy struct {
+	// Client signs urls and fetches images. If nil, the default
+	// client is used.
+	Client *ospry.Client
+	// Authorize decides whether r may access the image with the given
+	// id, e.g. by checking the session. If it's nil, every request is
+	// refused.
+	Authorize func(r *http.Request, id string) bool
+	// TTL is how long signed urls are valid. It only needs to cover
+	// the time it takes to forward a request. If zero, DefaultTTL is
+	// used.
+	TTL time.Duration
+}
+
+// forwardedHeaders are the request headers a Proxy passes on.
+var forwardedHeaders = []string{"If-None-Match", "If-Modified-Since", "Range"}
+
+// copiedHeaders are the response headers a Proxy passes back.
+var copiedHeaders = []string{"Content-Type", "Content-Length", "ETag", "Last-Modified", "Content-Range", "Accept-Ranges", "Content-Disposition"}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	id := strings.Trim(r.URL.Path, "/")
+	if id == "" || strings.Contains(id, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	if p.Authorize == nil || !p.Authorize(r, id) {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+	c := p.Client
+	if c == nil {
+		c = ospry.DefaultClient
+	}
+	m, err := c.GetMetadata(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	signed, err := p.sign(c, m, r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, signed, nil)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	for _, k := range forwardedHeaders {
+		if v := r.Header.Get(k); v != "" {
+			req.Header.Set(k, v)
+		}
+	}
+	res, err := c.HTTP().Do(req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer res.Body.Close()
+	for _, k := range copiedHeaders {
+		if v := res.Header.Get(k); v != "" {
+			w.Header().Set(k, v)
+		}
+	}
+	// The response depends on who's asking, so shared caches mustn't
+	// keep it. Browsers may for the render's cache ttl, if it has one.
+	cc := "private, no-cache"
+	if ttl, err := strconv.ParseInt(r.URL.Query().Get("cacheTTL"), 10, 64); err == nil && ttl > 0 {
+		cc = "private, max-age=" + strconv.FormatInt(ttl, 10)
+	}
+	w.Header().Set("Cache-Control", cc)
+	w.WriteHeader(res.StatusCode)
+	io.Copy(w, res.Body)
+}
+
+func (p *Proxy) sign(c *ospry.Client, m *ospry.Metadata, query url.Values) (string, error) {
+	u, err := url.Parse(m.URL)
+	if err != nil {
+		return "", err
+	}
+	q := url.Values{}
+	for _, k := range renderParams {
+		if v := query.Get(k); v != "" {
+			q.Set(k, v)
+		}
+	}
+	u.RawQuery = q.Encode()
+	ttl := p.TTL
+	if ttl == 0 {
+		ttl = DefaultTTL
+	}
+	return c.SignedURL(u.String(), ttl, nil)
+}
@@ -0,0 +1,79 @@
+package ospryhttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestProxy(t *testing.T) {
+	c, _ := newAPI(t)
+	var signed *url.URL
+	var upstream context.Context
+	c.HTTPClient = &http.Client{Transport: roundTripper(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Host != "api.ospry.io" {
+			return http.DefaultTransport.RoundTrip(r)
+		}
+		signed = r.URL
+		upstream = r.Context()
+		w := httptest.NewRecorder()
+		w.Header().Set("Content-Type", "image/gif")
+		w.Write([]byte("GIF89a"))
+		return w.Result(), nil
+	})}
+	p := &Proxy{
+		Client: c,
+		Authorize: func(r *http.Request, id string) bool {
+			return r.Header.Get("Cookie") == "session=ok"
+		},
+	}
+	r := httptest.NewRequest("GET", "/bar?maxWidth=20&signature=nope", nil)
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("got %d, want 403", w.Code)
+	}
+
+	r.Header.Set("Cookie", "session=ok")
+	w = httptest.NewRecorder()
+	p.ServeHTTP(w, r)
+	if w.Code != 200 {
+		t.Fatalf("got %d, want 200", w.Code)
+	}
+	if w.Body.String() != "GIF89a" {
+		t.Fatalf("got %q, want %q", w.Body.String(), "GIF89a")
+	}
+	q := signed.Query()
+	if q.Get("maxWidth") != "20" || q.Get("signature") == "nope" || q.Get("signature") == "" {
+		t.Fatalf("got %s, want signed url with maxWidth=20", signed)
+	}
+	if w.Header().Get("Cache-Control") != "private, no-cache" {
+		t.Fatalf("got %s, want private, no-cache", w.Header().Get("Cache-Control"))
+	}
+
+	r.URL.RawQuery += "&cacheTTL=300"
+	w = httptest.NewRecorder()
+	p.ServeHTTP(w, r)
+	if got := signed.Query().Get("cacheTTL"); got != "300" {
+		t.Fatalf("got cacheTTL %s, want 300", got)
+	}
+	if got := w.Header().Get("Cache-Control"); got != "private, max-age=300" {
+		t.Fatalf("got %s, want private, max-age=300", got)
+	}
+
+	// The render is fetched for as long as the client waits for it.
+	ctx, cancel := context.WithCancel(context.Background())
+	p.ServeHTTP(httptest.NewRecorder(), r.WithContext(ctx))
+	cancel()
+	if upstream.Err() == nil {
+		t.Fatal("got a render request outliving the proxied request")
+	}
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
@@ -0,0 +1,89 @@
+// Package osprytest provides utilities for testing code that uses
+// ospry, e.g. asserting the render and signed urls an application
+// produces:
+//
+//	url, err := app.AvatarURL(user)
+//	osprytest.AssertURL(t, url, "https://api.ospry.io/?maxWidth=64&signature=*&timeExpired=*&url=http%3A%2F%2Ffoo.ospry.io%2Fbar.png")
+//	osprytest.AssertSigned(t, url, secretKey)
+//
+// It also generates signed webhook requests for testing webhook
+// handlers (see NewWebhookRequest).
+package osprytest
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	ospry "github.com/ospry/ospry-go"
+)
+
+// Placeholder replaces the values of parameters that change every time
+// a url is signed.
+const Placeholder = "*"
+
+// volatileParams are the parameters replaced by NormalizeURL.
+var volatileParams = []string{"signature", "timeExpired"}
+
+// NormalizeURL returns urlstr with the values of its signature and
+// timeExpired parameters replaced by Placeholder, and its query
+// parameters sorted, so that urls can be compared regardless of when
+// they were signed.
+func NormalizeURL(urlstr string) (string, error) {
+	u, err := url.Parse(urlstr)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	for _, k := range volatileParams {
+		if _, ok := q[k]; ok {
+			q.Set(k, Placeholder)
+		}
+	}
+	// Keep the placeholders readable.
+	u.RawQuery = strings.Replace(q.Encode(), url.QueryEscape(Placeholder), Placeholder, -1)
+	return u.String(), nil
+}
+
+// AssertURL fails the test if got and want don't describe the same
+// render once normalized (see NormalizeURL). want may use Placeholder
+// for the signature and timeExpired values.
+func AssertURL(t testing.TB, got, want string) {
+	t.Helper()
+	g, err := NormalizeURL(got)
+	if err != nil {
+		t.Fatalf("osprytest: invalid url %q: %v", got, err)
+	}
+	w, err := NormalizeURL(want)
+	if err != nil {
+		t.Fatalf("osprytest: invalid url %q: %v", want, err)
+	}
+	if g != w {
+		t.Fatalf("got url %s\n    want url %s", g, w)
+	}
+}
+
+// VerifySignature checks that urlstr was signed with key, using any
+// of the algorithms ospry supports. It doesn't check whether the url
+// has expired.
+func VerifySignature(urlstr, key string) error {
+	return ospry.VerifySignature(urlstr, key)
+}
+
+// AssertSigned fails the test unless urlstr is signed with key and
+// hasn't expired.
+func AssertSigned(t testing.TB, urlstr, key string) {
+	t.Helper()
+	if err := VerifySignature(urlstr, key); err != nil {
+		t.Fatalf("%v: %s", err, urlstr)
+	}
+	u, _ := url.Parse(urlstr)
+	exp, err := time.Parse(time.RFC3339Nano, u.Query().Get("timeExpired"))
+	if err != nil {
+		t.Fatalf("osprytest: invalid timeExpired: %v", err)
+	}
+	if exp.Before(time.Now()) {
+		t.Fatalf("osprytest: url expired at %v: %s", exp, urlstr)
+	}
+}
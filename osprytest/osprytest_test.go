@@ -0,0 +1,37 @@
+package osprytest
+
+import (
+	"testing"
+	"time"
+
+	ospry "github.com/ospry/ospry-go"
+)
+
+func TestAssertURL(t *testing.T) {
+	c := ospry.New("sk-test-key")
+	got, err := c.FormatURL("http://foo.ospry.io/bar.png", &ospry.RenderOpts{
+		MaxWidth:    64,
+		TimeExpired: time.Now().Add(time.Minute),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertURL(t, got, "https://api.ospry.io/?url=http%3A%2F%2Ffoo.ospry.io%2Fbar.png&timeExpired=*&signature=*&maxWidth=64")
+	AssertSigned(t, got, "sk-test-key")
+	if err := VerifySignature(got, "sk-test-other"); err == nil {
+		t.Fatal("got nil, want error")
+	}
+	if err := VerifySignature("http://foo.ospry.io/bar.png", "sk-test-key"); err == nil {
+		t.Fatal("got nil, want error")
+	}
+}
+
+func TestNormalizeURL(t *testing.T) {
+	got, err := NormalizeURL("http://foo.ospry.io/bar.png?maxWidth=10&format=png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "http://foo.ospry.io/bar.png?format=png&maxWidth=10"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
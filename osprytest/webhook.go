@@ -0,0 +1,68 @@
+package osprytest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	ospry "github.com/ospry/ospry-go"
+	"github.com/ospry/ospry-go/webhook"
+)
+
+// A WebhookVariant selects what kind of webhook request to generate.
+type WebhookVariant int
+
+const (
+	// ValidWebhook is a correctly signed, current event.
+	ValidWebhook WebhookVariant = iota
+	// ExpiredWebhook is correctly signed, but a day old.
+	ExpiredWebhook
+	// BadSignatureWebhook is signed with the wrong secret.
+	BadSignatureWebhook
+)
+
+var eventSeq int64
+
+// WebhookPayload returns the body and signature header of a webhook
+// event of type typ (e.g. webhook.ImageUploaded) about the image m,
+// as ospry would send it to an endpoint with the given secret. Each
+// payload has a new event id.
+func WebhookPayload(secret, typ string, m *ospry.Metadata, v WebhookVariant) ([]byte, string) {
+	t := time.Now()
+	if v == ExpiredWebhook {
+		t = t.Add(-24 * time.Hour)
+	}
+	e := &webhook.Event{
+		ID:       "evt-test-" + strconv.FormatInt(atomic.AddInt64(&eventSeq, 1), 10),
+		Type:     typ,
+		Created:  t.UTC().Truncate(time.Second),
+		Metadata: m,
+	}
+	payload, err := json.Marshal(e)
+	if err != nil {
+		panic(err)
+	}
+	if v == BadSignatureWebhook {
+		secret += "-wrong"
+	}
+	return payload, webhook.Sign(secret, payload, t)
+}
+
+// NewWebhookRequest returns a webhook request to target carrying a
+// payload made by WebhookPayload, for passing to a handler under
+// test:
+//
+//	r := osprytest.NewWebhookRequest(secret, "/hooks/ospry", webhook.ImageUploaded, md, osprytest.ValidWebhook)
+//	w := httptest.NewRecorder()
+//	app.ServeHTTP(w, r)
+func NewWebhookRequest(secret, target, typ string, m *ospry.Metadata, v WebhookVariant) *http.Request {
+	payload, header := WebhookPayload(secret, typ, m, v)
+	r := httptest.NewRequest("POST", target, bytes.NewReader(payload))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set(webhook.SignatureHeader, header)
+	return r
+}
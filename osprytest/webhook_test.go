@@ -0,0 +1,37 @@
+package osprytest
+
+import (
+	"testing"
+
+	ospry "github.com/ospry/ospry-go"
+	"github.com/ospry/ospry-go/webhook"
+)
+
+func TestWebhookRequests(t *testing.T) {
+	const secret = "whsec-test"
+	v := &webhook.Verifier{Secret: secret, Seen: webhook.NewMemorySeenCache()}
+	m := &ospry.Metadata{ID: "foo", Format: "jpeg"}
+	for _, typ := range webhook.EventTypes {
+		e, err := v.VerifyRequest(NewWebhookRequest(secret, "/hook", typ, m, ValidWebhook))
+		if err != nil {
+			t.Fatalf("%s: %v", typ, err)
+		}
+		if e.Type != typ || e.Metadata.ID != "foo" {
+			t.Fatalf("got %+v, want %s event about foo", e, typ)
+		}
+		if _, err := v.VerifyRequest(NewWebhookRequest(secret, "/hook", typ, m, ExpiredWebhook)); err != webhook.ErrExpired {
+			t.Fatalf("got %v, want %v", err, webhook.ErrExpired)
+		}
+		if _, err := v.VerifyRequest(NewWebhookRequest(secret, "/hook", typ, m, BadSignatureWebhook)); err != webhook.ErrInvalidSignature {
+			t.Fatalf("got %v, want %v", err, webhook.ErrInvalidSignature)
+		}
+	}
+
+	payload, header := WebhookPayload(secret, webhook.ImageDeleted, m, ValidWebhook)
+	if _, err := v.Verify(payload, header); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Verify(payload, header); err != webhook.ErrReplayed {
+		t.Fatalf("got %v, want %v", err, webhook.ErrReplayed)
+	}
+}
@@ -0,0 +1,406 @@
+// Package ospryui provides an image gallery and admin component for
+// web applications: it relays uploads, claims images uploaded from the
+// browser with ospry.js, lists images and toggles their privacy. Mount
+// it with http.StripPrefix, e.g.:
+//
+//	http.Handle("/admin/images/", http.StripPrefix("/admin/images", &ospryui.UI{
+//		PublicKey: "pk-test-********",
+//		Store:     &ospryui.MemoryStore{},
+//		Authorize: isAdmin,
+//	}))
+//
+// Each of its handlers (Gallery, Images, Upload, Claim, MakePrivate,
+// MakePublic and Delete) can also be mounted on its own.
+//
+// POST requests must carry the CSRF token the gallery page is rendered
+// with (see Page.CSRFToken), either in the X-CSRF-Token header, the
+// "csrf" form value or the "csrf" query parameter. The token is also
+// kept in a cookie, which it's compared with.
+package ospryui
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	ospry "github.com/ospry/ospry-go"
+)
+
+// DefaultTTL is how long the urls a UI signs for private images are
+// valid if its TTL is zero.
+const DefaultTTL = time.Minute
+
+// CSRF tokens are kept in this cookie, and sent back in this header or
+// form value.
+const (
+	CSRFCookie = "ospryui_csrf"
+	CSRFHeader = "X-CSRF-Token"
+	csrfField  = "csrf"
+)
+
+//go:embed templates/*.html
+var templateFiles embed.FS
+
+// DefaultTemplate is the template a UI renders its gallery with if its
+// Template is nil.
+var DefaultTemplate = template.Must(template.ParseFS(templateFiles, "templates/*.html"))
+
+// A UI serves an image gallery at /, backed by its Store:
+//
+//	GET  /             the gallery page
+//	GET  /images       the images, as json
+//	POST /images       uploads the multipart form's "file" parts
+//	POST /claim        claims the image with the json body's id
+//	POST /make-private makes the form's "id" image private
+//	POST /make-public  makes the form's "id" image public
+//	POST /delete       deletes the form's "id" image
+type UI struct {
+	// Client talks to ospry. If nil, the default client is used. It
+	// needs a secret key.
+	Client *ospry.Client
+	// PublicKey is the key the gallery page's ospry.js uploads with.
+	PublicKey string
+	// Store keeps track of the images. It must be set.
+	Store Store
+	// Authorize decides whether r may use the UI, e.g. by checking
+	// the session. If it's nil, every request is refused.
+	Authorize func(r *http.Request) bool
+	// Template renders the gallery page. It must define "index",
+	// which is executed with a *Page. If nil, DefaultTemplate is used.
+	Template *template.Template
+	// UploadOpts are the options images are uploaded with.
+	UploadOpts *ospry.UploadOpts
+	// Constraints are what images uploaded from the browser must
+	// meet to be claimed. Claimed images are always refused, so that
+	// browsers can't add other people's images to the Store.
+	Constraints ospry.Constraints
+	// TTL is how long urls signed for private images are valid. If
+	// zero, DefaultTTL is used.
+	TTL time.Duration
+}
+
+// A Page is what a UI's template is executed with.
+type Page struct {
+	PublicKey string
+	// CSRFToken must be sent with the page's POST requests.
+	CSRFToken string
+	Images    []*Image
+}
+
+// An Image is an image listed by a UI. DisplayURL can be shown by
+// browsers: it's signed if the image is private.
+type Image struct {
+	Metadata   *ospry.Metadata `json:"metadata"`
+	DisplayURL string          `json:"displayURL"`
+}
+
+func (u *UI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var h http.HandlerFunc
+	switch strings.TrimSuffix(r.URL.Path, "/") {
+	case "":
+		h = u.Gallery
+	case "/images":
+		if r.Method == "POST" {
+			h = u.Upload
+		} else {
+			h = u.Images
+		}
+	case "/claim":
+		h = u.Claim
+	case "/make-private":
+		h = u.MakePrivate
+	case "/make-public":
+		h = u.MakePublic
+	case "/delete":
+		h = u.Delete
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	h(w, r)
+}
+
+// Gallery serves the gallery page.
+func (u *UI) Gallery(w http.ResponseWriter, r *http.Request) {
+	if !u.allow(w, r, "GET") {
+		return
+	}
+	images, err := u.list()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	t := u.Template
+	if t == nil {
+		t = DefaultTemplate
+	}
+	token, err := csrfToken(w, r)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	var buf bytes.Buffer
+	if err := t.ExecuteTemplate(&buf, "index", &Page{PublicKey: u.PublicKey, CSRFToken: token, Images: images}); err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	buf.WriteTo(w)
+}
+
+// Images serves the list of images as json.
+func (u *UI) Images(w http.ResponseWriter, r *http.Request) {
+	if !u.allow(w, r, "GET") {
+		return
+	}
+	images, err := u.list()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, images)
+}
+
+// Upload uploads the files in the request's multipart form and
+// redirects back to the gallery.
+func (u *UI) Upload(w http.ResponseWriter, r *http.Request) {
+	if !u.allow(w, r, "POST") {
+		return
+	}
+	mr, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if p.FormName() != "file" || p.FileName() == "" {
+			continue
+		}
+		m, err := u.client().Upload(p.FileName(), p, u.UploadOpts)
+		if err == nil {
+			err = u.Store.Save(m)
+		}
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	redirect(w)
+}
+
+// Claim claims the image whose id is given in the json request body,
+// e.g. the metadata ospry.js returned from an upload, if it meets the
+// UI's Constraints, and responds with its *Image.
+func (u *UI) Claim(w http.ResponseWriter, r *http.Request) {
+	if !u.allow(w, r, "POST") {
+		return
+	}
+	var body struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.ID == "" {
+		http.Error(w, "missing image id", http.StatusBadRequest)
+		return
+	}
+	cons := u.Constraints
+	cons.MustBeUnclaimed = true
+	m, err := u.client().VerifyBeforeClaim(body.ID, cons)
+	if err == nil {
+		err = u.Store.Save(m)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	img, err := u.image(m)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, img)
+}
+
+// MakePrivate makes the image with the form's id private and
+// redirects back to the gallery.
+func (u *UI) MakePrivate(w http.ResponseWriter, r *http.Request) {
+	u.update(w, r, func(c *ospry.Client, id string) error {
+		m, err := c.MakePrivate(id)
+		if err != nil {
+			return err
+		}
+		return u.Store.Save(m)
+	})
+}
+
+// MakePublic makes the image with the form's id public and redirects
+// back to the gallery.
+func (u *UI) MakePublic(w http.ResponseWriter, r *http.Request) {
+	u.update(w, r, func(c *ospry.Client, id string) error {
+		m, err := c.MakePublic(id)
+		if err != nil {
+			return err
+		}
+		return u.Store.Save(m)
+	})
+}
+
+// Delete deletes the image with the form's id and redirects back to
+// the gallery.
+func (u *UI) Delete(w http.ResponseWriter, r *http.Request) {
+	u.update(w, r, func(c *ospry.Client, id string) error {
+		if err := c.Delete(id); err != nil {
+			return err
+		}
+		return u.Store.Delete(id)
+	})
+}
+
+// update applies fn to the image with the form's id.
+func (u *UI) update(w http.ResponseWriter, r *http.Request, fn func(c *ospry.Client, id string) error) {
+	if !u.allow(w, r, "POST") {
+		return
+	}
+	id := r.PostFormValue("id")
+	if id == "" {
+		http.Error(w, "missing image id", http.StatusBadRequest)
+		return
+	}
+	if err := fn(u.client(), id); err != nil {
+		writeError(w, err)
+		return
+	}
+	redirect(w)
+}
+
+func (u *UI) allow(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method != method && !(method == "GET" && r.Method == "HEAD") {
+		w.Header().Set("Allow", method)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return false
+	}
+	if u.Authorize == nil || !u.Authorize(r) {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return false
+	}
+	if method == "POST" && !checkCSRF(r) {
+		http.Error(w, "invalid CSRF token", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// csrfToken returns the request's CSRF token, setting a new one if it
+// has none.
+func csrfToken(w http.ResponseWriter, r *http.Request) (string, error) {
+	if c, err := r.Cookie(CSRFCookie); err == nil && c.Value != "" {
+		return c.Value, nil
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+	http.SetCookie(w, &http.Cookie{
+		Name:     CSRFCookie,
+		Value:    token,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return token, nil
+}
+
+// checkCSRF reports whether r carries the token in its CSRF cookie.
+// Multipart bodies aren't parsed, so uploads send it in the query.
+func checkCSRF(r *http.Request) bool {
+	c, err := r.Cookie(CSRFCookie)
+	if err != nil || c.Value == "" {
+		return false
+	}
+	token := r.Header.Get(CSRFHeader)
+	if token == "" {
+		if ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); ct == "multipart/form-data" {
+			token = r.URL.Query().Get(csrfField)
+		} else {
+			token = r.FormValue(csrfField)
+		}
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(c.Value)) == 1
+}
+
+func (u *UI) client() *ospry.Client {
+	if u.Client == nil {
+		return ospry.DefaultClient
+	}
+	return u.Client
+}
+
+func (u *UI) list() ([]*Image, error) {
+	stored, err := u.Store.List()
+	if err != nil {
+		return nil, err
+	}
+	images := make([]*Image, len(stored))
+	for i, m := range stored {
+		if images[i], err = u.image(m); err != nil {
+			return nil, err
+		}
+	}
+	return images, nil
+}
+
+func (u *UI) image(m *ospry.Metadata) (*Image, error) {
+	img := &Image{Metadata: m, DisplayURL: m.URL}
+	if !m.IsPrivate {
+		return img, nil
+	}
+	ttl := u.TTL
+	if ttl == 0 {
+		ttl = DefaultTTL
+	}
+	var err error
+	img.DisplayURL, err = u.client().SignedURL(m.URL, ttl, nil)
+	return img, err
+}
+
+// redirect sends the browser back to the gallery. The location is
+// relative, so that it works wherever the UI is mounted.
+func redirect(w http.ResponseWriter) {
+	w.Header().Set("Location", ".")
+	w.WriteHeader(http.StatusSeeOther)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	code := http.StatusBadGateway
+	var e *ospry.Error
+	var cerr *ospry.ConstraintError
+	if errors.As(err, &e) && e.HTTPStatusCode >= 400 && e.HTTPStatusCode < 500 {
+		code = e.HTTPStatusCode
+	} else if errors.As(err, &cerr) {
+		code = http.StatusUnprocessableEntity
+	}
+	http.Error(w, http.StatusText(code), code)
+}
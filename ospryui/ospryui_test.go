@@ -0,0 +1,207 @@
+package ospryui
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	ospry "github.com/ospry/ospry-go"
+)
+
+// fakeOspry is an ospry api server keeping its images in memory.
+func fakeOspry(t *testing.T) *httptest.Server {
+	images := map[string]*ospry.Metadata{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/images/")
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/images":
+			ioutil.ReadAll(r.Body)
+			m := &ospry.Metadata{
+				ID:        "img" + string(rune('a'+len(images))),
+				URL:       "http://foo.ospry.io/" + r.URL.Query().Get("filename"),
+				Filename:  r.URL.Query().Get("filename"),
+				IsPrivate: r.URL.Query().Get("isPrivate") == "true",
+			}
+			images[m.ID] = m
+			json.NewEncoder(w).Encode(map[string]interface{}{"metadata": m})
+		case r.Method == "PUT":
+			m := images[id]
+			if m == nil {
+				http.NotFound(w, r)
+				return
+			}
+			var body map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&body)
+			if p, ok := body["isPrivate"].(bool); ok {
+				m.IsPrivate = p
+			}
+			if _, ok := body["isClaimed"]; ok {
+				m.IsClaimed = true
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"metadata": m})
+		case r.Method == "GET" && images[id] != nil:
+			json.NewEncoder(w).Encode(map[string]interface{}{"metadata": images[id]})
+		case r.Method == "DELETE":
+			json.NewEncoder(w).Encode(map[string]interface{}{"metadata": images[id]})
+			delete(images, id)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func newUI(t *testing.T) (*UI, func()) {
+	ts := fakeOspry(t)
+	c := ospry.New("sk-test-key")
+	c.ServerURL = ts.URL
+	u := &UI{
+		Client:    c,
+		PublicKey: "pk-test-key",
+		Store:     &MemoryStore{},
+		Authorize: func(r *http.Request) bool { return r.Header.Get("X-Admin") == "yes" },
+	}
+	return u, ts.Close
+}
+
+func serve(h http.Handler, method, path, contentType string, body []byte) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, bytes.NewReader(body))
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+	r.Header.Set("X-Admin", "yes")
+	r.AddCookie(&http.Cookie{Name: CSRFCookie, Value: "token"})
+	r.Header.Set(CSRFHeader, "token")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestUI(t *testing.T) {
+	u, done := newUI(t)
+	defer done()
+	h := http.StripPrefix("/admin", u)
+
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	fw, _ := mw.CreateFormFile("file", "cat.png")
+	fw.Write([]byte("cat"))
+	mw.Close()
+	w := serve(h, "POST", "/admin/images", mw.FormDataContentType(), form.Bytes())
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "." {
+		t.Fatalf("got %d %s, want 303 .", w.Code, w.Header().Get("Location"))
+	}
+
+	w = serve(h, "POST", "/admin/make-private", "application/x-www-form-urlencoded", []byte("id=imga"))
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("got %d, want 303: %s", w.Code, w.Body)
+	}
+	w = serve(h, "GET", "/admin/images", "", nil)
+	var images []*Image
+	if err := json.NewDecoder(w.Body).Decode(&images); err != nil {
+		t.Fatal(err)
+	}
+	if len(images) != 1 || !images[0].Metadata.IsPrivate || !strings.Contains(images[0].DisplayURL, "signature=") {
+		t.Fatalf("got %+v, want one signed private image", images)
+	}
+
+	w = serve(h, "GET", "/admin/", "", nil)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "cat.png") || !strings.Contains(w.Body.String(), "pk-test-key") {
+		t.Fatalf("got %d %s, want gallery listing cat.png", w.Code, w.Body)
+	}
+
+	if w := serve(h, "POST", "/admin/delete", "", nil); w.Code != http.StatusBadRequest {
+		t.Fatalf("got %d, want 400 for a delete without an id", w.Code)
+	}
+	w = serve(h, "POST", "/admin/delete", "application/x-www-form-urlencoded", []byte("id=imga"))
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("got %d, want 303: %s", w.Code, w.Body)
+	}
+	if stored, _ := u.Store.List(); len(stored) != 0 {
+		t.Fatalf("got %d images, want 0", len(stored))
+	}
+}
+
+func TestUIClaim(t *testing.T) {
+	u, done := newUI(t)
+	defer done()
+	m, err := u.Client.UploadPublic("dog.png", strings.NewReader("dog"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := serve(u, "POST", "/claim", "application/json", []byte(`{"id":"`+m.ID+`"}`))
+	var img Image
+	if err := json.NewDecoder(w.Body).Decode(&img); err != nil {
+		t.Fatal(err)
+	}
+	if !img.Metadata.IsClaimed || img.DisplayURL != m.URL {
+		t.Fatalf("got %+v, want claimed image at %s", img.Metadata, m.URL)
+	}
+	if stored, _ := u.Store.List(); len(stored) != 1 {
+		t.Fatalf("got %d images, want 1", len(stored))
+	}
+	if w := serve(u, "POST", "/claim", "application/json", []byte(`{}`)); w.Code != http.StatusBadRequest {
+		t.Fatalf("got %d, want 400", w.Code)
+	}
+	// Claimed images, e.g. someone else's, are refused.
+	if w := serve(u, "POST", "/claim", "application/json", []byte(`{"id":"`+m.ID+`"}`)); w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("got %d, want 422 for a claimed image", w.Code)
+	}
+}
+
+func TestUICSRF(t *testing.T) {
+	u, done := newUI(t)
+	defer done()
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Admin", "yes")
+	w := httptest.NewRecorder()
+	u.ServeHTTP(w, r)
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != CSRFCookie || !strings.Contains(w.Body.String(), cookies[0].Value) {
+		t.Fatalf("got cookies %v, want a CSRF token in the page", cookies)
+	}
+	token := cookies[0].Value
+	if _, err := u.Client.UploadPublic("dog.png", strings.NewReader("dog")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		cookie, field string
+		code          int
+	}{
+		{"", "", http.StatusForbidden},
+		{token, "", http.StatusForbidden},
+		{token, "other", http.StatusForbidden},
+		{token, token, http.StatusSeeOther},
+	} {
+		r := httptest.NewRequest("POST", "/delete", strings.NewReader("id=imga&csrf="+tc.field))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.Header.Set("X-Admin", "yes")
+		if tc.cookie != "" {
+			r.AddCookie(&http.Cookie{Name: CSRFCookie, Value: tc.cookie})
+		}
+		w := httptest.NewRecorder()
+		u.ServeHTTP(w, r)
+		if w.Code != tc.code {
+			t.Fatalf("cookie %q, field %q: got %d, want %d", tc.cookie, tc.field, w.Code, tc.code)
+		}
+	}
+}
+
+func TestUIAuthorize(t *testing.T) {
+	u, done := newUI(t)
+	defer done()
+	r := httptest.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	u.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("got %d, want 403", w.Code)
+	}
+	if w := serve(u, "GET", "/claim", "", nil); w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("got %d, want 405", w.Code)
+	}
+}
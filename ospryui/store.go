@@ -0,0 +1,63 @@
+package ospryui
+
+import (
+	"sync"
+
+	ospry "github.com/ospry/ospry-go"
+)
+
+// A Store keeps the metadata of the images a UI manages, e.g. one of
+// the stores in the store package. Implementations must be safe for
+// concurrent use.
+type Store interface {
+	// Save adds m, replacing any image with the same id.
+	Save(m *ospry.Metadata) error
+	// Delete removes the image with the given id, if it's there.
+	Delete(id string) error
+	// List returns the stored images in the order they were added.
+	List() ([]*ospry.Metadata, error)
+}
+
+// A MemoryStore is a Store that keeps images in memory, e.g. for demos
+// and tests.
+type MemoryStore struct {
+	mu     sync.RWMutex
+	images []*ospry.Metadata
+}
+
+func (s *MemoryStore) Save(m *ospry.Metadata) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := *m
+	for i, v := range s.images {
+		if v.ID == m.ID {
+			s.images[i] = &c
+			return nil
+		}
+	}
+	s.images = append(s.images, &c)
+	return nil
+}
+
+func (s *MemoryStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, v := range s.images {
+		if v.ID == id {
+			s.images = append(s.images[:i], s.images[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) List() ([]*ospry.Metadata, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	images := make([]*ospry.Metadata, len(s.images))
+	for i, v := range s.images {
+		c := *v
+		images[i] = &c
+	}
+	return images, nil
+}
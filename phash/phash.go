@@ -0,0 +1,232 @@
+// Package phash finds near-duplicate images by their perceptual hashes,
+// which stay close when an image is resized, recompressed or slightly
+// edited, unlike the content hashes ospry.UploadIfAbsent compares:
+//
+//	d := &phash.Detector{Client: c, Index: phash.NewMemoryIndex()}
+//	for _, m := range existing {
+//		d.Add(m)
+//	}
+//	matches, err := d.Check(bytes.NewReader(data))
+//	if len(matches) > 0 {
+//		// data is a near-duplicate of matches[0].ID
+//	}
+//
+// Existing images are hashed from small renders, so indexing them is
+// cheap. The hashes are difference hashes (dHash) of 64 bits.
+package phash
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"io/ioutil"
+	"math/bits"
+	"sort"
+	"sync"
+	"time"
+
+	ospry "github.com/ospry/ospry-go"
+)
+
+// DefaultMaxDistance is the MaxDistance of a Detector whose MaxDistance
+// is zero.
+const DefaultMaxDistance = 10
+
+// renderSize is the size of the renders existing images are hashed
+// from.
+const renderSize = 64
+
+// A Hash is a perceptual hash.
+type Hash uint64
+
+func (h Hash) String() string {
+	return fmt.Sprintf("%016x", uint64(h))
+}
+
+// Distance returns the number of bits in which a and b differ: 0 for
+// identical images, up to 64.
+func Distance(a, b Hash) int {
+	return bits.OnesCount64(uint64(a ^ b))
+}
+
+// Of returns the hash of img: the image is shrunk to 9x8 grey pixels,
+// and each bit tells whether a pixel is brighter than its right
+// neighbour.
+func Of(img image.Image) Hash {
+	var grey [8][9]float64
+	b := img.Bounds()
+	for y := 0; y < 8; y++ {
+		y0, y1 := b.Min.Y+y*b.Dy()/8, b.Min.Y+(y+1)*b.Dy()/8
+		for x := 0; x < 9; x++ {
+			x0, x1 := b.Min.X+x*b.Dx()/9, b.Min.X+(x+1)*b.Dx()/9
+			grey[y][x] = mean(img, x0, y0, x1, y1)
+		}
+	}
+	var h Hash
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			h <<= 1
+			if grey[y][x] > grey[y][x+1] {
+				h |= 1
+			}
+		}
+	}
+	return h
+}
+
+// mean returns the mean luminance of a rectangle of img, which is at
+// least one pixel large.
+func mean(img image.Image, x0, y0, x1, y1 int) float64 {
+	if x1 <= x0 {
+		x1 = x0 + 1
+	}
+	if y1 <= y0 {
+		y1 = y0 + 1
+	}
+	var sum float64
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			sum += float64(color.Gray16Model.Convert(img.At(x, y)).(color.Gray16).Y)
+		}
+	}
+	return sum / float64((x1-x0)*(y1-y0))
+}
+
+// Read decodes a gif, jpeg or png image with the default client's
+// limits (see ospry.Client.DecodeImage) and returns its hash.
+func Read(r io.Reader) (Hash, error) {
+	return read(ospry.DefaultClient, r)
+}
+
+func read(c *ospry.Client, r io.Reader) (Hash, error) {
+	img, _, err := c.DecodeImage(r)
+	if err != nil {
+		return 0, err
+	}
+	return Of(img), nil
+}
+
+// A Match is an indexed image close to a hash.
+type Match struct {
+	ID       string
+	Distance int
+}
+
+// An Index keeps the hashes of images. Implementations must be safe for
+// concurrent use.
+type Index interface {
+	Add(id string, h Hash) error
+	Remove(id string) error
+	// Near returns the images whose hashes are at most maxDistance
+	// from h, closest first.
+	Near(h Hash, maxDistance int) ([]Match, error)
+}
+
+// A MemoryIndex is an Index that keeps its hashes in memory and
+// compares them all on every lookup, which is fast enough for a few
+// million images.
+type MemoryIndex struct {
+	mu     sync.RWMutex
+	hashes map[string]Hash
+}
+
+// NewMemoryIndex creates an empty MemoryIndex.
+func NewMemoryIndex() *MemoryIndex {
+	return &MemoryIndex{hashes: map[string]Hash{}}
+}
+
+func (x *MemoryIndex) Add(id string, h Hash) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.hashes[id] = h
+	return nil
+}
+
+func (x *MemoryIndex) Remove(id string) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	delete(x.hashes, id)
+	return nil
+}
+
+func (x *MemoryIndex) Near(h Hash, maxDistance int) ([]Match, error) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	matches := []Match{}
+	for id, other := range x.hashes {
+		if d := Distance(h, other); d <= maxDistance {
+			matches = append(matches, Match{id, d})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Distance != matches[j].Distance {
+			return matches[i].Distance < matches[j].Distance
+		}
+		return matches[i].ID < matches[j].ID
+	})
+	return matches, nil
+}
+
+// A Detector indexes images and finds near-duplicates among them.
+type Detector struct {
+	// Client downloads the renders of indexed images and decodes
+	// images within its limits. If nil, the default client is used.
+	Client *ospry.Client
+	Index  Index
+	// MaxDistance is the largest distance at which images count as
+	// near-duplicates. If zero, DefaultMaxDistance is used.
+	MaxDistance int
+}
+
+// Add hashes a small render of the image and adds it to the index.
+func (d *Detector) Add(m *ospry.Metadata) (Hash, error) {
+	c := d.client()
+	opts := &ospry.RenderOpts{MaxWidth: renderSize, MaxHeight: renderSize, Format: "png"}
+	if m.IsPrivate {
+		now := time.Now
+		if c.Now != nil {
+			now = c.Now
+		}
+		opts.TimeExpired = now().Add(time.Minute)
+	}
+	rc, err := c.DownloadMeta(m, opts)
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+	h, err := read(c, rc)
+	if err != nil {
+		return 0, err
+	}
+	// Finish the download the decoder may have left unread, so that
+	// it isn't reported as abandoned.
+	if _, err := io.Copy(ioutil.Discard, rc); err != nil {
+		return 0, err
+	}
+	return h, d.Index.Add(m.ID, h)
+}
+
+// Check reads an image, e.g. one about to be uploaded, and returns the
+// indexed images it's a near-duplicate of, closest first.
+func (d *Detector) Check(data io.Reader) ([]Match, error) {
+	h, err := read(d.client(), data)
+	if err != nil {
+		return nil, err
+	}
+	max := d.MaxDistance
+	if max == 0 {
+		max = DefaultMaxDistance
+	}
+	return d.Index.Near(h, max)
+}
+
+func (d *Detector) client() *ospry.Client {
+	if d.Client == nil {
+		return ospry.DefaultClient
+	}
+	return d.Client
+}
@@ -0,0 +1,87 @@
+package phash
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	ospry "github.com/ospry/ospry-go"
+)
+
+// testImage draws a diagonal gradient, lightened by light and mirrored
+// if flip is set.
+func testImage(w, h int, light uint8, flip bool) image.Image {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := (x*200/w + y*40/h) + int(light)
+			if flip {
+				v = ((w-1-x)*200/w + y*40/h) + int(light)
+			}
+			if v > 255 {
+				v = 255
+			}
+			img.SetGray(x, y, color.Gray{uint8(v)})
+		}
+	}
+	return img
+}
+
+func encode(img image.Image) []byte {
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	return buf.Bytes()
+}
+
+func TestDistance(t *testing.T) {
+	a := Of(testImage(640, 480, 0, false))
+	b := Of(testImage(64, 48, 10, false))
+	c := Of(testImage(640, 480, 0, true))
+	if d := Distance(a, b); d > 4 {
+		t.Fatalf("got distance %d, want a resized, lightened copy to be close", d)
+	}
+	if d := Distance(a, c); d < 32 {
+		t.Fatalf("got distance %d, want a mirrored image to be far", d)
+	}
+}
+
+func TestDetector(t *testing.T) {
+	renders := map[string][]byte{
+		"/gradient.jpg": encode(testImage(64, 48, 0, false)),
+		"/mirror.jpg":   encode(testImage(64, 48, 0, true)),
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("maxWidth") != "64" {
+			t.Errorf("got %s, want a small render", r.URL)
+		}
+		w.Write(renders[r.URL.Path])
+	}))
+	defer srv.Close()
+	d := &Detector{Client: ospry.New("sk-test-key"), Index: NewMemoryIndex()}
+	for _, name := range []string{"gradient", "mirror"} {
+		if _, err := d.Add(&ospry.Metadata{ID: name, URL: srv.URL + "/" + name + ".jpg"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	matches, err := d.Check(bytes.NewReader(encode(testImage(1024, 768, 5, false))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 || matches[0].ID != "gradient" {
+		t.Fatalf("got %v, want gradient", matches)
+	}
+	if _, err := d.Check(strings.NewReader("not an image")); err == nil {
+		t.Fatal("got no error, want a decoding error")
+	}
+	d.Client.MaxImagePixels = 1000
+	var tooLarge *ospry.ImageTooLargeError
+	if _, err := d.Check(bytes.NewReader(encode(testImage(1024, 768, 5, false)))); !errors.As(err, &tooLarge) {
+		t.Fatalf("got %v, want *ospry.ImageTooLargeError", err)
+	}
+}
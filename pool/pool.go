@@ -0,0 +1,198 @@
+// Package pool spreads requests over several clients, e.g. clients
+// with the keys of several subaccounts, so that high-volume ingestion
+// isn't held back by the rate limit of a single key:
+//
+//	p := &pool.Pool{Clients: clients, RequestsPerSecond: 10}
+//	m, err := p.Upload(ctx, "foo.jpg", r, nil)
+//
+// Clients are used in turn. Each is held to its own rate limit, and
+// clients that keep failing (rate limited, server errors, network
+// errors) are rested for a while.
+package pool
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"sync"
+	"time"
+
+	ospry "github.com/ospry/ospry-go"
+)
+
+// Defaults for a Pool's zero fields.
+const (
+	DefaultMaxFailures = 3
+	DefaultCooldown    = 30 * time.Second
+)
+
+// ErrEmpty is returned by a Pool without clients.
+var ErrEmpty = errors.New("pool: no clients")
+
+// A Pool hands out its clients round-robin. It's safe for concurrent
+// use; the clients must not be changed once it's in use.
+type Pool struct {
+	Clients []*ospry.Client
+	// RequestsPerSecond, if positive, limits how often each client is
+	// handed out.
+	RequestsPerSecond float64
+	// MaxFailures is the number of failures in a row after which a
+	// client is rested for Cooldown. If zero, DefaultMaxFailures and
+	// DefaultCooldown are used.
+	MaxFailures int
+	Cooldown    time.Duration
+
+	mu      sync.Mutex
+	next    int
+	members []*member
+}
+
+type member struct {
+	c        *ospry.Client
+	slot     time.Time // the earliest time of the next request
+	failures int       // in a row
+	until    time.Time // rested until
+	requests int64
+	errors   int64
+}
+
+// Stats describes a client of a pool.
+type Stats struct {
+	Client   *ospry.Client
+	Requests int64
+	// Errors is the number of failed requests that counted against
+	// the client's health.
+	Errors int64
+	// Healthy is false while the client is rested.
+	Healthy bool
+}
+
+// Do calls fn with the next client, after waiting for the client's
+// rate limit, and returns fn's error. The error counts against the
+// client's health if it's a network error, a server error or a rate
+// limit error.
+func (p *Pool) Do(ctx context.Context, fn func(c *ospry.Client) error) error {
+	m, err := p.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	err = fn(m.c)
+	p.release(m, err)
+	return err
+}
+
+// Upload uploads an image with the next client (see Client.Upload).
+func (p *Pool) Upload(ctx context.Context, filename string, data io.Reader, opts *ospry.UploadOpts) (*ospry.Metadata, error) {
+	var m *ospry.Metadata
+	err := p.Do(ctx, func(c *ospry.Client) error {
+		var err error
+		m, err = c.Upload(filename, data, opts)
+		return err
+	})
+	return m, err
+}
+
+// Stats returns the stats of the pool's clients, in order.
+func (p *Pool) Stats() []Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.init()
+	now := time.Now()
+	stats := make([]Stats, len(p.members))
+	for i, m := range p.members {
+		stats[i] = Stats{Client: m.c, Requests: m.requests, Errors: m.errors, Healthy: !now.Before(m.until)}
+	}
+	return stats
+}
+
+func (p *Pool) init() {
+	if p.members == nil {
+		for _, c := range p.Clients {
+			p.members = append(p.members, &member{c: c})
+		}
+	}
+}
+
+// acquire picks the next healthy client, or the one rested the least
+// if none are, and waits for its turn.
+func (p *Pool) acquire(ctx context.Context) (*member, error) {
+	p.mu.Lock()
+	p.init()
+	if len(p.members) == 0 {
+		p.mu.Unlock()
+		return nil, ErrEmpty
+	}
+	now := time.Now()
+	var m *member
+	for i := range p.members {
+		c := p.members[(p.next+i)%len(p.members)]
+		if !now.Before(c.until) {
+			m = c
+			p.next = (p.next + i + 1) % len(p.members)
+			break
+		}
+		if m == nil || c.until.Before(m.until) {
+			m = c
+		}
+	}
+	slot := now
+	if m.until.After(slot) {
+		slot = m.until
+	}
+	if m.slot.After(slot) {
+		slot = m.slot
+	}
+	if p.RequestsPerSecond > 0 {
+		m.slot = slot.Add(time.Duration(float64(time.Second) / p.RequestsPerSecond))
+	}
+	m.requests++
+	p.mu.Unlock()
+
+	if d := time.Until(slot); d > 0 {
+		t := time.NewTimer(d)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return m, nil
+}
+
+func (p *Pool) release(m *member, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !unhealthy(err) {
+		m.failures = 0
+		return
+	}
+	m.errors++
+	m.failures++
+	max, cooldown := p.MaxFailures, p.Cooldown
+	if max <= 0 {
+		max = DefaultMaxFailures
+	}
+	if cooldown <= 0 {
+		cooldown = DefaultCooldown
+	}
+	if m.failures >= max {
+		m.failures = 0
+		m.until = time.Now().Add(cooldown)
+	}
+}
+
+// unhealthy reports whether err says something about the client
+// rather than about the request.
+func unhealthy(err error) bool {
+	if err == nil {
+		return false
+	}
+	var e *ospry.Error
+	if errors.As(err, &e) {
+		return e.HTTPStatusCode == 429 || e.HTTPStatusCode >= 500
+	}
+	var ue *url.Error
+	return errors.As(err, &ue)
+}
@@ -0,0 +1,69 @@
+package pool
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	ospry "github.com/ospry/ospry-go"
+)
+
+func newClient(t *testing.T, status int) (*ospry.Client, *int) {
+	uploads := new(int)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*uploads++
+		if status != 200 {
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(map[string]interface{}{"error": &ospry.Error{HTTPStatusCode: status, Message: "unavailable"}})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"metadata": &ospry.Metadata{ID: "foo"}})
+	}))
+	t.Cleanup(srv.Close)
+	c := ospry.New("sk-test-key")
+	c.ServerURL = srv.URL
+	return c, uploads
+}
+
+func TestPool(t *testing.T) {
+	good, goodUploads := newClient(t, 200)
+	bad, badUploads := newClient(t, 503)
+	p := &Pool{Clients: []*ospry.Client{good, bad}, MaxFailures: 2, Cooldown: time.Hour}
+	for i := 0; i < 10; i++ {
+		p.Upload(context.Background(), "foo.jpg", strings.NewReader("foo"), nil)
+	}
+	// The bad client is rested after failing twice.
+	if *goodUploads != 8 || *badUploads != 2 {
+		t.Fatalf("got %d, %d uploads, want 8, 2", *goodUploads, *badUploads)
+	}
+	stats := p.Stats()
+	if !stats[0].Healthy || stats[1].Healthy || stats[1].Errors != 2 {
+		t.Fatalf("got stats %+v, want the second client unhealthy with 2 errors", stats)
+	}
+}
+
+func TestPoolRateLimit(t *testing.T) {
+	c, _ := newClient(t, 200)
+	p := &Pool{Clients: []*ospry.Client{c}, RequestsPerSecond: 50}
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := p.Do(context.Background(), func(*ospry.Client) error { return nil }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := time.Since(start); d < 40*time.Millisecond {
+		t.Fatalf("got 3 requests in %v, want at least 40ms", d)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p.RequestsPerSecond = 0.1
+	p.Do(ctx, func(*ospry.Client) error { return nil })
+	if err := p.Do(ctx, func(*ospry.Client) error { return nil }); err != context.Canceled {
+		t.Fatalf("got %v, want %v", err, context.Canceled)
+	}
+}
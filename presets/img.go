@@ -0,0 +1,88 @@
+package presets
+
+import (
+	"bytes"
+	"html/template"
+	"sort"
+	"strconv"
+
+	ospry "github.com/ospry/ospry-go"
+)
+
+// ImgTag calls Preset.ImgTag with the default client.
+func ImgTag(m *ospry.Metadata, p Preset, attrs map[string]string) (template.HTML, error) {
+	return p.ImgTag(nil, m, attrs)
+}
+
+// ImgTag returns an <img> tag for the image rendered at the preset's
+// size. The tag has explicit width and height (to prevent layout
+// shift), a 2x srcset entry when the image is large enough, and
+// loading="lazy". The alt attribute defaults to empty. Any attribute
+// can be overridden with attrs. If c is nil, the default client is
+// used.
+func (p Preset) ImgTag(c *ospry.Client, m *ospry.Metadata, attrs map[string]string) (template.HTML, error) {
+	src, err := p.URL(c, m)
+	if err != nil {
+		return "", err
+	}
+	a := map[string]string{
+		"src":     src,
+		"width":   strconv.Itoa(p.Width),
+		"height":  strconv.Itoa(p.Height),
+		"alt":     "",
+		"loading": "lazy",
+	}
+	if m.Width >= 2*p.Width && m.Height >= 2*p.Height {
+		src2x, err := p.scaledURL(c, m, 2)
+		if err != nil {
+			return "", err
+		}
+		a["srcset"] = src + " 1x, " + src2x + " 2x"
+	}
+	for k, v := range attrs {
+		a[k] = v
+	}
+	return imgTag(a), nil
+}
+
+// attrOrder is the order of well-known attributes in generated tags.
+// Other attributes follow in alphabetical order.
+var attrOrder = []string{"src", "srcset", "width", "height", "alt", "loading"}
+
+func imgTag(attrs map[string]string) template.HTML {
+	var keys []string
+	for _, k := range attrOrder {
+		if _, ok := attrs[k]; ok {
+			keys = append(keys, k)
+		}
+	}
+	var rest []string
+	for k := range attrs {
+		if !isWellKnown(k) {
+			rest = append(rest, k)
+		}
+	}
+	sort.Strings(rest)
+	keys = append(keys, rest...)
+
+	var b bytes.Buffer
+	b.WriteString("<img")
+	for _, k := range keys {
+		b.WriteString(" ")
+		b.WriteString(template.HTMLEscapeString(k))
+		b.WriteString(`="`)
+		b.WriteString(template.HTMLEscapeString(attrs[k]))
+		b.WriteString(`"`)
+	}
+	b.WriteString(">")
+	return template.HTML(b.String())
+}
+
+func isWellKnown(attr string) bool {
+	for _, k := range attrOrder {
+		if k == attr {
+			return true
+		}
+	}
+	return false
+}
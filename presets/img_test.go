@@ -0,0 +1,31 @@
+package presets
+
+import (
+	"html/template"
+	"strings"
+	"testing"
+
+	ospry "github.com/ospry/ospry-go"
+)
+
+func TestImgTag(t *testing.T) {
+	c := ospry.New("sk-test-key")
+	m := &ospry.Metadata{URL: "http://foo.ospry.io/bar/baz.png", Width: 400, Height: 400}
+	got, err := SquareAvatar.ImgTag(c, m, map[string]string{"alt": `"me"`, "class": "avatar"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := m.URL + "?fit=crop&amp;maxHeight=400&amp;maxWidth=400"
+	want := template.HTML(`<img src="` + src + `" width="400" height="400" alt="&#34;me&#34;" loading="lazy" class="avatar">`)
+	if got != want {
+		t.Fatalf("got %s\n         want %s", got, want)
+	}
+	m.Width, m.Height = 800, 800
+	got, err = SquareAvatar.ImgTag(c, m, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(got), "maxWidth=800 2x") {
+		t.Fatalf("got %s, want 2x srcset", got)
+	}
+}
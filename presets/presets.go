@@ -0,0 +1,101 @@
+// Package presets provides standard image sizes for social media and
+// common page elements, so that rendering e.g. an OpenGraph image is
+// a single call:
+//
+//	url, err := presets.OpenGraph.URL(nil, metadata)
+//
+// Preset renders are cropped to exactly the preset's dimensions.
+package presets
+
+import (
+	"time"
+
+	ospry "github.com/ospry/ospry-go"
+)
+
+// SignTTL is how long preset urls to private images stay valid.
+var SignTTL = 10 * time.Minute
+
+// A Preset is a named render size.
+type Preset struct {
+	Name   string
+	Width  int
+	Height int
+}
+
+var (
+	OpenGraph         = Preset{"opengraph", 1200, 630}
+	TwitterCard       = Preset{"twitter-card", 1200, 600}
+	TwitterSummary    = Preset{"twitter-summary", 240, 240}
+	SquareAvatar      = Preset{"avatar", 400, 400}
+	SmallAvatar       = Preset{"avatar-small", 64, 64}
+	InstagramSquare   = Preset{"instagram-square", 1080, 1080}
+	InstagramPortrait = Preset{"instagram-portrait", 1080, 1350}
+	LinkedInShare     = Preset{"linkedin-share", 1200, 627}
+	PinterestPin      = Preset{"pinterest-pin", 1000, 1500}
+	FacebookCover     = Preset{"facebook-cover", 820, 312}
+)
+
+// All lists the standard presets.
+var All = []Preset{
+	OpenGraph,
+	TwitterCard,
+	TwitterSummary,
+	SquareAvatar,
+	SmallAvatar,
+	InstagramSquare,
+	InstagramPortrait,
+	LinkedInShare,
+	PinterestPin,
+	FacebookCover,
+}
+
+// Lookup returns the standard preset with the given name.
+func Lookup(name string) (Preset, bool) {
+	for _, p := range All {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Preset{}, false
+}
+
+// RenderOpts returns render options that crop an image to the
+// preset's dimensions.
+func (p Preset) RenderOpts() *ospry.RenderOpts {
+	return &ospry.RenderOpts{
+		MaxWidth:  p.Width,
+		MaxHeight: p.Height,
+		Fit:       ospry.FitCrop,
+	}
+}
+
+// URL returns a url to the image rendered at the preset's size. Urls
+// to private images are signed for SignTTL. If c is nil, the default
+// client is used.
+func (p Preset) URL(c *ospry.Client, m *ospry.Metadata) (string, error) {
+	return p.scaledURL(c, m, 1)
+}
+
+func (p Preset) scaledURL(c *ospry.Client, m *ospry.Metadata, scale int) (string, error) {
+	opts := p.RenderOpts()
+	opts.MaxWidth *= scale
+	opts.MaxHeight *= scale
+	if m.IsPrivate {
+		return client(c).SignedURL(m.URL, SignTTL, opts)
+	}
+	return client(c).FormatURL(m.URL, opts)
+}
+
+// Copy stores a copy of the image rendered at the preset's size (see
+// ospry.Client.Copy). If c is nil, the default client is used.
+func (p Preset) Copy(c *ospry.Client, m *ospry.Metadata) (*ospry.Metadata, error) {
+	return client(c).Copy(m.ID, p.RenderOpts())
+}
+
+func client(c *ospry.Client) *ospry.Client {
+	if c == nil {
+		return ospry.DefaultClient
+	}
+	return c
+}
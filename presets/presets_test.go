@@ -0,0 +1,30 @@
+package presets
+
+import (
+	"testing"
+
+	ospry "github.com/ospry/ospry-go"
+)
+
+func TestURL(t *testing.T) {
+	m := &ospry.Metadata{URL: "http://foo.ospry.io/bar/baz.png"}
+	got, err := OpenGraph.URL(ospry.New("sk-test-key"), m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := m.URL + "?fit=crop&maxHeight=630&maxWidth=1200"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	for _, p := range All {
+		got, ok := Lookup(p.Name)
+		if !ok || got != p {
+			t.Fatalf("got %v, want %v", got, p)
+		}
+	}
+	if _, ok := Lookup("nope"); ok {
+		t.Fatal("got true, want false")
+	}
+}
@@ -0,0 +1,91 @@
+package ospry
+
+import (
+	"io/ioutil"
+	"time"
+)
+
+// Defaults for PreviewOpts.
+const (
+	DefaultPreviewSize    = 20
+	DefaultPreviewFormat  = "jpeg"
+	DefaultPreviewQuality = 40
+)
+
+// PreviewOpts are options for FetchPreview. Zero fields take the defaults
+// above.
+type PreviewOpts struct {
+	// Size bounds the preview's width and height.
+	Size int
+	// Format is "jpeg" or "webp".
+	Format  string
+	Quality int
+}
+
+// A Preview is a tiny render of an image, for low-quality image
+// placeholders shown (scaled up and blurred) until the image loads.
+type Preview struct {
+	// Data is the render's encoded bytes.
+	Data   []byte
+	Format string
+	// DataURI is Data as a data: uri, ready to use as an img src.
+	DataURI string
+}
+
+// FetchPreview calls FetchPreview on the default client.
+func FetchPreview(m *Metadata, opts *PreviewOpts) (*Preview, error) {
+	return DefaultClient.FetchPreview(m, opts)
+}
+
+// FetchPreviews calls FetchPreviews on the default client.
+func FetchPreviews(images []*Metadata, opts *PreviewOpts) ([]BatchResult[*Preview], error) {
+	return DefaultClient.FetchPreviews(images, opts)
+}
+
+// FetchPreview downloads a tiny render of the image described by m. Private
+// images are signed for the download.
+func (c *Client) FetchPreview(m *Metadata, opts *PreviewOpts) (*Preview, error) {
+	render := opts.renderOpts()
+	if m.IsPrivate {
+		render.TimeExpired = c.now().Add(time.Minute)
+	}
+	rc, err := c.DownloadMeta(m, render)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	b, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+	return &Preview{Data: b, Format: render.Format, DataURI: DataURI(b)}, nil
+}
+
+// FetchPreviews downloads the previews of several images, e.g. to backfill
+// the previews of images uploaded before an application stored them.
+// Up to BatchConcurrency previews are downloaded at once. The results
+// are in the same order as images; if some failed, the error is a
+// *BatchError.
+func (c *Client) FetchPreviews(images []*Metadata, opts *PreviewOpts) ([]BatchResult[*Preview], error) {
+	results := fanOut(c.batchConcurrency(), len(images),
+		func(i int) string { return images[i].ID },
+		func(i int) (*Preview, error) { return c.FetchPreview(images[i], opts) })
+	return results, batchErr(results)
+}
+
+func (opts *PreviewOpts) renderOpts() *RenderOpts {
+	o := PreviewOpts{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.Size <= 0 {
+		o.Size = DefaultPreviewSize
+	}
+	if o.Format == "" {
+		o.Format = DefaultPreviewFormat
+	}
+	if o.Quality <= 0 {
+		o.Quality = DefaultPreviewQuality
+	}
+	return &RenderOpts{MaxWidth: o.Size, MaxHeight: o.Size, Format: o.Format, Quality: o.Quality}
+}
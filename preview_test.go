@@ -0,0 +1,37 @@
+package ospry
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestFetchPreviews(t *testing.T) {
+	var c *Client
+	c = newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if strings.HasSuffix(r.URL.Path, "/missing.jpg") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if q.Get("maxWidth") != "20" || q.Get("maxHeight") != "20" || q.Get("format") != "webp" || q.Get("quality") != "40" {
+			t.Errorf("got render %s, want 20x20 webp at quality 40", r.URL.RawQuery)
+		}
+		w.Write([]byte("RIFF\x00\x00\x00\x00WEBPVP8 "))
+	})
+	images := []*Metadata{
+		{ID: "a", URL: c.ServerURL + "/a.jpg"},
+		{ID: "missing", URL: c.ServerURL + "/missing.jpg"},
+	}
+	results, err := c.FetchPreviews(images, &PreviewOpts{Format: "webp"})
+	if _, ok := err.(*BatchError); !ok {
+		t.Fatalf("got %v, want *BatchError", err)
+	}
+	p := results[0].Value
+	if p == nil || p.Format != "webp" || !strings.HasPrefix(p.DataURI, "data:image/webp;base64,") {
+		t.Fatalf("got %+v, want a webp preview", p)
+	}
+	if results[1].Item != "missing" || results[1].Err == nil {
+		t.Fatalf("got %+v, want missing to fail", results[1])
+	}
+}
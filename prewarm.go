@@ -0,0 +1,59 @@
+package ospry
+
+import (
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+// DefaultPrewarmConcurrency is the default for Client.PrewarmConcurrency.
+const DefaultPrewarmConcurrency = 4
+
+// Prewarm calls Prewarm on the default client.
+func Prewarm(m *Metadata, variants []*RenderOpts) ([]BatchResult[int64], error) {
+	return DefaultClient.Prewarm(m, variants)
+}
+
+// Prewarm downloads each variant of the image and discards the data,
+// so that the renders are cached (by ospry and any CDN in front of it)
+// before the image's first viewers ask for them. Up to
+// PrewarmConcurrency variants are requested at once. Variants of
+// private images are signed for a minute. The results, whose values
+// are the number of bytes read, are in the same order as variants; if
+// some failed, the error is a *BatchError.
+func (c *Client) Prewarm(m *Metadata, variants []*RenderOpts) ([]BatchResult[int64], error) {
+	n := c.PrewarmConcurrency
+	if n <= 0 {
+		n = DefaultPrewarmConcurrency
+	}
+	results := fanOut(n, len(variants),
+		func(int) string { return m.ID },
+		func(i int) (int64, error) { return c.prewarm(m, variants[i]) })
+	return results, batchErr(results)
+}
+
+func (c *Client) prewarm(m *Metadata, opts *RenderOpts) (int64, error) {
+	if m.IsPrivate {
+		o := RenderOpts{}
+		if opts != nil {
+			o = *opts
+		}
+		o.TimeExpired = c.now().Add(time.Minute)
+		opts = &o
+	}
+	rc, err := c.DownloadMeta(m, opts)
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+	return io.Copy(ioutil.Discard, rc)
+}
+
+// prewarmNew prewarms the client's PrewarmVariants of a new or newly
+// claimed image in the background.
+func (c *Client) prewarmNew(m *Metadata) {
+	if len(c.PrewarmVariants) == 0 || m == nil {
+		return
+	}
+	go c.Prewarm(m, c.PrewarmVariants)
+}
@@ -0,0 +1,61 @@
+package ospry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrewarmAfterUpload(t *testing.T) {
+	var base string
+	rendered := make(chan string, 10)
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			writeMetadata(w, &Metadata{ID: "foo", URL: base + "bar.jpg"})
+			return
+		}
+		rendered <- r.URL.Query().Get("maxWidth")
+		w.Write([]byte("rendered"))
+	})
+	base = strings.TrimSuffix(c.ServerURL, "/v1") + "/"
+	c.PrewarmVariants = []*RenderOpts{{MaxWidth: 200}, {MaxWidth: 800}}
+	if _, err := c.Upload("bar.jpg", strings.NewReader("data"), nil); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for len(got) < 2 {
+		select {
+		case w := <-rendered:
+			got = append(got, w)
+		case <-time.After(time.Second):
+			t.Fatalf("got renders %v, want 200 and 800", got)
+		}
+	}
+	sort.Strings(got)
+	if got[0] != "200" || got[1] != "800" {
+		t.Fatalf("got renders %v, want 200 and 800", got)
+	}
+}
+
+func TestPrewarmPrivate(t *testing.T) {
+	var signed bool
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signed = r.URL.Query().Get("signature") != ""
+		w.Write([]byte("rendered"))
+	}))
+	defer srv.Close()
+	c := New("sk-test-key")
+	c.HTTPClient = srv.Client()
+	c.RenderHost = strings.TrimPrefix(srv.URL, "https://")
+	m := &Metadata{ID: "foo", URL: "http://foo.ospry.io/bar.jpg", IsPrivate: true}
+	results, err := c.Prewarm(m, []*RenderOpts{{MaxWidth: 200}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !signed || results[0].Value != int64(len("rendered")) {
+		t.Fatalf("got signed %v, %d bytes, want a signed render of 8 bytes", signed, results[0].Value)
+	}
+}
@@ -0,0 +1,154 @@
+package ospry
+
+import (
+	"io"
+	"sync"
+)
+
+// A ProgressReporter is told about the progress of image transfers.
+// Uploads and downloads report the bytes they move, and bulk
+// operations such as Downloader and manifest.UploadFiles announce the
+// work they're about to do. Its methods may be called concurrently.
+type ProgressReporter interface {
+	// Expect is called by bulk operations as they learn of more
+	// work: items more images totaling bytes bytes, or -1 if the size
+	// isn't known.
+	Expect(items int, bytes int64)
+	// Start is called when the transfer of item, a filename or url,
+	// begins. size is -1 if it isn't known.
+	Start(item string, size int64)
+	// Progress reports that n more bytes of item were transferred.
+	Progress(item string, n int64)
+	// Done is called when item's transfer completes or fails.
+	Done(item string, err error)
+}
+
+// BarProgress returns a ProgressReporter that reports the bytes
+// transferred by all items to a progress bar: add is called with the
+// number of bytes transferred, and setTotal, if non-nil, with the sum
+// of the sizes of the items started so far. For example, with
+// github.com/schollz/progressbar:
+//
+//	bar := progressbar.DefaultBytes(-1)
+//	c.Progress = ospry.BarProgress(func(n int64) { bar.Add64(n) }, bar.ChangeMax64)
+func BarProgress(add func(n int64), setTotal func(total int64)) ProgressReporter {
+	return &barProgress{add: add, setTotal: setTotal}
+}
+
+type barProgress struct {
+	add      func(int64)
+	setTotal func(int64)
+
+	mu    sync.Mutex
+	total int64
+}
+
+func (p *barProgress) Expect(items int, bytes int64) {}
+
+func (p *barProgress) Start(item string, size int64) {
+	if size < 0 || p.setTotal == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.total += size
+	p.setTotal(p.total)
+}
+
+func (p *barProgress) Progress(item string, n int64) { p.add(n) }
+
+func (p *barProgress) Done(item string, err error) {}
+
+// CountProgress returns a ProgressReporter that reports the number of
+// items done to a progress bar: add is called with 1 as each item
+// completes or fails, and setTotal, if non-nil, with the number of
+// items expected so far. For example, with github.com/cheggaaa/pb/v3:
+//
+//	bar := pb.New(0).Start()
+//	c.Progress = ospry.CountProgress(func(n int64) { bar.Add64(n) }, func(n int64) { bar.SetTotal(n) })
+func CountProgress(add func(n int64), setTotal func(total int64)) ProgressReporter {
+	return &countProgress{add: add, setTotal: setTotal}
+}
+
+type countProgress struct {
+	add      func(int64)
+	setTotal func(int64)
+
+	mu    sync.Mutex
+	total int64
+}
+
+func (p *countProgress) Expect(items int, bytes int64) {
+	if p.setTotal == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.total += int64(items)
+	p.setTotal(p.total)
+}
+
+func (p *countProgress) Start(item string, size int64) {}
+
+func (p *countProgress) Progress(item string, n int64) {}
+
+func (p *countProgress) Done(item string, err error) { p.add(1) }
+
+// reportProgress returns a reader that reports the bytes read from r
+// as progress on item, if the client has a ProgressReporter.
+func (c *Client) reportProgress(item string, r io.Reader) io.Reader {
+	if c.Progress == nil {
+		return r
+	}
+	return &progressReader{r, item, c.Progress}
+}
+
+// A progressReader reports the bytes read from r as progress on item.
+type progressReader struct {
+	r        io.Reader
+	item     string
+	reporter ProgressReporter
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.reporter.Progress(r.item, int64(n))
+	}
+	return n, err
+}
+
+// A progressBody reports the progress of a download, which is done
+// when its body has been read or closed. Downloads closed before
+// they were read to the end are reported as failed with
+// io.ErrUnexpectedEOF.
+type progressBody struct {
+	progressReader
+	body io.Closer
+	done bool
+}
+
+func (b *progressBody) Read(p []byte) (int, error) {
+	n, err := b.progressReader.Read(p)
+	if err != nil {
+		if err == io.EOF {
+			b.finish(nil)
+		} else {
+			b.finish(err)
+		}
+	}
+	return n, err
+}
+
+func (b *progressBody) Close() error {
+	err := b.body.Close()
+	b.finish(io.ErrUnexpectedEOF)
+	return err
+}
+
+func (b *progressBody) finish(err error) {
+	if !b.done {
+		b.done = true
+		b.reporter.Done(b.item, err)
+	}
+}
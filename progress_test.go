@@ -0,0 +1,118 @@
+package ospry
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type recordingProgress struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (p *recordingProgress) record(format string, args ...interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, fmt.Sprintf(format, args...))
+}
+
+func (p *recordingProgress) Expect(items int, bytes int64) { p.record("expect %d %d", items, bytes) }
+func (p *recordingProgress) Start(item string, size int64) { p.record("start %s %d", item, size) }
+func (p *recordingProgress) Progress(item string, n int64) { p.record("progress %s", item) }
+func (p *recordingProgress) Done(item string, err error)   { p.record("done %s %v", item, err) }
+
+// summary collapses runs of progress events.
+func (p *recordingProgress) summary() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var s []string
+	for _, e := range p.events {
+		if len(s) > 0 && s[len(s)-1] == e && strings.HasPrefix(e, "progress") {
+			continue
+		}
+		s = append(s, e)
+	}
+	p.events = nil
+	return strings.Join(s, "; ")
+}
+
+func TestProgress(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 100000)
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			ioutil.ReadAll(r.Body)
+			writeMetadata(w, &Metadata{ID: "foo"})
+			return
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+		w.Write(data)
+	})
+	p := &recordingProgress{}
+	c.Progress = p
+
+	if _, err := c.UploadPublic("foo.jpg", bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := p.summary(), "start foo.jpg 100000; progress foo.jpg; done foo.jpg <nil>"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+
+	imgURL := c.ServerURL + "/foo.jpg"
+	rc, err := c.Download(imgURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.ReadAll(rc)
+	rc.Close()
+	want := fmt.Sprintf("start %[1]s 100000; progress %[1]s; done %[1]s <nil>", imgURL)
+	if got := p.summary(); got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+
+	// Downloads abandoned before the end aren't reported as done.
+	rc, err = c.Download(imgURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc.Read(make([]byte, 10))
+	rc.Close()
+	want = fmt.Sprintf("start %[1]s 100000; progress %[1]s; done %[1]s unexpected EOF", imgURL)
+	if got := p.summary(); got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+
+	d := &Downloader{Client: c}
+	d.Add(&DownloadItem{URL: imgURL, Dest: ioutil.Discard})
+	d.Wait()
+	if got := p.summary(); !strings.HasPrefix(got, "expect 1 -1; start") {
+		t.Fatalf("got %s, want expect event first", got)
+	}
+}
+
+func TestBarProgress(t *testing.T) {
+	var done, total int64
+	p := BarProgress(func(n int64) { done += n }, func(n int64) { total = n })
+	p.Start("a", 10)
+	p.Start("b", -1)
+	p.Start("c", 5)
+	p.Progress("a", 10)
+	p.Progress("c", 2)
+	if done != 12 || total != 15 {
+		t.Fatalf("got %d/%d, want 12/15", done, total)
+	}
+
+	done, total = 0, 0
+	p = CountProgress(func(n int64) { done += n }, func(n int64) { total = n })
+	p.Expect(2, -1)
+	p.Expect(1, 100)
+	p.Done("a", nil)
+	p.Done("b", fmt.Errorf("failed"))
+	if done != 2 || total != 3 {
+		t.Fatalf("got %d/%d, want 2/3", done, total)
+	}
+}
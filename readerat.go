@@ -0,0 +1,160 @@
+package ospry
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// uploadBody prepares data for uploading. Data whose size is known and
+// that can be read at arbitrary offsets (files, bytes.Readers,
+// strings.Readers and io.SectionReaders) is returned as an
+// io.SectionReader covering the unread part of data, along with its
+// size, so that the request can set Content-Length and be retried
+// without buffering. Otherwise data is returned with a size of -1.
+// The returned bodyHold, if not nil, keeps resources the body needs;
+// see bodyHold.
+func (c *Client) uploadBody(data io.Reader) (io.Reader, int64, *bodyHold, error) {
+	ra, ok := data.(io.ReaderAt)
+	if !ok {
+		return data, -1, nil, nil
+	}
+	var size int64
+	switch r := data.(type) {
+	case *os.File:
+		fi, err := r.Stat()
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		if !fi.Mode().IsRegular() {
+			return data, -1, nil, nil
+		}
+		size = fi.Size()
+	case interface{ Size() int64 }:
+		size = r.Size()
+	default:
+		return data, -1, nil, nil
+	}
+	var off int64
+	if s, ok := data.(io.Seeker); ok {
+		var err error
+		if off, err = s.Seek(0, io.SeekCurrent); err != nil {
+			return nil, 0, nil, err
+		}
+	}
+	if off > size {
+		off = size
+	}
+	if f, ok := data.(*os.File); ok && c.MmapUploads && size > 0 {
+		if b, unmap, err := mmap(f, size); err == nil {
+			return bytes.NewReader(b[off:]), size - off, &bodyHold{refs: 1, free: unmap}, nil
+		}
+		// Fall back to regular reads if the file can't be mapped.
+	}
+	return io.NewSectionReader(ra, off, size-off), size - off, nil, nil
+}
+
+// A bodyHold keeps the resources of an upload body, such as a file's
+// memory mapping, until the upload has returned and the transport has
+// closed every request body it was given, since the transport may
+// still be writing a body after the response has arrived. A nil
+// bodyHold holds nothing.
+type bodyHold struct {
+	mu   sync.Mutex
+	refs int
+	free func()
+}
+
+func (h *bodyHold) acquire() {
+	h.mu.Lock()
+	h.refs++
+	h.mu.Unlock()
+}
+
+// release drops a reference, freeing the resources with the last one.
+func (h *bodyHold) release() {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	h.refs--
+	last := h.refs == 0
+	h.mu.Unlock()
+	if last {
+		h.free()
+	}
+}
+
+// hold makes req's body, and those GetBody returns, hold h until
+// they're closed.
+func (h *bodyHold) hold(req *http.Request) {
+	if h == nil || req.Body == nil || req.Body == http.NoBody {
+		return
+	}
+	h.acquire()
+	req.Body = &heldBody{ReadCloser: req.Body, h: h}
+	if getBody := req.GetBody; getBody != nil {
+		req.GetBody = func() (io.ReadCloser, error) {
+			rc, err := getBody()
+			if err != nil {
+				return nil, err
+			}
+			h.acquire()
+			return &heldBody{ReadCloser: rc, h: h}, nil
+		}
+	}
+}
+
+// A heldBody is a request body holding a bodyHold until it's closed.
+type heldBody struct {
+	io.ReadCloser
+	h    *bodyHold
+	once sync.Once
+}
+
+func (b *heldBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.h.release)
+	return err
+}
+
+// A SizedReader is a reader that knows how many bytes remain to be
+// read from it. Uploads of SizedReaders set Content-Length rather
+// than using chunked encoding.
+type SizedReader interface {
+	io.Reader
+	Size() int64
+}
+
+// bodySize returns the size of an upload body that uploadBody
+// couldn't determine, or -1.
+func bodySize(data io.Reader, opts *UploadOpts) int64 {
+	if opts.Size > 0 {
+		return opts.Size
+	}
+	if r, ok := data.(SizedReader); ok {
+		return r.Size()
+	}
+	return -1
+}
+
+// setBodySize sets req's Content-Length to size and, for bodies that
+// can be re-read, lets the transport rewind them for retries.
+func (c *Client) setBodySize(req *http.Request, body io.Reader, size int64) {
+	req.ContentLength = size
+	if size == 0 {
+		req.Body = http.NoBody
+		req.GetBody = func() (io.ReadCloser, error) { return http.NoBody, nil }
+		return
+	}
+	ra, ok := body.(io.ReaderAt)
+	if !ok {
+		return
+	}
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(c.throttle(io.NewSectionReader(ra, 0, size))), nil
+	}
+}
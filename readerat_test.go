@@ -0,0 +1,172 @@
+package ospry
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUploadReaderAt(t *testing.T) {
+	var length int64
+	var body string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		length = r.ContentLength
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body = string(b)
+		writeMetadata(w, &Metadata{ID: "foo"})
+	})
+	path := filepath.Join(t.TempDir(), "foo.jpg")
+	if err := ioutil.WriteFile(path, []byte("0123456789"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, mmap := range []bool{false, true} {
+		c.MmapUploads = mmap
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Seek(2, io.SeekStart)
+		_, err = c.UploadPublic("foo.jpg", f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if length != 8 || body != "23456789" {
+			t.Fatalf("mmap %v: got %d, %q, want 8, %q", mmap, length, body, "23456789")
+		}
+	}
+
+	r := bytes.NewReader([]byte("abcdef"))
+	r.Seek(1, io.SeekStart)
+	if _, err := c.UploadPublic("foo.jpg", r); err != nil {
+		t.Fatal(err)
+	}
+	if length != 5 || body != "bcdef" {
+		t.Fatalf("got %d, %q, want 5, %q", length, body, "bcdef")
+	}
+
+	// Readers of unknown size are streamed.
+	if _, err := c.UploadPublic("foo.jpg", io.MultiReader(strings.NewReader("abc"))); err != nil {
+		t.Fatal(err)
+	}
+	if length != -1 || body != "abc" {
+		t.Fatalf("got %d, %q, want -1, %q", length, body, "abc")
+	}
+}
+
+type rewindTransport struct {
+	t      *testing.T
+	bodies []string
+}
+
+func (rt *rewindTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for i := 0; i < 2; i++ {
+		body := req.Body
+		if i > 0 {
+			if req.GetBody == nil {
+				rt.t.Fatal("request body can't be rewound")
+			}
+			var err error
+			if body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+		b, err := ioutil.ReadAll(body)
+		if err != nil {
+			return nil, err
+		}
+		rt.bodies = append(rt.bodies, string(b))
+	}
+	return &http.Response{
+		StatusCode: 200,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       ioutil.NopCloser(strings.NewReader(`{"metadata":{"id":"foo"}}`)),
+	}, nil
+}
+
+func TestUploadReaderAtRetry(t *testing.T) {
+	rt := &rewindTransport{t: t}
+	c := New("sk-test-key")
+	c.HTTPClient = &http.Client{Transport: rt}
+	sr := io.NewSectionReader(strings.NewReader("0123456789"), 3, 4)
+	if _, err := c.UploadPublic("foo.jpg", sr); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(rt.bodies, ","), "3456,3456"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+type sizedReader struct {
+	io.Reader
+	n int64
+}
+
+func (r *sizedReader) Size() int64 { return r.n }
+
+func TestUploadSize(t *testing.T) {
+	var length int64
+	var encoding []string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		length = r.ContentLength
+		encoding = r.TransferEncoding
+		ioutil.ReadAll(r.Body)
+		writeMetadata(w, &Metadata{ID: "foo"})
+	})
+	stream := func() io.Reader { return io.MultiReader(strings.NewReader("abcd")) }
+
+	if _, err := c.Upload("foo.jpg", stream(), &UploadOpts{Size: 4}); err != nil {
+		t.Fatal(err)
+	}
+	if length != 4 || len(encoding) != 0 {
+		t.Fatalf("got %d, %q, want 4, no transfer encoding", length, encoding)
+	}
+	if _, err := c.Upload("foo.jpg", &sizedReader{stream(), 4}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if length != 4 || len(encoding) != 0 {
+		t.Fatalf("got %d, %q, want 4, no transfer encoding", length, encoding)
+	}
+	if _, err := c.Upload("foo.jpg", stream(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if length != -1 || len(encoding) != 1 || encoding[0] != "chunked" {
+		t.Fatalf("got %d, %q, want -1, chunked", length, encoding)
+	}
+	if _, err := c.Upload("foo.jpg", stream(), &UploadOpts{Size: 5}); err == nil {
+		t.Fatal("got nil error for short body, want error")
+	}
+}
+
+func TestBodyHold(t *testing.T) {
+	freed := 0
+	h := &bodyHold{refs: 1, free: func() { freed++ }}
+	req, err := http.NewRequest("POST", "http://example.com/", strings.NewReader("abc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.hold(req)
+	rc, err := req.GetBody()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.release()
+	req.Body.Close()
+	if freed != 0 {
+		t.Fatal("got body freed while a request body was open, want it held")
+	}
+	rc.Close()
+	rc.Close()
+	if freed != 1 {
+		t.Fatalf("got freed %d times, want 1", freed)
+	}
+}
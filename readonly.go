@@ -0,0 +1,24 @@
+package ospry
+
+import "errors"
+
+// ErrReadOnly is returned by operations that would modify images when
+// the client's ReadOnly field is set.
+var ErrReadOnly = errors.New("ospry: client is read-only")
+
+// checkWrite refuses modifications by read-only clients.
+func (c *Client) checkWrite() error {
+	if c.ReadOnly {
+		return ErrReadOnly
+	}
+	return nil
+}
+
+// checkMethod refuses requests that may modify images when the client
+// is read-only. It backs up the checks of the individual operations.
+func (c *Client) checkMethod(method string) error {
+	if method == "GET" || method == "HEAD" {
+		return nil
+	}
+	return c.checkWrite()
+}
@@ -0,0 +1,58 @@
+package ospry
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReadOnly(t *testing.T) {
+	var methods []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method+" "+r.URL.Path)
+		w.Write([]byte(`{"metadata":{"id":"foo"}}`))
+	}))
+	defer ts.Close()
+	c := New("sk-live-key")
+	c.ServerURL = ts.URL
+	c.ReadOnly = true
+	c.ConfirmLive = true
+
+	if _, err := c.UploadPublic("foo.jpg", strings.NewReader("foo")); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("upload: got %v, want %v", err, ErrReadOnly)
+	}
+	if _, err := c.Claim("foo"); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("claim: got %v, want %v", err, ErrReadOnly)
+	}
+	if _, err := c.MakePublic("foo"); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("make public: got %v, want %v", err, ErrReadOnly)
+	}
+	if _, err := c.Convert("foo", "png"); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("convert: got %v, want %v", err, ErrReadOnly)
+	}
+	if _, err := c.DeleteMany([]string{"foo"}); err == nil {
+		t.Fatal("delete many: got nil, want error")
+	}
+	if _, err := c.Batch([]BatchOp{BatchGetMetadata("foo"), BatchDelete("foo")}); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("batch: got %v, want %v", err, ErrReadOnly)
+	}
+	if len(methods) != 0 {
+		t.Fatalf("got requests %v, want none", methods)
+	}
+
+	if _, err := c.GetMetadata("foo"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Batch([]BatchOp{BatchGetMetadata("foo")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.curl("POST", ts.URL+"/images", "application/json", nil); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("post: got %v, want %v", err, ErrReadOnly)
+	}
+	want := "GET /images/foo|GET /images/foo"
+	if strings.Join(methods, "|") != want {
+		t.Fatalf("got requests %v, want %s", methods, want)
+	}
+}
@@ -0,0 +1,158 @@
+// Package reconcile finds drift between an application's store and
+// its ospry account: images nobody references (orphans), and
+// references to images that no longer exist:
+//
+//	r := &reconcile.Reconciler{Client: c, Store: s}
+//	report, err := r.Run(ctx)
+//	report.WriteText(os.Stdout)
+//
+// Setting DeleteOrphans and ForgetMissing makes Run fix the drift too.
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	ospry "github.com/ospry/ospry-go"
+	"github.com/ospry/ospry-go/store"
+)
+
+// DefaultMinAge is the MinAge of a Reconciler whose MinAge is zero.
+const DefaultMinAge = time.Hour
+
+// A Reconciler compares the images in an account with the images in a
+// store.
+type Reconciler struct {
+	// Client lists and deletes the images. If it's nil,
+	// ospry.DefaultClient is used. Deleting images in the live
+	// environment requires its ConfirmLive field to be set.
+	Client *ospry.Client
+	Store  store.Store
+	// MinAge is how old an image must be to count as an orphan, so
+	// that images uploaded but not yet saved to the store are left
+	// alone. If zero, DefaultMinAge is used.
+	MinAge time.Duration
+	// DeleteOrphans makes Run delete the orphans it finds.
+	DeleteOrphans bool
+	// ForgetMissing makes Run remove images that no longer exist
+	// from the store.
+	ForgetMissing bool
+}
+
+// A Report describes a reconciliation run.
+type Report struct {
+	// Orphans are the images in the account that aren't in the
+	// store.
+	Orphans []*ospry.Metadata
+	// Missing are the images in the store that don't exist anymore,
+	// as stored.
+	Missing []*ospry.Metadata
+	// Deleted are the results of deleting the orphans, in the same
+	// order, if DeleteOrphans is set.
+	Deleted []ospry.BatchResult[struct{}]
+	// Forgotten are the ids of the missing images removed from the
+	// store, if ForgetMissing is set.
+	Forgotten []string
+}
+
+// WriteText writes a line per orphan and missing image, and a summary,
+// to w.
+func (r *Report) WriteText(w io.Writer) error {
+	for i, m := range r.Orphans {
+		status := "orphan"
+		if i < len(r.Deleted) {
+			status = "orphan, deleted"
+			if err := r.Deleted[i].Err; err != nil {
+				status = "orphan, delete failed: " + err.Error()
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\n", m.ID, m.Filename, status); err != nil {
+			return err
+		}
+	}
+	forgotten := map[string]bool{}
+	for _, id := range r.Forgotten {
+		forgotten[id] = true
+	}
+	for _, m := range r.Missing {
+		status := "missing"
+		if forgotten[m.ID] {
+			status = "missing, forgotten"
+		}
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\n", m.ID, m.Filename, status); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "%d orphans, %d missing\n", len(r.Orphans), len(r.Missing))
+	return err
+}
+
+// Run lists the account's images and compares them with the store's.
+// Images in the store that aren't listed are only reported missing
+// once fetching their metadata confirms they're gone. The report
+// describes what was found and fixed, even when Run fails part way.
+func (rc *Reconciler) Run(ctx context.Context) (*Report, error) {
+	c := rc.Client
+	if c == nil {
+		c = ospry.DefaultClient
+	}
+	stored, err := rc.Store.List()
+	if err != nil {
+		return nil, err
+	}
+	known := map[string]*ospry.Metadata{}
+	for _, m := range stored {
+		known[m.ID] = m
+	}
+	minAge := rc.MinAge
+	if minAge == 0 {
+		minAge = DefaultMinAge
+	}
+	cutoff := time.Now().Add(-minAge)
+
+	report := &Report{}
+	listed := map[string]bool{}
+	images, errc := c.ListAll(ctx, nil)
+	for m := range images {
+		listed[m.ID] = true
+		if known[m.ID] == nil && m.TimeCreated.Before(cutoff) {
+			report.Orphans = append(report.Orphans, m)
+		}
+	}
+	if err := <-errc; err != nil {
+		return report, err
+	}
+	for _, m := range stored {
+		if listed[m.ID] {
+			continue
+		}
+		_, err := c.GetMetadata(m.ID)
+		var e *ospry.Error
+		if errors.As(err, &e) && e.HTTPStatusCode == 404 {
+			report.Missing = append(report.Missing, m)
+		} else if err != nil {
+			return report, err
+		}
+	}
+
+	if rc.ForgetMissing {
+		for _, m := range report.Missing {
+			if err := rc.Store.Delete(m.ID); err != nil {
+				return report, err
+			}
+			report.Forgotten = append(report.Forgotten, m.ID)
+		}
+	}
+	if rc.DeleteOrphans && len(report.Orphans) > 0 {
+		ids := make([]string, len(report.Orphans))
+		for i, m := range report.Orphans {
+			ids[i] = m.ID
+		}
+		report.Deleted, err = c.DeleteMany(ids)
+		return report, err
+	}
+	return report, nil
+}
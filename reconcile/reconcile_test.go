@@ -0,0 +1,80 @@
+package reconcile
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	ospry "github.com/ospry/ospry-go"
+	"github.com/ospry/ospry-go/store"
+)
+
+func TestReconciler(t *testing.T) {
+	old := time.Now().Add(-2 * time.Hour)
+	images := []*ospry.Metadata{
+		{ID: "kept", TimeCreated: old},
+		{ID: "orphan", Filename: "o.jpg", TimeCreated: old},
+		{ID: "fresh", TimeCreated: time.Now()},
+	}
+	var deleted []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/images/")
+		switch {
+		case r.Method == "DELETE":
+			deleted = append(deleted, id)
+			json.NewEncoder(w).Encode(map[string]interface{}{"metadata": &ospry.Metadata{}})
+		case id == "gone":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":{"httpStatusCode":404,"message":"not found"}}`))
+		case id == "unlisted":
+			json.NewEncoder(w).Encode(map[string]interface{}{"metadata": &ospry.Metadata{ID: id}})
+		default:
+			json.NewEncoder(w).Encode(map[string]interface{}{"images": images})
+		}
+	}))
+	defer srv.Close()
+	c := ospry.New("sk-test-key")
+	c.ServerURL = srv.URL
+
+	s := &store.Memory{}
+	for _, id := range []string{"kept", "gone", "unlisted"} {
+		s.Save(&ospry.Metadata{ID: id})
+	}
+	rc := &Reconciler{Client: c, Store: s}
+	report, err := rc.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Orphans) != 1 || report.Orphans[0].ID != "orphan" {
+		t.Fatalf("got orphans %v, want orphan", report.Orphans)
+	}
+	if len(report.Missing) != 1 || report.Missing[0].ID != "gone" {
+		t.Fatalf("got missing %v, want gone", report.Missing)
+	}
+	if len(deleted) != 0 {
+		t.Fatalf("got deleted %v, want none", deleted)
+	}
+
+	rc.DeleteOrphans, rc.ForgetMissing = true, true
+	report, err = rc.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 1 || deleted[0] != "orphan" {
+		t.Fatalf("got deleted %v, want orphan", deleted)
+	}
+	if _, err := s.Get("gone"); err != store.ErrNotFound {
+		t.Fatalf("got %v, want gone forgotten", err)
+	}
+	var buf bytes.Buffer
+	report.WriteText(&buf)
+	want := "orphan\to.jpg\torphan, deleted\ngone\t\tmissing, forgotten\n1 orphans, 1 missing\n"
+	if buf.String() != want {
+		t.Fatalf("got %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,69 @@
+package redis
+
+import (
+	"strconv"
+	"time"
+)
+
+// A Cache is a cache.Cache kept in redis. Like every cache, it's
+// best-effort: redis errors make Get miss and Set do nothing.
+type Cache struct {
+	Client *Client
+	// Prefix is prepended to every key, to keep the cache's keys
+	// apart from other data.
+	Prefix string
+	// TTL, if positive, makes entries expire, which bounds how long
+	// changes made by other means than the cache's users stay
+	// unnoticed.
+	TTL time.Duration
+}
+
+// Get returns the data stored under key.
+func (c *Cache) Get(key string) ([]byte, bool) {
+	reply, err := c.Client.Do("GET", c.Prefix+key)
+	b, ok := reply.([]byte)
+	return b, err == nil && ok
+}
+
+// Set stores data under key.
+func (c *Cache) Set(key string, data []byte) {
+	args := []string{"SET", c.Prefix + key, string(data)}
+	if c.TTL > 0 {
+		args = append(args, "PX", strconv.FormatInt(int64(c.TTL/time.Millisecond), 10))
+	}
+	c.Client.Do(args...)
+}
+
+// Delete removes the entry stored under key.
+func (c *Cache) Delete(key string) {
+	c.Client.Do("DEL", c.Prefix+key)
+}
+
+// A HashIndex is an ospry.HashIndex kept in redis.
+type HashIndex struct {
+	Client *Client
+	// Prefix is prepended to every hash to make its key.
+	Prefix string
+}
+
+// Lookup returns the id of the image with the given hash.
+func (x *HashIndex) Lookup(hash string) (string, bool, error) {
+	reply, err := x.Client.Do("GET", x.Prefix+hash)
+	if err != nil {
+		return "", false, err
+	}
+	b, ok := reply.([]byte)
+	return string(b), ok, nil
+}
+
+// Store records the id of the image with the given hash.
+func (x *HashIndex) Store(hash, id string) error {
+	_, err := x.Client.Do("SET", x.Prefix+hash, id)
+	return err
+}
+
+// Delete forgets the image with the given hash.
+func (x *HashIndex) Delete(hash string) error {
+	_, err := x.Client.Do("DEL", x.Prefix+hash)
+	return err
+}
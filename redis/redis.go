@@ -0,0 +1,204 @@
+// Package redis shares ospry client state between processes through
+// redis: Cache is a cache.Cache (e.g. for a client's MetadataCache or
+// Cache), and HashIndex an ospry.HashIndex for UploadIfAbsent. With
+// several instances of a web app pointing at the same redis, they see
+// the same cached metadata and deduplicate uploads against each other:
+//
+//	rc := &redis.Client{Addr: "redis:6379"}
+//	client.MetadataCache = &redis.Cache{Client: rc, Prefix: "ospry:", TTL: time.Hour}
+//	client.HashIndex = &redis.HashIndex{Client: rc, Prefix: "ospry:hash:"}
+//
+// It speaks the redis protocol itself, so it has no dependencies.
+package redis
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Defaults for a Client's zero fields.
+const (
+	DefaultAddr    = "localhost:6379"
+	DefaultTimeout = 5 * time.Second
+	DefaultMaxIdle = 4
+)
+
+// An Error is an error reply from redis.
+type Error string
+
+func (e Error) Error() string { return "redis: " + string(e) }
+
+var errProtocol = errors.New("redis: protocol error")
+
+// A Client sends commands to a redis server, keeping a few idle
+// connections for reuse. It's safe for concurrent use.
+type Client struct {
+	// Addr is the server's host:port. If empty, DefaultAddr is used.
+	Addr string
+	// Password and DB, if set, are sent with AUTH and SELECT on
+	// every new connection.
+	Password string
+	DB       int
+	// Timeout limits dialing and each command. If zero,
+	// DefaultTimeout is used.
+	Timeout time.Duration
+	// MaxIdle is the number of idle connections kept. If zero,
+	// DefaultMaxIdle is used.
+	MaxIdle int
+
+	mu   sync.Mutex
+	idle []*conn
+}
+
+type conn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// Do sends a command and returns its reply: a string for status
+// replies, an int64 for integers, a []byte (or nil) for bulk strings
+// and a []interface{} for arrays. Error replies are returned as an
+// Error.
+func (c *Client) Do(args ...string) (interface{}, error) {
+	cn, err := c.get()
+	if err != nil {
+		return nil, err
+	}
+	reply, err := cn.do(c.timeout(), args)
+	if _, ok := err.(Error); err != nil && !ok {
+		cn.Close()
+		return nil, err
+	}
+	c.put(cn)
+	return reply, err
+}
+
+func (c *Client) timeout() time.Duration {
+	if c.Timeout == 0 {
+		return DefaultTimeout
+	}
+	return c.Timeout
+}
+
+func (c *Client) get() (*conn, error) {
+	c.mu.Lock()
+	if n := len(c.idle); n > 0 {
+		cn := c.idle[n-1]
+		c.idle = c.idle[:n-1]
+		c.mu.Unlock()
+		return cn, nil
+	}
+	c.mu.Unlock()
+	addr := c.Addr
+	if addr == "" {
+		addr = DefaultAddr
+	}
+	nc, err := net.DialTimeout("tcp", addr, c.timeout())
+	if err != nil {
+		return nil, err
+	}
+	cn := &conn{Conn: nc, r: bufio.NewReader(nc)}
+	var setup [][]string
+	if c.Password != "" {
+		setup = append(setup, []string{"AUTH", c.Password})
+	}
+	if c.DB != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(c.DB)})
+	}
+	for _, args := range setup {
+		if _, err := cn.do(c.timeout(), args); err != nil {
+			cn.Close()
+			return nil, err
+		}
+	}
+	return cn, nil
+}
+
+func (c *Client) put(cn *conn) {
+	max := c.MaxIdle
+	if max == 0 {
+		max = DefaultMaxIdle
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.idle) >= max {
+		cn.Close()
+		return
+	}
+	c.idle = append(c.idle, cn)
+}
+
+// Close closes the client's idle connections.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, cn := range c.idle {
+		cn.Close()
+	}
+	c.idle = nil
+	return nil
+}
+
+func (cn *conn) do(timeout time.Duration, args []string) (interface{}, error) {
+	cn.SetDeadline(time.Now().Add(timeout))
+	w := bufio.NewWriter(cn)
+	fmt.Fprintf(w, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	return readReply(cn.r)
+}
+
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, errProtocol
+	}
+	kind, line := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return line, nil
+	case '-':
+		return nil, Error(line)
+	case ':':
+		return strconv.ParseInt(line, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		replies := make([]interface{}, n)
+		for i := range replies {
+			if replies[i], err = readReply(r); err != nil {
+				if _, ok := err.(Error); !ok {
+					return nil, err
+				}
+				replies[i] = err
+			}
+		}
+		return replies, nil
+	}
+	return nil, errProtocol
+}
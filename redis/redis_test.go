@@ -0,0 +1,138 @@
+package redis
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	ospry "github.com/ospry/ospry-go"
+	"github.com/ospry/ospry-go/cache"
+)
+
+var (
+	_ cache.Deleter   = &Cache{}
+	_ ospry.HashIndex = &HashIndex{}
+)
+
+// fakeRedis serves GET, SET, DEL and AUTH from memory, returning the
+// server's address and the commands it received.
+func fakeRedis(t *testing.T) (string, *[]string, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	data := map[string]string{}
+	var cmds []string
+	serve := func(c net.Conn) {
+		defer c.Close()
+		r := bufio.NewReader(c)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+			args := make([]string, n)
+			for i := range args {
+				line, _ := r.ReadString('\n')
+				size, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+				arg := make([]byte, size+2)
+				io.ReadFull(r, arg)
+				args[i] = string(arg[:size])
+			}
+			mu.Lock()
+			cmds = append(cmds, strings.Join(args, " "))
+			switch args[0] {
+			case "AUTH":
+				if args[1] == "secret" {
+					c.Write([]byte("+OK\r\n"))
+				} else {
+					c.Write([]byte("-ERR invalid password\r\n"))
+				}
+			case "GET":
+				if v, ok := data[args[1]]; ok {
+					c.Write([]byte("$" + strconv.Itoa(len(v)) + "\r\n" + v + "\r\n"))
+				} else {
+					c.Write([]byte("$-1\r\n"))
+				}
+			case "SET":
+				data[args[1]] = args[2]
+				c.Write([]byte("+OK\r\n"))
+			case "DEL":
+				delete(data, args[1])
+				c.Write([]byte(":1\r\n"))
+			default:
+				c.Write([]byte("-ERR unknown command\r\n"))
+			}
+			mu.Unlock()
+		}
+	}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serve(c)
+		}
+	}()
+	return l.Addr().String(), &cmds, func() { l.Close() }
+}
+
+func TestCache(t *testing.T) {
+	addr, cmds, done := fakeRedis(t)
+	defer done()
+	rc := &Client{Addr: addr, Password: "secret"}
+	defer rc.Close()
+	c := &Cache{Client: rc, Prefix: "p:", TTL: 1500 * time.Millisecond}
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("got hit, want miss")
+	}
+	c.Set("a", []byte("data\r\nwith crlf"))
+	if b, ok := c.Get("a"); !ok || string(b) != "data\r\nwith crlf" {
+		t.Fatalf("got %q, %v, want data", b, ok)
+	}
+	c.Delete("a")
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("got hit after delete, want miss")
+	}
+	want := []string{"AUTH secret", "GET p:a", "SET p:a data\r\nwith crlf PX 1500", "GET p:a", "DEL p:a", "GET p:a"}
+	if strings.Join(*cmds, "|") != strings.Join(want, "|") {
+		t.Fatalf("got %q, want %q", *cmds, want)
+	}
+}
+
+func TestHashIndex(t *testing.T) {
+	addr, _, done := fakeRedis(t)
+	defer done()
+	x := &HashIndex{Client: &Client{Addr: addr}, Prefix: "h:"}
+	if err := x.Store("abc", "img1"); err != nil {
+		t.Fatal(err)
+	}
+	// Another instance sees the same index.
+	y := &HashIndex{Client: &Client{Addr: addr}, Prefix: "h:"}
+	if id, ok, err := y.Lookup("abc"); err != nil || !ok || id != "img1" {
+		t.Fatalf("got %s, %v, %v, want img1", id, ok, err)
+	}
+	if err := y.Delete("abc"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := x.Lookup("abc"); err != nil || ok {
+		t.Fatalf("got %v, %v, want miss", ok, err)
+	}
+}
+
+func TestAuthError(t *testing.T) {
+	addr, _, done := fakeRedis(t)
+	defer done()
+	c := &Client{Addr: addr, Password: "wrong"}
+	if _, err := c.Do("GET", "a"); err == nil || err.Error() != "redis: ERR invalid password" {
+		t.Fatalf("got %v, want invalid password error", err)
+	}
+}
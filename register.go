@@ -0,0 +1,57 @@
+package ospry
+
+import (
+	"fmt"
+	"io"
+)
+
+// A RegisterError is returned by UploadAndRegister when registering an
+// uploaded image failed. If the image couldn't be deleted either,
+// DeleteErr is set and the image is orphaned.
+type RegisterError struct {
+	ID        string
+	Err       error
+	DeleteErr error
+}
+
+func (e *RegisterError) Error() string {
+	if e.DeleteErr != nil {
+		return fmt.Sprintf("ospry: registering image %s failed: %v (deleting it failed too: %v)", e.ID, e.Err, e.DeleteErr)
+	}
+	return fmt.Sprintf("ospry: registering image %s failed: %v", e.ID, e.Err)
+}
+
+func (e *RegisterError) Unwrap() error {
+	return e.Err
+}
+
+// UploadAndRegister calls UploadAndRegister on the default client.
+func UploadAndRegister(filename string, data io.Reader, opts *UploadOpts, register func(*Metadata) error) (*Metadata, error) {
+	return DefaultClient.UploadAndRegister(filename, data, opts, register)
+}
+
+// UploadAndRegister uploads an image (see Upload) and passes its
+// metadata to register, e.g. to save it in the application's
+// database. If register fails or panics, the image is deleted again,
+// so that failed registrations don't leave unreferenced images behind,
+// and a *RegisterError is returned.
+func (c *Client) UploadAndRegister(filename string, data io.Reader, opts *UploadOpts, register func(*Metadata) error) (m *Metadata, err error) {
+	m, err = c.Upload(filename, data, opts)
+	if err != nil {
+		return nil, err
+	}
+	ok := false
+	defer func() {
+		if ok {
+			return
+		}
+		derr := c.Delete(m.ID)
+		if v := recover(); v != nil {
+			panic(v)
+		}
+		m, err = nil, &RegisterError{ID: m.ID, Err: err, DeleteErr: derr}
+	}()
+	err = register(m)
+	ok = err == nil
+	return m, err
+}
@@ -0,0 +1,48 @@
+package ospry
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUploadAndRegister(t *testing.T) {
+	var deleted []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "DELETE" {
+			deleted = append(deleted, strings.TrimPrefix(r.URL.Path, "/images/"))
+		}
+		w.Write([]byte(`{"metadata":{"id":"foo"}}`))
+	}))
+	defer ts.Close()
+	c := New("sk-test-key")
+	c.ServerURL = ts.URL
+
+	m, err := c.UploadAndRegister("foo.jpg", strings.NewReader("foo"), nil, func(m *Metadata) error { return nil })
+	if err != nil || m.ID != "foo" || len(deleted) != 0 {
+		t.Fatalf("got %v, %v, deleted %v, want foo kept", m, err, deleted)
+	}
+
+	errDB := errors.New("db down")
+	m, err = c.UploadAndRegister("foo.jpg", strings.NewReader("foo"), nil, func(m *Metadata) error { return errDB })
+	if m != nil || !errors.Is(err, errDB) || err.(*RegisterError).DeleteErr != nil {
+		t.Fatalf("got %v, %v, want *RegisterError wrapping %v", m, err, errDB)
+	}
+	if len(deleted) != 1 || deleted[0] != "foo" {
+		t.Fatalf("got deleted %v, want foo", deleted)
+	}
+
+	func() {
+		defer func() {
+			if v := recover(); v != "boom" {
+				t.Fatalf("got %v, want panic boom", v)
+			}
+		}()
+		c.UploadAndRegister("foo.jpg", strings.NewReader("foo"), nil, func(m *Metadata) error { panic("boom") })
+	}()
+	if len(deleted) != 2 {
+		t.Fatalf("got deleted %v, want foo deleted after panic", deleted)
+	}
+}
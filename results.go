@@ -0,0 +1,75 @@
+package ospry
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// A BatchResult is the outcome of one item of a bulk operation (e.g.
+// Batch, DeleteMany or Downloader). Bulk operations run every item
+// and return one result per item, in order; if any failed, they also
+// return a *BatchError.
+type BatchResult[T any] struct {
+	// Item identifies the item: an image id, path or url.
+	Item string
+	// Value is the item's result, if it succeeded.
+	Value T
+	Err   error
+	// Attempts is the number of requests made for the item.
+	Attempts int
+	// Duration is how long the item took.
+	Duration time.Duration
+}
+
+// A BatchError is returned by bulk operations some of whose items
+// failed. It unwraps to the items' errors.
+type BatchError struct {
+	// Total is the number of items in the operation.
+	Total int
+	// Errs are the errors of the failed items.
+	Errs []error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("ospry: %d of %d items failed, first: %v", len(e.Errs), e.Total, e.Errs[0])
+}
+
+// Unwrap returns the errors of the failed items.
+func (e *BatchError) Unwrap() []error {
+	return e.Errs
+}
+
+// fanOut calls do for each of n items, up to concurrency at once, and
+// returns their results in order. item names the items.
+func fanOut[T any](concurrency, n int, item func(i int) string, do func(i int) (T, error)) []BatchResult[T] {
+	results := make([]BatchResult[T], n)
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() { <-sem; wg.Done() }()
+			start := time.Now()
+			v, err := do(i)
+			results[i] = BatchResult[T]{Item: item(i), Value: v, Err: err, Attempts: 1, Duration: time.Since(start)}
+		}(i)
+	}
+	wg.Wait()
+	return results
+}
+
+// batchErr returns a *BatchError for the failed results, or nil.
+func batchErr[T any](results []BatchResult[T]) error {
+	var errs []error
+	for _, r := range results {
+		if r.Err != nil {
+			errs = append(errs, r.Err)
+		}
+	}
+	if errs == nil {
+		return nil
+	}
+	return &BatchError{Total: len(results), Errs: errs}
+}
@@ -0,0 +1,121 @@
+package ospry
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// retryBackoff is the delay before the first retry. It doubles with
+// each further retry.
+var retryBackoff = 250 * time.Millisecond
+
+func retryDelay(attempt int) time.Duration {
+	if attempt > 5 {
+		attempt = 5
+	}
+	return retryBackoff << uint(attempt)
+}
+
+// An UploadRetryError is returned when an upload failed with an error
+// that could have been retried, but its data couldn't be rewound (see
+// Client.UploadRetries).
+type UploadRetryError struct {
+	Err error
+}
+
+func (e *UploadRetryError) Error() string {
+	return "ospry: can't retry upload of data that isn't seekable: " + e.Err.Error()
+}
+
+func (e *UploadRetryError) Unwrap() error {
+	return e.Err
+}
+
+// retryBody prepares an upload body of the given size (or -1) for
+// retries, returning the body to upload, its size and a func that
+// rewinds it. The func is nil if the body can't be rewound or the
+// client doesn't retry uploads. Data that isn't seekable is buffered
+// if it fits in the client's RetryBufferSize.
+func (c *Client) retryBody(data io.Reader, size int64) (io.Reader, int64, func() error, error) {
+	if c.UploadRetries <= 0 {
+		return data, size, nil, nil
+	}
+	if s, ok := data.(io.Seeker); ok {
+		if off, err := s.Seek(0, io.SeekCurrent); err == nil {
+			return data, size, func() error {
+				_, err := s.Seek(off, io.SeekStart)
+				return err
+			}, nil
+		}
+	}
+	if c.RetryBufferSize <= 0 || size > c.RetryBufferSize {
+		return data, size, nil, nil
+	}
+	// The buffer only grows as far as the data goes.
+	var buf bytes.Buffer
+	if size >= 0 {
+		buf.Grow(int(size) + 1)
+	}
+	_, err := io.CopyN(&buf, data, c.RetryBufferSize+1)
+	switch err {
+	case nil:
+		// Too big to buffer.
+		return io.MultiReader(&buf, data), size, nil, nil
+	case io.EOF:
+	default:
+		return nil, 0, nil, err
+	}
+	r := bytes.NewReader(buf.Bytes())
+	return r, r.Size(), func() error {
+		_, err := r.Seek(0, io.SeekStart)
+		return err
+	}, nil
+}
+
+// sendUpload sends an upload request and reports whether it failed in
+// a way that's worth retrying.
+func (c *Client) sendUpload(req *http.Request) (*Metadata, bool, error) {
+	res, err := c.do(req)
+	if err != nil {
+		_, retry := err.(*url.Error)
+		return nil, retry, err
+	}
+	defer res.Body.Close()
+	m, err := c.decodeMetadata(res)
+	if res.StatusCode >= 500 || res.StatusCode == http.StatusTooManyRequests {
+		if _, ok := err.(*Error); !ok {
+			err = &Error{HTTPStatusCode: res.StatusCode, Message: "upload failed: " + res.Status}
+		}
+		return nil, true, err
+	}
+	return m, false, err
+}
+
+// A retryProgress reports the bytes read from an upload body as
+// progress on item, counting each byte once however often the body is
+// rewound for retries.
+type retryProgress struct {
+	r        io.Reader
+	item     string
+	reporter ProgressReporter
+	pos      int64
+	reported int64
+}
+
+func (r *retryProgress) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.pos += int64(n)
+	if r.pos > r.reported {
+		r.reporter.Progress(r.item, r.pos-r.reported)
+		r.reported = r.pos
+	}
+	return n, err
+}
+
+// rewind notes that the body was rewound.
+func (r *retryProgress) rewind() {
+	r.pos = 0
+}
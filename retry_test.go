@@ -0,0 +1,90 @@
+package ospry
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUploadRetry(t *testing.T) {
+	retryBackoff = time.Millisecond
+	defer func() { retryBackoff = 250 * time.Millisecond }()
+
+	var failures, requests int
+	var bodies []string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		b, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		if requests <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		writeMetadata(w, &Metadata{ID: "foo"})
+	})
+	stream := func(s string) io.Reader { return io.MultiReader(strings.NewReader(s)) }
+	tests := []struct {
+		data        io.Reader
+		retries     int
+		bufferSize  int64
+		failures    int
+		err         string
+		wantRequest int
+	}{
+		{strings.NewReader("seekable"), 2, 0, 2, "", 3},
+		{stream("buffered"), 2, 100, 2, "", 3},
+		{stream("too big"), 2, 3, 1, "ospry: can't retry upload of data that isn't seekable: ospry: upload failed: 503 Service Unavailable", 1},
+		{stream("unbuffered"), 2, 0, 1, "ospry: can't retry upload of data that isn't seekable: ospry: upload failed: 503 Service Unavailable", 1},
+		{strings.NewReader("exhausted"), 1, 0, 5, "ospry: upload failed: 503 Service Unavailable", 2},
+		{strings.NewReader("no retries"), 0, 0, 1, "ospry: upload failed: 503 Service Unavailable", 1},
+	}
+	for _, test := range tests {
+		requests, failures, bodies = 0, test.failures, nil
+		c.UploadRetries = test.retries
+		c.RetryBufferSize = test.bufferSize
+		_, err := c.UploadPublic("foo.jpg", test.data)
+		if test.err == "" && err != nil {
+			t.Fatal(err)
+		}
+		if test.err != "" && (err == nil || errors.Unwrap(err).Error() != test.err) {
+			t.Fatalf("got %v, want %s", err, test.err)
+		}
+		if requests != test.wantRequest {
+			t.Fatalf("got %d requests, want %d", requests, test.wantRequest)
+		}
+		for _, b := range bodies[1:] {
+			if b != bodies[0] {
+				t.Fatalf("retried with body %q, want %q", b, bodies[0])
+			}
+		}
+	}
+}
+
+func TestUploadRetryProgress(t *testing.T) {
+	retryBackoff = time.Millisecond
+	defer func() { retryBackoff = 250 * time.Millisecond }()
+
+	var requests int
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		ioutil.ReadAll(r.Body)
+		if requests <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		writeMetadata(w, &Metadata{ID: "foo"})
+	})
+	c.UploadRetries = 2
+	var total int64
+	c.Progress = BarProgress(func(n int64) { total += n }, nil)
+	if _, err := c.UploadPublic("foo.jpg", strings.NewReader("seekable")); err != nil {
+		t.Fatal(err)
+	}
+	if requests != 3 || total != int64(len("seekable")) {
+		t.Fatalf("got %d bytes of progress over %d requests, want each byte once", total, requests)
+	}
+}
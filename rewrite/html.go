@@ -0,0 +1,107 @@
+package rewrite
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	imgTag  = regexp.MustCompile(`(?is)<img\b[^>]*>`)
+	srcAttr = regexp.MustCompile(`(?is)(\ssrc(?:set)?\s*=\s*)("[^"]*"|'[^']*'|[^\s"'>]+)`)
+)
+
+// HTMLFile rewrites the images of the HTML file at path in place (see
+// HTML).
+func (rw *Rewriter) HTMLFile(path string) error {
+	return rw.rewriteFile(path, rw.HTML)
+}
+
+// HTML rewrites the src and srcset attributes of the <img> tags in
+// doc that refer to local files. Relative references are resolved
+// against dir, the directory of the document.
+func (rw *Rewriter) HTML(doc []byte, dir string) ([]byte, error) {
+	return replaceAll(imgTag, doc, func(tag []byte) ([]byte, error) {
+		return replaceAll(srcAttr, tag, func(attr []byte) ([]byte, error) {
+			m := srcAttr.FindSubmatch(attr)
+			name, quoted := string(m[1]), string(m[2])
+			quote := ""
+			value := quoted
+			if strings.HasPrefix(quoted, `"`) || strings.HasPrefix(quoted, `'`) {
+				quote = quoted[:1]
+				value = quoted[1 : len(quoted)-1]
+			}
+			orig := html.UnescapeString(value)
+			var err error
+			if strings.HasSuffix(strings.TrimSpace(strings.ToLower(name)), "srcset=") {
+				value, err = rw.srcset(orig, dir)
+			} else {
+				value, err = rw.ref(orig, dir)
+			}
+			if err != nil {
+				return nil, err
+			}
+			if value == orig {
+				return attr, nil
+			}
+			if quote == "" {
+				quote = `"`
+			}
+			return []byte(name + quote + html.EscapeString(value) + quote), nil
+		})
+	})
+}
+
+func (rw *Rewriter) ref(ref, dir string) (string, error) {
+	u, ok, err := rw.urlFor(strings.TrimSpace(ref), dir)
+	if err != nil || !ok {
+		return ref, err
+	}
+	return u, nil
+}
+
+// srcset rewrites the url of each candidate of a srcset attribute,
+// leaving everything else as it is. Candidates are parsed as in the
+// HTML spec: a url runs up to whitespace, so it may contain commas,
+// and trailing commas end the candidate.
+func (rw *Rewriter) srcset(value, dir string) (string, error) {
+	var b strings.Builder
+	i := 0
+	for i < len(value) {
+		start := i
+		for i < len(value) && (isSpace(value[i]) || value[i] == ',') {
+			i++
+		}
+		b.WriteString(value[start:i])
+		start = i
+		for i < len(value) && !isSpace(value[i]) {
+			i++
+		}
+		end := i
+		for end > start && value[end-1] == ',' {
+			end--
+		}
+		if end > start {
+			u, err := rw.ref(value[start:end], dir)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(u)
+		}
+		b.WriteString(value[end:i])
+		if end < i {
+			// The url ended with commas, so there are no descriptors.
+			continue
+		}
+		start = i
+		for i < len(value) && value[i] != ',' {
+			i++
+		}
+		b.WriteString(value[start:i])
+	}
+	return b.String(), nil
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
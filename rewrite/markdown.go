@@ -0,0 +1,42 @@
+package rewrite
+
+import (
+	"regexp"
+	"strings"
+)
+
+// mdImage matches inline Markdown images, capturing everything up to
+// the destination, the destination, and the rest.
+var mdImage = regexp.MustCompile(`(!\[[^\]]*\]\(\s*)(<[^>\n]*>|[^\s)]+)((?:\s+"[^"]*"|\s+'[^']*')?\s*\))`)
+
+// MarkdownFile rewrites the images of the Markdown file at path in
+// place (see Markdown).
+func (rw *Rewriter) MarkdownFile(path string) error {
+	return rw.rewriteFile(path, rw.Markdown)
+}
+
+// Markdown rewrites the inline images (![alt](path "title")) and
+// <img> tags in doc that refer to local files. Relative references are
+// resolved against dir, the directory of the document.
+func (rw *Rewriter) Markdown(doc []byte, dir string) ([]byte, error) {
+	doc, err := replaceAll(mdImage, doc, func(img []byte) ([]byte, error) {
+		m := mdImage.FindSubmatch(img)
+		dest := string(m[2])
+		angled := strings.HasPrefix(dest, "<")
+		if angled {
+			dest = dest[1 : len(dest)-1]
+		}
+		u, err := rw.ref(dest, dir)
+		if err != nil || u == dest {
+			return img, err
+		}
+		if angled {
+			u = "<" + u + ">"
+		}
+		return []byte(string(m[1]) + u + string(m[3])), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rw.HTML(doc, dir)
+}
@@ -0,0 +1,78 @@
+package rewrite
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	ospry "github.com/ospry/ospry-go"
+	"github.com/ospry/ospry-go/manifest"
+)
+
+func TestMarkdown(t *testing.T) {
+	dir, c, uploads := newSite(t)
+	rw := &Rewriter{Client: c, Manifest: &manifest.Manifest{}}
+	in := "# Hi\n\n![a](a.png \"title\") and ![b](<img/b.png>) and [link](a.png)\n" +
+		"![remote](https://example.com/x.png)\n<img src=\"img/c.png\">\n"
+	got, err := rw.Markdown([]byte(in), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# Hi\n\n![a](http://foo.ospry.io/a.png \"title\") and ![b](<http://foo.ospry.io/b.png>) and [link](a.png)\n" +
+		"![remote](https://example.com/x.png)\n<img src=\"http://foo.ospry.io/c.png\">\n"
+	if string(got) != want {
+		t.Fatalf("got %s\n         want %s", got, want)
+	}
+	if *uploads != 3 || len(rw.Manifest.Entries) != 3 || len(rw.Refs) != 3 {
+		t.Fatalf("got %d uploads, %d entries and %d refs, want 3", *uploads, len(rw.Manifest.Entries), len(rw.Refs))
+	}
+
+	// Files in the manifest aren't uploaded again.
+	rw = &Rewriter{Client: c, Manifest: rw.Manifest}
+	if _, err := rw.Markdown([]byte(in), dir); err != nil {
+		t.Fatal(err)
+	}
+	if *uploads != 3 {
+		t.Fatalf("got %d uploads, want 3", *uploads)
+	}
+}
+
+func TestMarkdownDryRun(t *testing.T) {
+	dir, c, uploads := newSite(t)
+	path := filepath.Join(dir, "post.md")
+	in := "![a](a.png)\n"
+	if err := os.WriteFile(path, []byte(in), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rw := &Rewriter{Client: c, DryRun: true}
+	if err := rw.MarkdownFile(path); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := os.ReadFile(path)
+	if string(b) != in || *uploads != 0 {
+		t.Fatalf("got %q and %d uploads, want unmodified file and no uploads", b, *uploads)
+	}
+	if len(rw.Refs) != 1 || rw.Refs[0].Path != filepath.Join(dir, "a.png") {
+		t.Fatalf("got %v, want a.png ref", rw.Refs)
+	}
+}
+
+func TestMarkdownSigned(t *testing.T) {
+	dir, c, _ := newSite(t)
+	rw := &Rewriter{Client: c, RenderOpts: &ospry.RenderOpts{TimeExpired: time.Now().Add(time.Hour)}}
+	got, err := rw.Markdown([]byte("![a](a.png)"), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := strings.TrimSuffix(strings.TrimPrefix(string(got), "![a]("), ")")
+	u, err := url.Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Query().Get("signature") == "" {
+		t.Fatalf("got %s, want signed url", s)
+	}
+}
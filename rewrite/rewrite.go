@@ -0,0 +1,221 @@
+// Package rewrite migrates the images of static documents to ospry:
+// it finds references to local image files, uploads the files, and
+// rewrites the references to the uploaded images' urls.
+//
+//	rw := &rewrite.Rewriter{Client: client, Root: "public"}
+//	err := rw.HTMLFile("public/index.html")
+//	err = rw.MarkdownFile("posts/hello.md")
+package rewrite
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	ospry "github.com/ospry/ospry-go"
+	"github.com/ospry/ospry-go/manifest"
+)
+
+// A Rewriter uploads local images referenced by documents and
+// rewrites the references. Each file is uploaded once, however many
+// documents reference it. A Rewriter isn't safe for concurrent use.
+type Rewriter struct {
+	// Client uploads images. If nil, the default client is used.
+	Client *ospry.Client
+	// Root is the directory that root-relative references (e.g.
+	// "/img/logo.png") are resolved against. If empty, root-relative
+	// references are left alone. References may only name files in
+	// Root, or, if it's empty, in the document's directory.
+	Root string
+	// UploadOpts are the options images are uploaded with.
+	UploadOpts *ospry.UploadOpts
+	// Manifest, if set, records the uploaded files. Files already in
+	// the manifest with the same content aren't uploaded again.
+	Manifest *manifest.Manifest
+	// RenderOpts, if set, are applied to the urls of uploaded images,
+	// e.g. to resize or sign them.
+	RenderOpts *ospry.RenderOpts
+	// DryRun reports the references that would be rewritten in Refs
+	// without uploading files or modifying documents.
+	DryRun bool
+
+	// Refs lists the references to local files found so far.
+	Refs []Ref
+
+	urls map[string]string
+}
+
+// ErrOutsideRoot is returned for references to files outside the
+// Rewriter's Root (or the document's directory), e.g. "../../etc/passwd"
+// or a symlink pointing out of it.
+var ErrOutsideRoot = errors.New("rewrite: reference outside root")
+
+// A Ref is a reference to a local file found in a document.
+type Ref struct {
+	// Ref is the reference as it appears in the document.
+	Ref string
+	// Path is the path of the file it refers to.
+	Path string
+	// URL is the url it's rewritten to. It's empty in dry runs.
+	URL string
+}
+
+// isLocal reports whether ref refers to a local file.
+func isLocal(ref string) bool {
+	if ref == "" || strings.HasPrefix(ref, "//") || strings.HasPrefix(ref, "#") {
+		return false
+	}
+	u, err := url.Parse(ref)
+	return err == nil && u.Scheme == "" && u.Host == ""
+}
+
+// resolve returns the path of the local file ref refers to from a
+// document in dir, which must be in the Rewriter's Root (or dir) once
+// symlinks are followed.
+func (rw *Rewriter) resolve(ref, dir string) (string, bool, error) {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return "", false, nil
+	}
+	p := filepath.FromSlash(u.Path)
+	root := rw.Root
+	var path string
+	if strings.HasPrefix(u.Path, "/") {
+		if root == "" {
+			return "", false, nil
+		}
+		path = filepath.Join(root, p)
+	} else {
+		if root == "" {
+			root = dir
+		}
+		path = filepath.Join(dir, p)
+	}
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", false, err
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", false, err
+	}
+	if !within(realRoot, real) {
+		return "", false, &os.PathError{Op: "resolve", Path: ref, Err: ErrOutsideRoot}
+	}
+	return path, true, nil
+}
+
+// within reports whether path is root or in it. Both must be clean.
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
+// urlFor returns the url that replaces ref in a document in dir. The
+// boolean result is false if ref isn't a local file.
+func (rw *Rewriter) urlFor(ref, dir string) (string, bool, error) {
+	if !isLocal(ref) {
+		return "", false, nil
+	}
+	path, ok, err := rw.resolve(ref, dir)
+	if !ok {
+		return "", false, err
+	}
+	if rw.DryRun {
+		rw.Refs = append(rw.Refs, Ref{Ref: ref, Path: path})
+		return ref, true, nil
+	}
+	u, ok := rw.urls[path]
+	if !ok {
+		var err error
+		if u, err = rw.upload(path); err != nil {
+			return "", false, err
+		}
+		if rw.RenderOpts != nil {
+			if u, err = rw.client().FormatURL(u, rw.RenderOpts); err != nil {
+				return "", false, err
+			}
+		}
+		if rw.urls == nil {
+			rw.urls = map[string]string{}
+		}
+		rw.urls[path] = u
+	}
+	rw.Refs = append(rw.Refs, Ref{Ref: ref, Path: path, URL: u})
+	return u, true, nil
+}
+
+func (rw *Rewriter) client() *ospry.Client {
+	if rw.Client == nil {
+		return ospry.DefaultClient
+	}
+	return rw.Client
+}
+
+func (rw *Rewriter) upload(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash, err := manifest.Hash(f)
+	if err != nil {
+		return "", err
+	}
+	if rw.Manifest != nil {
+		if e, ok := rw.Manifest.Get(path); ok && e.Hash == hash {
+			return e.URL, nil
+		}
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	md, err := rw.client().Upload(filepath.Base(path), f, rw.UploadOpts)
+	if err != nil {
+		return "", err
+	}
+	if rw.Manifest != nil {
+		rw.Manifest.Add(path, hash, md)
+	}
+	return md.URL, nil
+}
+
+// rewriteFile applies fn to the file at path in place.
+func (rw *Rewriter) rewriteFile(path string, fn func(doc []byte, dir string) ([]byte, error)) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	doc, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	out, err := fn(doc, filepath.Dir(path))
+	if err != nil || rw.DryRun {
+		return err
+	}
+	return ioutil.WriteFile(path, out, fi.Mode())
+}
+
+// replaceAll is like Regexp.ReplaceAllFunc, but stops at the first
+// error.
+func replaceAll(re *regexp.Regexp, src []byte, fn func(match []byte) ([]byte, error)) ([]byte, error) {
+	var err error
+	out := re.ReplaceAllFunc(src, func(m []byte) []byte {
+		if err != nil {
+			return m
+		}
+		var r []byte
+		r, err = fn(m)
+		if err != nil {
+			return m
+		}
+		return r
+	})
+	return out, err
+}
@@ -0,0 +1,152 @@
+package rewrite
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	ospry "github.com/ospry/ospry-go"
+)
+
+// newSite creates a directory with a few image files and returns it
+// along with a client for a fake api server that names uploaded
+// images after their filenames, and a counter of uploads.
+func newSite(t *testing.T) (string, *ospry.Client, *int) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.png", "img/b.png", "img/c.png"} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	uploads := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		uploads++
+		name := r.URL.Query().Get("filename")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"metadata": &ospry.Metadata{ID: name, URL: "http://foo.ospry.io/" + name},
+		})
+	}))
+	t.Cleanup(srv.Close)
+	c := ospry.New("sk-test-key")
+	c.ServerURL = srv.URL + "/v1"
+	return dir, c, &uploads
+}
+
+func TestHTML(t *testing.T) {
+	dir, c, uploads := newSite(t)
+	rw := &Rewriter{Client: c, Root: dir}
+	in := `<p><img alt="a" src="a.png"> <IMG SRC='/img/b.png' srcset="img/b.png 1x, img/c.png 2x">` +
+		`<img src=https://example.com/x.png><img src="missing.png" data-src="a.png"></p>`
+	_, err := rw.HTML([]byte(in), dir)
+	if err == nil {
+		t.Fatal("got nil, want error for missing file")
+	}
+	in = in[:len(in)-len(`<img src="missing.png" data-src="a.png"></p>`)]
+	got, err := rw.HTML([]byte(in), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<p><img alt="a" src="http://foo.ospry.io/a.png"> <IMG SRC='http://foo.ospry.io/b.png' ` +
+		`srcset="http://foo.ospry.io/b.png 1x, http://foo.ospry.io/c.png 2x"><img src=https://example.com/x.png>`
+	if string(got) != want {
+		t.Fatalf("got %s\n         want %s", got, want)
+	}
+	if *uploads != 3 {
+		t.Fatalf("got %d uploads, want 3", *uploads)
+	}
+}
+
+func TestHTMLSrcset(t *testing.T) {
+	dir, c, uploads := newSite(t)
+	rw := &Rewriter{Client: c, Root: dir}
+	tests := []struct {
+		in, want string
+	}{
+		{`<img srcset="data:image/png;base64,iVBORw0KGgo= 1x">`, `<img srcset="data:image/png;base64,iVBORw0KGgo= 1x">`},
+		{`<img srcset="https://cdn.example.com/w_300,h_200/x.jpg 1x,a.png 2x">`, `<img srcset="https://cdn.example.com/w_300,h_200/x.jpg 1x,http://foo.ospry.io/a.png 2x">`},
+		{`<img srcset="https://example.com/x.jpg 1x,https://example.com/y.jpg 2x">`, `<img srcset="https://example.com/x.jpg 1x,https://example.com/y.jpg 2x">`},
+		{`<img srcset="a.png,  https://example.com/x.jpg 2x">`, `<img srcset="http://foo.ospry.io/a.png,  https://example.com/x.jpg 2x">`},
+	}
+	for _, test := range tests {
+		got, err := rw.HTML([]byte(test.in), dir)
+		if err != nil {
+			t.Fatalf("%s: %v", test.in, err)
+		}
+		if string(got) != test.want {
+			t.Fatalf("got %s\n         want %s", got, test.want)
+		}
+	}
+	if *uploads != 1 {
+		t.Fatalf("got %d uploads, want 1", *uploads)
+	}
+}
+
+func TestHTMLFile(t *testing.T) {
+	dir, c, _ := newSite(t)
+	path := filepath.Join(dir, "img", "index.html")
+	if err := os.WriteFile(path, []byte(`<img src="b.png">`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rw := &Rewriter{Client: c}
+	if err := rw.HTMLFile(path); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `<img src="http://foo.ospry.io/b.png">`; string(b) != want {
+		t.Fatalf("got %s, want %s", b, want)
+	}
+}
+
+func TestOutsideRoot(t *testing.T) {
+	dir, c, uploads := newSite(t)
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret.png"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret.png"), filepath.Join(dir, "img", "link.png")); err != nil {
+		t.Fatal(err)
+	}
+	secret := filepath.Join(outside, "secret.png")
+	rel, err := filepath.Rel(filepath.Join(dir, "img"), secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromRoot, err := filepath.Rel(dir, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		root, ref string
+	}{
+		{dir, filepath.ToSlash(rel)},
+		{dir, "/" + filepath.ToSlash(fromRoot)},
+		{dir, "link.png"},
+		{"", filepath.ToSlash(rel)},
+		{"", "../a.png"},
+		{"", "link.png"},
+	} {
+		rw := &Rewriter{Client: c, Root: tc.root}
+		if _, err := rw.HTML([]byte(`<img src="`+tc.ref+`">`), filepath.Join(dir, "img")); !errors.Is(err, ErrOutsideRoot) {
+			t.Fatalf("root %q, ref %s: got %v, want ErrOutsideRoot", tc.root, tc.ref, err)
+		}
+	}
+	if *uploads != 0 {
+		t.Fatalf("got %d uploads, want 0", *uploads)
+	}
+	// References up to the root are fine.
+	rw := &Rewriter{Client: c, Root: dir}
+	if _, err := rw.HTML([]byte(`<img src="../a.png">`), filepath.Join(dir, "img")); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,147 @@
+package ospry
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// MaxFilenameLength is the longest filename, in bytes, that
+	// SanitizeFilename returns.
+	MaxFilenameLength = 255
+
+	// OriginalFilenameTag is the tag that holds an upload's filename
+	// before it was sanitized.
+	OriginalFilenameTag = "originalFilename"
+)
+
+// extensions maps image formats to their canonical file extensions.
+var extensions = map[string]string{
+	"jpeg": ".jpg",
+	"png":  ".png",
+	"gif":  ".gif",
+	"webp": ".webp",
+}
+
+// SanitizeFilename returns a version of name that's safe to hand to
+// the api: path components and control characters are removed, the
+// name is truncated to MaxFilenameLength bytes and, if format is
+// non-empty, the extension is made to agree with it. Empty results
+// become "image" plus the extension.
+func SanitizeFilename(name, format string) string {
+	name = strings.ToValidUTF8(name, "")
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		if r == '\\' {
+			return '/'
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(path.Base("/" + name))
+	// Leading dots would make hidden files.
+	name = strings.TrimLeft(name, ".")
+	if name == "/" {
+		name = ""
+	}
+	ext := path.Ext(name)
+	if want, ok := extensions[format]; ok && !extMatches(ext, format) {
+		// A wrong image extension is replaced, anything else is
+		// kept as part of the name.
+		if isImageExt(ext) {
+			name = strings.TrimSuffix(name, ext)
+		}
+		ext = want
+	} else {
+		name = strings.TrimSuffix(name, ext)
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "image"
+	}
+	if len(ext) > MaxFilenameLength/2 {
+		ext = ""
+	}
+	if max := MaxFilenameLength - len(ext); len(name) > max {
+		name = name[:max]
+		for !utf8.ValidString(name) {
+			name = name[:len(name)-1]
+		}
+	}
+	return name + ext
+}
+
+func isImageExt(ext string) bool {
+	ext = strings.ToLower(ext)
+	for f := range extensions {
+		if extMatches(ext, f) {
+			return true
+		}
+	}
+	return false
+}
+
+func extMatches(ext, format string) bool {
+	ext = strings.ToLower(ext)
+	if format == "jpeg" {
+		return ext == ".jpg" || ext == ".jpeg"
+	}
+	return ext == "."+format
+}
+
+// sanitizeUpload sanitizes filename using the format sniffed from the
+// start of data, and records the original name in a copy of tags if
+// it changed. The returned reader replays the sniffed bytes; seekable
+// data is returned as is, rewound to where it started.
+func sanitizeUpload(filename string, data io.Reader, tags map[string]string) (string, io.Reader, map[string]string, error) {
+	start := int64(-1)
+	if s, ok := data.(io.Seeker); ok {
+		if off, err := s.Seek(0, io.SeekCurrent); err == nil {
+			start = off
+		}
+	}
+	head := make([]byte, 512)
+	n, err := io.ReadFull(data, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", nil, nil, err
+	}
+	head = head[:n]
+	if start >= 0 {
+		if _, err := data.(io.Seeker).Seek(start, io.SeekStart); err != nil {
+			return "", nil, nil, err
+		}
+	} else {
+		data = io.MultiReader(bytes.NewReader(head), data)
+	}
+	clean := SanitizeFilename(filename, sniffFormat(head))
+	if clean == filename {
+		return filename, data, tags, nil
+	}
+	t := map[string]string{OriginalFilenameTag: filename}
+	for k, v := range tags {
+		t[k] = v
+	}
+	return clean, data, t, nil
+}
+
+// sniffFormat returns the image format of the data starting with
+// head, or "" if it isn't recognized.
+func sniffFormat(head []byte) string {
+	switch http.DetectContentType(head) {
+	case "image/jpeg":
+		return "jpeg"
+	case "image/png":
+		return "png"
+	case "image/gif":
+		return "gif"
+	case "image/webp":
+		return "webp"
+	}
+	return ""
+}
@@ -0,0 +1,77 @@
+package ospry
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := []struct {
+		name, format, want string
+	}{
+		{"photo.jpg", "jpeg", "photo.jpg"},
+		{"photo.JPEG", "jpeg", "photo.JPEG"},
+		{"../../etc/passwd.png", "png", "passwd.png"},
+		{`C:\Users\me\cat.gif`, "gif", "cat.gif"},
+		{"bad\x00na\nme.png", "png", "badname.png"},
+		{"photo.jpg", "png", "photo.png"},
+		{"page.html", "png", "page.html.png"},
+		{"noext", "webp", "noext.webp"},
+		{"..", "jpeg", "image.jpg"},
+		{".hidden", "", "hidden"},
+		{"../", "", "image"},
+		{"写真.txt", "", "写真.txt"},
+		{strings.Repeat("a", 300) + ".png", "png", strings.Repeat("a", 251) + ".png"},
+		{strings.Repeat("é", 200) + ".png", "png", strings.Repeat("é", 125) + ".png"},
+	}
+	for _, test := range tests {
+		if got := SanitizeFilename(test.name, test.format); got != test.want {
+			t.Fatalf("SanitizeFilename(%q, %q): got %q, want %q", test.name, test.format, got, test.want)
+		}
+	}
+}
+
+func TestUploadSanitizeFilenames(t *testing.T) {
+	var query map[string][]string
+	var body string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body = string(b)
+		writeMetadata(w, &Metadata{ID: "foo", Filename: r.URL.Query().Get("filename")})
+	})
+	png := "\x89PNG\r\n\x1a\n" + strings.Repeat("x", 1000)
+
+	if _, err := c.Upload("../../etc/passwd.jpg", strings.NewReader(png), &UploadOpts{Tags: map[string]string{"a": "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := query["filename"]; len(got) != 1 || got[0] != "../../etc/passwd.jpg" {
+		t.Fatalf("got %q, want unsanitized filename", got)
+	}
+
+	c.SanitizeFilenames = true
+	if _, err := c.Upload("../../etc/passwd.jpg", strings.NewReader(png), &UploadOpts{Tags: map[string]string{"a": "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := query["filename"][0], "passwd.png"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if got, want := strings.Join(query["tag"], ","), "a=b,originalFilename=../../etc/passwd.jpg"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if body != png {
+		t.Fatalf("got %d body bytes, want %d", len(body), len(png))
+	}
+
+	if _, err := c.Upload("fine.png", strings.NewReader(png), nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := query["tag"]; len(got) != 0 {
+		t.Fatalf("got tags %q, want none", got)
+	}
+}
@@ -0,0 +1,131 @@
+// Package awssm reads an ospry key from AWS Secrets Manager.
+package awssm
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+
+	ospry "github.com/ospry/ospry-go"
+	"github.com/ospry/ospry-go/secrets"
+)
+
+// ErrNoCredentials is returned when no AWS credentials are configured.
+var ErrNoCredentials = errors.New("awssm: no credentials")
+
+// Credentials sign requests to AWS.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is set for temporary credentials.
+	SessionToken string
+}
+
+// EnvCredentials returns the credentials in the AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables.
+func EnvCredentials() (*Credentials, error) {
+	c := &Credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if c.AccessKeyID == "" || c.SecretAccessKey == "" {
+		return nil, ErrNoCredentials
+	}
+	return c, nil
+}
+
+// A Secret is a key stored in Secrets Manager.
+type Secret struct {
+	// Region is the secret's region. If empty, AWS_REGION is used.
+	Region string
+	// SecretID is the secret's name or arn.
+	SecretID string
+	// VersionStage selects the secret's version. If empty, the
+	// current version (AWSCURRENT) is read.
+	VersionStage string
+	// Field, if set, is the field holding the key in a secret stored
+	// as a json object. If empty, the whole secret is the key.
+	Field string
+	// Credentials returns the credentials signing the requests. It's
+	// called for every fetch, so it can hand out refreshed temporary
+	// credentials. If nil, EnvCredentials is used.
+	Credentials func() (*Credentials, error)
+	// Endpoint, if set, replaces the regional endpoint, e.g. for a
+	// vpc endpoint.
+	Endpoint string
+	// HTTPClient, if set, is used for the requests (see
+	// secrets.HTTPClient).
+	HTTPClient *http.Client
+}
+
+// KeyProvider returns a provider fetching the secret every interval
+// (ospry.DefaultRefreshInterval if zero).
+func KeyProvider(s *Secret, interval time.Duration) *ospry.RefreshingKey {
+	return &ospry.RefreshingKey{Fetch: s.Fetch, Interval: interval}
+}
+
+// Fetch reads the key from Secrets Manager.
+func (s *Secret) Fetch() (string, error) {
+	creds := s.Credentials
+	if creds == nil {
+		creds = EnvCredentials
+	}
+	cred, err := creds()
+	if err != nil {
+		return "", err
+	}
+	region := s.Region
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	endpoint := s.Endpoint
+	if endpoint == "" {
+		endpoint = "https://secretsmanager." + region + ".amazonaws.com/"
+	}
+	in := map[string]string{"SecretId": s.SecretID}
+	if s.VersionStage != "" {
+		in["VersionStage"] = s.VersionStage
+	}
+	payload, err := json.Marshal(in)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	sign(req, payload, cred, region, "secretsmanager", time.Now())
+	client := secrets.HTTPClient(s.HTTPClient)
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != 200 {
+		var e struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		json.Unmarshal(body, &e)
+		return "", fmt.Errorf("awssm: reading %s: %s %s %s", s.SecretID, resp.Status, e.Type, e.Message)
+	}
+	var out struct {
+		SecretString string
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return "", err
+	}
+	return secrets.Field([]byte(out.SecretString), s.Field)
+}
@@ -0,0 +1,64 @@
+package awssm
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSign checks the signer against the example in AWS's signature
+// version 4 documentation.
+func TestSign(t *testing.T) {
+	req, _ := http.NewRequest("GET", "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	cred := &Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	sign(req, nil, cred, "us-east-1", "iam", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, " +
+		"SignedHeaders=content-type;host;x-amz-date, " +
+		"Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestFetch(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in struct{ SecretId string }
+		json.NewDecoder(r.Body).Decode(&in)
+		auth := r.Header.Get("Authorization")
+		if r.Header.Get("X-Amz-Target") != "secretsmanager.GetSecretValue" ||
+			!strings.Contains(auth, "Credential=AKID/") || !strings.Contains(auth, "/eu-west-1/secretsmanager/") ||
+			r.Header.Get("X-Amz-Security-Token") != "session" {
+			w.WriteHeader(400)
+			w.Write([]byte(`{"__type":"InvalidSignatureException","message":"bad"}`))
+			return
+		}
+		if in.SecretId != "prod/ospry" {
+			w.WriteHeader(400)
+			w.Write([]byte(`{"__type":"ResourceNotFoundException","message":"not found"}`))
+			return
+		}
+		w.Write([]byte(`{"Name":"prod/ospry","SecretString":"{\"key\":\"sk-test-aws\"}"}`))
+	}))
+	defer ts.Close()
+
+	s := &Secret{
+		Region:   "eu-west-1",
+		SecretID: "prod/ospry",
+		Field:    "key",
+		Endpoint: ts.URL,
+		Credentials: func() (*Credentials, error) {
+			return &Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret", SessionToken: "session"}, nil
+		},
+	}
+	if key, err := KeyProvider(s, 0).Key(); err != nil || key != "sk-test-aws" {
+		t.Fatalf("got %s, %v, want sk-test-aws", key, err)
+	}
+	s.SecretID = "prod/missing"
+	if _, err := s.Fetch(); err == nil || !strings.Contains(err.Error(), "ResourceNotFoundException") {
+		t.Fatalf("got %v, want ResourceNotFoundException", err)
+	}
+}
@@ -0,0 +1,131 @@
+// Package gcpsm reads an ospry key from Google Cloud Secret Manager.
+package gcpsm
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	ospry "github.com/ospry/ospry-go"
+	"github.com/ospry/ospry-go/secrets"
+)
+
+// DefaultEndpoint is Secret Manager's api endpoint.
+const DefaultEndpoint = "https://secretmanager.googleapis.com"
+
+// MetadataTokenURL is where MetadataToken gets its tokens.
+var MetadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+// MetadataToken returns an access token of the default service account
+// from the metadata server, which is available on GCE, GKE, Cloud Run
+// and Cloud Functions.
+func MetadataToken() (string, error) {
+	req, err := http.NewRequest("GET", MetadataTokenURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	var t struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := getJSON(secrets.HTTPClient(nil), req, &t); err != nil {
+		return "", err
+	}
+	if t.AccessToken == "" {
+		return "", errors.New("gcpsm: no access token from metadata server")
+	}
+	return t.AccessToken, nil
+}
+
+// A Secret is a key stored in Secret Manager.
+type Secret struct {
+	Project string
+	Name    string
+	// Version is the secret's version. If empty, "latest" is read.
+	Version string
+	// Field, if set, is the field holding the key in a secret stored
+	// as a json object. If empty, the whole secret is the key.
+	Field string
+	// Token returns the oauth2 access token authenticating the
+	// requests. If nil, MetadataToken is used.
+	Token func() (string, error)
+	// Endpoint, if set, replaces DefaultEndpoint.
+	Endpoint string
+	// HTTPClient, if set, is used for the requests (see
+	// secrets.HTTPClient).
+	HTTPClient *http.Client
+}
+
+// KeyProvider returns a provider fetching the secret every interval
+// (ospry.DefaultRefreshInterval if zero).
+func KeyProvider(s *Secret, interval time.Duration) *ospry.RefreshingKey {
+	return &ospry.RefreshingKey{Fetch: s.Fetch, Interval: interval}
+}
+
+// Fetch reads the key from Secret Manager.
+func (s *Secret) Fetch() (string, error) {
+	token := s.Token
+	if token == nil {
+		token = MetadataToken
+	}
+	tok, err := token()
+	if err != nil {
+		return "", err
+	}
+	endpoint := s.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultEndpoint
+	}
+	version := s.Version
+	if version == "" {
+		version = "latest"
+	}
+	u := fmt.Sprintf("%s/v1/projects/%s/secrets/%s/versions/%s:access",
+		strings.TrimRight(endpoint, "/"), s.Project, s.Name, version)
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+tok)
+	client := secrets.HTTPClient(s.HTTPClient)
+	var r struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}
+	if err := getJSON(client, req, &r); err != nil {
+		return "", fmt.Errorf("gcpsm: reading %s: %v", s.Name, err)
+	}
+	data, err := base64.StdEncoding.DecodeString(r.Payload.Data)
+	if err != nil {
+		return "", err
+	}
+	return secrets.Field(data, s.Field)
+}
+
+func getJSON(client *http.Client, req *http.Request, v interface{}) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != 200 {
+		var e struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.Unmarshal(body, &e)
+		return errors.New(strings.TrimSpace(resp.Status + " " + e.Error.Message))
+	}
+	return json.Unmarshal(body, v)
+}
@@ -0,0 +1,35 @@
+package gcpsm
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFetch(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token" && r.Header.Get("Metadata-Flavor") == "Google":
+			w.Write([]byte(`{"access_token":"ya29.token","expires_in":3599,"token_type":"Bearer"}`))
+		case r.URL.Path == "/v1/projects/acme/secrets/ospry-key/versions/latest:access" &&
+			r.Header.Get("Authorization") == "Bearer ya29.token":
+			// base64("sk-test-gcp")
+			w.Write([]byte(`{"name":"projects/1/secrets/ospry-key/versions/2","payload":{"data":"c2stdGVzdC1nY3A="}}`))
+		default:
+			w.WriteHeader(404)
+			w.Write([]byte(`{"error":{"code":404,"message":"Secret not found"}}`))
+		}
+	}))
+	defer ts.Close()
+	defer func(u string) { MetadataTokenURL = u }(MetadataTokenURL)
+	MetadataTokenURL = ts.URL + "/token"
+
+	s := &Secret{Project: "acme", Name: "ospry-key", Endpoint: ts.URL}
+	if key, err := KeyProvider(s, 0).Key(); err != nil || key != "sk-test-gcp" {
+		t.Fatalf("got %s, %v, want sk-test-gcp", key, err)
+	}
+	s.Name = "missing"
+	if _, err := s.Fetch(); err == nil || err.Error() != "gcpsm: reading missing: 404 Not Found Secret not found" {
+		t.Fatalf("got %v, want Secret not found", err)
+	}
+}
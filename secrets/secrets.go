@@ -0,0 +1,70 @@
+// Package secrets holds ospry.KeyProviders reading the api key from
+// secret managers, so that it never has to live in env files on disk:
+// vault (HashiCorp Vault), awssm (AWS Secrets Manager) and gcpsm
+// (Google Cloud Secret Manager). They talk to the managers' http apis
+// directly, so none of them pulls in a cloud sdk.
+//
+// Each provider is an *ospry.RefreshingKey, which caches the key and
+// fetches it again every refresh interval, picking up rotations:
+//
+//	c := ospry.New("")
+//	c.KeyProvider = vault.KeyProvider(&vault.Secret{
+//		Addr:  "https://vault.internal:8200",
+//		Token: os.Getenv("VAULT_TOKEN"),
+//		Path:  "ospry",
+//		Field: "key",
+//	}, 10*time.Minute)
+package secrets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	ospry "github.com/ospry/ospry-go"
+)
+
+// ErrNoField is returned when a secret doesn't have the requested
+// field.
+var ErrNoField = errors.New("secrets: field not found in secret")
+
+// Field returns the string field of a secret holding a json object.
+// An empty field returns the secret itself.
+func Field(secret []byte, field string) (string, error) {
+	if field == "" {
+		return string(secret), nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(secret, &fields); err != nil {
+		return "", fmt.Errorf("secrets: secret isn't a json object: %v", err)
+	}
+	s, ok := fields[field].(string)
+	if !ok {
+		return "", ErrNoField
+	}
+	return s, nil
+}
+
+// Timeout is the timeout of the http client shared by the providers.
+const Timeout = 30 * time.Second
+
+var (
+	sharedOnce sync.Once
+	shared     *http.Client
+)
+
+// HTTPClient returns c, or if it's nil, the http client shared by the
+// providers, which is created on first use with an ospry.NewTransport
+// and Timeout. http.DefaultClient isn't used.
+func HTTPClient(c *http.Client) *http.Client {
+	if c != nil {
+		return c
+	}
+	sharedOnce.Do(func() {
+		shared = &http.Client{Transport: ospry.NewTransport(), Timeout: Timeout}
+	})
+	return shared
+}
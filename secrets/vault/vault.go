@@ -0,0 +1,84 @@
+// Package vault reads an ospry key from a HashiCorp Vault kv (version
+// 2) secrets engine.
+package vault
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	ospry "github.com/ospry/ospry-go"
+	"github.com/ospry/ospry-go/secrets"
+)
+
+// A Secret is a key stored in vault.
+type Secret struct {
+	// Addr is vault's address, e.g. "https://vault.internal:8200".
+	Addr string
+	// Token authenticates the requests.
+	Token string
+	// Mount is the path the kv engine is mounted at. If empty,
+	// "secret" is used.
+	Mount string
+	// Path is the secret's path within the engine.
+	Path string
+	// Field is the secret's field holding the key. If empty, "key"
+	// is used.
+	Field string
+	// HTTPClient, if set, is used for the requests (see
+	// secrets.HTTPClient).
+	HTTPClient *http.Client
+}
+
+// KeyProvider returns a provider fetching the secret's latest version
+// every interval (ospry.DefaultRefreshInterval if zero).
+func KeyProvider(s *Secret, interval time.Duration) *ospry.RefreshingKey {
+	return &ospry.RefreshingKey{Fetch: s.Fetch, Interval: interval}
+}
+
+// Fetch reads the key from vault.
+func (s *Secret) Fetch() (string, error) {
+	mount := s.Mount
+	if mount == "" {
+		mount = "secret"
+	}
+	u := strings.TrimRight(s.Addr, "/") + "/v1/" + strings.Trim(mount, "/") + "/data/" + strings.TrimLeft(s.Path, "/")
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", s.Token)
+	client := secrets.HTTPClient(s.HTTPClient)
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != 200 {
+		var e struct {
+			Errors []string `json:"errors"`
+		}
+		json.Unmarshal(body, &e)
+		return "", fmt.Errorf("vault: reading %s: %s", s.Path, strings.TrimSpace(resp.Status+" "+strings.Join(e.Errors, "; ")))
+	}
+	var r struct {
+		Data struct {
+			Data json.RawMessage `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &r); err != nil {
+		return "", err
+	}
+	field := s.Field
+	if field == "" {
+		field = "key"
+	}
+	return secrets.Field(r.Data.Data, field)
+}
@@ -0,0 +1,29 @@
+package vault
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFetch(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/kv/data/apps/ospry" || r.Header.Get("X-Vault-Token") != "s.token" {
+			w.WriteHeader(403)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		w.Write([]byte(`{"data":{"data":{"key":"sk-test-vault"},"metadata":{"version":3}}}`))
+	}))
+	defer ts.Close()
+
+	s := &Secret{Addr: ts.URL + "/", Token: "s.token", Mount: "kv", Path: "apps/ospry"}
+	key, err := KeyProvider(s, 0).Key()
+	if err != nil || key != "sk-test-vault" {
+		t.Fatalf("got %s, %v, want sk-test-vault", key, err)
+	}
+	s.Token = "s.wrong"
+	if _, err := s.Fetch(); err == nil || err.Error() != "vault: reading apps/ospry: 403 Forbidden permission denied" {
+		t.Fatalf("got %v, want permission denied", err)
+	}
+}
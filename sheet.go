@@ -0,0 +1,165 @@
+package ospry
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+	"time"
+)
+
+// Defaults for a SheetOpts' zero fields.
+const (
+	DefaultSheetColumns = 4
+	DefaultSheetCell    = 160
+	DefaultSheetGap     = 4
+)
+
+// SheetOpts lay out a contact sheet.
+type SheetOpts struct {
+	// Columns is the number of images per row.
+	Columns int
+	// CellWidth and CellHeight are the size each image is rendered
+	// to fit in, not counting its label.
+	CellWidth  int
+	CellHeight int
+	// Gap is the space between cells and around the sheet. A negative
+	// Gap means none.
+	Gap int
+	// Background fills the sheet. Nil means white.
+	Background color.Color
+	// Labels prints each image's filename (or id) under it.
+	Labels bool
+}
+
+// A Sheet is a contact sheet: images rendered small and laid out in a
+// grid.
+type Sheet struct {
+	Image *image.RGBA
+	// Tiles are where each image was drawn, in the same order as the
+	// images. Images that failed to download have empty tiles.
+	Tiles []SheetTile
+}
+
+// A SheetTile is the part of a sheet showing an image.
+type SheetTile struct {
+	ID string
+	image.Rectangle
+}
+
+// ContactSheet calls ContactSheet on the default client.
+func ContactSheet(images []*Metadata, opts *SheetOpts) (*Sheet, error) {
+	return DefaultClient.ContactSheet(images, opts)
+}
+
+// ContactSheet downloads small renders of the images, up to
+// BatchConcurrency at once, and lays them out in a grid, e.g. for
+// admin review pages and email digests. Private images are signed for
+// a minute. If some downloads failed, their cells are left empty and
+// the error is a *BatchError; the sheet is returned either way.
+func (c *Client) ContactSheet(images []*Metadata, opts *SheetOpts) (*Sheet, error) {
+	o := SheetOpts{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.Columns <= 0 {
+		o.Columns = DefaultSheetColumns
+	}
+	if o.CellWidth <= 0 {
+		o.CellWidth = DefaultSheetCell
+	}
+	if o.CellHeight <= 0 {
+		o.CellHeight = DefaultSheetCell
+	}
+	if o.Gap == 0 {
+		o.Gap = DefaultSheetGap
+	} else if o.Gap < 0 {
+		o.Gap = 0
+	}
+	if o.Background == nil {
+		o.Background = color.White
+	}
+	labelHeight := 0
+	if o.Labels {
+		labelHeight = glyphHeight + 4
+	}
+
+	results := c.sheetRenders(images, o.CellWidth, o.CellHeight)
+	rows := (len(images) + o.Columns - 1) / o.Columns
+	cols := o.Columns
+	if len(images) < cols {
+		cols = len(images)
+	}
+	w := cols*(o.CellWidth+o.Gap) + o.Gap
+	h := rows*(o.CellHeight+labelHeight+o.Gap) + o.Gap
+	sheet := &Sheet{Image: image.NewRGBA(image.Rect(0, 0, w, h)), Tiles: make([]SheetTile, len(images))}
+	draw.Draw(sheet.Image, sheet.Image.Bounds(), image.NewUniform(o.Background), image.Point{}, draw.Src)
+	for i, m := range images {
+		cell := image.Rect(0, 0, o.CellWidth, o.CellHeight).Add(image.Pt(
+			o.Gap+(i%o.Columns)*(o.CellWidth+o.Gap),
+			o.Gap+(i/o.Columns)*(o.CellHeight+labelHeight+o.Gap),
+		))
+		sheet.Tiles[i].ID = m.ID
+		if img := results[i].Value; img != nil {
+			// Renders larger than the cell are cut to it, centered.
+			b := img.Bounds()
+			at := cell.Min.Add(image.Pt((cell.Dx()-b.Dx())/2, (cell.Dy()-b.Dy())/2))
+			dst := image.Rect(0, 0, b.Dx(), b.Dy()).Add(at)
+			r := dst.Intersect(cell)
+			draw.Draw(sheet.Image, r, img, b.Min.Add(r.Min.Sub(dst.Min)), draw.Over)
+			sheet.Tiles[i].Rectangle = r
+		}
+		if o.Labels {
+			label := m.Filename
+			if label == "" {
+				label = m.ID
+			}
+			drawLabel(sheet.Image, label, image.Pt(cell.Min.X, cell.Max.Y+2), cell.Dx())
+		}
+	}
+	return sheet, batchErr(results)
+}
+
+// sheetRenders downloads and decodes the renders of a sheet's images.
+func (c *Client) sheetRenders(images []*Metadata, w, h int) []BatchResult[image.Image] {
+	return fanOut(c.batchConcurrency(), len(images),
+		func(i int) string { return images[i].ID },
+		func(i int) (image.Image, error) {
+			m := images[i]
+			opts := &RenderOpts{MaxWidth: w, MaxHeight: h, Format: "png"}
+			if m.IsPrivate {
+				opts.TimeExpired = c.now().Add(time.Minute)
+			}
+			rc, err := c.DownloadMeta(m, opts)
+			if err != nil {
+				return nil, err
+			}
+			defer rc.Close()
+			img, _, err := c.decodeDownload(rc)
+			return img, err
+		})
+}
+
+// WritePNG encodes the sheet's image as png.
+func (s *Sheet) WritePNG(w io.Writer) error {
+	return png.Encode(w, s.Image)
+}
+
+// CSS returns a stylesheet using the sheet as a CSS sprite served from
+// url: a rule per image, selecting class prefix + image id.
+func (s *Sheet) CSS(url, prefix string) string {
+	var b strings.Builder
+	for _, t := range s.Tiles {
+		if t.Empty() {
+			continue
+		}
+		fmt.Fprintf(&b, ".%s%s{background:url(%q) -%dpx -%dpx;width:%dpx;height:%dpx}\n",
+			prefix, t.ID, url, t.Min.X, t.Min.Y, t.Dx(), t.Dy())
+	}
+	return b.String()
+}
@@ -0,0 +1,83 @@
+package ospry
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestContactSheet(t *testing.T) {
+	renders := map[string]struct {
+		w, h int
+		c    color.Color
+	}{
+		"/a.png": {10, 10, color.RGBA{255, 0, 0, 255}},
+		"/b.png": {30, 10, color.RGBA{0, 0, 255, 255}},
+	}
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("maxWidth") != "20" {
+			t.Errorf("got %s, want renders fitting the cells", r.URL)
+		}
+		render, ok := renders[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		img := image.NewRGBA(image.Rect(0, 0, render.w, render.h))
+		draw.Draw(img, img.Bounds(), image.NewUniform(render.c), image.Point{}, draw.Src)
+		png.Encode(w, img)
+	})
+	base := strings.TrimSuffix(c.ServerURL, "/v1") + "/"
+	images := []*Metadata{
+		{ID: "a", Filename: "a.png", URL: base + "a.png"},
+		{ID: "b", URL: base + "b.png"},
+		{ID: "c", URL: base + "c.png"},
+	}
+	sheet, err := c.ContactSheet(images, &SheetOpts{Columns: 2, CellWidth: 20, CellHeight: 20, Gap: 2, Labels: true})
+	var berr *BatchError
+	if !errors.As(err, &berr) {
+		t.Fatalf("got %v, want a *BatchError for c", err)
+	}
+	if b := sheet.Image.Bounds(); b.Dx() != 46 || b.Dy() != 74 {
+		t.Fatalf("got %v, want 46x74", b)
+	}
+	want := []image.Rectangle{image.Rect(7, 7, 17, 17), image.Rect(24, 7, 44, 17), {}}
+	for i, tile := range sheet.Tiles {
+		if tile.ID != images[i].ID || tile.Rectangle != want[i] {
+			t.Fatalf("got tile %d %v, want %s at %v", i, tile, images[i].ID, want[i])
+		}
+	}
+	if got := sheet.Image.RGBAAt(12, 12); got != (color.RGBA{255, 0, 0, 255}) {
+		t.Fatalf("got %v, want red", got)
+	}
+	if got := sheet.Image.RGBAAt(30, 12); got != (color.RGBA{0, 0, 255, 255}) {
+		t.Fatalf("got %v, want blue", got)
+	}
+	black := 0
+	for y := 24; y < 36; y++ {
+		for x := 2; x < 22; x++ {
+			if sheet.Image.RGBAAt(x, y) == (color.RGBA{0, 0, 0, 255}) {
+				black++
+			}
+		}
+	}
+	if black == 0 {
+		t.Fatal("got no label under a, want one")
+	}
+
+	css := sheet.CSS("sprite.png", "sheet-")
+	if want := `.sheet-a{background:url("sprite.png") -7px -7px;width:10px;height:10px}`; !strings.Contains(css, want) || strings.Contains(css, "sheet-c") {
+		t.Fatalf("got %s, want %s and no rule for c", css, want)
+	}
+
+	c.MaxImagePixels = 200
+	_, err = c.ContactSheet(images[1:2], &SheetOpts{Columns: 1, CellWidth: 20, CellHeight: 20})
+	if !errors.As(err, new(*ImageTooLargeError)) {
+		t.Fatalf("got %v, want renders decoded within the client's limits", err)
+	}
+}
@@ -0,0 +1,64 @@
+package ospry
+
+import (
+	"image"
+	"image/color"
+	"strings"
+)
+
+// Sheet labels are drawn with a tiny built-in font, so that contact
+// sheets don't need font files: 3x5 pixel glyphs drawn at twice their
+// size. Letters are drawn in upper case; characters without a glyph
+// are drawn as "?".
+const (
+	glyphScale   = 2
+	glyphWidth   = 3 * glyphScale
+	glyphHeight  = 5 * glyphScale
+	glyphAdvance = glyphWidth + glyphScale
+)
+
+// glyphs holds the rows of each glyph, top first, with the leftmost
+// pixel in bit 2.
+var glyphs = map[rune][5]uint8{
+	'0': {7, 5, 5, 5, 7}, '1': {2, 6, 2, 2, 7}, '2': {7, 1, 7, 4, 7},
+	'3': {7, 1, 7, 1, 7}, '4': {5, 5, 7, 1, 1}, '5': {7, 4, 7, 1, 7},
+	'6': {7, 4, 7, 5, 7}, '7': {7, 1, 1, 1, 1}, '8': {7, 5, 7, 5, 7},
+	'9': {7, 5, 7, 1, 7}, 'A': {2, 5, 7, 5, 5}, 'B': {6, 5, 6, 5, 6},
+	'C': {3, 4, 4, 4, 3}, 'D': {6, 5, 5, 5, 6}, 'E': {7, 4, 6, 4, 7},
+	'F': {7, 4, 6, 4, 4}, 'G': {3, 4, 5, 5, 3}, 'H': {5, 5, 7, 5, 5},
+	'I': {7, 2, 2, 2, 7}, 'J': {1, 1, 1, 5, 2}, 'K': {5, 5, 6, 5, 5},
+	'L': {4, 4, 4, 4, 7}, 'M': {5, 7, 7, 5, 5}, 'N': {6, 5, 5, 5, 5},
+	'O': {2, 5, 5, 5, 2}, 'P': {6, 5, 6, 4, 4}, 'Q': {2, 5, 5, 6, 3},
+	'R': {6, 5, 6, 5, 5}, 'S': {3, 4, 2, 1, 6}, 'T': {7, 2, 2, 2, 2},
+	'U': {5, 5, 5, 5, 7}, 'V': {5, 5, 5, 5, 2}, 'W': {5, 5, 7, 7, 5},
+	'X': {5, 5, 2, 5, 5}, 'Y': {5, 5, 2, 2, 2}, 'Z': {7, 1, 2, 4, 7},
+	'.': {0, 0, 0, 0, 2}, '-': {0, 0, 7, 0, 0}, '_': {0, 0, 0, 0, 7},
+	' ': {0, 0, 0, 0, 0}, '?': {7, 1, 2, 0, 2},
+}
+
+// drawLabel draws s in black at pt, cut off at width pixels.
+func drawLabel(img *image.RGBA, s string, pt image.Point, width int) {
+	x := pt.X
+	for _, r := range strings.ToUpper(s) {
+		if x+glyphWidth > pt.X+width {
+			return
+		}
+		g, ok := glyphs[r]
+		if !ok {
+			g = glyphs['?']
+		}
+		for row := 0; row < 5; row++ {
+			for col := 0; col < 3; col++ {
+				if g[row]&(4>>col) == 0 {
+					continue
+				}
+				for dy := 0; dy < glyphScale; dy++ {
+					for dx := 0; dx < glyphScale; dx++ {
+						img.Set(x+col*glyphScale+dx, pt.Y+row*glyphScale+dy, color.Black)
+					}
+				}
+			}
+		}
+		x += glyphAdvance
+	}
+}
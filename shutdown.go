@@ -0,0 +1,41 @@
+package ospry
+
+import (
+	"context"
+	"sync"
+)
+
+// A Shutdowner is a background component that can be stopped without
+// silently dropping its work.
+type Shutdowner interface {
+	// Shutdown stops accepting work and waits for the work in flight
+	// to finish. If ctx is done first, it stops waiting and returns
+	// ctx's error. Either way, it returns the items left unfinished.
+	Shutdown(ctx context.Context) (incomplete []string, err error)
+}
+
+// ShutdownAll shuts the components down concurrently, returning the
+// items they left unfinished and the first error.
+func ShutdownAll(ctx context.Context, components ...Shutdowner) ([]string, error) {
+	var (
+		mu         sync.Mutex
+		wg         sync.WaitGroup
+		incomplete []string
+		firstErr   error
+	)
+	for _, s := range components {
+		wg.Add(1)
+		go func(s Shutdowner) {
+			defer wg.Done()
+			items, err := s.Shutdown(ctx)
+			mu.Lock()
+			defer mu.Unlock()
+			incomplete = append(incomplete, items...)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}(s)
+	}
+	wg.Wait()
+	return incomplete, firstErr
+}
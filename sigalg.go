@@ -0,0 +1,94 @@
+package ospry
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"hash"
+	"net/url"
+	"sync"
+)
+
+// Signature algorithms for signed urls (see Client.SignatureAlg).
+const (
+	HMACSHA256 = "hmac-sha256"
+	HMACSHA512 = "hmac-sha512"
+)
+
+// DefaultSignatureAlg is used when a client's SignatureAlg is empty.
+// Urls signed with it carry no sigAlg parameter, so they look the
+// same as urls signed before algorithms were versioned.
+const DefaultSignatureAlg = HMACSHA256
+
+var (
+	// ErrBadSignature is returned by VerifySignature when a url's
+	// signature doesn't match.
+	ErrBadSignature = errors.New("ospry: signature mismatch")
+	// ErrUnknownSignatureAlg is returned when a url is signed, or is
+	// to be signed, with an algorithm that isn't registered.
+	ErrUnknownSignatureAlg = errors.New("ospry: unknown signature algorithm")
+)
+
+var sigAlgs = struct {
+	sync.RWMutex
+	m map[string]func() hash.Hash
+}{m: map[string]func() hash.Hash{
+	HMACSHA256: sha256.New,
+	HMACSHA512: sha512.New,
+}}
+
+// RegisterSignatureAlg makes an HMAC algorithm using the hash h
+// available under name, both for signing and for VerifySignature.
+func RegisterSignatureAlg(name string, h func() hash.Hash) {
+	sigAlgs.Lock()
+	defer sigAlgs.Unlock()
+	sigAlgs.m[name] = h
+}
+
+// sign returns the signature of imgURL, valid until timeExpired, made
+// with key using alg. The signature covers the algorithm too (except
+// for the default one), so it can't be swapped for a weaker one.
+func sign(key, alg, imgURL, timeExpired string) (string, error) {
+	if alg == "" {
+		alg = DefaultSignatureAlg
+	}
+	sigAlgs.RLock()
+	h := sigAlgs.m[alg]
+	sigAlgs.RUnlock()
+	if h == nil {
+		return "", ErrUnknownSignatureAlg
+	}
+	payload := imgURL + "?"
+	if alg != DefaultSignatureAlg {
+		payload += "sigAlg=" + url.QueryEscape(alg) + "&"
+	}
+	payload += "timeExpired=" + url.QueryEscape(timeExpired)
+	mac := hmac.New(h, []byte(key))
+	mac.Write([]byte(payload))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// VerifySignature checks that urlstr was signed with key, using any
+// registered algorithm. It doesn't check whether the url has expired
+// (see ExpiresAt).
+func VerifySignature(urlstr, key string) error {
+	u, err := url.Parse(urlstr)
+	if err != nil {
+		return err
+	}
+	q := u.Query()
+	imgURL, timeExpired, sig := q.Get("url"), q.Get("timeExpired"), q.Get("signature")
+	if imgURL == "" || timeExpired == "" || sig == "" {
+		return ErrNotSigned
+	}
+	want, err := sign(key, q.Get("sigAlg"), imgURL, timeExpired)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(sig), []byte(want)) {
+		return ErrBadSignature
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package ospry
+
+import (
+	"crypto/sha1"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestSignatureAlgs(t *testing.T) {
+	RegisterSignatureAlg("hmac-sha1-test", sha1.New)
+	imgURL := "http://foo.ospry.io/bar/baz.png"
+	exp := time.Now().Add(time.Minute)
+	for _, alg := range []string{"", HMACSHA256, HMACSHA512, "hmac-sha1-test"} {
+		c := New("sk-test-key")
+		c.SignatureAlg = alg
+		signed, err := c.FormatURL(imgURL, &RenderOpts{TimeExpired: exp})
+		if err != nil {
+			t.Fatal(err)
+		}
+		u, _ := url.Parse(signed)
+		wantAlg := alg
+		if alg == DefaultSignatureAlg {
+			wantAlg = ""
+		}
+		if got := u.Query().Get("sigAlg"); got != wantAlg {
+			t.Fatalf("got sigAlg %q, want %q", got, wantAlg)
+		}
+		if err := VerifySignature(signed, "sk-test-key"); err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+		if err := VerifySignature(signed, "sk-test-other"); err != ErrBadSignature {
+			t.Fatalf("%s: got %v, want %v", alg, err, ErrBadSignature)
+		}
+	}
+}
+
+func TestSignatureAlgDowngrade(t *testing.T) {
+	c := New("sk-test-key")
+	c.SignatureAlg = HMACSHA512
+	signed, err := c.FormatURL("http://foo.ospry.io/bar.png", &RenderOpts{TimeExpired: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(signed)
+	q := u.Query()
+	q.Set("sigAlg", "bogus")
+	u.RawQuery = q.Encode()
+	if err := VerifySignature(u.String(), "sk-test-key"); err != ErrUnknownSignatureAlg {
+		t.Fatalf("got %v, want %v", err, ErrUnknownSignatureAlg)
+	}
+	q.Del("sigAlg")
+	u.RawQuery = q.Encode()
+	if err := VerifySignature(u.String(), "sk-test-key"); err != ErrBadSignature {
+		t.Fatalf("got %v, want %v", err, ErrBadSignature)
+	}
+
+	// Re-signing with another algorithm replaces the url's sigAlg.
+	c.SignatureAlg = ""
+	resigned, err := c.RefreshSignature(signed, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ = url.Parse(resigned)
+	if got := u.Query().Get("sigAlg"); got != "" {
+		t.Fatalf("got sigAlg %q, want none", got)
+	}
+	if err := VerifySignature(resigned, "sk-test-key"); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifySignature("http://foo.ospry.io/bar.png", "sk-test-key"); err != ErrNotSigned {
+		t.Fatalf("got %v, want %v", err, ErrNotSigned)
+	}
+}
@@ -0,0 +1,140 @@
+package ospry
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// ErrNotSigned is returned when a signed url is expected but the given
+// url isn't signed.
+var ErrNotSigned = errors.New("ospry: url isn't signed")
+
+// ParseRenderURL splits a url produced by FormatURL into the image url
+// and the render options it encodes. For signed urls, the options
+// include TimeExpired and the RenderHost that serves them.
+func ParseRenderURL(urlstr string) (string, *RenderOpts, error) {
+	u, err := url.Parse(urlstr)
+	if err != nil {
+		return "", nil, err
+	}
+	q := u.Query()
+	opts := &RenderOpts{}
+	if err := opts.fill(q); err != nil {
+		return "", nil, err
+	}
+	if q.Get("timeExpired") != "" {
+		opts.TimeExpired, err = time.Parse(time.RFC3339Nano, q.Get("timeExpired"))
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	if q.Get("url") != "" {
+		if u.Host != defaultRenderHost {
+			opts.RenderHost = u.Host
+		}
+		return q.Get("url"), opts, nil
+	}
+	u.RawQuery = ""
+	return u.String(), opts, nil
+}
+
+// ExpiresAt returns the time a signed url expires. The boolean result
+// is false if the url isn't signed. An error is returned if the url or
+// its expiration time is malformed.
+func ExpiresAt(urlstr string) (time.Time, bool, error) {
+	u, err := url.Parse(urlstr)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	v := u.Query().Get("timeExpired")
+	if v == "" {
+		return time.Time{}, false, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, v)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return t, true, nil
+}
+
+// SignedURL calls SignedURL on the default client.
+func SignedURL(urlOrMeta interface{}, ttl time.Duration, opts *RenderOpts) (string, error) {
+	return DefaultClient.SignedURL(urlOrMeta, ttl, opts)
+}
+
+// SignedURL formats a url (see FormatURL) signed to be valid for ttl
+// from now, as told by the client's Now. urlOrMeta is either an image
+// url or the *Metadata of an image (see FormatMetaURL). opts may be
+// nil; its TimeExpired is ignored.
+func (c *Client) SignedURL(urlOrMeta interface{}, ttl time.Duration, opts *RenderOpts) (string, error) {
+	if ttl <= 0 {
+		return "", errors.New("ospry: signed urls need a positive ttl")
+	}
+	o := RenderOpts{}
+	if opts != nil {
+		o = *opts
+	}
+	o.TimeExpired = c.now().Add(ttl)
+	switch v := urlOrMeta.(type) {
+	case string:
+		return c.FormatURL(v, &o)
+	case *Metadata:
+		return c.FormatMetaURL(v, &o)
+	}
+	return "", fmt.Errorf("ospry: can't sign a url for a %T", urlOrMeta)
+}
+
+// now returns the current time according to the client.
+func (c *Client) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
+
+// RefreshSignature calls RefreshSignature on the default client.
+func RefreshSignature(urlstr string, ttl time.Duration) (string, error) {
+	return DefaultClient.RefreshSignature(urlstr, ttl)
+}
+
+// RefreshSignatures calls RefreshSignatures on the default client.
+func RefreshSignatures(urls []string, ttl time.Duration) ([]string, error) {
+	return DefaultClient.RefreshSignatures(urls, ttl)
+}
+
+// RefreshSignature re-signs a previously signed url so that it's valid
+// for ttl from now, keeping its render options. ErrNotSigned is
+// returned for unsigned urls.
+func (c *Client) RefreshSignature(urlstr string, ttl time.Duration) (string, error) {
+	_, opts, err := ParseRenderURL(urlstr)
+	if err != nil {
+		return "", err
+	}
+	if opts.TimeExpired.IsZero() {
+		return "", ErrNotSigned
+	}
+	// FormatURL keeps the url's other parameters, and replaces the
+	// expiration time and signature.
+	return c.FormatURL(urlstr, &RenderOpts{
+		TimeExpired: c.now().Add(ttl),
+		RenderHost:  opts.RenderHost,
+	})
+}
+
+// RefreshSignatures re-signs each of the given urls (see
+// RefreshSignature), e.g. to refresh cached pages before their urls
+// expire. The refreshed urls are returned in the same order. If any
+// url can't be refreshed, an error is returned.
+func (c *Client) RefreshSignatures(urls []string, ttl time.Duration) ([]string, error) {
+	refreshed := make([]string, len(urls))
+	for i, urlstr := range urls {
+		var err error
+		refreshed[i], err = c.RefreshSignature(urlstr, ttl)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return refreshed, nil
+}
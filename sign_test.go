@@ -0,0 +1,109 @@
+package ospry
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestParseRenderURL(t *testing.T) {
+	c := New("sk-test-key")
+	imgURL := "http://foo.ospry.io/bar/baz.png"
+	exp := time.Now().Add(time.Minute).Round(0)
+	in := &RenderOpts{Format: "png", MaxWidth: 10, Quality: 80, TimeExpired: exp}
+	signed, err := c.FormatURL(imgURL, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotURL, opts, err := ParseRenderURL(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotURL != imgURL {
+		t.Fatalf("got %s, want %s", gotURL, imgURL)
+	}
+	if opts.Format != "png" || opts.MaxWidth != 10 || opts.Quality != 80 || !opts.TimeExpired.Equal(exp) {
+		t.Fatalf("got %+v, want %+v", opts, in)
+	}
+	gotURL, opts, err = ParseRenderURL(imgURL + "?maxHeight=5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotURL != imgURL || opts.MaxHeight != 5 || !opts.TimeExpired.IsZero() {
+		t.Fatalf("got %s %+v, want %s with MaxHeight 5", gotURL, opts, imgURL)
+	}
+}
+
+func TestRefreshSignatures(t *testing.T) {
+	c := New("sk-test-key")
+	imgURL := "http://foo.ospry.io/bar/baz.png"
+	old, err := c.FormatURL(imgURL+"?sub=foo", &RenderOpts{MaxWidth: 10, TimeExpired: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := time.Now()
+	urls, err := c.RefreshSignatures([]string{old}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(urls[0])
+	exp, err := time.Parse(time.RFC3339Nano, u.Query().Get("timeExpired"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp.Before(before.Add(time.Hour)) {
+		t.Fatalf("got %v, want after %v", exp, before.Add(time.Hour))
+	}
+	want, _ := c.FormatURL(imgURL+"?sub=foo", &RenderOpts{MaxWidth: 10, TimeExpired: exp})
+	if urls[0] != want {
+		t.Fatalf("got %s, want %s", urls[0], want)
+	}
+	if _, err := c.RefreshSignature(imgURL, time.Hour); err != ErrNotSigned {
+		t.Fatalf("got %v, want %v", err, ErrNotSigned)
+	}
+}
+
+func TestExpiresAt(t *testing.T) {
+	c := New("sk-test-key")
+	imgURL := "http://foo.ospry.io/bar/baz.png"
+	exp := time.Now().Add(time.Minute).Round(0)
+	signed, err := c.FormatURL(imgURL, &RenderOpts{TimeExpired: exp})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok, err := ExpiresAt(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || !got.Equal(exp) {
+		t.Fatalf("got %v, %t, want %v, true", got, ok, exp)
+	}
+	if _, ok, err := ExpiresAt(imgURL); ok || err != nil {
+		t.Fatalf("got %t, %v, want false, nil", ok, err)
+	}
+	if _, _, err := ExpiresAt(imgURL + "?timeExpired=tomorrow"); err == nil {
+		t.Fatal("got nil, want error")
+	}
+}
+
+func TestSignedURL(t *testing.T) {
+	now := time.Date(2015, 6, 1, 12, 0, 0, 0, time.UTC)
+	c := New("sk-test-key")
+	c.Now = func() time.Time { return now }
+	for _, v := range []interface{}{"http://foo.ospry.io/bar.jpg", &Metadata{URL: "http://foo.ospry.io/bar.jpg", IsPrivate: true}} {
+		signed, err := c.SignedURL(v, time.Hour, &RenderOpts{MaxWidth: 100})
+		if err != nil {
+			t.Fatal(err)
+		}
+		exp, ok, err := ExpiresAt(signed)
+		if err != nil || !ok || !exp.Equal(now.Add(time.Hour)) {
+			t.Fatalf("got expiry %v, %v, %v, want %v", exp, ok, err, now.Add(time.Hour))
+		}
+		if err := VerifySignature(signed, "sk-test-key"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := c.SignedURL(42, time.Hour, nil); err == nil {
+		t.Fatal("got nil, want error for an int")
+	}
+}
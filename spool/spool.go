@@ -0,0 +1,378 @@
+// Package spool queues uploads on disk while ospry can't be reached,
+// e.g. on kiosks and field devices, and uploads them once it can:
+//
+//	q := &spool.Queue{Dir: "/var/spool/photos", Client: c,
+//		Uploaded: func(e *spool.Entry, m *ospry.Metadata) {
+//			db.SavePhoto(e.Filename, m)
+//		},
+//	}
+//	q.AddFile("/media/card/IMG_0001.jpg", "", nil)
+//	go q.Run(ctx, time.Minute)
+//
+// Entries are written to disk before Add returns, so they survive
+// crashes and restarts. They're uploaded at least once: a crash
+// between an upload and the removal of its entry uploads it again.
+// Entries whose uploads keep failing while ospry is reachable are set
+// aside (see Failed).
+package spool
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	ospry "github.com/ospry/ospry-go"
+	"github.com/ospry/ospry-go/internal/worker"
+)
+
+// Defaults for a Queue's zero fields.
+const (
+	DefaultMaxAttempts = 10
+	DefaultBackoff     = 5 * time.Second
+	maxBackoff         = time.Hour
+)
+
+// failedDir is the subdirectory of a queue's directory that holds the
+// entries given up on.
+const failedDir = "failed"
+
+// ErrShutdown is returned by Add, AddFile, Drain and Run once Shutdown
+// has been called.
+var ErrShutdown = errors.New("spool: queue shut down")
+
+// A Queue is a directory of uploads waiting to be sent. Several
+// goroutines may use a queue, but only one process may use its
+// directory at a time.
+type Queue struct {
+	Dir string
+	// Client uploads the images. If nil, the default client is used.
+	Client *ospry.Client
+	// MaxAttempts is the number of times an upload is tried before
+	// it's given up on (see Failed). Failures while ospry can't be
+	// reached don't count. If zero, DefaultMaxAttempts is used.
+	MaxAttempts int
+	// Backoff is the delay before a failed upload is retried, doubled
+	// after each failure up to an hour. Uploads that failed because
+	// ospry couldn't be reached are retried after Backoff. If zero,
+	// DefaultBackoff is used.
+	Backoff time.Duration
+	// Uploaded, if set, is called with each entry once it has been
+	// uploaded, before it's removed from the queue.
+	Uploaded func(e *Entry, m *ospry.Metadata)
+
+	loop worker.Loop
+}
+
+// An Entry is an upload waiting in a queue.
+type Entry struct {
+	ID string `json:"id"`
+	// Path is the file holding the upload's data. For entries added
+	// with Add, it's a copy kept in the queue's directory.
+	Path     string            `json:"path"`
+	Filename string            `json:"filename"`
+	Opts     *ospry.UploadOpts `json:"opts,omitempty"`
+	Added    time.Time         `json:"added"`
+	// Copied is set if Path is a copy owned by the queue.
+	Copied      bool      `json:"copied,omitempty"`
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `json:"nextAttempt"`
+	LastError   string    `json:"lastError,omitempty"`
+}
+
+// Add copies data into the queue's directory and queues it for
+// uploading.
+func (q *Queue) Add(filename string, data io.Reader, opts *ospry.UploadOpts) (*Entry, error) {
+	if err := q.open(); err != nil {
+		return nil, err
+	}
+	e := q.newEntry(filename, opts)
+	e.Path = filepath.Join(q.Dir, e.ID+".data")
+	e.Copied = true
+	if err := worker.WriteFile(e.Path, func(f *os.File) error {
+		_, err := io.Copy(f, data)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	if err := q.save(e); err != nil {
+		os.Remove(e.Path)
+		return nil, err
+	}
+	return e, nil
+}
+
+// AddFile queues the file at path for uploading. The file must stay in
+// place until it has been uploaded. If filename is empty, the file's
+// base name is used.
+func (q *Queue) AddFile(path, filename string, opts *ospry.UploadOpts) (*Entry, error) {
+	if err := q.open(); err != nil {
+		return nil, err
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	if filename == "" {
+		filename = filepath.Base(path)
+	}
+	e := q.newEntry(filename, opts)
+	e.Path = path
+	if err := q.save(e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Entries returns the queued entries, oldest first.
+func (q *Queue) Entries() ([]*Entry, error) {
+	return readEntries(q.Dir)
+}
+
+// Failed returns the entries that were given up on after MaxAttempts
+// tries, oldest first. They stay in the queue's directory until Retry
+// or Discard is called.
+func (q *Queue) Failed() ([]*Entry, error) {
+	return readEntries(filepath.Join(q.Dir, failedDir))
+}
+
+// Retry queues a failed entry again, with a fresh set of attempts.
+func (q *Queue) Retry(e *Entry) error {
+	e.Attempts = 0
+	e.NextAttempt = time.Now()
+	if err := q.save(e); err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(q.Dir, failedDir, e.ID+".json")); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Discard removes a failed entry, and its data if the queue owns it.
+func (q *Queue) Discard(e *Entry) error {
+	if err := os.Remove(filepath.Join(q.Dir, failedDir, e.ID+".json")); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	q.removeData(e)
+	return nil
+}
+
+// readEntries reads the entry files in dir, oldest first.
+func readEntries(dir string) ([]*Entry, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	entries := []*Entry{}
+	for _, name := range names {
+		b, err := ioutil.ReadFile(name)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		e := &Entry{}
+		if err := json.Unmarshal(b, e); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// Drain uploads the entries that are due, oldest first, and returns
+// the number uploaded. Failed uploads are retried by later calls after
+// a backoff. When ospry can't be reached, the remaining entries are
+// left for later too.
+func (q *Queue) Drain(ctx context.Context) (int, error) {
+	return q.loop.Drain(func() (int, error) { return q.drain(ctx) })
+}
+
+func (q *Queue) drain(ctx context.Context) (int, error) {
+	entries, err := q.Entries()
+	if err != nil {
+		return 0, err
+	}
+	done := 0
+	for _, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return done, err
+		}
+		if q.loop.Stopped() {
+			return done, ErrShutdown
+		}
+		if time.Now().Before(e.NextAttempt) {
+			continue
+		}
+		m, err := q.upload(e)
+		if err != nil {
+			e.LastError = err.Error()
+			if offline(err) {
+				// Outages don't count as attempts, and the entry is
+				// retried as soon as ospry may be back.
+				e.NextAttempt = time.Now().Add(q.backoff(1))
+				if serr := q.save(e); serr != nil {
+					return done, serr
+				}
+				return done, nil
+			}
+			e.Attempts++
+			e.NextAttempt = time.Now().Add(q.backoff(e.Attempts))
+			if serr := q.save(e); serr != nil {
+				return done, serr
+			}
+			if e.Attempts >= q.maxAttempts() {
+				if ferr := q.fail(e); ferr != nil {
+					return done, ferr
+				}
+			}
+			continue
+		}
+		if q.Uploaded != nil {
+			q.Uploaded(e, m)
+		}
+		if err := q.remove(e); err != nil {
+			return done, err
+		}
+		done++
+	}
+	return done, nil
+}
+
+// Run drains the queue every interval until ctx is done or Shutdown is
+// called.
+func (q *Queue) Run(ctx context.Context, interval time.Duration) error {
+	return q.loop.Run(ctx, interval, q.Drain, ErrShutdown)
+}
+
+// Shutdown stops accepting entries and stops Run and Drain after the
+// upload in flight. It returns the entries still queued, as "<id>
+// <filename>"; they stay on disk for the next process to upload. If
+// ctx is done before the upload in flight finishes, the queued entries
+// are returned with ctx's error. Shutdown implements ospry.Shutdowner.
+func (q *Queue) Shutdown(ctx context.Context) ([]string, error) {
+	return q.loop.Shutdown(ctx, func() ([]string, error) {
+		entries, err := q.Entries()
+		var queued []string
+		for _, e := range entries {
+			queued = append(queued, e.ID+" "+e.Filename)
+		}
+		return queued, err
+	})
+}
+
+func (q *Queue) upload(e *Entry) (*ospry.Metadata, error) {
+	c := q.Client
+	if c == nil {
+		c = ospry.DefaultClient
+	}
+	f, err := os.Open(e.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	opts := ospry.UploadOpts{}
+	if e.Opts != nil {
+		opts = *e.Opts
+	}
+	if fi, err := f.Stat(); err == nil {
+		opts.Size = fi.Size()
+	}
+	return c.Upload(e.Filename, f, &opts)
+}
+
+// offline reports whether err means ospry couldn't be reached, rather
+// than that the upload itself failed.
+func offline(err error) bool {
+	var uerr *url.Error
+	if errors.As(err, &uerr) {
+		return true
+	}
+	var e *ospry.Error
+	return errors.As(err, &e) && (e.HTTPStatusCode == 429 || e.HTTPStatusCode >= 500)
+}
+
+// open fails once the queue has been shut down, and creates its
+// directory otherwise.
+func (q *Queue) open() error {
+	if q.loop.Stopped() {
+		return ErrShutdown
+	}
+	return os.MkdirAll(q.Dir, 0700)
+}
+
+func (q *Queue) newEntry(filename string, opts *ospry.UploadOpts) *Entry {
+	now := time.Now()
+	b := make([]byte, 4)
+	rand.Read(b)
+	// Ids sort in the order entries were added.
+	id := strconv.FormatInt(now.UnixNano(), 10) + "-" + hex.EncodeToString(b)
+	return &Entry{ID: id, Filename: filename, Opts: opts, Added: now, NextAttempt: now}
+}
+
+// save writes e's entry file atomically.
+func (q *Queue) save(e *Entry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return worker.WriteFile(filepath.Join(q.Dir, e.ID+".json"), func(f *os.File) error {
+		_, err := f.Write(b)
+		return err
+	})
+}
+
+// remove deletes e's entry file, and its data if the queue owns it.
+func (q *Queue) remove(e *Entry) error {
+	if err := os.Remove(filepath.Join(q.Dir, e.ID+".json")); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	q.removeData(e)
+	return nil
+}
+
+func (q *Queue) removeData(e *Entry) {
+	if e.Copied && strings.HasPrefix(e.Path, q.Dir) {
+		os.Remove(e.Path)
+	}
+}
+
+// fail moves e's entry file to the failed entries. Its data stays in
+// place.
+func (q *Queue) fail(e *Entry) error {
+	if err := os.MkdirAll(filepath.Join(q.Dir, failedDir), 0700); err != nil {
+		return err
+	}
+	return os.Rename(filepath.Join(q.Dir, e.ID+".json"), filepath.Join(q.Dir, failedDir, e.ID+".json"))
+}
+
+func (q *Queue) maxAttempts() int {
+	if q.MaxAttempts == 0 {
+		return DefaultMaxAttempts
+	}
+	return q.MaxAttempts
+}
+
+// backoff returns the delay before retrying after the given attempt.
+func (q *Queue) backoff(attempt int) time.Duration {
+	d := q.Backoff
+	if d == 0 {
+		d = DefaultBackoff
+	}
+	return worker.Backoff(d, maxBackoff, attempt)
+}
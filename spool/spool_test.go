@@ -0,0 +1,152 @@
+package spool
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	ospry "github.com/ospry/ospry-go"
+)
+
+func TestQueue(t *testing.T) {
+	var mu sync.Mutex
+	down := true
+	var uploaded []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if down {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error":{"httpStatusCode":503,"message":"unavailable"}}`))
+			return
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		uploaded = append(uploaded, string(b))
+		w.Write([]byte(`{"metadata":{"id":"img-` + r.URL.Query().Get("filename") + `","url":"http://foo.ospry.io/x.jpg"}}`))
+	}))
+	defer ts.Close()
+	c := ospry.New("sk-test-key")
+	c.ServerURL = ts.URL
+
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	photo := filepath.Join(dir, "photo.jpg")
+	ioutil.WriteFile(photo, []byte("file data"), 0600)
+
+	var done []string
+	q := &Queue{Dir: filepath.Join(dir, "q"), Client: c, Backoff: time.Nanosecond,
+		Uploaded: func(e *Entry, m *ospry.Metadata) { done = append(done, e.Filename+"="+m.ID) }}
+	if _, err := q.Add("mem.jpg", strings.NewReader("memory data"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.AddFile(photo, "", &ospry.UploadOpts{IsPrivate: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Offline: the first failure leaves the rest for later.
+	n, err := q.Drain(context.Background())
+	if err != nil || n != 0 {
+		t.Fatalf("got %d, %v, want nothing uploaded", n, err)
+	}
+	entries, _ := q.Entries()
+	if len(entries) != 2 || entries[0].Attempts != 0 || entries[1].Attempts != 0 || !strings.Contains(entries[0].LastError, "unavailable") {
+		t.Fatalf("got %+v, want the first entry's error recorded without counting an attempt", entries)
+	}
+
+	mu.Lock()
+	down = false
+	mu.Unlock()
+	time.Sleep(time.Millisecond)
+	n, err = q.Drain(context.Background())
+	if err != nil || n != 2 {
+		t.Fatalf("got %d, %v, want 2 uploaded", n, err)
+	}
+	if got, want := strings.Join(uploaded, ","), "memory data,file data"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if got, want := strings.Join(done, ","), "mem.jpg=img-mem.jpg,photo.jpg=img-photo.jpg"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if names, _ := filepath.Glob(filepath.Join(q.Dir, "*")); len(names) != 0 {
+		t.Fatalf("got %v, want an empty spool", names)
+	}
+	if _, err := os.Stat(photo); err != nil {
+		t.Fatalf("got %v, want the added file kept", err)
+	}
+
+	if _, err := q.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.AddFile(photo, "", nil); err != ErrShutdown {
+		t.Fatalf("got %v, want ErrShutdown", err)
+	}
+}
+
+func TestQueueFailed(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"httpStatusCode":400,"message":"not an image"}}`))
+	}))
+	defer ts.Close()
+	c := ospry.New("sk-test-key")
+	c.ServerURL = ts.URL
+
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	q := &Queue{Dir: dir, Client: c, Backoff: time.Nanosecond, MaxAttempts: 2}
+	e, err := q.Add("bad.jpg", strings.NewReader("not an image"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		time.Sleep(time.Millisecond)
+		if _, err := q.Drain(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, _ := q.Entries()
+	failed, _ := q.Failed()
+	if len(entries) != 0 || len(failed) != 1 || failed[0].Attempts != 2 || !strings.Contains(failed[0].LastError, "not an image") {
+		t.Fatalf("got %+v and failed %+v, want the entry given up on", entries, failed)
+	}
+
+	if err := q.Retry(failed[0]); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ = q.Entries()
+	failed, _ = q.Failed()
+	if len(entries) != 1 || entries[0].Attempts != 0 || len(failed) != 0 {
+		t.Fatalf("got %+v and failed %+v, want the entry queued again", entries, failed)
+	}
+
+	for i := 0; i < 2; i++ {
+		time.Sleep(time.Millisecond)
+		q.Drain(context.Background())
+	}
+	failed, _ = q.Failed()
+	if len(failed) != 1 {
+		t.Fatalf("got failed %+v, want the entry given up on again", failed)
+	}
+	if err := q.Discard(failed[0]); err != nil {
+		t.Fatal(err)
+	}
+	if failed, _ = q.Failed(); len(failed) != 0 {
+		t.Fatalf("got failed %+v, want none", failed)
+	}
+	if _, err := os.Stat(e.Path); !os.IsNotExist(err) {
+		t.Fatalf("got %v, want the copied data removed", err)
+	}
+}
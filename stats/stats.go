@@ -0,0 +1,179 @@
+// Package stats computes distribution statistics over an account's
+// images, e.g. for capacity planning or tuning render presets.
+package stats
+
+import (
+	"context"
+	"html/template"
+	"io"
+	"sort"
+
+	ospry "github.com/ospry/ospry-go"
+)
+
+// DimensionEdges are the lower bounds, in pixels, of the bins of the
+// dimension histograms.
+var DimensionEdges = []int{0, 256, 512, 1024, 2048, 4096, 8192}
+
+// Percentiles are the size percentiles computed.
+var Percentiles = []int{50, 90, 95, 99}
+
+// A Bin is a histogram bin counting values in [Min, Max). The last
+// bin's Max is zero, meaning unbounded.
+type Bin struct {
+	Min   int `json:"min"`
+	Max   int `json:"max"`
+	Count int `json:"count"`
+}
+
+// Stats describe a set of images.
+type Stats struct {
+	Count     int `json:"count"`
+	Claimed   int `json:"claimed"`
+	Unclaimed int `json:"unclaimed"`
+
+	Widths  []Bin `json:"widths"`
+	Heights []Bin `json:"heights"`
+	// SizePercentiles maps each of Percentiles to the image size, in
+	// bytes, at that percentile.
+	SizePercentiles map[int]int64 `json:"sizePercentiles"`
+	// FormatsByMonth maps months ("2006-01") of creation to counts of
+	// the images created by format.
+	FormatsByMonth map[string]map[string]int `json:"formatsByMonth"`
+}
+
+// ClaimedRatio returns the fraction of images that are claimed.
+func (s *Stats) ClaimedRatio() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return float64(s.Claimed) / float64(s.Count)
+}
+
+// Months returns the keys of FormatsByMonth in order.
+func (s *Stats) Months() []string {
+	var months []string
+	for m := range s.FormatsByMonth {
+		months = append(months, m)
+	}
+	sort.Strings(months)
+	return months
+}
+
+// A Collector accumulates images' statistics.
+type Collector struct {
+	stats Stats
+	sizes []int64
+}
+
+// Collect walks every image matching filter and returns their
+// statistics. A nil filter covers the whole account.
+func Collect(ctx context.Context, c *ospry.Client, filter *ospry.ListFilter) (*Stats, error) {
+	var col Collector
+	images, errc := c.ListAll(ctx, filter)
+	for m := range images {
+		col.Add(m)
+	}
+	if err := <-errc; err != nil {
+		return nil, err
+	}
+	return col.Stats(), nil
+}
+
+// Add adds an image.
+func (col *Collector) Add(m *ospry.Metadata) {
+	s := &col.stats
+	if s.Widths == nil {
+		s.Widths, s.Heights = bins(), bins()
+		s.FormatsByMonth = make(map[string]map[string]int)
+	}
+	s.Count++
+	if m.IsClaimed {
+		s.Claimed++
+	} else {
+		s.Unclaimed++
+	}
+	addToBin(s.Widths, m.Width)
+	addToBin(s.Heights, m.Height)
+	col.sizes = append(col.sizes, m.Size)
+	month := m.TimeCreated.UTC().Format("2006-01")
+	if s.FormatsByMonth[month] == nil {
+		s.FormatsByMonth[month] = make(map[string]int)
+	}
+	s.FormatsByMonth[month][m.Format]++
+}
+
+// Stats returns the statistics of the images added so far.
+func (col *Collector) Stats() *Stats {
+	s := col.stats
+	if s.Widths == nil {
+		s.Widths, s.Heights = bins(), bins()
+	}
+	s.SizePercentiles = make(map[int]int64)
+	if len(col.sizes) > 0 {
+		sizes := append([]int64(nil), col.sizes...)
+		sort.Slice(sizes, func(i, j int) bool { return sizes[i] < sizes[j] })
+		for _, p := range Percentiles {
+			// Nearest rank.
+			i := (p*len(sizes)+99)/100 - 1
+			if i < 0 {
+				i = 0
+			}
+			s.SizePercentiles[p] = sizes[i]
+		}
+	}
+	return &s
+}
+
+func bins() []Bin {
+	b := make([]Bin, len(DimensionEdges))
+	for i, min := range DimensionEdges {
+		b[i].Min = min
+		if i+1 < len(DimensionEdges) {
+			b[i].Max = DimensionEdges[i+1]
+		}
+	}
+	return b
+}
+
+func addToBin(bins []Bin, v int) {
+	for i := len(bins) - 1; i >= 0; i-- {
+		if v >= bins[i].Min {
+			bins[i].Count++
+			return
+		}
+	}
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Image statistics</title></head>
+<body>
+<h1>Image statistics</h1>
+<p>{{.Count}} images, {{.Claimed}} claimed, {{.Unclaimed}} unclaimed.</p>
+<h2>Sizes</h2>
+<table>
+<tr><th>Percentile</th><th>Bytes</th></tr>
+{{range $p, $size := .SizePercentiles}}<tr><td>p{{$p}}</td><td>{{$size}}</td></tr>
+{{end}}</table>
+{{define "bins"}}<table>
+<tr><th>Pixels</th><th>Images</th></tr>
+{{range .}}<tr><td>{{.Min}}{{if .Max}}–{{.Max}}{{else}}+{{end}}</td><td>{{.Count}}</td></tr>
+{{end}}</table>{{end}}
+<h2>Widths</h2>
+{{template "bins" .Widths}}
+<h2>Heights</h2>
+{{template "bins" .Heights}}
+<h2>Formats by month</h2>
+<table>
+<tr><th>Month</th><th>Formats</th></tr>
+{{range $month := .Months}}<tr><td>{{$month}}</td><td>{{range $f, $n := index $.FormatsByMonth $month}}{{$f}}: {{$n}} {{end}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// WriteHTML writes the statistics to w as an HTML page.
+func (s *Stats) WriteHTML(w io.Writer) error {
+	return reportTemplate.Execute(w, s)
+}
@@ -0,0 +1,60 @@
+package stats
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	ospry "github.com/ospry/ospry-go"
+)
+
+func TestCollector(t *testing.T) {
+	jan := time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC)
+	feb := time.Date(2020, 2, 15, 0, 0, 0, 0, time.UTC)
+	var col Collector
+	for i := 1; i <= 100; i++ {
+		m := &ospry.Metadata{Size: int64(i), Width: 300, Height: 5000, Format: "jpeg", TimeCreated: jan, IsClaimed: i%4 != 0}
+		if i > 90 {
+			m.Format, m.TimeCreated, m.Width = "webp", feb, 9000
+		}
+		col.Add(m)
+	}
+	s := col.Stats()
+	if s.Count != 100 || s.Claimed != 75 || s.ClaimedRatio() != 0.75 {
+		t.Fatalf("got %d images, %d claimed, want 100, 75", s.Count, s.Claimed)
+	}
+	if s.Widths[1].Count != 90 || s.Widths[len(s.Widths)-1].Count != 10 || s.Heights[5].Count != 100 {
+		t.Fatalf("got widths %v, heights %v", s.Widths, s.Heights)
+	}
+	for p, want := range map[int]int64{50: 50, 90: 90, 95: 95, 99: 99} {
+		if s.SizePercentiles[p] != want {
+			t.Fatalf("p%d: got %d, want %d", p, s.SizePercentiles[p], want)
+		}
+	}
+	if s.FormatsByMonth["2020-01"]["jpeg"] != 90 || s.FormatsByMonth["2020-02"]["webp"] != 10 {
+		t.Fatalf("got %v", s.FormatsByMonth)
+	}
+
+	var buf bytes.Buffer
+	if err := s.WriteHTML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"100 images, 75 claimed", "<td>p99</td><td>99</td>", "<td>8192+</td><td>10</td>", "<td>2020-02</td><td>webp: 10 </td>"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("report doesn't contain %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	var col Collector
+	s := col.Stats()
+	if s.Count != 0 || s.ClaimedRatio() != 0 || len(s.SizePercentiles) != 0 {
+		t.Fatalf("got %+v, want empty stats", s)
+	}
+	var buf bytes.Buffer
+	if err := s.WriteHTML(&buf); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,114 @@
+package store
+
+import (
+	"sort"
+	"sync"
+
+	ospry "github.com/ospry/ospry-go"
+)
+
+// Memory is a Store that keeps everything in memory. The zero value is
+// an empty store.
+type Memory struct {
+	mu     sync.RWMutex
+	images []*ospry.Metadata
+	refs   map[string]map[string]bool // id -> refs
+}
+
+func (s *Memory) Save(m *ospry.Metadata) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := *m
+	if i := s.index(m.ID); i >= 0 {
+		s.images[i] = &c
+	} else {
+		s.images = append(s.images, &c)
+	}
+	return nil
+}
+
+func (s *Memory) Get(id string) (*ospry.Metadata, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	i := s.index(id)
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+	c := *s.images[i]
+	return &c, nil
+}
+
+func (s *Memory) List() ([]*ospry.Metadata, error) {
+	return s.filter(func(string) bool { return true }), nil
+}
+
+func (s *Memory) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := s.index(id); i >= 0 {
+		s.images = append(s.images[:i], s.images[i+1:]...)
+	}
+	delete(s.refs, id)
+	return nil
+}
+
+func (s *Memory) Associate(id, ref string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.index(id) < 0 {
+		return ErrNotFound
+	}
+	if s.refs == nil {
+		s.refs = map[string]map[string]bool{}
+	}
+	if s.refs[id] == nil {
+		s.refs[id] = map[string]bool{}
+	}
+	s.refs[id][ref] = true
+	return nil
+}
+
+func (s *Memory) Dissociate(id, ref string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.refs[id], ref)
+	return nil
+}
+
+func (s *Memory) Associated(ref string) ([]*ospry.Metadata, error) {
+	return s.filter(func(id string) bool { return s.refs[id][ref] }), nil
+}
+
+func (s *Memory) Refs(id string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	refs := []string{}
+	for ref := range s.refs[id] {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+	return refs, nil
+}
+
+func (s *Memory) index(id string) int {
+	for i, m := range s.images {
+		if m.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// filter returns copies of the images whose ids match.
+func (s *Memory) filter(match func(id string) bool) []*ospry.Metadata {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	images := []*ospry.Metadata{}
+	for _, m := range s.images {
+		if match(m.ID) {
+			c := *m
+			images = append(images, &c)
+		}
+	}
+	return images
+}
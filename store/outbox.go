@@ -0,0 +1,251 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	ospry "github.com/ospry/ospry-go"
+	"github.com/ospry/ospry-go/internal/worker"
+)
+
+// Outbox operations.
+const (
+	OpClaim  = "claim"
+	OpDelete = "delete"
+)
+
+// Defaults for an Outbox's zero fields.
+const (
+	DefaultMaxAttempts = 10
+	DefaultBackoff     = time.Second
+	maxBackoff         = time.Hour
+)
+
+// An Outbox defers ospry operations until the database transaction
+// that decides them has committed: intents are recorded in the
+// transaction (see Claim and Delete), and Drain carries them out
+// afterwards, retrying failures. A rollback discards the intents along
+// with the rows referencing the images, so images are only claimed if
+// the application kept them, and only deleted once it has let go of
+// them.
+//
+// The outbox's table is created by the SQL store's Migrate.
+type Outbox struct {
+	SQL *SQL
+	// Client carries out the operations. If nil, the default client
+	// is used.
+	Client *ospry.Client
+	// MaxAttempts is the number of times an operation is tried
+	// before it's given up on (see Failed). If zero,
+	// DefaultMaxAttempts is used.
+	MaxAttempts int
+	// Backoff is the delay before an operation is retried, doubled
+	// after each failure up to an hour. If zero, DefaultBackoff is
+	// used.
+	Backoff time.Duration
+
+	loop worker.Loop
+}
+
+// An OutboxEntry is an operation recorded in an outbox.
+type OutboxEntry struct {
+	Op        string
+	ImageID   string
+	Created   time.Time
+	Attempts  int
+	LastError string
+}
+
+// Claim records, in tx, the intent to claim the image with the given
+// id.
+func (o *Outbox) Claim(tx *sql.Tx, id string) error {
+	return o.add(tx, OpClaim, id)
+}
+
+// Delete records, in tx, the intent to delete the image with the
+// given id.
+func (o *Outbox) Delete(tx *sql.Tx, id string) error {
+	return o.add(tx, OpDelete, id)
+}
+
+func (o *Outbox) add(tx *sql.Tx, op, id string) error {
+	now := time.Now().UnixNano()
+	_, err := tx.Exec(o.SQL.rebind(`INSERT INTO ospry_outbox (op, image_id, created, attempts, next_attempt, last_error)
+		VALUES (?, ?, ?, 0, ?, '')`), op, id, now, now)
+	return err
+}
+
+// Drain carries out the operations that are due, returning the number
+// that succeeded. Failed operations are retried by later calls after
+// a backoff. Several processes may drain the same outbox: operations
+// are idempotent, so running one twice does no harm.
+func (o *Outbox) Drain(ctx context.Context) (int, error) {
+	return o.loop.Drain(func() (int, error) { return o.drain(ctx) })
+}
+
+func (o *Outbox) drain(ctx context.Context) (int, error) {
+	rows, err := o.SQL.DB.QueryContext(ctx, o.SQL.rebind(`SELECT op, image_id, created, attempts FROM ospry_outbox
+		WHERE attempts < ? AND next_attempt <= ? ORDER BY created`), o.maxAttempts(), time.Now().UnixNano())
+	if err != nil {
+		return 0, err
+	}
+	type due struct {
+		op, id   string
+		created  int64
+		attempts int
+	}
+	var pending []due
+	for rows.Next() {
+		var d due
+		if err := rows.Scan(&d.op, &d.id, &d.created, &d.attempts); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		pending = append(pending, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	done := 0
+	for _, d := range pending {
+		if err := ctx.Err(); err != nil {
+			return done, err
+		}
+		if o.loop.Stopped() {
+			return done, ErrShutdown
+		}
+		if err := o.run(d.op, d.id); err != nil {
+			next := time.Now().Add(o.backoff(d.attempts + 1)).UnixNano()
+			_, err = o.SQL.DB.ExecContext(ctx, o.SQL.rebind(`UPDATE ospry_outbox SET attempts = ?, next_attempt = ?, last_error = ?
+				WHERE op = ? AND image_id = ? AND created = ?`), d.attempts+1, next, err.Error(), d.op, d.id, d.created)
+			if err != nil {
+				return done, err
+			}
+			continue
+		}
+		_, err := o.SQL.DB.ExecContext(ctx, o.SQL.rebind(`DELETE FROM ospry_outbox WHERE op = ? AND image_id = ? AND created = ?`),
+			d.op, d.id, d.created)
+		if err != nil {
+			return done, err
+		}
+		done++
+	}
+	return done, nil
+}
+
+// ErrShutdown is returned by Drain and Run once Shutdown has been
+// called.
+var ErrShutdown = errors.New("store: outbox shut down")
+
+// Run drains the outbox every interval until ctx is done or Shutdown
+// is called.
+func (o *Outbox) Run(ctx context.Context, interval time.Duration) error {
+	return o.loop.Run(ctx, interval, o.Drain, ErrShutdown)
+}
+
+// Shutdown stops Run and Drain after the operation in flight, and
+// returns the operations still pending, as "<op> <image id>". They
+// stay in the outbox for the next process to carry out. If ctx is
+// done before the operation in flight finishes, the pending operations
+// are returned with ctx's error. Shutdown implements ospry.Shutdowner.
+func (o *Outbox) Shutdown(ctx context.Context) ([]string, error) {
+	return o.loop.Shutdown(ctx, o.pending)
+}
+
+// pending returns the operations not given up on, as "<op> <image
+// id>".
+func (o *Outbox) pending() ([]string, error) {
+	rows, err := o.SQL.DB.Query(o.SQL.rebind(`SELECT op, image_id FROM ospry_outbox
+		WHERE attempts < ? ORDER BY created`), o.maxAttempts())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var pending []string
+	for rows.Next() {
+		var op, id string
+		if err := rows.Scan(&op, &id); err != nil {
+			return nil, err
+		}
+		pending = append(pending, op+" "+id)
+	}
+	return pending, rows.Err()
+}
+
+// Failed returns the operations that were given up on after
+// MaxAttempts tries. They stay in the outbox until Retry or Discard is
+// called.
+func (o *Outbox) Failed() ([]OutboxEntry, error) {
+	rows, err := o.SQL.DB.Query(o.SQL.rebind(`SELECT op, image_id, created, attempts, last_error FROM ospry_outbox
+		WHERE attempts >= ? ORDER BY created`), o.maxAttempts())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	entries := []OutboxEntry{}
+	for rows.Next() {
+		var e OutboxEntry
+		var created int64
+		if err := rows.Scan(&e.Op, &e.ImageID, &created, &e.Attempts, &e.LastError); err != nil {
+			return nil, err
+		}
+		e.Created = time.Unix(0, created)
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// Retry makes a failed operation due again, with a fresh set of
+// attempts.
+func (o *Outbox) Retry(e OutboxEntry) error {
+	_, err := o.SQL.DB.Exec(o.SQL.rebind(`UPDATE ospry_outbox SET attempts = 0, next_attempt = ?
+		WHERE op = ? AND image_id = ? AND created = ?`), time.Now().UnixNano(), e.Op, e.ImageID, e.Created.UnixNano())
+	return err
+}
+
+// Discard removes an operation from the outbox.
+func (o *Outbox) Discard(e OutboxEntry) error {
+	_, err := o.SQL.DB.Exec(o.SQL.rebind(`DELETE FROM ospry_outbox WHERE op = ? AND image_id = ? AND created = ?`),
+		e.Op, e.ImageID, e.Created.UnixNano())
+	return err
+}
+
+func (o *Outbox) run(op, id string) error {
+	c := o.Client
+	if c == nil {
+		c = ospry.DefaultClient
+	}
+	switch op {
+	case OpClaim:
+		_, err := c.Claim(id)
+		return err
+	case OpDelete:
+		err := c.Delete(id)
+		var e *ospry.Error
+		if errors.As(err, &e) && e.HTTPStatusCode == 404 {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+func (o *Outbox) maxAttempts() int {
+	if o.MaxAttempts == 0 {
+		return DefaultMaxAttempts
+	}
+	return o.MaxAttempts
+}
+
+// backoff returns the delay before the given attempt.
+func (o *Outbox) backoff(attempt int) time.Duration {
+	d := o.Backoff
+	if d == 0 {
+		d = DefaultBackoff
+	}
+	return worker.Backoff(d, maxBackoff, attempt)
+}
@@ -0,0 +1,118 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	ospry "github.com/ospry/ospry-go"
+)
+
+func TestOutbox(t *testing.T) {
+	var claimed []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/images/")
+		if id == "gone" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":{"httpStatusCode":404,"message":"not found"}}`))
+			return
+		}
+		claimed = append(claimed, id)
+		w.Write([]byte(`{"metadata":{"id":"` + id + `","isClaimed":true}}`))
+	}))
+	defer ts.Close()
+	c := ospry.New("sk-test-key")
+	c.ServerURL = ts.URL
+
+	db, err := sql.Open("store-recorder", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	o := &Outbox{SQL: &SQL{DB: db}, Client: c}
+
+	rec.reset(nil)
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Claim(tx, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	tx.Commit()
+	if !strings.HasPrefix(rec.stmts[0], "INSERT INTO ospry_outbox") || rec.args[0][0] != OpClaim || rec.args[0][1] != "foo" {
+		t.Fatalf("got %q %v, want claim intent for foo", rec.stmts[0], rec.args[0])
+	}
+
+	rec.reset(map[string][][]driver.Value{
+		"SELECT op, image_id, created, attempts FROM ospry_outbox": {
+			{OpClaim, "foo", int64(1), int64(0)},
+			{OpClaim, "gone", int64(2), int64(3)},
+		},
+	})
+	n, err := o.Drain(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || len(claimed) != 1 || claimed[0] != "foo" {
+		t.Fatalf("got %d done, claimed %v, want foo", n, claimed)
+	}
+	if !strings.HasPrefix(rec.stmts[1], "DELETE FROM ospry_outbox") || rec.args[1][1] != "foo" {
+		t.Fatalf("got %q %v, want foo removed", rec.stmts[1], rec.args[1])
+	}
+	if !strings.HasPrefix(rec.stmts[2], "UPDATE ospry_outbox SET attempts") || rec.args[2][0] != int64(4) {
+		t.Fatalf("got %q %v, want gone's attempts raised to 4", rec.stmts[2], rec.args[2])
+	}
+	next := time.Unix(0, rec.args[2][1].(int64))
+	if d := time.Until(next); d < 7*time.Second || d > 8*time.Second {
+		t.Fatalf("got retry in %v, want 8s", d)
+	}
+}
+
+func TestOutboxBackoff(t *testing.T) {
+	o := &Outbox{Backoff: time.Minute}
+	for attempt, want := range map[int]time.Duration{1: time.Minute, 2: 2 * time.Minute, 3: 4 * time.Minute, 20: time.Hour} {
+		if got := o.backoff(attempt); got != want {
+			t.Fatalf("attempt %d: got %v, want %v", attempt, got, want)
+		}
+	}
+}
+
+func TestOutboxShutdown(t *testing.T) {
+	db, err := sql.Open("store-recorder", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	o := &Outbox{SQL: &SQL{DB: db}}
+	rec.reset(map[string][][]driver.Value{
+		"SELECT op, image_id FROM ospry_outbox": {{OpDelete, "foo"}},
+	})
+	queried := make(chan string, 10)
+	rec.mu.Lock()
+	rec.queried = queried
+	rec.mu.Unlock()
+	errc := make(chan error)
+	go func() { errc <- o.Run(context.Background(), time.Hour) }()
+	// Wait for Run's first drain.
+	<-queried
+
+	pending, err := o.Shutdown(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0] != "delete foo" {
+		t.Fatalf("got %v, want [delete foo]", pending)
+	}
+	if err := <-errc; err != ErrShutdown {
+		t.Fatalf("got %v, want ErrShutdown", err)
+	}
+	if _, err := o.Drain(context.Background()); err != nil {
+		t.Fatalf("got %v, want nothing to drain", err)
+	}
+}
@@ -0,0 +1,242 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	ospry "github.com/ospry/ospry-go"
+)
+
+// A Dialect is the flavor of SQL a database speaks.
+type Dialect string
+
+const (
+	SQLite   Dialect = "sqlite"
+	MySQL    Dialect = "mysql"
+	Postgres Dialect = "postgres"
+)
+
+// migrations create and update the SQL store's schema. Each one is
+// applied once, in order; the schema's version is the number of
+// migrations applied. Existing migrations must never change.
+var migrations = []string{
+	`CREATE TABLE ospry_images (
+		id VARCHAR(255) NOT NULL PRIMARY KEY,
+		seq BIGINT NOT NULL,
+		metadata TEXT NOT NULL
+	)`,
+	`CREATE TABLE ospry_image_refs (
+		image_id VARCHAR(255) NOT NULL,
+		ref VARCHAR(255) NOT NULL,
+		PRIMARY KEY (image_id, ref)
+	)`,
+	`CREATE INDEX ospry_image_refs_ref ON ospry_image_refs (ref)`,
+	`CREATE TABLE ospry_outbox (
+		op VARCHAR(32) NOT NULL,
+		image_id VARCHAR(255) NOT NULL,
+		created BIGINT NOT NULL,
+		attempts INTEGER NOT NULL,
+		next_attempt BIGINT NOT NULL,
+		last_error TEXT NOT NULL
+	)`,
+	`CREATE INDEX ospry_outbox_due ON ospry_outbox (next_attempt)`,
+}
+
+// SQL is a Store backed by a database/sql database. Metadata is
+// stored as json, so fields added to ospry.Metadata don't need schema
+// changes. Call Migrate to create or update its tables before using
+// it.
+type SQL struct {
+	DB *sql.DB
+	// Dialect selects the placeholder and upsert syntax. If empty, ?
+	// placeholders and ON CONFLICT upserts are used, as for SQLite.
+	Dialect Dialect
+}
+
+// Migrate brings the store's tables up to date, applying the
+// migrations that haven't been applied yet. It's safe to call every
+// time the application starts, but not concurrently.
+func (s *SQL) Migrate() error {
+	if _, err := s.DB.Exec(`CREATE TABLE IF NOT EXISTS ospry_schema (version INTEGER NOT NULL)`); err != nil {
+		return err
+	}
+	version, err := s.Version()
+	if err != nil {
+		return err
+	}
+	for v := version; v < len(migrations); v++ {
+		err := s.tx(func(tx *sql.Tx) error {
+			if _, err := tx.Exec(migrations[v]); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`DELETE FROM ospry_schema`); err != nil {
+				return err
+			}
+			_, err := tx.Exec(s.rebind(`INSERT INTO ospry_schema (version) VALUES (?)`), v+1)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Version returns the number of migrations applied to the database.
+func (s *SQL) Version() (int, error) {
+	var version int
+	err := s.DB.QueryRow(`SELECT version FROM ospry_schema`).Scan(&version)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return version, err
+}
+
+func (s *SQL) Save(m *ospry.Metadata) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	// A single upsert, rather than a check and an insert, so that
+	// concurrent saves of a new image don't collide. Updates keep the
+	// image's seq, and so its place in List.
+	upsert := `ON CONFLICT (id) DO UPDATE SET metadata = excluded.metadata`
+	if s.Dialect == MySQL {
+		upsert = `ON DUPLICATE KEY UPDATE metadata = VALUES(metadata)`
+	}
+	_, err = s.DB.Exec(s.rebind(`INSERT INTO ospry_images (id, seq, metadata) VALUES (?, ?, ?) `+upsert),
+		m.ID, time.Now().UnixNano(), string(b))
+	return err
+}
+
+func (s *SQL) Get(id string) (*ospry.Metadata, error) {
+	var data string
+	err := s.DB.QueryRow(s.rebind(`SELECT metadata FROM ospry_images WHERE id = ?`), id).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	m := &ospry.Metadata{}
+	if err := json.Unmarshal([]byte(data), m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (s *SQL) List() ([]*ospry.Metadata, error) {
+	return s.query(`SELECT metadata FROM ospry_images ORDER BY seq, id`)
+}
+
+func (s *SQL) Delete(id string) error {
+	return s.tx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(s.rebind(`DELETE FROM ospry_image_refs WHERE image_id = ?`), id); err != nil {
+			return err
+		}
+		_, err := tx.Exec(s.rebind(`DELETE FROM ospry_images WHERE id = ?`), id)
+		return err
+	})
+}
+
+func (s *SQL) Associate(id, ref string) error {
+	return s.tx(func(tx *sql.Tx) error {
+		var n int
+		err := tx.QueryRow(s.rebind(`SELECT COUNT(*) FROM ospry_images WHERE id = ?`), id).Scan(&n)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return ErrNotFound
+		}
+		if _, err := tx.Exec(s.rebind(`DELETE FROM ospry_image_refs WHERE image_id = ? AND ref = ?`), id, ref); err != nil {
+			return err
+		}
+		_, err = tx.Exec(s.rebind(`INSERT INTO ospry_image_refs (image_id, ref) VALUES (?, ?)`), id, ref)
+		return err
+	})
+}
+
+func (s *SQL) Dissociate(id, ref string) error {
+	_, err := s.DB.Exec(s.rebind(`DELETE FROM ospry_image_refs WHERE image_id = ? AND ref = ?`), id, ref)
+	return err
+}
+
+func (s *SQL) Associated(ref string) ([]*ospry.Metadata, error) {
+	return s.query(`SELECT i.metadata FROM ospry_images i
+		JOIN ospry_image_refs r ON r.image_id = i.id
+		WHERE r.ref = ? ORDER BY i.seq, i.id`, ref)
+}
+
+func (s *SQL) Refs(id string) ([]string, error) {
+	rows, err := s.DB.Query(s.rebind(`SELECT ref FROM ospry_image_refs WHERE image_id = ? ORDER BY ref`), id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	refs := []string{}
+	for rows.Next() {
+		var ref string
+		if err := rows.Scan(&ref); err != nil {
+			return nil, err
+		}
+		refs = append(refs, ref)
+	}
+	return refs, rows.Err()
+}
+
+// query returns the images whose metadata is selected by query.
+func (s *SQL) query(query string, args ...interface{}) ([]*ospry.Metadata, error) {
+	rows, err := s.DB.Query(s.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	images := []*ospry.Metadata{}
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		m := &ospry.Metadata{}
+		if err := json.Unmarshal([]byte(data), m); err != nil {
+			return nil, err
+		}
+		images = append(images, m)
+	}
+	return images, rows.Err()
+}
+
+func (s *SQL) tx(fn func(tx *sql.Tx) error) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// rebind rewrites the ? placeholders in query for the store's
+// dialect.
+func (s *SQL) rebind(query string) string {
+	if s.Dialect != Postgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,162 @@
+package store
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	ospry "github.com/ospry/ospry-go"
+)
+
+// recorder is a database/sql driver that records the statements it's
+// given and their arguments. Queries return the rows of the first
+// entry in rows whose key they start with, or no rows.
+type recorder struct {
+	mu    sync.Mutex
+	stmts []string
+	args  [][]driver.Value
+	rows  map[string][][]driver.Value
+	// queried, if set, is sent each query.
+	queried chan string
+}
+
+func (d *recorder) Open(string) (driver.Conn, error) { return d, nil }
+func (d *recorder) Prepare(query string) (driver.Stmt, error) {
+	return &recordedStmt{d, query}, nil
+}
+func (d *recorder) Close() error              { return nil }
+func (d *recorder) Begin() (driver.Tx, error) { return d, nil }
+func (d *recorder) Commit() error             { return nil }
+func (d *recorder) Rollback() error           { return nil }
+
+type recordedStmt struct {
+	d     *recorder
+	query string
+}
+
+func (s *recordedStmt) Close() error  { return nil }
+func (s *recordedStmt) NumInput() int { return -1 }
+func (s *recordedStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	s.d.stmts = append(s.d.stmts, s.query)
+	s.d.args = append(s.d.args, args)
+	return driver.RowsAffected(1), nil
+}
+func (s *recordedStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	s.d.stmts = append(s.d.stmts, s.query)
+	s.d.args = append(s.d.args, args)
+	if s.d.queried != nil {
+		s.d.queried <- s.query
+	}
+	for prefix, rows := range s.d.rows {
+		if strings.HasPrefix(s.query, prefix) {
+			return &fakeRows{rows: rows}, nil
+		}
+	}
+	return &fakeRows{}, nil
+}
+
+type fakeRows struct {
+	rows [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	if len(r.rows) == 0 {
+		return []string{"x"}
+	}
+	return make([]string, len(r.rows[0]))
+}
+func (r *fakeRows) Close() error { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+// reset clears the recorder and scripts the given rows.
+func (d *recorder) reset(rows map[string][][]driver.Value) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.stmts, d.args, d.rows, d.queried = nil, nil, rows, nil
+}
+
+var rec = &recorder{}
+
+func init() {
+	sql.Register("store-recorder", rec)
+}
+
+func TestSQLMigrate(t *testing.T) {
+	db, err := sql.Open("store-recorder", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	rec.reset(nil)
+	s := &SQL{DB: db, Dialect: Postgres}
+	if err := s.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	applied := 0
+	for _, stmt := range rec.stmts {
+		for _, m := range migrations {
+			if stmt == m {
+				applied++
+			}
+		}
+		if strings.Contains(stmt, "?") {
+			t.Fatalf("got %q, want $n placeholders", stmt)
+		}
+	}
+	if applied != len(migrations) {
+		t.Fatalf("got %d migrations applied, want %d", applied, len(migrations))
+	}
+	last := rec.stmts[len(rec.stmts)-1]
+	if last != "INSERT INTO ospry_schema (version) VALUES ($1)" {
+		t.Fatalf("got last statement %q, want version update", last)
+	}
+}
+
+func TestSQLSave(t *testing.T) {
+	db, err := sql.Open("store-recorder", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for dialect, want := range map[Dialect]string{
+		"":       "VALUES (?, ?, ?) ON CONFLICT (id) DO UPDATE SET metadata = excluded.metadata",
+		Postgres: "VALUES ($1, $2, $3) ON CONFLICT (id) DO UPDATE SET metadata = excluded.metadata",
+		MySQL:    "VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE metadata = VALUES(metadata)",
+	} {
+		rec.reset(nil)
+		if err := (&SQL{DB: db, Dialect: dialect}).Save(&ospry.Metadata{ID: "foo"}); err != nil {
+			t.Fatal(err)
+		}
+		if len(rec.stmts) != 1 || !strings.HasSuffix(rec.stmts[0], want) {
+			t.Fatalf("%s: got %q, want a single upsert", dialect, rec.stmts)
+		}
+	}
+}
+
+func TestRebind(t *testing.T) {
+	q := `SELECT a FROM t WHERE b = ? AND c = ?`
+	if got := (&SQL{}).rebind(q); got != q {
+		t.Fatalf("got %s, want %s", got, q)
+	}
+	want := `SELECT a FROM t WHERE b = $1 AND c = $2`
+	if got := (&SQL{Dialect: Postgres}).rebind(q); got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+// SQL stores must satisfy Store.
+var _ Store = &SQL{}
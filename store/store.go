@@ -0,0 +1,55 @@
+// Package store keeps track of an application's ospry images: their
+// metadata, and which of the application's records (users, posts,
+// products) each image belongs to. Memory keeps them in memory, e.g.
+// for tests, and SQL in a database:
+//
+//	s := &store.SQL{DB: db, Dialect: store.Postgres}
+//	if err := s.Migrate(); err != nil {
+//		...
+//	}
+//	m, err := ospry.Claim(id)
+//	err = s.Save(m)
+//	err = s.Associate(m.ID, "user/42")
+//
+// Stores can be used as ospryui stores too. An Outbox claims and
+// deletes images once the transactions deciding so have committed.
+package store
+
+import (
+	"errors"
+
+	ospry "github.com/ospry/ospry-go"
+)
+
+// ErrNotFound is returned by Get for images that aren't in the store.
+var ErrNotFound = errors.New("store: image not found")
+
+// A Store keeps image metadata and the references of the application
+// records images are associated with. References are opaque strings
+// such as "user/42". Implementations must be safe for concurrent use.
+type Store interface {
+	// Save adds m, replacing the stored metadata of an image with
+	// the same id.
+	Save(m *ospry.Metadata) error
+	// Get returns the metadata of the image with the given id, or
+	// ErrNotFound.
+	Get(id string) (*ospry.Metadata, error)
+	// List returns the stored images in the order they were first
+	// saved.
+	List() ([]*ospry.Metadata, error)
+	// Delete removes the image with the given id, and its
+	// associations. Deleting a missing image isn't an error.
+	Delete(id string) error
+
+	// Associate records that the image with the given id belongs to
+	// ref. The image must be stored.
+	Associate(id, ref string) error
+	// Dissociate removes an association.
+	Dissociate(id, ref string) error
+	// Associated returns the images associated with ref, in the
+	// order they were first saved.
+	Associated(ref string) ([]*ospry.Metadata, error)
+	// Refs returns the references an image is associated with, in
+	// sorted order.
+	Refs(id string) ([]string, error)
+}
@@ -0,0 +1,64 @@
+package store
+
+import (
+	"reflect"
+	"testing"
+
+	ospry "github.com/ospry/ospry-go"
+)
+
+func ids(images []*ospry.Metadata) []string {
+	ids := []string{}
+	for _, m := range images {
+		ids = append(ids, m.ID)
+	}
+	return ids
+}
+
+func TestMemory(t *testing.T) {
+	s := &Memory{}
+	for _, id := range []string{"a", "b", "c"} {
+		if err := s.Save(&ospry.Metadata{ID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Save(&ospry.Metadata{ID: "a", IsPrivate: true}); err != nil {
+		t.Fatal(err)
+	}
+	m, err := s.Get("a")
+	if err != nil || !m.IsPrivate {
+		t.Fatalf("got %+v, %v, want private image a", m, err)
+	}
+	if _, err := s.Get("x"); err != ErrNotFound {
+		t.Fatalf("got %v, want %v", err, ErrNotFound)
+	}
+	images, _ := s.List()
+	if got, want := ids(images), []string{"a", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	s.Associate("a", "user/1")
+	s.Associate("c", "user/1")
+	s.Associate("c", "post/2")
+	if err := s.Associate("x", "user/1"); err != ErrNotFound {
+		t.Fatalf("got %v, want %v", err, ErrNotFound)
+	}
+	images, _ = s.Associated("user/1")
+	if got, want := ids(images), []string{"a", "c"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	refs, _ := s.Refs("c")
+	if want := []string{"post/2", "user/1"}; !reflect.DeepEqual(refs, want) {
+		t.Fatalf("got %v, want %v", refs, want)
+	}
+	s.Dissociate("c", "user/1")
+	s.Delete("a")
+	images, _ = s.Associated("user/1")
+	if len(images) != 0 {
+		t.Fatalf("got %v, want none", ids(images))
+	}
+	images, _ = s.List()
+	if got, want := ids(images), []string{"b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
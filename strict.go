@@ -0,0 +1,81 @@
+package ospry
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// urlParams are the query parameters FormatURL understands.
+var urlParams = map[string]bool{
+	"format":        true,
+	"maxWidth":      true,
+	"maxHeight":     true,
+	"quality":       true,
+	"fit":           true,
+	"url":           true,
+	"timeExpired":   true,
+	"signature":     true,
+	"sigAlg":        true,
+	"sub":           true,
+	"download":      true,
+	"cacheTTL":      true,
+	"v":             true,
+	"autoOrient":    true,
+	"icc":           true,
+	"progressive":   true,
+	"interlaced":    true,
+	"lossless":      true,
+	"compression":   true,
+	"bg":            true,
+	"trim":          true,
+	"trimThreshold": true,
+	"ar":            true,
+	"gravity":       true,
+	"crop":          true,
+}
+
+// checkStrict enforces the client's strict mode (see Client.Strict)
+// on a url being formatted.
+func (c *Client) checkStrict(q url.Values, opts *RenderOpts, imgURL string) error {
+	for k := range q {
+		if !urlParams[k] {
+			return errors.New("ospry: unknown url parameter " + k)
+		}
+	}
+	if c.MaxRenderWidth > 0 && (opts.MaxWidth == 0 || opts.MaxWidth > c.MaxRenderWidth) {
+		return errors.New("ospry: MaxWidth must be at most " + strconv.Itoa(c.MaxRenderWidth))
+	}
+	if c.MaxRenderHeight > 0 && (opts.MaxHeight == 0 || opts.MaxHeight > c.MaxRenderHeight) {
+		return errors.New("ospry: MaxHeight must be at most " + strconv.Itoa(c.MaxRenderHeight))
+	}
+	if opts.TimeExpired.IsZero() {
+		return nil
+	}
+	if c.MaxSignTTL > 0 && opts.TimeExpired.After(c.now().Add(c.MaxSignTTL)) {
+		return errors.New("ospry: TimeExpired is more than " + c.MaxSignTTL.String() + " away")
+	}
+	u, err := url.Parse(imgURL)
+	if err != nil {
+		return err
+	}
+	if !c.isImageHost(u.Host) {
+		return errors.New("ospry: refusing to sign url on host " + u.Host)
+	}
+	return nil
+}
+
+// isImageHost reports whether host serves images from the client's
+// account.
+func (c *Client) isImageHost(host string) bool {
+	if host == "ospry.io" || strings.HasSuffix(host, ".ospry.io") {
+		return true
+	}
+	for custom, ospryHost := range c.CustomDomains {
+		if host == custom || host == ospryHost {
+			return true
+		}
+	}
+	return false
+}
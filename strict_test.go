@@ -0,0 +1,44 @@
+package ospry
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatURLStrict(t *testing.T) {
+	c := New("sk-test-key")
+	c.Strict = true
+	c.MaxRenderWidth = 1000
+	c.MaxSignTTL = time.Hour
+	c.CustomDomains = map[string]string{"images.mybrand.com": "mybrand.ospry.io"}
+	imgURL := "http://foo.ospry.io/bar/baz.png"
+	soon := time.Now().Add(time.Minute)
+	valid := []struct {
+		in   string
+		opts *RenderOpts
+	}{
+		{imgURL + "?maxWidth=100", nil},
+		{imgURL, &RenderOpts{MaxWidth: 1000, TimeExpired: soon}},
+		{"http://images.mybrand.com/baz.png", &RenderOpts{MaxWidth: 10, TimeExpired: soon}},
+	}
+	for _, v := range valid {
+		if _, err := c.FormatURL(v.in, v.opts); err != nil {
+			t.Fatalf("%s: %v", v.in, err)
+		}
+	}
+	invalid := []struct {
+		in   string
+		opts *RenderOpts
+	}{
+		{imgURL + "?maxwidth=100", nil},
+		{imgURL, nil},
+		{imgURL, &RenderOpts{MaxWidth: 1001}},
+		{imgURL, &RenderOpts{MaxWidth: 10, TimeExpired: time.Now().Add(2 * time.Hour)}},
+		{"http://example.com/baz.png", &RenderOpts{MaxWidth: 10, TimeExpired: soon}},
+	}
+	for _, v := range invalid {
+		if _, err := c.FormatURL(v.in, v.opts); err == nil {
+			t.Fatalf("%s %+v: got nil, want error", v.in, v.opts)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package ospry
+
+import (
+	"net/url"
+	"sort"
+)
+
+// addTags adds tags to an upload query as "tag" parameters of the
+// form key=value, sorted by key.
+func addTags(q url.Values, tags map[string]string) {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		q.Add("tag", k+"="+tags[k])
+	}
+}
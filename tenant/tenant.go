@@ -0,0 +1,134 @@
+// Package tenant manages ospry clients for platforms whose customers
+// each have their own ospry account:
+//
+//	m := &tenant.ClientManager{
+//		KeyProvider: func(tenant string) (string, error) {
+//			return db.OspryKey(tenant)
+//		},
+//		MaxBytesPerSecond: 10 << 20,
+//	}
+//	c, err := m.Client(customerID)
+//
+// Each tenant gets its own client, so limits, audit hooks and progress
+// reporting are kept apart per tenant, while their connections are
+// pooled together.
+package tenant
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+
+	ospry "github.com/ospry/ospry-go"
+)
+
+// ErrNoKey is returned when a tenant has no key.
+var ErrNoKey = errors.New("tenant: no key for tenant")
+
+// A ClientManager hands out a client per tenant, creating it on first
+// use with the key returned by its KeyProvider. It's safe for
+// concurrent use.
+type ClientManager struct {
+	// KeyProvider returns the key of a tenant's account. An empty
+	// key fails with ErrNoKey.
+	KeyProvider func(tenant string) (string, error)
+	// HTTPClient is shared by every tenant's client. If nil, one
+	// with an ospry.NewTransport is created.
+	HTTPClient *http.Client
+	// Limits applied to each tenant separately (see the
+	// ospry.Client fields of the same names).
+	MaxBytesPerSecond    int64
+	MaxConcurrentUploads int
+	// Configure, if set, is called with each new client before it's
+	// used, e.g. to set per-tenant limits or to label its Audit and
+	// Progress hooks with the tenant.
+	Configure func(tenant string, c *ospry.Client)
+
+	mu      sync.Mutex
+	shared  *http.Client
+	clients map[string]*entry
+}
+
+type entry struct {
+	ready chan struct{}
+	c     *ospry.Client
+	err   error
+}
+
+// Client returns the tenant's client.
+func (m *ClientManager) Client(tenant string) (*ospry.Client, error) {
+	m.mu.Lock()
+	if m.clients == nil {
+		m.clients = map[string]*entry{}
+	}
+	e, ok := m.clients[tenant]
+	if ok {
+		m.mu.Unlock()
+		<-e.ready
+		return e.c, e.err
+	}
+	e = &entry{ready: make(chan struct{})}
+	m.clients[tenant] = e
+	m.mu.Unlock()
+
+	e.c, e.err = m.newClient(tenant)
+	if e.err != nil {
+		// Don't keep failures around, the key may show up later.
+		m.mu.Lock()
+		if m.clients[tenant] == e {
+			delete(m.clients, tenant)
+		}
+		m.mu.Unlock()
+	}
+	close(e.ready)
+	return e.c, e.err
+}
+
+// Forget drops the tenant's client, e.g. after its key changed. The
+// next call to Client creates a new one.
+func (m *ClientManager) Forget(tenant string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.clients, tenant)
+}
+
+// Tenants returns the tenants that currently have a client.
+func (m *ClientManager) Tenants() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tenants := make([]string, 0, len(m.clients))
+	for t := range m.clients {
+		tenants = append(tenants, t)
+	}
+	return tenants
+}
+
+func (m *ClientManager) newClient(tenant string) (*ospry.Client, error) {
+	key, err := m.KeyProvider(tenant)
+	if err != nil {
+		return nil, err
+	}
+	if key == "" {
+		return nil, ErrNoKey
+	}
+	c := ospry.New(key)
+	c.HTTPClient = m.httpClient()
+	c.MaxBytesPerSecond = m.MaxBytesPerSecond
+	c.MaxConcurrentUploads = m.MaxConcurrentUploads
+	if m.Configure != nil {
+		m.Configure(tenant, c)
+	}
+	return c, nil
+}
+
+func (m *ClientManager) httpClient() *http.Client {
+	if m.HTTPClient != nil {
+		return m.HTTPClient
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.shared == nil {
+		m.shared = &http.Client{Transport: ospry.NewTransport()}
+	}
+	return m.shared
+}
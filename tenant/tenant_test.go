@@ -0,0 +1,73 @@
+package tenant
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	ospry "github.com/ospry/ospry-go"
+)
+
+func TestClientManager(t *testing.T) {
+	var mu sync.Mutex
+	calls := map[string]int{}
+	keys := map[string]string{"a": "sk-test-a", "b": "sk-test-b"}
+	m := &ClientManager{
+		KeyProvider: func(tenant string) (string, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			calls[tenant]++
+			if tenant == "broken" {
+				return "", errors.New("db down")
+			}
+			return keys[tenant], nil
+		},
+		MaxConcurrentUploads: 2,
+		Configure: func(tenant string, c *ospry.Client) {
+			c.Actor = "tenant:" + tenant
+		},
+	}
+	var wg sync.WaitGroup
+	clients := make([]*ospry.Client, 10)
+	for i := range clients {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			clients[i], _ = m.Client("a")
+		}(i)
+	}
+	wg.Wait()
+	for _, c := range clients {
+		if c != clients[0] {
+			t.Fatal("got different clients, want the same one")
+		}
+	}
+	if calls["a"] != 1 {
+		t.Fatalf("got %d key lookups, want 1", calls["a"])
+	}
+	a := clients[0]
+	b, err := m.Client("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Key != "sk-test-a" || b.Key != "sk-test-b" || a.Actor != "tenant:a" || a.MaxConcurrentUploads != 2 {
+		t.Fatalf("got %s %s %s, want tenant a's client", a.Key, a.Actor, b.Key)
+	}
+	if a.HTTPClient != b.HTTPClient {
+		t.Fatal("got separate http clients, want a shared one")
+	}
+
+	if _, err := m.Client("c"); err != ErrNoKey {
+		t.Fatalf("got %v, want %v", err, ErrNoKey)
+	}
+	m.Client("broken")
+	if _, err := m.Client("broken"); err == nil || calls["broken"] != 2 {
+		t.Fatalf("got %v after %d lookups, want failures not cached", err, calls["broken"])
+	}
+
+	keys["a"] = "sk-test-a2"
+	m.Forget("a")
+	if c, _ := m.Client("a"); c.Key != "sk-test-a2" {
+		t.Fatalf("got key %s, want rotated key", c.Key)
+	}
+}
@@ -0,0 +1,95 @@
+package ospry
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// A limiter spaces out transfers so that they don't exceed rate bytes
+// per second.
+type limiter struct {
+	rate int64
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// reserve reserves n bytes of transfer and returns the time at which
+// they may be transferred.
+func (l *limiter) reserve(n int) time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	l.next = l.next.Add(time.Duration(n) * time.Second / time.Duration(l.rate))
+	return l.next
+}
+
+// limiters returns the limiters that apply to a new transfer, or nil if
+// its bandwidth isn't limited.
+func (c *Client) limiters() []*limiter {
+	var ls []*limiter
+	if c.MaxOpBytesPerSecond > 0 {
+		ls = append(ls, &limiter{rate: c.MaxOpBytesPerSecond})
+	}
+	if c.MaxBytesPerSecond > 0 {
+		c.mu.Lock()
+		if c.bandwidth == nil || c.bandwidth.rate != c.MaxBytesPerSecond {
+			c.bandwidth = &limiter{rate: c.MaxBytesPerSecond}
+		}
+		ls = append(ls, c.bandwidth)
+		c.mu.Unlock()
+	}
+	return ls
+}
+
+// throttle limits the rate at which r can be read (see
+// Client.MaxBytesPerSecond and Client.MaxOpBytesPerSecond).
+func (c *Client) throttle(r io.Reader) io.Reader {
+	ls := c.limiters()
+	if ls == nil {
+		return r
+	}
+	return &throttledReader{r: r, limiters: ls}
+}
+
+// throttleBody is like throttle for response bodies.
+func (c *Client) throttleBody(rc io.ReadCloser) io.ReadCloser {
+	ls := c.limiters()
+	if ls == nil {
+		return rc
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{&throttledReader{r: rc, limiters: ls}, rc}
+}
+
+type throttledReader struct {
+	r        io.Reader
+	limiters []*limiter
+}
+
+// throttleChunk is the most a throttledReader reads at once, so that
+// transfers proceed smoothly rather than in large bursts.
+const throttleChunk = 16 << 10
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	if len(p) > throttleChunk {
+		p = p[:throttleChunk]
+	}
+	n, err := r.r.Read(p)
+	if n > 0 {
+		var until time.Time
+		for _, l := range r.limiters {
+			if t := l.reserve(n); t.After(until) {
+				until = t
+			}
+		}
+		time.Sleep(time.Until(until))
+	}
+	return n, err
+}
@@ -0,0 +1,68 @@
+package ospry
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestThrottle(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 1000)
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			ioutil.ReadAll(r.Body)
+			writeMetadata(w, &Metadata{ID: "foo"})
+			return
+		}
+		w.Write(data)
+	})
+	download := func() {
+		rc, err := c.Download(c.ServerURL+"/foo.jpg", nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer rc.Close()
+		if b, err := ioutil.ReadAll(rc); err != nil || len(b) != len(data) {
+			t.Errorf("got %d bytes, %v, want %d bytes", len(b), err, len(data))
+		}
+	}
+	upload := func() {
+		if _, err := c.UploadPublic("foo.jpg", bytes.NewReader(data)); err != nil {
+			t.Error(err)
+		}
+	}
+	timed := func(ops ...func()) time.Duration {
+		start := time.Now()
+		var wg sync.WaitGroup
+		for _, op := range ops {
+			wg.Add(1)
+			go func(op func()) {
+				defer wg.Done()
+				op()
+			}(op)
+		}
+		wg.Wait()
+		return time.Since(start)
+	}
+
+	if d := timed(download, upload); d > 100*time.Millisecond {
+		t.Fatalf("unthrottled transfers took %v", d)
+	}
+
+	// Each operation moves 1000 bytes at 5000 bytes/sec.
+	c.MaxOpBytesPerSecond = 5000
+	if d := timed(download, upload); d < 180*time.Millisecond || d > 400*time.Millisecond {
+		t.Fatalf("per-operation limit: transfers took %v, want about 200ms", d)
+	}
+
+	// Both operations share 5000 bytes/sec.
+	c.MaxOpBytesPerSecond = 0
+	c.MaxBytesPerSecond = 5000
+	if d := timed(download, upload); d < 380*time.Millisecond || d > 700*time.Millisecond {
+		t.Fatalf("client limit: transfers took %v, want about 400ms", d)
+	}
+}
@@ -0,0 +1,169 @@
+package ospry
+
+import (
+	"errors"
+	"time"
+)
+
+// A Pipeline describes how to present an image as a sequence of
+// steps, e.g.:
+//
+//	url, err := ospry.Transform(metadata).
+//		Resize(800, 0).
+//		Format("webp").
+//		Quality(80).
+//		Sign(10 * time.Minute).
+//		URL()
+//
+// Each step is validated as it's added. Once a step fails, the
+// remaining steps are ignored and the error is returned by Err and
+// URL. The steps can be serialized (see Steps) and applied to other
+// images later (see Apply).
+type Pipeline struct {
+	client *Client
+	url    string
+	steps  []Step
+	opts   RenderOpts
+	ttl    time.Duration
+	err    error
+}
+
+// Step ops.
+const (
+	OpResize  = "resize"
+	OpFormat  = "format"
+	OpQuality = "quality"
+	OpSign    = "sign"
+)
+
+// A Step is a single stage of a Pipeline. Only the fields relevant
+// to Op are used.
+type Step struct {
+	Op      string        `json:"op"`
+	Width   int           `json:"width,omitempty"`
+	Height  int           `json:"height,omitempty"`
+	Format  string        `json:"format,omitempty"`
+	Quality int           `json:"quality,omitempty"`
+	TTL     time.Duration `json:"ttl,omitempty"`
+}
+
+// Transform starts a pipeline for the given image on the default
+// client.
+func Transform(m *Metadata) *Pipeline {
+	return DefaultClient.Transform(m)
+}
+
+// Transform starts a pipeline for the given image. Signed urls
+// produced by the pipeline are signed with the client's key. A nil m
+// starts a pipeline without an image, e.g. to build Steps or
+// RenderOpts; its URL fails.
+func (c *Client) Transform(m *Metadata) *Pipeline {
+	p := &Pipeline{client: c}
+	if m != nil {
+		p.url = m.URL
+	}
+	return p
+}
+
+// Resize limits the rendered image to the given width and height. A
+// zero dimension is unconstrained.
+func (p *Pipeline) Resize(width, height int) *Pipeline {
+	return p.Apply(Step{Op: OpResize, Width: width, Height: height})
+}
+
+// Format converts the rendered image to the given format (see
+// Formats).
+func (p *Pipeline) Format(format string) *Pipeline {
+	return p.Apply(Step{Op: OpFormat, Format: format})
+}
+
+// Quality sets the encoding quality (1-100) of the rendered image.
+func (p *Pipeline) Quality(quality int) *Pipeline {
+	return p.Apply(Step{Op: OpQuality, Quality: quality})
+}
+
+// Sign makes the url valid for ttl from the time it's produced by
+// URL.
+func (p *Pipeline) Sign(ttl time.Duration) *Pipeline {
+	return p.Apply(Step{Op: OpSign, TTL: ttl})
+}
+
+// Apply adds the given steps to the pipeline.
+func (p *Pipeline) Apply(steps ...Step) *Pipeline {
+	for _, s := range steps {
+		if p.err != nil {
+			return p
+		}
+		p.err = p.apply(s)
+		if p.err == nil {
+			p.steps = append(p.steps, s)
+		}
+	}
+	return p
+}
+
+func (p *Pipeline) apply(s Step) error {
+	switch s.Op {
+	case OpResize:
+		if s.Width < 0 || s.Height < 0 {
+			return errors.New("ospry: resize dimensions can't be negative")
+		}
+		p.opts.MaxWidth = s.Width
+		p.opts.MaxHeight = s.Height
+	case OpFormat:
+		if !isFormat(s.Format) {
+			return errors.New("ospry: invalid format " + s.Format)
+		}
+		p.opts.Format = s.Format
+	case OpQuality:
+		if s.Quality < 1 || s.Quality > 100 {
+			return errors.New("ospry: Quality must be between 1 and 100")
+		}
+		p.opts.Quality = s.Quality
+	case OpSign:
+		if s.TTL <= 0 {
+			return errors.New("ospry: signing ttl must be positive")
+		}
+		p.ttl = s.TTL
+	default:
+		return errors.New("ospry: unknown pipeline step " + s.Op)
+	}
+	return nil
+}
+
+// Steps returns the steps that have been applied successfully. They
+// can be marshaled with encoding/json.
+func (p *Pipeline) Steps() []Step {
+	return append([]Step(nil), p.steps...)
+}
+
+// Err returns the first error encountered while applying steps.
+func (p *Pipeline) Err() error {
+	return p.err
+}
+
+// RenderOpts returns the options the pipeline's steps add up to. If
+// the pipeline is signed, TimeExpired is computed from the client's
+// clock (see Client.Now).
+func (p *Pipeline) RenderOpts() (*RenderOpts, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	opts := p.opts
+	if p.ttl > 0 {
+		opts.TimeExpired = p.client.now().Add(p.ttl)
+	}
+	return &opts, nil
+}
+
+// URL formats the image url according to the pipeline's steps.
+func (p *Pipeline) URL() (string, error) {
+	opts, err := p.RenderOpts()
+	if err != nil {
+		return "", err
+	}
+	if p.url == "" {
+		return "", errors.New("ospry: pipeline has no image url")
+	}
+	return p.client.FormatURL(p.url, opts)
+}
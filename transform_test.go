@@ -0,0 +1,88 @@
+package ospry
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestPipeline(t *testing.T) {
+	c := New("sk-test-key")
+	m := &Metadata{URL: "http://foo.ospry.io/bar/baz.png"}
+	got, err := c.Transform(m).Resize(800, 0).Format("webp").Quality(80).URL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := m.URL + "?format=webp&maxWidth=800&quality=80"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	signed, err := c.Transform(m).Resize(10, 10).Sign(10 * time.Minute).URL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(signed)
+	if u.Query().Get("signature") == "" {
+		t.Fatalf("got unsigned url %s", signed)
+	}
+	now := time.Unix(1500000000, 0)
+	c.Now = func() time.Time { return now }
+	opts, err := c.Transform(m).Sign(time.Minute).RenderOpts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := now.Add(time.Minute); !opts.TimeExpired.Equal(want) {
+		t.Fatalf("got expiry %v, want %v", opts.TimeExpired, want)
+	}
+}
+
+func TestPipelineErrors(t *testing.T) {
+	c := New("sk-test-key")
+	m := &Metadata{URL: "http://foo.ospry.io/bar/baz.png"}
+	p := c.Transform(m).Resize(10, 10).Format("bmp").Quality(80)
+	if p.Err() == nil {
+		t.Fatal("got nil, want error")
+	}
+	if len(p.Steps()) != 1 {
+		t.Fatalf("got %d steps, want 1", len(p.Steps()))
+	}
+	if _, err := p.URL(); err != p.Err() {
+		t.Fatalf("got %v, want %v", err, p.Err())
+	}
+	if c.Transform(m).Quality(101).Err() == nil {
+		t.Fatal("got nil, want error")
+	}
+	if c.Transform(m).Sign(-time.Second).Err() == nil {
+		t.Fatal("got nil, want error")
+	}
+
+	p = c.Transform(nil).Resize(10, 10)
+	if opts, err := p.RenderOpts(); err != nil || opts.MaxWidth != 10 {
+		t.Fatalf("got %+v, %v, want the options of a pipeline without an image", opts, err)
+	}
+	if _, err := p.URL(); err == nil {
+		t.Fatal("got nil, want an error for a pipeline without an image")
+	}
+}
+
+func TestPipelineSerialization(t *testing.T) {
+	c := New("sk-test-key")
+	m := &Metadata{URL: "http://foo.ospry.io/bar/baz.png"}
+	p := c.Transform(m).Resize(800, 600).Format("png").Quality(90)
+	b, err := json.Marshal(p.Steps())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var steps []Step
+	if err := json.Unmarshal(b, &steps); err != nil {
+		t.Fatal(err)
+	}
+	want, _ := p.URL()
+	got, err := c.Transform(m).Apply(steps...).URL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,123 @@
+package ospry
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ErrDialerUnsupported is returned by requests of clients with a
+// DialContext or Resolver whose HTTPClient's transport isn't an
+// *http.Transport, which can't be given a dialer.
+var ErrDialerUnsupported = errors.New("ospry: DialContext and Resolver require an *http.Transport")
+
+// NewTransport returns a new transport with the settings New uses for
+// each client. It can be tuned and installed in a client's HTTPClient,
+// e.g.:
+//
+//	t := ospry.NewTransport()
+//	t.MaxIdleConnsPerHost = 64
+//	c.HTTPClient = &http.Client{Transport: t}
+//
+// Under GOOS=js, the transport sends requests with the browser's fetch
+// api, which doesn't work with a custom dialer: a client's DialContext
+// and Resolver must be left unset there.
+func NewTransport() *http.Transport {
+	return newTransport()
+}
+
+// HTTP returns the http client the client sends its requests with, for
+// packages making requests on its behalf (e.g. ospryhttp's proxy).
+func (c *Client) HTTP() *http.Client {
+	return c.httpClient()
+}
+
+// httpClient returns the http client requests are sent with: the
+// client's HTTPClient or its own, with the transport's dialer replaced
+// if the client has a DialContext or Resolver. If the transport has no
+// dialer to replace, the requests fail with ErrDialerUnsupported
+// rather than silently bypassing DialContext.
+func (c *Client) httpClient() *http.Client {
+	base := c.baseHTTPClient()
+	if c.DialContext == nil && c.Resolver == nil {
+		return base
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.dialed != nil && c.dialedFor == base {
+		return c.dialed
+	}
+	hc := *base
+	if t, ok := base.Transport.(*http.Transport); ok {
+		t = t.Clone()
+		t.DialContext = c.dialer()
+		hc.Transport = t
+	} else {
+		hc.Transport = errTransport{ErrDialerUnsupported}
+	}
+	c.dialed, c.dialedFor = &hc, base
+	return c.dialed
+}
+
+// An errTransport fails every request with err.
+type errTransport struct {
+	err error
+}
+
+func (t errTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	return nil, t.err
+}
+
+// baseHTTPClient returns the client's HTTPClient, or if it's nil, the
+// client's own, which is created on first use.
+func (c *Client) baseHTTPClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.own == nil {
+		c.own = &http.Client{Transport: NewTransport()}
+	}
+	return c.own
+}
+
+func (c *Client) dialer() func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if c.DialContext == nil {
+		return (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			Resolver:  c.Resolver,
+		}).DialContext
+	}
+	if c.Resolver == nil {
+		return c.DialContext
+	}
+	// Resolve hostnames ourselves and hand DialContext addresses.
+	dial, resolver := c.DialContext, c.Resolver
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		if net.ParseIP(host) != nil {
+			return dial(ctx, network, addr)
+		}
+		ips, err := resolver.LookupHost(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			var conn net.Conn
+			if conn, err = dial(ctx, network, net.JoinHostPort(ip, port)); err == nil {
+				return conn, nil
+			}
+		}
+		return nil, err
+	}
+}
@@ -0,0 +1,13 @@
+//go:build js && wasm
+// +build js,wasm
+
+package ospry
+
+import "net/http"
+
+// newTransport returns a transport using the browser's fetch api,
+// which net/http only does for transports without dialers. Connections
+// are managed by the browser, so there's nothing to tune.
+func newTransport() *http.Transport {
+	return &http.Transport{}
+}
@@ -0,0 +1,92 @@
+package ospry
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestNewIsolatedTransport(t *testing.T) {
+	a, b := New("a"), New("b")
+	if a.own != nil {
+		t.Fatal("client created its transport before it was used")
+	}
+	if a.HTTP() == http.DefaultClient || a.HTTP().Transport == http.DefaultTransport {
+		t.Fatal("client uses the default http client")
+	}
+	if a.HTTP() != a.HTTP() {
+		t.Fatal("client created more than one http client")
+	}
+	if a.HTTP().Transport == b.HTTP().Transport {
+		t.Fatal("clients share a transport")
+	}
+}
+
+func TestDialContext(t *testing.T) {
+	var srvAddr string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeMetadata(w, &Metadata{ID: "foo"})
+	})
+	srvAddr = strings.TrimPrefix(strings.TrimSuffix(c.ServerURL, "/v1"), "http://")
+	c.ServerURL = "http://api.ospry.test/v1"
+	var dialed []string
+	c.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialed = append(dialed, addr)
+		return net.Dial(network, srvAddr)
+	}
+	if _, err := c.GetMetadata("foo"); err != nil {
+		t.Fatal(err)
+	}
+	if len(dialed) != 1 || dialed[0] != "api.ospry.test:80" {
+		t.Fatalf("got dials %q, want api.ospry.test:80", dialed)
+	}
+
+	// With a resolver, DialContext gets addresses.
+	c = New("sk-test-key")
+	c.ServerURL = "http://api.ospry.test/v1"
+	c.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialed = append(dialed, addr)
+		return net.Dial(network, srvAddr)
+	}
+	c.Resolver = &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return nil, errors.New("no dns")
+		},
+	}
+	dialed = nil
+	if _, err := c.GetMetadata("foo"); err == nil || !strings.Contains(err.Error(), "no dns") {
+		t.Fatalf("got %v, want resolver error", err)
+	}
+	if len(dialed) != 0 {
+		t.Fatalf("got dials %q, want none", dialed)
+	}
+	c.ServerURL = "http://" + srvAddr + "/v1"
+	if _, err := c.GetMetadata("foo"); err != nil {
+		t.Fatal(err)
+	}
+	if len(dialed) != 1 || dialed[0] != srvAddr {
+		t.Fatalf("got dials %q, want %s", dialed, srvAddr)
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestDialContextUnsupported(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeMetadata(w, &Metadata{ID: "foo"})
+	})
+	base := c.HTTPClient.Transport
+	c.HTTPClient = &http.Client{Transport: roundTripFunc(base.RoundTrip)}
+	c.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return nil, errors.New("not dialed")
+	}
+	if _, err := c.GetMetadata("foo"); !errors.Is(err, ErrDialerUnsupported) {
+		t.Fatalf("got %v, want %v", err, ErrDialerUnsupported)
+	}
+}
@@ -0,0 +1,248 @@
+package ospry
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// An UploadItem is an image to upload with UploadMany.
+type UploadItem struct {
+	Filename string
+	Data     io.Reader
+	// Opts are the image's upload options. Nil uploads a public
+	// image.
+	Opts *UploadOpts
+}
+
+// UploadMany calls UploadMany on the default client.
+func UploadMany(items []UploadItem) ([]BatchResult[*Metadata], error) {
+	return DefaultClient.UploadMany(items)
+}
+
+// UploadMany uploads several images in a single multipart request,
+// saving the per-request overhead of bursts of small uploads, e.g.
+// from batch jobs. The images are read into memory first. Results are
+// in the same order as items, keyed by filename; if some uploads
+// failed, the error is a *BatchError. Other errors mean the request as
+// a whole failed.
+//
+// If the server doesn't accept multipart uploads, or the client has
+// SanitizeFilenames or ValidateUploads set, the images are uploaded
+// individually instead, BatchConcurrency at a time. Progress is
+// reported for each image either way, though images whose multipart
+// upload was turned down are started again.
+func (c *Client) UploadMany(items []UploadItem) ([]BatchResult[*Metadata], error) {
+	if len(items) == 0 {
+		return nil, nil
+	}
+	urlstr := c.apiURL("/images/multi")
+	fail := func(err error) ([]BatchResult[*Metadata], error) {
+		for range items {
+			c.audit(OpUpload, "", err)
+		}
+		return nil, opError("ospry.UploadMany", "", urlstr, err)
+	}
+	if err := c.checkWrite(); err != nil {
+		return fail(err)
+	}
+	data := make([][]byte, len(items))
+	for i, item := range items {
+		b, err := ioutil.ReadAll(item.Data)
+		if err != nil {
+			return fail(err)
+		}
+		data[i] = b
+	}
+	c.mu.Lock()
+	single := c.noMultiUpload || c.SanitizeFilenames || c.ValidateUploads
+	c.mu.Unlock()
+	if !single {
+		p := c.Progress
+		if p != nil {
+			for i, item := range items {
+				p.Start(item.Filename, int64(len(data[i])))
+			}
+		}
+		results, err := c.sendMultiUpload(items, data)
+		if err == nil {
+			for i, r := range results {
+				var id string
+				if r.Value != nil {
+					id = r.Value.ID
+				}
+				results[i].Err = opError("ospry.UploadMany", id, urlstr, r.Err)
+				c.audit(OpUpload, id, results[i].Err)
+				if p != nil {
+					p.Done(r.Item, results[i].Err)
+				}
+				if r.Err == nil {
+					c.prewarmNew(r.Value)
+				}
+			}
+			return results, batchErr(results)
+		}
+		if err != errMultiUploadUnsupported {
+			if p != nil {
+				for _, item := range items {
+					p.Done(item.Filename, err)
+				}
+			}
+			return fail(err)
+		}
+		c.mu.Lock()
+		c.noMultiUpload = true
+		c.mu.Unlock()
+	}
+	results := c.uploadEach(items, data)
+	return results, batchErr(results)
+}
+
+var errMultiUploadUnsupported = errors.New("ospry: multipart uploads not supported")
+
+// sendMultiUpload posts the images as the parts of a multipart body,
+// retrying as uploads do. Each part carries its image's upload options
+// as a query string in an Ospry-Upload-Options header.
+func (c *Client) sendMultiUpload(items []UploadItem, data [][]byte) ([]BatchResult[*Metadata], error) {
+	// The framing before each image is kept apart from the images, so
+	// that progress can be reported on each as it's sent.
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	heads := make([][]byte, len(items))
+	size := int64(0)
+	for i, item := range items {
+		opts := item.Opts
+		if opts == nil {
+			opts = &UploadOpts{}
+		}
+		filename := strings.ToValidUTF8(item.Filename, "\uFFFD")
+		q := url.Values{}
+		q.Add("isPrivate", strconv.FormatBool(opts.IsPrivate))
+		addTags(q, opts.Tags)
+		h := textproto.MIMEHeader{}
+		h.Set("Content-Disposition", `form-data; name="image"; filename*=UTF-8''`+url.PathEscape(filename))
+		h.Set("Content-Type", "image/jpeg")
+		h.Set("Ospry-Upload-Options", q.Encode())
+		if _, err := mw.CreatePart(h); err != nil {
+			return nil, err
+		}
+		heads[i] = append([]byte(nil), buf.Bytes()...)
+		buf.Reset()
+		size += int64(len(heads[i]) + len(data[i]))
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	tail := buf.Bytes()
+	size += int64(len(tail))
+	body := func(report bool) io.Reader {
+		rs := make([]io.Reader, 0, 2*len(items)+1)
+		for i, item := range items {
+			var r io.Reader = bytes.NewReader(data[i])
+			if report {
+				r = c.reportProgress(item.Filename, r)
+			}
+			rs = append(rs, bytes.NewReader(heads[i]), r)
+		}
+		return io.MultiReader(append(rs, bytes.NewReader(tail))...)
+	}
+
+	defer c.acquireUpload()()
+	u, err := url.Parse(c.ServerURL)
+	if err != nil {
+		return nil, err
+	}
+	u.Path += "/images/multi"
+	start := time.Now()
+	var res *http.Response
+	attempts := 0
+	for {
+		attempts++
+		// Retries don't report progress again, since the bytes were
+		// already counted.
+		req, err := c.newRequest("POST", u.String(), mw.FormDataContentType(), c.throttle(body(attempts == 1)))
+		if err != nil {
+			return nil, err
+		}
+		req.ContentLength = size
+		var retry bool
+		res, err = c.do(req)
+		if err != nil {
+			_, retry = err.(*url.Error)
+		} else if res.StatusCode >= 500 || res.StatusCode == http.StatusTooManyRequests {
+			res.Body.Close()
+			retry = true
+			err = &Error{HTTPStatusCode: res.StatusCode, Message: "upload failed: " + res.Status}
+		}
+		if err == nil {
+			break
+		}
+		if !retry || attempts > c.UploadRetries {
+			return nil, err
+		}
+		time.Sleep(retryDelay(attempts - 1))
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented, http.StatusUnsupportedMediaType:
+		return nil, errMultiUploadUnsupported
+	}
+	var resp struct {
+		Results []struct {
+			Metadata *Metadata `json:"metadata"`
+			Error    *Error    `json:"error"`
+		} `json:"results"`
+		Error *Error `json:"error"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+	if len(resp.Results) != len(items) {
+		return nil, errors.New("ospry: multipart upload response doesn't match request")
+	}
+	d := time.Since(start)
+	results := make([]BatchResult[*Metadata], len(items))
+	for i, r := range resp.Results {
+		results[i] = BatchResult[*Metadata]{Item: items[i].Filename, Attempts: attempts, Duration: d}
+		if r.Error != nil {
+			results[i].Err = r.Error
+			continue
+		}
+		if r.Metadata == nil {
+			results[i].Err = errors.New("ospry: multipart upload response has no metadata")
+			continue
+		}
+		c.checkInvariants(res, r.Metadata)
+		if err := c.checkFields(r.Metadata); err != nil {
+			results[i].Err = err
+			continue
+		}
+		if err := c.normalizeMetadata(r.Metadata); err != nil {
+			results[i].Err = err
+			continue
+		}
+		results[i].Value = r.Metadata
+	}
+	return results, nil
+}
+
+// uploadEach uploads the images as individual requests.
+func (c *Client) uploadEach(items []UploadItem, data [][]byte) []BatchResult[*Metadata] {
+	return fanOut(c.batchConcurrency(), len(items),
+		func(i int) string { return items[i].Filename },
+		func(i int) (*Metadata, error) {
+			return c.Upload(items[i].Filename, bytes.NewReader(data[i]), items[i].Opts)
+		})
+}
@@ -0,0 +1,127 @@
+package ospry
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestUploadMany(t *testing.T) {
+	var requests int
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/v1/images/multi" {
+			t.Errorf("got %s, want /v1/images/multi", r.URL.Path)
+		}
+		_, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		mr := multipart.NewReader(r.Body, params["boundary"])
+		var results []interface{}
+		for {
+			p, err := mr.NextPart()
+			if err != nil {
+				break
+			}
+			b, _ := ioutil.ReadAll(p)
+			opts, _ := url.ParseQuery(p.Header.Get("Ospry-Upload-Options"))
+			if string(b) == "bad" {
+				results = append(results, map[string]interface{}{"error": &Error{HTTPStatusCode: 400, Message: "not an image"}})
+				continue
+			}
+			results = append(results, map[string]interface{}{"metadata": &Metadata{
+				ID: string(b), URL: "http://foo.ospry.io/" + p.FileName(), Filename: p.FileName(), IsPrivate: opts.Get("isPrivate") == "true",
+			}})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"results": results})
+	})
+	results, err := c.UploadMany([]UploadItem{
+		{Filename: "a.jpg", Data: strings.NewReader("img-a")},
+		{Filename: "b.jpg", Data: strings.NewReader("bad")},
+		{Filename: "c.jpg", Data: strings.NewReader("img-c"), Opts: &UploadOpts{IsPrivate: true}},
+	})
+	var berr *BatchError
+	if !errors.As(err, &berr) || len(berr.Errs) != 1 {
+		t.Fatalf("got %v, want b.jpg failed", err)
+	}
+	if requests != 1 {
+		t.Fatalf("got %d requests, want 1", requests)
+	}
+	if results[0].Value.ID != "img-a" || results[0].Value.Filename != "a.jpg" || results[1].Err == nil || !results[2].Value.IsPrivate {
+		t.Fatalf("got %+v, want a, a failure and a private c", results)
+	}
+}
+
+func TestUploadManyRetry(t *testing.T) {
+	retryBackoff = time.Millisecond
+	defer func() { retryBackoff = 250 * time.Millisecond }()
+	var requests int
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		ioutil.ReadAll(r.Body)
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"results": []interface{}{
+			map[string]interface{}{"metadata": &Metadata{ID: "a", URL: "http://foo.ospry.io/a.jpg"}},
+			map[string]interface{}{"error": &Error{HTTPStatusCode: 400, Message: "not an image"}},
+		}})
+	})
+	c.UploadRetries = 1
+	p := &recordingProgress{}
+	c.Progress = p
+	results, err := c.UploadMany([]UploadItem{
+		{Filename: "a.jpg", Data: strings.NewReader("img-a")},
+		{Filename: "b.jpg", Data: strings.NewReader("bad")},
+	})
+	if err == nil || requests != 2 || results[0].Attempts != 2 {
+		t.Fatalf("got %v after %d requests, want a retry and b.jpg failed", err, requests)
+	}
+	var oe *OpError
+	if !errors.As(results[1].Err, &oe) || oe.Op != "ospry.UploadMany" {
+		t.Fatalf("got %#v, want an *OpError", results[1].Err)
+	}
+	want := "start a.jpg 5; start b.jpg 3; progress a.jpg; progress b.jpg; done a.jpg <nil>; done b.jpg " + results[1].Err.Error()
+	if got := p.summary(); got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestUploadManyFallback(t *testing.T) {
+	var mu sync.Mutex
+	var paths []string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		if r.URL.Path == "/v1/images/multi" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		writeMetadata(w, &Metadata{ID: string(b), URL: "http://foo.ospry.io/x.jpg"})
+	})
+	items := []UploadItem{{Filename: "a.jpg", Data: strings.NewReader("img-a")}, {Filename: "b.jpg", Data: strings.NewReader("img-b")}}
+	results, err := c.UploadMany(items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Value.ID != "img-a" || results[1].Value.ID != "img-b" {
+		t.Fatalf("got %+v, want both uploaded individually", results)
+	}
+	paths = nil
+	items = []UploadItem{{Filename: "c.jpg", Data: strings.NewReader("img-c")}}
+	if _, err := c.UploadMany(items); err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 1 || paths[0] != "/v1/images" {
+		t.Fatalf("got %v, want multipart uploads no longer tried", paths)
+	}
+}
@@ -0,0 +1,204 @@
+package ospry
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// A URLError reports a malformed image or render url.
+type URLError struct {
+	// URL is the url being processed.
+	URL string
+	// Param is the query parameter at fault, if any.
+	Param string
+	Err   error
+}
+
+func (e *URLError) Error() string {
+	msg := "ospry: invalid url"
+	if e.Param != "" {
+		msg += " parameter " + e.Param
+	}
+	if e.URL != "" {
+		msg += " in " + e.URL
+	}
+	return msg + ": " + e.Err.Error()
+}
+
+func (e *URLError) Unwrap() error {
+	return e.Err
+}
+
+// withURL sets the url of a URLError that doesn't have one yet.
+func withURL(err error, urlstr string) error {
+	if e, ok := err.(*URLError); ok && e.URL == "" {
+		e.URL = urlstr
+	}
+	return err
+}
+
+// canonicalURL parses an image url into the form it's signed in.
+// Absolute urls that were percent-encoded once too often are decoded,
+// and internationalized host names are converted to their ASCII
+// (punycode) form, so that formatting a url is stable: the canonical
+// form of a canonical url is itself. Relative urls are kept as they
+// are.
+func canonicalURL(s string) (*url.URL, error) {
+	if !strings.Contains(s, "://") {
+		unescaped := s
+		for i := 0; i < 2; i++ {
+			next, err := url.QueryUnescape(unescaped)
+			if err != nil || next == unescaped {
+				break
+			}
+			unescaped = next
+			if strings.Contains(unescaped, "://") {
+				s = unescaped
+				break
+			}
+		}
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, &URLError{URL: s, Err: err}
+	}
+	if u.Host == "" {
+		return u, nil
+	}
+	host, err := hostToASCII(u.Hostname())
+	if err != nil {
+		return nil, &URLError{URL: s, Err: err}
+	}
+	if port := u.Port(); port != "" {
+		host = net.JoinHostPort(host, port)
+	}
+	u.Host = host
+	return u, nil
+}
+
+// hostToASCII converts an internationalized host name to ASCII,
+// encoding non-ASCII labels with punycode (RFC 3492).
+func hostToASCII(host string) (string, error) {
+	if isASCII(host) {
+		return host, nil
+	}
+	labels := strings.Split(host, ".")
+	for i, label := range labels {
+		if isASCII(label) {
+			continue
+		}
+		if !utf8.ValidString(label) {
+			return "", errors.New("invalid host " + host)
+		}
+		encoded, err := punycode(strings.ToLower(label))
+		if err != nil {
+			return "", err
+		}
+		labels[i] = "xn--" + encoded
+	}
+	return strings.Join(labels, "."), nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// Punycode parameters (RFC 3492 section 5).
+const (
+	pcBase        = 36
+	pcTMin        = 1
+	pcTMax        = 26
+	pcSkew        = 38
+	pcDamp        = 700
+	pcInitialBias = 72
+	pcInitialN    = 128
+)
+
+// punycode encodes s as described in RFC 3492 section 6.3.
+func punycode(s string) (string, error) {
+	runes := []rune(s)
+	var out []byte
+	for _, r := range runes {
+		if r < utf8.RuneSelf {
+			out = append(out, byte(r))
+		}
+	}
+	b := len(out)
+	h := b
+	if b > 0 {
+		out = append(out, '-')
+	}
+	n, delta, bias := rune(pcInitialN), 0, pcInitialBias
+	for h < len(runes) {
+		m := rune(utf8.MaxRune + 1)
+		for _, r := range runes {
+			if r >= n && r < m {
+				m = r
+			}
+		}
+		if int(m-n) > (1<<31-1-delta)/(h+1) {
+			return "", errors.New("punycode overflow")
+		}
+		delta += int(m-n) * (h + 1)
+		n = m
+		for _, r := range runes {
+			if r < n {
+				delta++
+			}
+			if r != n {
+				continue
+			}
+			q := delta
+			for k := pcBase; ; k += pcBase {
+				t := k - bias
+				if t < pcTMin {
+					t = pcTMin
+				} else if t > pcTMax {
+					t = pcTMax
+				}
+				if q < t {
+					break
+				}
+				out = append(out, punycodeDigit(t+(q-t)%(pcBase-t)))
+				q = (q - t) / (pcBase - t)
+			}
+			out = append(out, punycodeDigit(q))
+			bias = punycodeAdapt(delta, h+1, h == b)
+			delta = 0
+			h++
+		}
+		delta++
+		n++
+	}
+	return string(out), nil
+}
+
+func punycodeDigit(d int) byte {
+	if d < 26 {
+		return byte('a' + d)
+	}
+	return byte('0' + d - 26)
+}
+
+func punycodeAdapt(delta, numPoints int, first bool) int {
+	if first {
+		delta /= pcDamp
+	} else {
+		delta /= 2
+	}
+	delta += delta / numPoints
+	k := 0
+	for delta > ((pcBase-pcTMin)*pcTMax)/2 {
+		delta /= pcBase - pcTMin
+		k += pcBase
+	}
+	return k + (pcBase-pcTMin+1)*delta/(delta+pcSkew)
+}
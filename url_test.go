@@ -0,0 +1,99 @@
+package ospry
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestPunycode(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"bücher", "bcher-kva"},
+		{"münchen", "mnchen-3ya"},
+		{"ü", "tda"},
+		{"例え", "r8jz45g"},
+	}
+	for _, test := range tests {
+		got, err := punycode(test.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Fatalf("%s: got %s, want %s", test.in, got, test.want)
+		}
+	}
+}
+
+func TestFormatURLCanonical(t *testing.T) {
+	c := New("sk-test-key")
+	exp := time.Now().Add(time.Minute)
+	tests := []struct{ in, wantImg string }{
+		{"http://bücher.example.com/a.png", "http://xn--bcher-kva.example.com/a.png"},
+		{"http://foo.ospry.io/a%20b.png", "http://foo.ospry.io/a%20b.png"},
+		{"http://foo.ospry.io/a b.png", "http://foo.ospry.io/a%20b.png"},
+		{"https://api.ospry.io/?url=" + url.QueryEscape(url.QueryEscape("http://foo.ospry.io/a.png")) + "&timeExpired=" +
+			url.QueryEscape(exp.Format(time.RFC3339Nano)), "http://foo.ospry.io/a.png"},
+	}
+	for _, test := range tests {
+		signed, err := c.FormatURL(test.in, &RenderOpts{TimeExpired: exp})
+		if err != nil {
+			t.Fatal(err)
+		}
+		u, _ := url.Parse(signed)
+		if got := u.Query().Get("url"); got != test.wantImg {
+			t.Fatalf("%s: got %s, want %s", test.in, got, test.wantImg)
+		}
+		// Formatting is stable.
+		again, err := c.FormatURL(signed, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if again != signed {
+			t.Fatalf("got %s, want %s", again, signed)
+		}
+	}
+}
+
+func TestFormatURLRelative(t *testing.T) {
+	c := New("sk-test-key")
+	for in, want := range map[string]string{
+		"/a%20b.png":               "/a%20b.png?maxWidth=10",
+		"images/a.png?maxHeight=5": "images/a.png?maxHeight=5&maxWidth=10",
+	} {
+		got, err := c.FormatURL(in, &RenderOpts{MaxWidth: 10})
+		if err != nil {
+			t.Fatalf("%s: %v", in, err)
+		}
+		if got != want {
+			t.Fatalf("%s: got %s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestFormatURLErrors(t *testing.T) {
+	c := New("sk-test-key")
+	tests := []struct {
+		in    string
+		param string
+	}{
+		{"http://foo.ospry.io/a.png?maxWidth=ten", "maxWidth"},
+		{"http://foo.ospry.io/a.png?timeExpired=soon", "timeExpired"},
+		{"http://foo.ospry.io/a.png?maxWidth=1;x", ""},
+	}
+	for _, test := range tests {
+		_, err := c.FormatURL(test.in, nil)
+		var e *URLError
+		if !errors.As(err, &e) {
+			t.Fatalf("%s: got %v, want *URLError", test.in, err)
+		}
+		if e.Param != test.param {
+			t.Fatalf("%s: got param %q, want %q", test.in, e.Param, test.param)
+		}
+	}
+	_, err := c.FormatURL("http://foo.ospry.io/a.png?maxHeight=x", nil)
+	if !errors.Is(err, strconv.ErrSyntax) {
+		t.Fatalf("got %v, want wrapped %v", err, strconv.ErrSyntax)
+	}
+}
@@ -0,0 +1,170 @@
+// Package usage reports what an account's image storage is made of:
+// image counts and bytes by format, privacy and age.
+package usage
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	ospry "github.com/ospry/ospry-go"
+)
+
+// A Bucket counts images and their bytes.
+type Bucket struct {
+	Count int64 `json:"count"`
+	Bytes int64 `json:"bytes"`
+}
+
+func (b *Bucket) add(m *ospry.Metadata) {
+	b.Count++
+	b.Bytes += m.Size
+}
+
+// An AgeBucket groups images younger than Max. The last bucket of a
+// list should have a zero Max, which holds all older images.
+type AgeBucket struct {
+	Label string
+	Max   time.Duration
+}
+
+// DefaultAgeBuckets are the age buckets used by reports without their
+// own.
+var DefaultAgeBuckets = []AgeBucket{
+	{"<1d", 24 * time.Hour},
+	{"<7d", 7 * 24 * time.Hour},
+	{"<30d", 30 * 24 * time.Hour},
+	{"<90d", 90 * 24 * time.Hour},
+	{"<1y", 365 * 24 * time.Hour},
+	{">=1y", 0},
+}
+
+// A Report aggregates images' counts and bytes.
+type Report struct {
+	Total     Bucket             `json:"total"`
+	ByFormat  map[string]*Bucket `json:"byFormat"`
+	ByPrivacy map[string]*Bucket `json:"byPrivacy"`
+	ByAge     map[string]*Bucket `json:"byAge"`
+
+	// AgeBuckets are the buckets ByAge is keyed by. If it's nil,
+	// DefaultAgeBuckets is used.
+	AgeBuckets []AgeBucket `json:"-"`
+	// Now is the time ages are measured from. If it's zero, the time
+	// of the first Add is used.
+	Now time.Time `json:"now"`
+}
+
+// Collect walks every image matching filter and returns a report of
+// them. A nil filter reports on the whole account.
+func Collect(ctx context.Context, c *ospry.Client, filter *ospry.ListFilter) (*Report, error) {
+	r := &Report{}
+	images, errc := c.ListAll(ctx, filter)
+	for m := range images {
+		r.Add(m)
+	}
+	return r, <-errc
+}
+
+// Add adds an image to the report.
+func (r *Report) Add(m *ospry.Metadata) {
+	if r.ByFormat == nil {
+		r.ByFormat = make(map[string]*Bucket)
+		r.ByPrivacy = make(map[string]*Bucket)
+		r.ByAge = make(map[string]*Bucket)
+	}
+	if r.Now.IsZero() {
+		r.Now = time.Now()
+	}
+	r.Total.add(m)
+	bucket(r.ByFormat, m.Format).add(m)
+	privacy := "public"
+	if m.IsPrivate {
+		privacy = "private"
+	}
+	bucket(r.ByPrivacy, privacy).add(m)
+	bucket(r.ByAge, r.ageLabel(m.TimeCreated)).add(m)
+}
+
+func (r *Report) ageLabel(created time.Time) string {
+	buckets := r.AgeBuckets
+	if buckets == nil {
+		buckets = DefaultAgeBuckets
+	}
+	age := r.Now.Sub(created)
+	for _, b := range buckets {
+		if b.Max == 0 || age < b.Max {
+			return b.Label
+		}
+	}
+	return buckets[len(buckets)-1].Label
+}
+
+func bucket(m map[string]*Bucket, key string) *Bucket {
+	b := m[key]
+	if b == nil {
+		b = &Bucket{}
+		m[key] = b
+	}
+	return b
+}
+
+// Cost estimates the monthly storage cost of the reported images at
+// the given price per GB (10^9 bytes) per month.
+func (r *Report) Cost(perGBMonth float64) float64 {
+	return float64(r.Total.Bytes) / 1e9 * perGBMonth
+}
+
+// WriteJSON writes the report to w as JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteCSV writes the report to w as CSV rows of dimension, key, count
+// and bytes, starting with the total.
+func (r *Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"dimension", "key", "count", "bytes"})
+	row := func(dim, key string, b *Bucket) {
+		cw.Write([]string{dim, key, strconv.FormatInt(b.Count, 10), strconv.FormatInt(b.Bytes, 10)})
+	}
+	row("total", "", &r.Total)
+	for _, dim := range []struct {
+		name    string
+		buckets map[string]*Bucket
+	}{{"format", r.ByFormat}, {"privacy", r.ByPrivacy}, {"age", r.ByAge}} {
+		for _, k := range r.keys(dim.name, dim.buckets) {
+			row(dim.name, k, dim.buckets[k])
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// keys returns the keys of buckets, with ages in bucket order and
+// other dimensions sorted.
+func (r *Report) keys(dim string, buckets map[string]*Bucket) []string {
+	var keys []string
+	if dim == "age" {
+		ages := r.AgeBuckets
+		if ages == nil {
+			ages = DefaultAgeBuckets
+		}
+		for _, b := range ages {
+			if buckets[b.Label] != nil {
+				keys = append(keys, b.Label)
+			}
+		}
+		return keys
+	}
+	for k := range buckets {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,76 @@
+package usage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	ospry "github.com/ospry/ospry-go"
+)
+
+func TestCollect(t *testing.T) {
+	now := time.Now()
+	images := []*ospry.Metadata{
+		{ID: "a", Format: "jpeg", Size: 100, TimeCreated: now.Add(-time.Hour)},
+		{ID: "b", Format: "jpeg", Size: 200, IsPrivate: true, TimeCreated: now.Add(-10 * 24 * time.Hour)},
+		{ID: "c", Format: "png", Size: 1000, TimeCreated: now.Add(-400 * 24 * time.Hour)},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"images": images})
+	}))
+	defer srv.Close()
+	c := ospry.New("sk-test-key")
+	c.ServerURL = srv.URL + "/v1"
+
+	r, err := Collect(context.Background(), c, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Total != (Bucket{3, 1300}) {
+		t.Fatalf("got total %+v, want 3 images, 1300 bytes", r.Total)
+	}
+	if *r.ByFormat["jpeg"] != (Bucket{2, 300}) || *r.ByPrivacy["private"] != (Bucket{1, 200}) {
+		t.Fatalf("got %+v, %+v", r.ByFormat["jpeg"], r.ByPrivacy["private"])
+	}
+	if r.ByAge["<1d"].Count != 1 || r.ByAge["<30d"].Count != 1 || r.ByAge[">=1y"].Count != 1 {
+		t.Fatalf("got ages %+v", r.ByAge)
+	}
+	if cost := r.Cost(0.02); math.Abs(cost-1300/1e9*0.02) > 1e-15 {
+		t.Fatalf("got cost %v", cost)
+	}
+
+	var buf bytes.Buffer
+	if err := r.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `dimension,key,count,bytes
+total,,3,1300
+format,jpeg,2,300
+format,png,1,1000
+privacy,private,1,200
+privacy,public,2,1100
+age,<1d,1,100
+age,<30d,1,200
+age,>=1y,1,1000
+`
+	if buf.String() != want {
+		t.Fatalf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := r.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var decoded Report
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Total != r.Total || *decoded.ByAge[">=1y"] != *r.ByAge[">=1y"] {
+		t.Fatalf("got %+v after round trip", decoded)
+	}
+}
@@ -0,0 +1,61 @@
+package ospry
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// VersionToken returns the token identifying the current data of the
+// image described by m: its VersionID, or if the api didn't send one,
+// a hash of the id and the time the data was last modified, along with
+// its size, format and dimensions.
+func VersionToken(m *Metadata) string {
+	if m.VersionID != "" {
+		return m.VersionID
+	}
+	modified := m.TimeCreated
+	if m.TimeModified.After(modified) {
+		modified = m.TimeModified
+	}
+	h := sha256.Sum256([]byte(strings.Join([]string{
+		m.ID,
+		modified.UTC().Format(time.RFC3339Nano),
+		strconv.FormatInt(m.Size, 10),
+		m.Format,
+		strconv.Itoa(m.Width) + "x" + strconv.Itoa(m.Height),
+	}, "\x00")))
+	return hex.EncodeToString(h[:6])
+}
+
+// BustURL replaces the version token of a url formatted with
+// VersionedURLs by the current token of the image described by m, e.g.
+// after Replace, so that caches fetch the new data. Signatures stay
+// valid, since they don't cover the token.
+func BustURL(urlstr string, m *Metadata) (string, error) {
+	u, err := url.Parse(urlstr)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set("v", VersionToken(m))
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// versioned returns opts with m's version token if the client uses
+// versioned urls.
+func (c *Client) versioned(m *Metadata, opts *RenderOpts) *RenderOpts {
+	if !c.VersionedURLs {
+		return opts
+	}
+	o := RenderOpts{}
+	if opts != nil {
+		o = *opts
+	}
+	o.Version = VersionToken(m)
+	return &o
+}
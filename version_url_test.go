@@ -0,0 +1,42 @@
+package ospry
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestVersionedURLs(t *testing.T) {
+	c := New("sk-test-key")
+	c.VersionedURLs = true
+	m := &Metadata{ID: "foo", URL: "http://foo.ospry.io/bar.jpg", VersionID: "v1", IsPrivate: true}
+	signed, err := c.FormatMetaURL(m, &RenderOpts{TimeExpired: time.Now().Add(time.Hour), MaxWidth: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(signed)
+	if got := u.Query().Get("v"); got != "v1" {
+		t.Fatalf("got v=%s, want v1", got)
+	}
+
+	m.VersionID = "v2"
+	busted, err := BustURL(signed, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ = url.Parse(busted)
+	if got := u.Query().Get("v"); got != "v2" {
+		t.Fatalf("got v=%s, want v2", got)
+	}
+	if err := VerifySignature(busted, "sk-test-key"); err != nil {
+		t.Fatalf("got %v, want the signature to stay valid", err)
+	}
+
+	a := VersionToken(&Metadata{ID: "foo", Size: 1})
+	if b := VersionToken(&Metadata{ID: "foo", Size: 2}); a == b || len(a) != 12 {
+		t.Fatalf("got tokens %s, %s, want different 12 character tokens", a, b)
+	}
+	if b := VersionToken(&Metadata{ID: "foo", Size: 1, TimeModified: time.Unix(1, 0)}); a == b {
+		t.Fatalf("got token %s for a replaced image, want a new one", b)
+	}
+}
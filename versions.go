@@ -0,0 +1,105 @@
+package ospry
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+)
+
+// Replace calls Replace on the default client.
+func Replace(id string, data io.Reader) (*Metadata, error) {
+	return DefaultClient.Replace(id, data)
+}
+
+// Versions calls Versions on the default client.
+func Versions(id string) ([]*Metadata, error) {
+	return DefaultClient.Versions(id)
+}
+
+// Revert calls Revert on the default client.
+func Revert(id, versionID string) (*Metadata, error) {
+	return DefaultClient.Revert(id, versionID)
+}
+
+// Replace replaces an image's data in place, keeping its id, url and
+// privacy. The previous data is kept as a version (see Versions). The
+// data goes through the same checks, retries and progress reports as
+// an Upload. Renders of the old data may still be served from caches,
+// including the client's Cache, until they expire; VersionedURLs
+// avoids that.
+func (c *Client) Replace(id string, data io.Reader) (*Metadata, error) {
+	m, err := c.replace(id, data)
+	c.audit(OpReplace, id, err)
+	return m, opError("ospry.Replace", id, c.apiURL("/images/"+id+"/data"), err)
+}
+
+func (c *Client) replace(id string, data io.Reader) (*Metadata, error) {
+	m, err := c.sendImage("PUT", "/images/"+id+"/data", id, "", data, &UploadOpts{}, nil)
+	if err != nil {
+		// The data may have been replaced anyway.
+		c.uncacheMetadata(id)
+		return nil, err
+	}
+	c.cacheMetadata(m)
+	return m, nil
+}
+
+// Versions returns the image's versions, newest (the current one)
+// first. Each has the metadata the image had while it was current.
+func (c *Client) Versions(id string) ([]*Metadata, error) {
+	versions, err := c.versions(id)
+	return versions, opError("ospry.Versions", id, c.apiURL("/images/"+id+"/versions"), err)
+}
+
+func (c *Client) versions(id string) ([]*Metadata, error) {
+	u, err := url.Parse(c.ServerURL)
+	if err != nil {
+		return nil, err
+	}
+	u.Path += "/images/" + id + "/versions"
+	res, err := c.curl("GET", u.String(), "application/json", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	var body struct {
+		Versions []*Metadata `json:"versions"`
+		Error    *Error      `json:"error"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	if body.Error != nil {
+		return nil, body.Error
+	}
+	for _, m := range body.Versions {
+		c.checkInvariants(res, m)
+		if err := c.checkFields(m); err != nil {
+			return nil, err
+		}
+		if err := c.normalizeMetadata(m); err != nil {
+			return nil, err
+		}
+	}
+	return body.Versions, nil
+}
+
+// Revert makes a previous version of an image current again, e.g. to
+// undo a Replace. The version replaced by the revert is kept too.
+func (c *Client) Revert(id, versionID string) (*Metadata, error) {
+	m, err := c.revert(id, versionID)
+	c.audit(OpRevert, id, err)
+	return m, opError("ospry.Revert", id, c.apiURL("/images/"+id+"/versions/"+versionID+"/revert"), err)
+}
+
+func (c *Client) revert(id, versionID string) (*Metadata, error) {
+	if err := c.checkWrite(); err != nil {
+		return nil, err
+	}
+	m, err := c.sendJSON("POST", "/images/"+id+"/versions/"+url.PathEscape(versionID)+"/revert", struct{}{})
+	if err != nil {
+		return nil, err
+	}
+	c.cacheMetadata(m)
+	return m, nil
+}
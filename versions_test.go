@@ -0,0 +1,54 @@
+package ospry
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestVersions(t *testing.T) {
+	versions := []*Metadata{{ID: "foo", VersionID: "v1"}}
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "PUT /v1/images/foo/data":
+			b, _ := ioutil.ReadAll(r.Body)
+			if string(b) != "new data" {
+				t.Errorf("got %q, want new data", b)
+			}
+			versions = append([]*Metadata{{ID: "foo", VersionID: "v2"}}, versions...)
+			writeMetadata(w, versions[0])
+		case "GET /v1/images/foo/versions":
+			json.NewEncoder(w).Encode(map[string]interface{}{"versions": versions})
+		case "POST /v1/images/foo/versions/v1/revert":
+			versions = append([]*Metadata{{ID: "foo", VersionID: "v3"}}, versions...)
+			writeMetadata(w, versions[0])
+		default:
+			t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+		}
+	})
+	var ops []string
+	c.Audit = func(e *AuditEvent) { ops = append(ops, e.Op) }
+
+	m, err := c.Replace("foo", strings.NewReader("new data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.VersionID != "v2" {
+		t.Fatalf("got version %s, want v2", m.VersionID)
+	}
+	vs, err := c.Versions("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vs) != 2 || vs[1].VersionID != "v1" {
+		t.Fatalf("got %d versions, want v2, v1", len(vs))
+	}
+	if m, err = c.Revert("foo", "v1"); err != nil || m.VersionID != "v3" {
+		t.Fatalf("got %v, %v, want version v3", m, err)
+	}
+	if got, want := strings.Join(ops, ","), "replace,revert"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,134 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	ospry "github.com/ospry/ospry-go"
+)
+
+// A Policy decides whether an uploaded image may be claimed. It
+// returns an error describing why not.
+type Policy func(m *ospry.Metadata) error
+
+// MaxSize returns a Policy that rejects images larger than n bytes.
+func MaxSize(n int64) Policy {
+	return func(m *ospry.Metadata) error {
+		if m.Size > n {
+			return fmt.Errorf("image is %d bytes, limit is %d", m.Size, n)
+		}
+		return nil
+	}
+}
+
+// Formats returns a Policy that rejects images in other formats.
+func Formats(formats ...string) Policy {
+	return func(m *ospry.Metadata) error {
+		for _, f := range formats {
+			if m.Format == f {
+				return nil
+			}
+		}
+		return errors.New("format " + m.Format + " not allowed")
+	}
+}
+
+// Constrain returns a Policy that rejects images violating cons (see
+// ospry.ValidateMetadata).
+func Constrain(cons ospry.Constraints) Policy {
+	return func(m *ospry.Metadata) error {
+		return ospry.ValidateMetadata(m, cons)
+	}
+}
+
+// All returns a Policy that requires all of policies to pass.
+func All(policies ...Policy) Policy {
+	return func(m *ospry.Metadata) error {
+		for _, p := range policies {
+			if err := p(m); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// A Claimer is a webhook endpoint that claims newly uploaded images.
+// For each verified image.uploaded event it fetches the image's
+// current metadata, checks it against Policy, claims the image and
+// hands the claimed metadata to Store. Other events are acknowledged
+// and ignored.
+//
+// Failures are answered with a 500 so that ospry retries the event.
+type Claimer struct {
+	Verifier *Verifier
+	// Client claims the images. If it's nil, ospry.DefaultClient is
+	// used.
+	Client *ospry.Client
+	// Policy, if set, decides which images are claimed.
+	Policy Policy
+	// Store, if set, is called with each claimed image, e.g. to
+	// record it in the application's database.
+	Store func(m *ospry.Metadata) error
+	// Rejected, if set, is called with images that fail Policy, e.g.
+	// to delete them.
+	Rejected func(m *ospry.Metadata, reason error) error
+}
+
+func (c *Claimer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e, err := c.Verifier.VerifyRequest(r)
+	switch err {
+	case nil:
+	case ErrReplayed:
+		// Already handled.
+		w.WriteHeader(http.StatusOK)
+		return
+	case ErrInvalidSignature, ErrExpired:
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if e.Type != ImageUploaded || e.Metadata == nil {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if err := c.handle(e.Metadata.ID); err != nil {
+		if f, ok := c.Verifier.Seen.(Forgetter); ok {
+			f.Forget(e.ID)
+		}
+		http.Error(w, "claiming "+e.Metadata.ID+": "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (c *Claimer) handle(id string) error {
+	client := c.Client
+	if client == nil {
+		client = ospry.DefaultClient
+	}
+	m, err := client.GetMetadata(id)
+	if err != nil {
+		return err
+	}
+	if c.Policy != nil {
+		if reason := c.Policy(m); reason != nil {
+			if c.Rejected != nil {
+				return c.Rejected(m, reason)
+			}
+			return nil
+		}
+	}
+	if !m.IsClaimed {
+		if m, err = client.Claim(id); err != nil {
+			return err
+		}
+	}
+	if c.Store != nil {
+		return c.Store(m)
+	}
+	return nil
+}
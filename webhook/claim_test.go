@@ -0,0 +1,114 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	ospry "github.com/ospry/ospry-go"
+)
+
+func TestClaimer(t *testing.T) {
+	images := map[string]*ospry.Metadata{
+		"small": {ID: "small", Format: "jpeg", Size: 100},
+		"big":   {ID: "big", Format: "jpeg", Size: 1 << 30},
+		"gif":   {ID: "gif", Format: "gif", Size: 100},
+	}
+	var claimed []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/v1/images/")
+		m := *images[id]
+		if r.Method == "PUT" {
+			claimed = append(claimed, id)
+			m.IsClaimed = true
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"metadata": &m})
+	}))
+	defer srv.Close()
+	client := ospry.New("sk-test-key")
+	client.ServerURL = srv.URL + "/v1"
+
+	var stored, rejected []string
+	failStore := false
+	c := &Claimer{
+		Verifier: &Verifier{Secret: secret, Seen: NewMemorySeenCache()},
+		Client:   client,
+		Policy:   All(MaxSize(1<<20), Formats("jpeg", "png")),
+		Store: func(m *ospry.Metadata) error {
+			if failStore {
+				return errors.New("database down")
+			}
+			stored = append(stored, m.ID)
+			return nil
+		},
+		Rejected: func(m *ospry.Metadata, reason error) error {
+			rejected = append(rejected, m.ID+": "+reason.Error())
+			return nil
+		},
+	}
+	send := func(id, typ string, body []byte) int {
+		if body == nil {
+			body, _ = json.Marshal(&Event{ID: "evt-" + id + typ, Type: typ, Metadata: &ospry.Metadata{ID: id}})
+		}
+		r := httptest.NewRequest("POST", "/hook", bytes.NewReader(body))
+		r.Header.Set(SignatureHeader, Sign(secret, body, time.Now()))
+		w := httptest.NewRecorder()
+		c.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	for _, id := range []string{"small", "big", "gif"} {
+		if code := send(id, ImageUploaded, nil); code != 200 {
+			t.Fatalf("%s: got %d, want 200", id, code)
+		}
+	}
+	if code := send("small", ImageDeleted, nil); code != 200 {
+		t.Fatalf("got %d, want 200", code)
+	}
+	if got := strings.Join(claimed, ","); got != "small" {
+		t.Fatalf("got claimed %s, want small", got)
+	}
+	if got := strings.Join(stored, ","); got != "small" {
+		t.Fatalf("got stored %s, want small", got)
+	}
+	if len(rejected) != 2 || !strings.HasPrefix(rejected[0], "big: ") || rejected[1] != "gif: format gif not allowed" {
+		t.Fatalf("got rejected %q", rejected)
+	}
+
+	// Failed events can be retried.
+	failStore = true
+	body, _ := json.Marshal(&Event{ID: "evt-retry", Type: ImageUploaded, Metadata: &ospry.Metadata{ID: "small"}})
+	if code := send("", "", body); code != 500 {
+		t.Fatalf("got %d, want 500", code)
+	}
+	failStore = false
+	if code := send("", "", body); code != 200 {
+		t.Fatalf("got %d, want 200", code)
+	}
+	if len(stored) != 2 {
+		t.Fatalf("got %d stored, want 2", len(stored))
+	}
+
+	// Bad signatures are refused.
+	r := httptest.NewRequest("POST", "/hook", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	c.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("got %d, want 401", w.Code)
+	}
+}
+
+func TestConstrain(t *testing.T) {
+	p := Constrain(ospry.Constraints{MaxSize: 10})
+	if err := p(&ospry.Metadata{Size: 10}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p(&ospry.Metadata{Size: 11}).(*ospry.ConstraintError); !ok {
+		t.Fatal("want *ospry.ConstraintError")
+	}
+}
@@ -0,0 +1,226 @@
+// Package webhook verifies and decodes the events ospry posts to
+// webhook endpoints.
+//
+// Each request carries an Ospry-Signature header of the form
+//
+//	t=<unix timestamp>,v1=<hex HMAC-SHA256 of "<timestamp>.<body>">
+//
+// keyed with the endpoint's secret. A Verifier checks the signature,
+// rejects events whose timestamp is too old (or too far in the
+// future), and rejects events it has seen before, so that captured
+// requests can't be replayed.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	ospry "github.com/ospry/ospry-go"
+)
+
+// SignatureHeader is the header holding an event's signature.
+const SignatureHeader = "Ospry-Signature"
+
+// DefaultTolerance is the default for Verifier.Tolerance.
+const DefaultTolerance = 5 * time.Minute
+
+// MaxPayloadSize is the largest request body VerifyRequest reads.
+const MaxPayloadSize = 1 << 20
+
+// Event types.
+const (
+	ImageUploaded = "image.uploaded"
+	ImageClaimed  = "image.claimed"
+	ImageDeleted  = "image.deleted"
+)
+
+// EventTypes lists the event types ospry sends.
+var EventTypes = []string{ImageUploaded, ImageClaimed, ImageDeleted}
+
+var (
+	// ErrInvalidSignature is returned for events whose signature is
+	// missing, malformed or doesn't match.
+	ErrInvalidSignature = errors.New("webhook: invalid signature")
+	// ErrExpired is returned for events whose timestamp is outside
+	// the verifier's tolerance.
+	ErrExpired = errors.New("webhook: timestamp outside tolerance")
+	// ErrReplayed is returned for events that have been seen before.
+	ErrReplayed = errors.New("webhook: event already seen")
+)
+
+// An Event is a notification about an image.
+type Event struct {
+	ID       string          `json:"id"`
+	Type     string          `json:"type"`
+	Created  time.Time       `json:"created"`
+	Metadata *ospry.Metadata `json:"metadata"`
+}
+
+// A SeenCache remembers the ids of verified events. Implementations
+// must be safe for concurrent use, and can be shared by several
+// processes (e.g. backed by Redis) to reject replays across a cluster.
+type SeenCache interface {
+	// Seen records that the event with the given id was seen, and
+	// needs to be remembered until expires. It reports whether the id
+	// had already been recorded.
+	Seen(id string, expires time.Time) bool
+}
+
+// A Forgetter is a SeenCache that can forget ids, so that events whose
+// handling failed are accepted when ospry retries them.
+type Forgetter interface {
+	Forget(id string)
+}
+
+// A MemorySeenCache is a SeenCache that lives in memory.
+type MemorySeenCache struct {
+	mu  sync.Mutex
+	ids map[string]time.Time
+}
+
+// NewMemorySeenCache creates an empty MemorySeenCache.
+func NewMemorySeenCache() *MemorySeenCache {
+	return &MemorySeenCache{ids: make(map[string]time.Time)}
+}
+
+// Seen implements SeenCache. Expired ids are dropped as new ones are
+// recorded.
+func (c *MemorySeenCache) Seen(id string, expires time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if exp, ok := c.ids[id]; ok && exp.After(now) {
+		return true
+	}
+	for k, exp := range c.ids {
+		if !exp.After(now) {
+			delete(c.ids, k)
+		}
+	}
+	c.ids[id] = expires
+	return false
+}
+
+// A Verifier verifies webhook requests.
+type Verifier struct {
+	// Secret is the endpoint's signing secret.
+	Secret string
+	// Tolerance is how far an event's timestamp may be from the
+	// current time. Zero means DefaultTolerance.
+	Tolerance time.Duration
+	// Seen, if set, is used to reject replayed events. Without it
+	// only the timestamp limits replays.
+	Seen SeenCache
+}
+
+// VerifyRequest reads a webhook request's body, up to MaxPayloadSize
+// bytes, and verifies it (see Verify).
+func (v *Verifier) VerifyRequest(r *http.Request) (*Event, error) {
+	payload, err := ioutil.ReadAll(http.MaxBytesReader(nil, r.Body, MaxPayloadSize))
+	if err != nil {
+		return nil, err
+	}
+	return v.Verify(payload, r.Header.Get(SignatureHeader))
+}
+
+// Verify checks payload against its signature header and returns the
+// event it holds. Events are checked for replays only once their
+// signature and timestamp are valid.
+func (v *Verifier) Verify(payload []byte, header string) (*Event, error) {
+	t, sigs, err := parseHeader(header)
+	if err != nil {
+		return nil, err
+	}
+	want := signature(v.Secret, payload, t)
+	ok := false
+	for _, sig := range sigs {
+		if hmac.Equal(sig, want) {
+			ok = true
+		}
+	}
+	if !ok {
+		return nil, ErrInvalidSignature
+	}
+	tolerance := v.Tolerance
+	if tolerance <= 0 {
+		tolerance = DefaultTolerance
+	}
+	if d := time.Since(t); d > tolerance || d < -tolerance {
+		return nil, ErrExpired
+	}
+	var e Event
+	if err := json.Unmarshal(payload, &e); err != nil {
+		return nil, err
+	}
+	if v.Seen != nil {
+		if e.ID == "" {
+			return nil, errors.New("webhook: event has no id")
+		}
+		if v.Seen.Seen(e.ID, t.Add(tolerance)) {
+			return nil, ErrReplayed
+		}
+	}
+	return &e, nil
+}
+
+// Sign returns the signature header for payload sent at t, as ospry
+// computes it.
+func Sign(secret string, payload []byte, t time.Time) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(signature(secret, payload, t))
+}
+
+func signature(secret string, payload []byte, t time.Time) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(strconv.FormatInt(t.Unix(), 10)))
+	h.Write([]byte("."))
+	h.Write(payload)
+	return h.Sum(nil)
+}
+
+// parseHeader returns the timestamp and v1 signatures in a signature
+// header. There may be several signatures while secrets are rotated.
+func parseHeader(header string) (time.Time, [][]byte, error) {
+	var t time.Time
+	var sigs [][]byte
+	for _, part := range strings.Split(header, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			return time.Time{}, nil, ErrInvalidSignature
+		}
+		switch kv[0] {
+		case "t":
+			sec, err := strconv.ParseInt(kv[1], 10, 64)
+			if err != nil {
+				return time.Time{}, nil, ErrInvalidSignature
+			}
+			t = time.Unix(sec, 0)
+		case "v1":
+			sig, err := hex.DecodeString(kv[1])
+			if err != nil {
+				return time.Time{}, nil, ErrInvalidSignature
+			}
+			sigs = append(sigs, sig)
+		}
+	}
+	if t.IsZero() || len(sigs) == 0 {
+		return time.Time{}, nil, ErrInvalidSignature
+	}
+	return t, sigs, nil
+}
+
+// Forget implements Forgetter.
+func (c *MemorySeenCache) Forget(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.ids, id)
+}
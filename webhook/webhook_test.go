@@ -0,0 +1,83 @@
+package webhook
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const secret = "whsec-test"
+
+func TestVerify(t *testing.T) {
+	now := time.Now()
+	payload := []byte(`{"id":"evt-1","type":"image.uploaded","metadata":{"id":"foo"}}`)
+	v := &Verifier{Secret: secret, Seen: NewMemorySeenCache()}
+
+	tests := []struct {
+		header string
+		err    error
+	}{
+		{"", ErrInvalidSignature},
+		{"t=abc,v1=00", ErrInvalidSignature},
+		{Sign("wrong", payload, now), ErrInvalidSignature},
+		{Sign(secret, payload, now.Add(-10*time.Minute)), ErrExpired},
+		{Sign(secret, payload, now.Add(10*time.Minute)), ErrExpired},
+		{Sign(secret, payload, now.Add(-time.Minute)), nil},
+		{Sign(secret, payload, now), ErrReplayed},
+	}
+	for _, test := range tests {
+		e, err := v.Verify(payload, test.header)
+		if err != test.err {
+			t.Fatalf("%q: got %v, want %v", test.header, err, test.err)
+		}
+		if err == nil && (e.ID != "evt-1" || e.Type != ImageUploaded || e.Metadata.ID != "foo") {
+			t.Fatalf("got %+v, want decoded event", e)
+		}
+	}
+
+	// Several signatures are accepted while secrets are rotated.
+	other := []byte(`{"id":"evt-2"}`)
+	sig := Sign(secret, other, now)
+	header := Sign("old", other, now) + sig[strings.Index(sig, ","):]
+	if _, err := v.Verify(other, header); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyRequest(t *testing.T) {
+	payload := []byte(`{"id":"evt-1","type":"image.claimed"}`)
+	r := httptest.NewRequest("POST", "/hook", bytes.NewReader(payload))
+	r.Header.Set(SignatureHeader, Sign(secret, payload, time.Now()))
+	v := &Verifier{Secret: secret}
+	e, err := v.VerifyRequest(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Type != ImageClaimed {
+		t.Fatalf("got %s, want %s", e.Type, ImageClaimed)
+	}
+
+	r = httptest.NewRequest("POST", "/hook", bytes.NewReader(make([]byte, MaxPayloadSize+1)))
+	if _, err := v.VerifyRequest(r); err == nil || err == ErrInvalidSignature {
+		t.Fatalf("got %v, want the body refused for its size", err)
+	}
+}
+
+func TestMemorySeenCache(t *testing.T) {
+	c := NewMemorySeenCache()
+	if c.Seen("a", time.Now().Add(time.Hour)) {
+		t.Fatal("new id reported as seen")
+	}
+	if !c.Seen("a", time.Now().Add(time.Hour)) {
+		t.Fatal("recorded id not reported as seen")
+	}
+	c.Seen("b", time.Now().Add(-time.Second))
+	if c.Seen("b", time.Now().Add(time.Hour)) {
+		t.Fatal("expired id reported as seen")
+	}
+	if len(c.ids) != 2 {
+		t.Fatalf("got %d ids, want expired ids dropped", len(c.ids))
+	}
+}